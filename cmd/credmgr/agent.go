@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh"
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+	"github.com/nzions/fdot/pkg/fdh/credmgr/agent"
+	"github.com/nzions/fdot/pkg/fdotconfig"
+)
+
+// agentStartTimeout bounds how long agent start waits for the background agent to
+// unlock the store (a FIDO2 touch or YubiKey press) and listen
+const agentStartTimeout = 2 * time.Minute
+
+// handleAgent runs the agent in the foreground (credmgr agent [socket]) or manages a
+// background one with start, stop and status
+func handleAgent(cm credmgr.CredManager, args []string) {
+	subcommand := "run"
+	if len(args) > 0 {
+		switch args[0] {
+		case "run", "start", "stop", "status":
+			subcommand, args = args[0], args[1:]
+		}
+	}
+	if len(args) > 1 {
+		usageError("agent", "unexpected argument %q", args[1])
+	}
+
+	sock, err := agent.DefaultSocketPath()
+	if storeSelected() {
+		// One agent per database: the socket of --db and --profile agents sits next to
+		// their database, whatever CREDMGR_AGENT_SOCK names
+		sock = strings.TrimSuffix(selectedStore.CredFile, filepath.Ext(selectedStore.CredFile)) + "-agent.sock"
+	}
+	if len(args) == 1 {
+		sock, err = args[0], nil
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating agent socket: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	switch subcommand {
+	case "run":
+		runAgent(cm, sock)
+	case "start":
+		startAgent(sock)
+	case "stop":
+		stopAgent(sock)
+	case "status":
+		agentStatus(sock)
+	}
+}
+
+// agentPIDFile records the pid of the agent listening on sock
+func agentPIDFile(sock string) string {
+	return sock + ".pid"
+}
+
+// agentRunning reports whether an agent answers on sock
+func agentRunning(sock string) bool {
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// agentPID returns the pid recorded for the agent on sock, or 0
+func agentPID(sock string) int {
+	data, err := os.ReadFile(agentPIDFile(sock))
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// printAgentEnv prints the variables clients need, in the form eval expects; the
+// socket paths are quoted since they may hold spaces or shell metacharacters
+func printAgentEnv(sock string, pid int) {
+	framedSock := agent.FramedSocketPath(sock)
+	fmt.Printf("%s=%s; export %s;\n", fdotconfig.CredMgrEnvVarAgentSock, shellQuote(sock), fdotconfig.CredMgrEnvVarAgentSock)
+	fmt.Printf("%s=%s; export %s;\n", fdotconfig.CredMgrEnvVarAgentFramedSock, shellQuote(framedSock), fdotconfig.CredMgrEnvVarAgentFramedSock)
+	if pid > 0 {
+		fmt.Printf("echo credmgr agent pid %d;\n", pid)
+	}
+}
+
+// runAgent serves cm on sock until interrupted
+func runAgent(cm credmgr.CredManager, sock string) {
+	dbPath, err := credFilePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating credential database: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	policyFile := filepath.Join(filepath.Dir(dbPath), "agent-policies.json")
+	policies, err := agent.LoadPolicies(policyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading agent policies: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	// Unlock now, while the user is at the terminal (FIDO2 touch, YubiKey), not on the first request
+	if _, err := cm.List(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error unlocking credential database: %v\n", err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+
+	l, err := agent.Listen(sock)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting agent: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	defer os.Remove(sock)
+
+	framedSock := agent.FramedSocketPath(sock)
+	framed, err := agent.Listen(framedSock)
+	if err != nil {
+		l.Close()
+		fmt.Fprintf(os.Stderr, "Error starting agent: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	defer os.Remove(framedSock)
+
+	if err := fdh.WritePrivateFile(agentPIDFile(sock), []byte(strconv.Itoa(os.Getpid())+"\n")); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not record the agent pid: %v\n", err)
+	}
+	defer os.Remove(agentPIDFile(sock))
+
+	srv := agent.NewServer(cm, agent.NewEnforcer(policies, agent.AskpassConfirmer{}))
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		srv.Stop()
+	}()
+	go func() {
+		if err := srv.ServeFramed(framed); err != nil {
+			fmt.Fprintf(os.Stderr, "Error serving framed protocol: %v\n", err)
+		}
+	}()
+
+	printAgentEnv(sock, 0)
+	fmt.Fprintf(os.Stderr, "credmgr agent listening on %s and %s (Ctrl-C to stop)\n", sock, framedSock)
+	if err := srv.Serve(l); err != nil {
+		fmt.Fprintf(os.Stderr, "Error serving agent: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// startAgent runs the agent in the background, like ssh-agent: once it is listening,
+// the variables for clients are printed for eval "$(credmgr agent start)". The agent
+// unlocks the store itself and may prompt on the terminal first.
+func startAgent(sock string) {
+	if agentRunning(sock) {
+		fmt.Fprintf(os.Stderr, "credmgr agent already running on %s\n", sock)
+		printAgentEnv(sock, agentPID(sock))
+		return
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating credmgr: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	cmd := exec.Command(exe, append(slices.Clone(globalArgs), "agent", "run", sock)...)
+	cmd.Stdin = os.Stdin   // unlock prompts
+	cmd.Stderr = os.Stderr // unlock prompts and errors; stdout is discarded
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting agent: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	deadline := time.After(agentStartTimeout)
+	for !agentRunning(sock) {
+		select {
+		case err := <-exited:
+			fmt.Fprintf(os.Stderr, "Error: credmgr agent exited before listening (%v)\n", err)
+			os.Exit(exitCode(err))
+		case <-deadline:
+			cmd.Process.Kill()
+			fmt.Fprintf(os.Stderr, "Error: credmgr agent did not start listening within %s\n", agentStartTimeout)
+			os.Exit(exitFailure)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	printAgentEnv(sock, cmd.Process.Pid)
+}
+
+// stopAgent terminates the agent on sock and prints the commands that unset the client
+// variables, for eval "$(credmgr agent stop)"
+func stopAgent(sock string) {
+	pid := agentPID(sock)
+	if pid == 0 {
+		if agentRunning(sock) {
+			fmt.Fprintf(os.Stderr, "Error: an agent is listening on %s but %s is missing; stop it by hand\n", sock, agentPIDFile(sock))
+		} else {
+			fmt.Fprintf(os.Stderr, "No credmgr agent running on %s\n", sock)
+		}
+		os.Exit(exitFailure)
+	}
+
+	p, err := os.FindProcess(pid)
+	if err == nil {
+		// SIGTERM lets the agent remove its sockets; Windows has no signals
+		if err = p.Signal(syscall.SIGTERM); err != nil && !errors.Is(err, os.ErrProcessDone) {
+			err = p.Kill()
+		}
+	}
+	if err != nil && !errors.Is(err, os.ErrProcessDone) {
+		fmt.Fprintf(os.Stderr, "Error stopping agent (pid %d): %v\n", pid, err)
+		os.Exit(exitCode(err))
+	}
+	for i := 0; i < 50 && agentRunning(sock); i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if agentRunning(sock) {
+		fmt.Fprintf(os.Stderr, "Error: credmgr agent (pid %d) is still listening on %s\n", pid, sock)
+		os.Exit(exitFailure)
+	}
+	os.Remove(agentPIDFile(sock))
+
+	fmt.Printf("unset %s;\n", fdotconfig.CredMgrEnvVarAgentSock)
+	fmt.Printf("unset %s;\n", fdotconfig.CredMgrEnvVarAgentFramedSock)
+	fmt.Printf("echo %s;\n", shellQuote(fmt.Sprintf("credmgr agent pid %d on %s killed", pid, sock)))
+}
+
+// agentStatus reports whether an agent serves sock, exiting 1 if none does
+func agentStatus(sock string) {
+	if !agentRunning(sock) {
+		fmt.Printf("credmgr agent not running on %s\n", sock)
+		os.Exit(exitFailure)
+	}
+	if pid := agentPID(sock); pid > 0 {
+		fmt.Printf("credmgr agent running on %s (pid %d)\n", sock, pid)
+	} else {
+		fmt.Printf("credmgr agent running on %s\n", sock)
+	}
+	fmt.Printf("  %s=%s\n", fdotconfig.CredMgrEnvVarAgentSock, sock)
+	fmt.Printf("  %s=%s\n", fdotconfig.CredMgrEnvVarAgentFramedSock, agent.FramedSocketPath(sock))
+}
@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import "os/exec"
+
+// detach is a no-op where processes already outlive their parent
+func detach(cmd *exec.Cmd) {}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+)
+
+// handleBackup snapshots the encrypted database with retention, or lists the snapshots
+func handleBackup(args []string) {
+	fs := newFlagSet("backup")
+	dir := fs.String("dir", "", "snapshot `directory` (default: backups next to the database)")
+	keep := fs.Int("keep", 0, "number of snapshots to keep (default 5)")
+	args = parseFlags(fs, args)
+	list := len(args) == 1 && args[0] == "list"
+	switch {
+	case len(args) > 0 && !list:
+		usageError("backup", "unexpected argument %q", args[0])
+	case *keep < 0:
+		usageError("backup", "--keep must be at least 1")
+	case list && *keep != 0:
+		usageError("backup", "--keep does not apply to backup list")
+	}
+
+	dbPath, err := credFilePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating credential database: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	if list {
+		backups, err := credmgr.Backups(dbPath, *dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing backups: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		if len(backups) == 0 {
+			fmt.Println("No backups found")
+			return
+		}
+		for _, b := range backups {
+			fmt.Printf("%s  %8d  %s\n", b.Time.Format("2006-01-02 15:04:05"), b.Size, b.Path)
+		}
+		return
+	}
+
+	path, err := credmgr.Backup(dbPath, *dir, *keep)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error backing up %s: %v\n", dbPath, err)
+		os.Exit(exitCode(err))
+	}
+	if *keep == 0 {
+		*keep = credmgr.DefaultBackupKeep
+	}
+	fmt.Printf("Backup written to %s (keeping the newest %d)\n", path, *keep)
+}
+
+// handleRestoreBackup replaces the database with a snapshot taken by backup, for
+// restore --backup
+func handleRestoreBackup(path string, assumeYes bool) {
+	dbPath, err := credFilePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating credential database: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	if path == "latest" {
+		backups, err := credmgr.Backups(dbPath, "")
+		if err != nil || len(backups) == 0 {
+			fmt.Fprintf(os.Stderr, "Error: no backups found in %s\n", credmgr.BackupDir(dbPath))
+			os.Exit(exitNotFound)
+		}
+		path = backups[0].Path
+	}
+
+	confirm(fmt.Sprintf("Replace %s with %s? Changes made since the backup are lost", dbPath, path), assumeYes)
+	if err := credmgr.RestoreBackup(dbPath, path); err != nil {
+		fmt.Fprintf(os.Stderr, "Error restoring %s: %v\n", path, err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+	fmt.Printf("Credential database restored from %s (the replaced version is in %s.bak)\n", path, dbPath)
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+	"github.com/nzions/fdot/pkg/fdh/credmgr/agent"
+)
+
+// Exit codes shared by every command, documented in printUsage and the README. exec
+// exits with the command's own status instead.
+const (
+	exitOK       = 0
+	exitFailure  = 1  // the operation failed or was cancelled, or a check found problems
+	exitNotFound = 2  // no such credential, trash entry or backup
+	exitDecrypt  = 3  // wrong master key or archive passphrase, or a corrupt database
+	exitExists   = 4  // the target credential already exists
+	exitDenied   = 5  // read-only store, or refused by an access policy
+	exitNoAgent  = 6  // CREDMGR_AGENT_SOCK names an agent that is not running
+	exitUsage    = 64 // bad command line (EX_USAGE from sysexits.h)
+)
+
+// exitCode returns the exit status for an operation that failed with err
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, credmgr.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, credmgr.ErrWrongKey), errors.Is(err, credmgr.ErrCorrupt), errors.Is(err, credmgr.ErrBadPassphrase):
+		return exitDecrypt
+	case errors.Is(err, credmgr.ErrAlreadyExists):
+		return exitExists
+	case errors.Is(err, credmgr.ErrReadOnly), errors.Is(err, credmgr.ErrForbidden), errors.Is(err, agent.ErrDenied):
+		return exitDenied
+	case errors.Is(err, agent.ErrAgentUnavailable):
+		return exitNoAgent
+	}
+	return exitFailure
+}
+
+// storeMode says which credential manager a command is given
+type storeMode int
+
+const (
+	storeAgent storeMode = iota // the agent named by CREDMGR_AGENT_SOCK if set, else the file
+	storeFile                   // always the credential file, for commands that work on the file itself
+	storeNone                   // none: the command finds the database itself, or needs no store
+)
+
+// command is one credmgr subcommand
+type command struct {
+	name    string
+	aliases []string
+	usage   []string // synopses, without the leading "credmgr "
+	summary string   // may span lines
+	store   storeMode
+	names   bool // the first argument is a credential name, completed by the shells
+	run     func(cm credmgr.CredManager, args []string)
+}
+
+// commands is the command table, in the order printUsage lists it. It is filled in by
+// init because the handlers refer back to it for their usage text.
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "init", usage: []string{"init"}, summary: "First-run setup: key storage, SSH credentials, config file", store: storeNone,
+			run: func(_ credmgr.CredManager, args []string) { noArgs("init", args); handleInit() }},
+		{name: "get", usage: []string{"get [--json] <name>"}, summary: "Retrieve credential (--json: name, type, username, value)", names: true, run: handleGet},
+		{name: "set", usage: []string{"set <name> [-]"}, summary: "Store credential, prompted for without echo (- reads stdin)", names: true, run: handleSet},
+		{name: "setssh", usage: []string{"setssh [--sync] <un> <pw> [site]", "setssh [--sync] --key <file> [--passphrase-prompt] <un> [site]"},
+			summary: "Store SSH credentials (global, per site, or --sync for credmgr sync),\na password or a private key login", run: handleSetSSH},
+		{name: "getssh", usage: []string{"getssh [site]"}, summary: "Get SSH credentials and whether they use a password or a key", run: handleGetSSH},
+		{name: "getbigkey", usage: []string{"getbigkey"}, summary: "Get or create big key", run: handleGetBigKey},
+		{name: "generate", aliases: []string{"gen"}, usage: []string{"generate <name> [--length N] [--charset alnum|hex|symbols] [--symbols] [--no-ambiguous]"},
+			summary: "Generate, store and print a random secret", run: handleGenerate},
+		{name: "totp", usage: []string{"totp add <name> [-]", "totp code [--watch] <name>"},
+			summary: "Store a TOTP seed (base32 or otpauth:// URI), prompted or from stdin,\nor print the current code, or a live countdown", run: handleTOTP},
+		{name: "export", usage: []string{"export [--force] <archive>"}, summary: "Write all credentials to a passphrase-encrypted archive", run: handleExport},
+		{name: "import", usage: []string{"import <archive> [--merge|--overwrite|--replace [--yes]]"},
+			summary: "Load an archive, keeping (default) or replacing existing entries", run: handleImport},
+		{name: "import-env", usage: []string{"import-env <.env> [--prefix p] [--overwrite] [--shred]"},
+			summary: "Load a plaintext KEY=value file, then shred it", run: func(cm credmgr.CredManager, args []string) { handleImportFile(cm, "env", args) }},
+		{name: "import-json", usage: []string{"import-json <file> [--prefix p] [--overwrite] [--shred]"},
+			summary: "Load a plaintext {\"name\": \"value\"} file, then shred it", run: func(cm credmgr.CredManager, args []string) { handleImportFile(cm, "json", args) }},
+		{name: "del", aliases: []string{"delete"}, usage: []string{"del <name>"}, summary: "Move credential to the trash", names: true, run: handleDelete},
+		{name: "mv", aliases: []string{"rename"}, usage: []string{"mv <old> <new>"}, summary: "Rename a credential (fails if <new> exists)", names: true, run: handleRename},
+		{name: "edit", usage: []string{"edit <name>"}, summary: "Edit a credential in $VISUAL/$EDITOR via a private temp file", names: true, run: handleEdit},
+		{name: "shell", usage: []string{"shell"}, summary: "Interactive get/set/list/del, unlocking once, with Tab completion",
+			run: func(cm credmgr.CredManager, args []string) { noArgs("shell", args); handleShell(cm) }},
+		{name: "completion", usage: []string{"completion bash|zsh|fish"}, summary: "Print a completion script (names via 'credmgr list'):\nsource <(credmgr completion bash), or zsh; credmgr completion fish | source", store: storeNone,
+			run: func(_ credmgr.CredManager, args []string) { handleCompletion(args) }},
+		{name: "restore", aliases: []string{"undelete"}, usage: []string{"restore <name>", "restore --backup <file|latest> [--yes]"},
+			summary: "Restore a deleted credential, or replace the database with a snapshot", run: handleRestore},
+		{name: "trash", usage: []string{"trash"}, summary: "List deleted credentials", run: handleTrash},
+		{name: "purge", usage: []string{"purge [--yes] [name]"}, summary: "Permanently remove one or (after confirmation) all trashed credentials", run: handlePurge},
+		{name: "deletedb", aliases: []string{"cleardb", "clear"}, usage: []string{"deletedb [--yes]"}, summary: "Delete ALL credentials (confirmation skipped with --yes or -f)", run: handleDeleteDB},
+		{name: "list", aliases: []string{"ls"}, usage: []string{"list [-l|--long] [--json] [pattern]"},
+			summary: "List credentials, optionally matching a prefix, glob or re:regex;\n--long also shows each credential's kind and size", run: handleList},
+		{name: "info", usage: []string{"info [--json] <name>"}, summary: "Show a credential's kind and size, not its value", names: true, run: handleInfo},
+		{name: "search", aliases: []string{"find"}, usage: []string{"search [-i] [--regex] <pattern>"}, summary: "List credentials whose name contains pattern", run: handleSearch},
+		{name: "verify", aliases: []string{"check"}, usage: []string{"verify"}, summary: "Check the database decrypts and decodes (exit 1 on problems)", store: storeFile, run: handleVerify},
+		{name: "backup", usage: []string{"backup [--dir path] [--keep N]", "backup list [--dir path]"},
+			summary: "Snapshot the encrypted database, keeping N (default 5), or list the snapshots", store: storeNone,
+			run: func(_ credmgr.CredManager, args []string) { handleBackup(args) }},
+		{name: "history", usage: []string{"history [--dir path] <name>"}, summary: "List the values a credential had in the snapshots", names: true, run: handleHistory},
+		{name: "rollback", usage: []string{"rollback [--dir path] <name> <version>"}, summary: "Store a value listed by history", names: true, run: handleRollback},
+		{name: "rotate-key", usage: []string{"rotate-key [--yes]"}, summary: "Re-encrypt the database under a new master key (backup kept)", store: storeNone,
+			run: func(_ credmgr.CredManager, args []string) { handleRotateKey(args) }},
+		{name: "fido2", usage: []string{"fido2 enroll|remove <label>", "fido2 list"}, summary: "Enroll, remove or list FIDO2 security keys for unlock", store: storeNone,
+			run: func(_ credmgr.CredManager, args []string) { handleFIDO2(args) }},
+		{name: "keychain", usage: []string{"keychain enroll|remove|status"}, summary: "Store, remove or check the master key in the OS keychain", store: storeNone,
+			run: func(_ credmgr.CredManager, args []string) { handleKeychain(args) }},
+		{name: "yubikey", usage: []string{"yubikey enroll <label> [slot]", "yubikey remove <label>", "yubikey list"},
+			summary: "Enroll (slot 2 by default), remove or list YubiKey challenge-response slots", store: storeNone,
+			run: func(_ credmgr.CredManager, args []string) { handleYubiKey(args) }},
+		{name: "sync", usage: []string{"sync [push|pull] [user@]<host[:port]> [remote-path]"},
+			summary: "Sync the credential file with a known_hosts host over SSH, logging in with\nthe setssh --sync credential or ssh-agent; refuses when both sides changed", store: storeFile, run: handleSync},
+		{name: "agent", usage: []string{"agent [socket]", "agent start|stop|status [socket]"},
+			summary: "Unlock once and serve credentials to local clients; start runs it in the background\nand, like stop, prints lines to eval that set CREDMGR_AGENT_SOCK, like ssh-agent",
+			store:   storeFile, run: handleAgent},
+		{name: "serve", usage: []string{"serve [--addr host:port] [--cert file --key file] [--read-only] --allow <pattern>..."},
+			summary: "Serve credentials matching the patterns over HTTPS (default\n127.0.0.1:8443, self-signed) with a generated bearer token", run: handleServe},
+		{name: "git-credential", usage: []string{"git-credential <get|store|erase>"},
+			summary: "git credential helper: credential.helper '!credmgr git-credential'", run: handleGitCredential},
+		{name: "kube-token", usage: []string{"kube-token <name>"}, summary: "Print a stored token as a kubectl exec plugin ExecCredential", run: handleKubeToken},
+		{name: "exec", usage: []string{"exec -e VAR=name [-e VAR=name]... -- <command> [args...]"}, summary: "Run a command with credentials in its environment", run: handleExec},
+		{name: "env", usage: []string{"env VAR=name [VAR=name]..."}, summary: "Print export lines, for eval \"$(credmgr env ...)\"", run: handleEnv},
+		{name: "render", usage: []string{"render <template> [output]"},
+			summary: "Render a Go template with {{ secret \"name\" }} lookups\nto stdout, or to a file readable only by you", run: handleRender},
+		{name: "version", aliases: []string{"-v", "--version"}, usage: []string{"version"}, summary: "Show version information", store: storeNone,
+			run: func(_ credmgr.CredManager, args []string) { noArgs("version", args); printVersion() }},
+		{name: "help", aliases: []string{"-h", "--help"}, usage: []string{"help [command]"}, summary: "Show this help, or a command's usage", store: storeNone,
+			run: func(_ credmgr.CredManager, args []string) { handleHelp(args) }},
+	}
+}
+
+// lookupCommand finds a command by name or alias, ignoring case
+func lookupCommand(name string) *command {
+	name = strings.ToLower(name)
+	for _, c := range commands {
+		if c.name == name || slices.Contains(c.aliases, name) {
+			return c
+		}
+	}
+	return nil
+}
+
+// mustCommand returns the named command; the handlers use it to reach their own entry
+func mustCommand(name string) *command {
+	c := lookupCommand(name)
+	if c == nil {
+		panic("credmgr: no command " + name)
+	}
+	return c
+}
+
+// isHelpFlag reports whether arg asks for help
+func isHelpFlag(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help"
+}
+
+// printHelp writes the command's usage, aliases and summary
+func (c *command) printHelp(w io.Writer) {
+	for i, u := range c.usage {
+		prefix := "Usage: "
+		if i > 0 {
+			prefix = "       "
+		}
+		fmt.Fprintf(w, "%scredmgr %s\n", prefix, u)
+	}
+	if aliases := slices.DeleteFunc(slices.Clone(c.aliases), func(a string) bool { return strings.HasPrefix(a, "-") }); len(aliases) > 0 {
+		fmt.Fprintf(w, "Aliases: %s\n", strings.Join(aliases, ", "))
+	}
+	fmt.Fprintf(w, "\n%s\n", c.summary)
+}
+
+// usageError reports a bad command line for the named command and exits with exitUsage
+func usageError(name, format string, args ...any) {
+	if format != "" {
+		fmt.Fprintf(os.Stderr, "Error: "+format+"\n", args...)
+	}
+	c := mustCommand(name)
+	for i, u := range c.usage {
+		prefix := "Usage: "
+		if i > 0 {
+			prefix = "       "
+		}
+		fmt.Fprintf(os.Stderr, "%scredmgr %s\n", prefix, u)
+	}
+	os.Exit(exitUsage)
+}
+
+// noArgs rejects arguments to a command that takes none
+func noArgs(name string, args []string) {
+	if len(args) > 0 {
+		usageError(name, "unexpected argument %q", args[0])
+	}
+}
+
+// newFlagSet returns an empty flag set for the named command. Parse it with parseFlags.
+func newFlagSet(name string) *flag.FlagSet {
+	c := mustCommand(name)
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		c.printHelp(fs.Output())
+		fmt.Fprintf(fs.Output(), "\nOptions:\n")
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args with fs and returns the positional arguments. Flags may come
+// before or after them, as in "get router --json"; everything after "--" is positional.
+// A bad flag prints the command's usage and exits with exitUsage; -h prints it and exits.
+func parseFlags(fs *flag.FlagSet, args []string) []string {
+	var positional, rest []string
+	if i := slices.Index(args, "--"); i >= 0 {
+		args, rest = args[:i], args[i+1:]
+	}
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				os.Exit(exitOK)
+			}
+			os.Exit(exitUsage)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return append(positional, rest...)
+		}
+		positional, args = append(positional, args[0]), args[1:]
+	}
+}
+
+// printUsage lists every command
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "credmgr - Simple Credential Manager CLI")
+	fmt.Fprintf(w, "Binary Version   %s\n", Version)
+	fmt.Fprintf(w, "Library Version  %s\n", credmgr.Version)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Usage:")
+	const column = 30
+	for _, c := range commands {
+		lines := strings.Split(c.summary, "\n")
+		first := "  credmgr " + c.usage[0]
+		if len(c.usage) == 1 && len(first) < column {
+			fmt.Fprintf(w, "%-*s%s\n", column, first, lines[0])
+			lines = lines[1:]
+		} else {
+			for _, u := range c.usage {
+				fmt.Fprintf(w, "  credmgr %s\n", u)
+			}
+		}
+		for _, line := range lines {
+			fmt.Fprintf(w, "%*s%s\n", column, "", line)
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Global options, before the command:")
+	fmt.Fprintln(w, "  --db <file>                 Use another credential database")
+	fmt.Fprintln(w, "  --profile <name>            Use a database from \"profiles\" in the fdot config file")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'credmgr help <command>' or 'credmgr <command> --help' for a command's usage.")
+	fmt.Fprintln(w, "Exit status: 0 success, 1 failure, 2 not found, 3 wrong key or corrupt database,")
+	fmt.Fprintln(w, "4 already exists, 5 read-only or denied, 6 agent not running, 64 bad command line.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Examples:")
+	fmt.Fprintln(w, "  credmgr set myapp-token")
+	fmt.Fprintln(w, "  vault-cli read token | credmgr set myapp-token -")
+	fmt.Fprintln(w, "  credmgr setssh john mypassword")
+	fmt.Fprintln(w, "  credmgr setssh --key ~/.ssh/id_ed25519 --passphrase-prompt john")
+	fmt.Fprintln(w, "  credmgr getssh")
+	fmt.Fprintln(w, "  credmgr getbigkey")
+	fmt.Fprintln(w, "  credmgr get myapp-token")
+	fmt.Fprintln(w, "  credmgr list 'myapp-*'")
+	fmt.Fprintln(w, "  credmgr get --json router | jq -r .username")
+	fmt.Fprintln(w, "  credmgr del myapp-token")
+	fmt.Fprintln(w, "  credmgr restore myapp-token")
+	fmt.Fprintln(w, "  credmgr exec -e API_TOKEN=myapp-token -- ./deploy.sh")
+	fmt.Fprintln(w, "  eval \"$(credmgr env API_TOKEN=myapp-token)\"")
+	fmt.Fprintln(w, "  credmgr --profile work get jira-token")
+}
+
+// handleHelp prints the command list, or one command's usage
+func handleHelp(args []string) {
+	switch len(args) {
+	case 0:
+		printUsage(os.Stdout)
+	case 1:
+		c := lookupCommand(args[0])
+		if c == nil {
+			fmt.Fprintf(os.Stderr, "Unknown command: %s\n", args[0])
+			os.Exit(exitUsage)
+		}
+		c.printHelp(os.Stdout)
+	default:
+		usageError("help", "unexpected argument %q", args[1])
+	}
+}
+
+// addYesFlag registers --yes and its short form -f, which answer a command's
+// confirmation prompt so it can run from scripts
+func addYesFlag(fs *flag.FlagSet) *bool {
+	assumeYes := fs.Bool("yes", false, "do not ask for confirmation")
+	fs.BoolVar(assumeYes, "f", false, "short for --yes")
+	return assumeYes
+}
+
+// confirm asks question unless assumeYes is set, and exits with exitFailure unless the
+// answer is yes
+func confirm(question string, assumeYes bool) {
+	if assumeYes {
+		return
+	}
+	if !yes(prompt(question+" (yes/no)", "")) {
+		fmt.Println("Operation cancelled")
+		os.Exit(exitFailure)
+	}
+}
+
+// nameArg returns the single credential name in args
+func nameArg(name string, args []string) string {
+	switch len(args) {
+	case 0:
+		usageError(name, "credential name required")
+	case 1:
+		return args[0]
+	default:
+		usageError(name, "unexpected argument %q", args[1])
+	}
+	return ""
+}
+
+// stringsFlag is a flag that may be repeated, collecting every value
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
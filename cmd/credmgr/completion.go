@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// completionCommands are the commands offered for the first word
+func completionCommands() []string {
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.name
+	}
+	return names
+}
+
+// completionNameCommands take a credential name, completed from 'credmgr list'; their
+// aliases are included since they are typed out in full
+func completionNameCommands() []string {
+	var names []string
+	for _, c := range commands {
+		if c.names {
+			names = append(append(names, c.name), c.aliases...)
+		}
+	}
+	return names
+}
+
+// handleCompletion prints a completion script for the named shell. Credential names are
+// completed by running 'credmgr list' at completion time, so they need an unlocked store
+// (CREDMGR_KEY, a key file, the keychain or the agent) and are silently skipped otherwise.
+func handleCompletion(args []string) {
+	if len(args) != 1 {
+		usageError("completion", "shell required")
+	}
+
+	commands := strings.Join(completionCommands(), " ")
+	nameCommands := strings.Join(completionNameCommands(), " ")
+	switch shell := strings.ToLower(args[0]); shell {
+	case "bash":
+		fmt.Printf(bashCompletion, commands, strings.Join(completionNameCommands(), "|"))
+	case "zsh":
+		fmt.Printf(zshCompletion, commands, nameCommands)
+	case "fish":
+		fmt.Printf(fishCompletion, commands, nameCommands)
+	default:
+		usageError("completion", "unsupported shell %q (use bash, zsh or fish)", shell)
+	}
+}
+
+const bashCompletion = `# credmgr bash completion
+_credmgr() {
+    local cur=${COMP_WORDS[COMP_CWORD]}
+    if [[ $COMP_CWORD -eq 1 ]]; then
+        COMPREPLY=($(compgen -W "%s" -- "$cur"))
+        return
+    fi
+    if [[ $COMP_CWORD -eq 2 ]]; then
+        case ${COMP_WORDS[1]} in
+            %s)
+                local IFS=$'\n'
+                COMPREPLY=($(compgen -W "$(credmgr list 2>/dev/null)" -- "$cur"))
+                ;;
+        esac
+    fi
+}
+complete -F _credmgr credmgr
+`
+
+const zshCompletion = `#compdef credmgr
+# credmgr zsh completion
+_credmgr() {
+    local -a commands namecmds names
+    commands=(%s)
+    namecmds=(%s)
+    if (( CURRENT == 2 )); then
+        compadd -a commands
+    elif (( CURRENT == 3 )) && (( ${namecmds[(Ie)$words[2]]} )); then
+        names=(${(f)"$(credmgr list 2>/dev/null)"})
+        compadd -a names
+    fi
+}
+if [[ $zsh_eval_context[-1] == loadautofunc ]]; then
+    _credmgr "$@"
+else
+    compdef _credmgr credmgr
+fi
+`
+
+const fishCompletion = `# credmgr fish completion
+complete -c credmgr -f
+complete -c credmgr -n __fish_use_subcommand -a "%s"
+complete -c credmgr -n "__fish_seen_subcommand_from %s; and test (count (commandline -opc)) -eq 2" -a "(credmgr list 2>/dev/null)"
+`
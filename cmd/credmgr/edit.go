@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/nzions/fdot/pkg/fdh"
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+)
+
+// handleEdit opens a credential in $VISUAL or $EDITOR and stores the result, for
+// multi-line secrets such as kubeconfigs and PEM keys. The value sits in a file
+// readable only by the user, in a private directory that is shredded afterwards.
+func handleEdit(cm credmgr.CredManager, args []string) {
+	name := nameArg("edit", args)
+
+	data, err := cm.Read(name)
+	switch {
+	case errors.Is(err, credmgr.ErrNotFound):
+		fmt.Fprintf(os.Stderr, "Credential '%s' does not exist yet; it is created when you save\n", name)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Error reading credential '%s': %v\n", name, err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+	defer clear(data)
+
+	edited, err := editInEditor(data)
+	defer clear(edited)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error editing credential '%s': %v\n", name, err)
+		os.Exit(exitCode(err))
+	}
+	if bytes.Equal(edited, data) {
+		fmt.Println("No changes")
+		return
+	}
+	if len(edited) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: the edited value is empty; use 'credmgr del %s' to remove the credential\n", name)
+		os.Exit(exitFailure)
+	}
+
+	if err := cm.Write(name, edited); err != nil {
+		fmt.Fprintf(os.Stderr, "Error storing credential '%s': %v\n", name, err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+	fmt.Printf("Credential '%s' stored successfully\n", name)
+}
+
+// editInEditor writes data to a private temporary file, runs the editor on it and
+// returns the saved contents. Every file left in the temporary directory (including
+// editor swap and backup files) is shredded before it returns, even when the
+// terminal is interrupted or closed.
+func editInEditor(data []byte) ([]byte, error) {
+	dir, err := os.MkdirTemp(editTempRoot(), "credmgr-edit-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer shredDir(dir)
+
+	path := filepath.Join(dir, "credential")
+	if err := fdh.WritePrivateFile(path, data); err != nil {
+		return nil, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	editor := strings.Fields(editorCommand())
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	// Catch the signals that would kill credmgr before the deferred shred runs. Ctrl-C
+	// still reaches the editor, which decides what it means; a hangup or termination
+	// discards the edit once the editor is gone.
+	signals := make(chan os.Signal, 3)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	err = cmd.Run()
+	signal.Stop(signals)
+	if err != nil {
+		return nil, fmt.Errorf("editor %s failed, nothing was stored: %w", editor[0], err)
+	}
+	for len(signals) > 0 {
+		if sig := <-signals; sig != os.Interrupt {
+			return nil, fmt.Errorf("received %v while editing, nothing was stored", sig)
+		}
+	}
+
+	edited, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the edited file: %w", err)
+	}
+	return edited, nil
+}
+
+// editorCommand returns $VISUAL, $EDITOR, or the platform's default editor. The
+// value may carry arguments, as in EDITOR="code --wait".
+func editorCommand() string {
+	for _, v := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.TrimSpace(os.Getenv(v)); editor != "" {
+			return editor
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
+
+// editTempRoot keeps the plaintext in memory where a tmpfs is available
+func editTempRoot() string {
+	if info, err := os.Stat("/dev/shm"); err == nil && info.IsDir() {
+		return "/dev/shm"
+	}
+	return ""
+}
+
+// shredDir overwrites and removes every file in dir, then dir itself
+func shredDir(dir string) {
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			shredFile(path)
+		}
+		return nil
+	})
+	os.RemoveAll(dir)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/nzions/fdot/pkg/fdh"
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+)
+
+// handleExport writes every credential to a passphrase-encrypted archive readable only by
+// the current user
+func handleExport(cm credmgr.CredManager, args []string) {
+	fs := newFlagSet("export")
+	var force bool
+	fs.BoolVar(&force, "force", false, "overwrite an existing archive")
+	fs.BoolVar(&force, "f", false, "short for --force")
+	args = parseFlags(fs, args)
+	if len(args) != 1 {
+		usageError("export", "archive path required")
+	}
+	path := args[0]
+	if _, err := os.Stat(path); err == nil && !force {
+		fmt.Fprintf(os.Stderr, "Error: %s already exists (use --force to overwrite)\n", path)
+		os.Exit(exitExists)
+	}
+
+	passphrase, err := promptHidden("Archive passphrase")
+	if err == nil && passphrase == "" {
+		err = errors.New("the passphrase must not be empty")
+	}
+	if err == nil {
+		var again string
+		if again, err = promptHidden("Repeat passphrase"); err == nil && again != passphrase {
+			err = errors.New("the passphrases do not match")
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading passphrase: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	var archive bytes.Buffer
+	if err := cm.Export(&archive, passphrase); err != nil {
+		fmt.Fprintf(os.Stderr, "Error exporting credentials: %v\n", err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+	if err := fdh.WritePrivateFile(path, archive.Bytes()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", path, err)
+		os.Exit(exitCode(err))
+	}
+
+	names, _ := cm.List()
+	fmt.Printf("Exported %d credentials to %s\n", len(names), path)
+}
+
+// handleImport loads an archive written by export. By default credentials that already
+// exist are kept; --overwrite replaces them and --replace deletes the database first.
+func handleImport(cm credmgr.CredManager, args []string) {
+	fs := newFlagSet("import")
+	policy := credmgr.MergeSkipExisting
+	fs.BoolFunc("merge", "add new credentials, keep existing ones (default)", setPolicy(&policy, credmgr.MergeSkipExisting))
+	fs.BoolFunc("overwrite", "add new credentials, replace existing ones", setPolicy(&policy, credmgr.MergeOverwrite))
+	fs.BoolFunc("replace", "delete ALL credentials, then import the archive", setPolicy(&policy, credmgr.MergeReplace))
+	assumeYes := addYesFlag(fs)
+	args = parseFlags(fs, args)
+	if len(args) != 1 {
+		usageError("import", "archive path required")
+	}
+	path := args[0]
+
+	archive, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening archive: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	defer archive.Close()
+
+	if policy == credmgr.MergeReplace {
+		confirm("This will delete ALL credentials before importing. Are you sure?", *assumeYes)
+	}
+	passphrase, err := promptHidden("Archive passphrase")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading passphrase: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	if err := cm.Import(archive, passphrase, policy); err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing %s: %v\n", path, err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+	fmt.Printf("Imported credentials from %s\n", path)
+}
+
+// setPolicy returns a flag.BoolFunc callback that selects policy p; the last flag given wins
+func setPolicy(policy *credmgr.MergePolicy, p credmgr.MergePolicy) func(string) error {
+	return func(string) error {
+		*policy = p
+		return nil
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+)
+
+// gitCredPrefix starts the names of credentials stored for git
+const gitCredPrefix = "git:"
+
+// gitRequest is the description of a credential git sends on stdin
+type gitRequest struct {
+	protocol, host, path, username, password string
+}
+
+// readGitRequest parses git's key=value lines up to a blank line or EOF
+func readGitRequest(r io.Reader) (gitRequest, error) {
+	var req gitRequest
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			break
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return req, fmt.Errorf("invalid line %q", line)
+		}
+		switch key {
+		case "protocol":
+			req.protocol = value
+		case "host":
+			req.host = value
+		case "path":
+			req.path = value
+		case "username":
+			req.username = value
+		case "password":
+			req.password = value
+		}
+		// Other attributes (capability[], wwwauth[], ...) are not used
+	}
+	return req, scanner.Err()
+}
+
+// location is the host and, with credential.useHttpPath, the path a credential is for
+func (r gitRequest) location() string {
+	if r.path != "" {
+		return r.host + "/" + r.path
+	}
+	return r.host
+}
+
+// name returns the credential name for username at the request's location,
+// git:https://alice@github.com; the username is escaped so it cannot contain '@'
+func (r gitRequest) name(username string) string {
+	return gitCredPrefix + r.protocol + "://" + url.QueryEscape(username) + "@" + r.location()
+}
+
+// lookup finds the stored name for the request: the given username's entry, or the
+// first account stored for the location when git does not know the username yet
+func (r gitRequest) lookup(cm credmgr.CredManager) (string, error) {
+	if r.username != "" {
+		return r.name(r.username), nil
+	}
+	names, err := cm.List()
+	if err != nil {
+		return "", err
+	}
+	slices.Sort(names)
+	prefix := gitCredPrefix + r.protocol + "://"
+	for _, name := range names {
+		user, location, ok := strings.Cut(strings.TrimPrefix(name, prefix), "@")
+		if ok && strings.HasPrefix(name, prefix) && user != "" && location == r.location() {
+			return name, nil
+		}
+	}
+	return "", credmgr.ErrNotFound
+}
+
+// handleGitCredential implements git's credential helper protocol:
+//
+//	git config --global credential.helper '!credmgr git-credential'
+//
+// Credentials are stored as user credentials named git:<protocol>://<username>@<host>.
+func handleGitCredential(cm credmgr.CredManager, args []string) {
+	if len(args) < 1 {
+		usageError("git-credential", "operation required")
+	}
+	op := args[0]
+
+	req, err := readGitRequest(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading git credential request: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if req.protocol == "" || req.host == "" {
+		// Nothing to match on; git then asks the next helper or the user
+		return
+	}
+
+	switch op {
+	case "get":
+		name, err := req.lookup(cm)
+		if err == nil {
+			var cred credmgr.UserCred
+			if cred, err = cm.ReadUserCred(name); err == nil {
+				fmt.Printf("username=%s\npassword=%s\n", cred.Username(), cred.Password())
+				return
+			}
+		}
+		if !errors.Is(err, credmgr.ErrNotFound) {
+			fmt.Fprintf(os.Stderr, "credmgr: %v\n", err)
+			printHint(err)
+			os.Exit(exitCode(err))
+		}
+
+	case "store":
+		if req.username == "" || req.password == "" {
+			return
+		}
+		if err := cm.WriteUserCred(req.name(req.username), credmgr.NewUnPw(req.username, req.password)); err != nil {
+			fmt.Fprintf(os.Stderr, "credmgr: %v\n", err)
+			printHint(err)
+			os.Exit(exitCode(err))
+		}
+
+	case "erase":
+		name, err := req.lookup(cm)
+		if err == nil {
+			err = cm.Delete(name)
+		}
+		if err != nil && !errors.Is(err, credmgr.ErrNotFound) {
+			fmt.Fprintf(os.Stderr, "credmgr: %v\n", err)
+			printHint(err)
+			os.Exit(exitCode(err))
+		}
+
+	default:
+		// git may add operations; unknown ones are ignored as the protocol requires
+	}
+}
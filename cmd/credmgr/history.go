@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+)
+
+// handleHistory lists the values a credential had in the database backups
+func handleHistory(cm credmgr.CredManager, args []string) {
+	args, dir := historyArgs("history", args)
+	name := nameArg("history", args)
+	dbPath, err := credFilePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating credential database: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	versions, err := credmgr.History(dbPath, dir, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading backups: %v\n", err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+	if len(versions) == 0 {
+		fmt.Printf("No backups of '%s' found in %s (see 'credmgr backup')\n", name, backupDirOrDefault(dbPath, dir))
+		return
+	}
+
+	current, _ := cm.Read(name)
+	defer clear(current)
+	for _, v := range versions {
+		note := ""
+		if current != nil {
+			if data, err := credmgr.CredentialFromBackup(dbPath, dir, name, v.ID); err == nil {
+				if bytes.Equal(data, current) {
+					note = "  (current)"
+				}
+				clear(data)
+			}
+		}
+		fmt.Printf("%s  %s  %6d bytes%s\n", v.ID, v.Backup.Time.Format("2006-01-02 15:04:05"), v.Size, note)
+	}
+}
+
+// handleRollback stores the value a credential had in one of the backups
+func handleRollback(cm credmgr.CredManager, args []string) {
+	args, dir := historyArgs("rollback", args)
+	if len(args) != 2 {
+		usageError("rollback", "credential name and version required")
+	}
+	name, id := args[0], args[1]
+	dbPath, err := credFilePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating credential database: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	data, err := credmgr.CredentialFromBackup(dbPath, dir, name, id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading version %s of '%s': %v\n", id, name, err)
+		fmt.Fprintf(os.Stderr, "Hint: 'credmgr history %s' lists the versions\n", name)
+		os.Exit(exitCode(err))
+	}
+	defer clear(data)
+
+	if err := cm.Write(name, data); err != nil {
+		fmt.Fprintf(os.Stderr, "Error storing credential '%s': %v\n", name, err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+	fmt.Printf("Credential '%s' rolled back to version %s\n", name, id)
+}
+
+// historyArgs parses the --dir flag shared by history and rollback
+func historyArgs(name string, args []string) ([]string, string) {
+	fs := newFlagSet(name)
+	dir := fs.String("dir", "", "snapshot `directory` (default: backups next to the database)")
+	args = parseFlags(fs, args)
+	return args, *dir
+}
+
+// backupDirOrDefault names the directory History searched
+func backupDirOrDefault(dbPath, dir string) string {
+	if dir == "" {
+		return credmgr.BackupDir(dbPath)
+	}
+	return dir
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+)
+
+// handleImportFile loads a plaintext .env (format "env") or JSON (format "json") file
+// into the store, keeping existing credentials unless --overwrite is given
+func handleImportFile(cm credmgr.CredManager, format string, args []string) {
+	command := "import-" + format
+	fs := newFlagSet(command)
+	prefix := fs.String("prefix", "", "prepend `prefix` to every credential name")
+	overwrite := fs.Bool("overwrite", false, "replace credentials that already exist")
+	shred := fs.Bool("shred", false, "overwrite and delete the file after a successful import")
+	args = parseFlags(fs, args)
+	if len(args) != 1 {
+		usageError(command, "file required")
+	}
+	path := args[0]
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+		os.Exit(exitCode(err))
+	}
+	var secrets map[string]string
+	if format == "env" {
+		secrets, err = parseDotEnv(data)
+	} else {
+		secrets, err = parseSecretsJSON(data)
+	}
+	clear(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing %s: %v\n", path, err)
+		os.Exit(exitCode(err))
+	}
+
+	creds := make(map[string][]byte, len(secrets))
+	skipped := 0
+	for _, key := range slices.Sorted(maps.Keys(secrets)) {
+		name := *prefix + key
+		if !*overwrite {
+			exists, err := cm.Exists(name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error checking credential '%s': %v\n", name, err)
+				printHint(err)
+				os.Exit(exitCode(err))
+			}
+			if exists {
+				fmt.Printf("Skipping '%s': already exists (use --overwrite to replace)\n", name)
+				skipped++
+				continue
+			}
+		}
+		creds[name] = []byte(secrets[key])
+	}
+	if err := cm.WriteBatch(creds); err != nil {
+		fmt.Fprintf(os.Stderr, "Error storing credentials: %v\n", err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+	for _, value := range creds {
+		clear(value)
+	}
+	fmt.Printf("Imported %d credentials from %s", len(creds), path)
+	if skipped > 0 {
+		fmt.Printf(" (%d skipped)", skipped)
+	}
+	fmt.Println()
+
+	if *shred {
+		if err := shredFile(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error shredding %s: %v\n", path, err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("Shredded %s\n", path)
+	}
+}
+
+// parseDotEnv reads KEY=value lines as written for docker compose and dotenv loaders:
+// blank lines and # comments are skipped, an "export " prefix is allowed, single quotes
+// are literal, double quotes understand \n, \t, \" and \\, and unquoted values end at
+// " #". Later assignments of a key replace earlier ones.
+func parseDotEnv(data []byte) (map[string]string, error) {
+	secrets := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !validEnvName(key) {
+			return nil, fmt.Errorf("line %d: want KEY=value", lineNo)
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.IndexByte(value[1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single quote", lineNo)
+			}
+			value = value[1 : end+1]
+		case strings.HasPrefix(value, `"`):
+			unquoted, err := unquoteDotEnv(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			value = unquoted
+		default:
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		secrets[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// unquoteDotEnv decodes a double-quoted value, ignoring anything after the closing quote
+func unquoteDotEnv(value string) (string, error) {
+	var b strings.Builder
+	for i := 1; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			i++
+			if i == len(value) {
+				return "", errors.New("unterminated double quote")
+			}
+			switch value[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(value[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", errors.New("unterminated double quote")
+}
+
+// parseSecretsJSON reads a JSON object of credential names to values. Strings are
+// stored as they are; numbers and booleans as their JSON text. null, nested objects
+// and arrays are rejected rather than stored as empty or JSON-encoded values.
+func parseSecretsJSON(data []byte) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("want a JSON object of names to values: %w", err)
+	}
+	if raw == nil {
+		return nil, errors.New("want a JSON object of names to values, got null")
+	}
+	secrets := make(map[string]string, len(raw))
+	for name, value := range raw {
+		if name == "" {
+			return nil, errors.New("empty credential name")
+		}
+		switch value[0] {
+		case '"':
+			var s string
+			if err := json.Unmarshal(value, &s); err != nil {
+				return nil, fmt.Errorf("value of %q: %w", name, err)
+			}
+			secrets[name] = s
+		case 'n', '{', '[':
+			return nil, fmt.Errorf("value of %q is not a string, number or boolean", name)
+		default:
+			// true, false or a number; json.Unmarshal has validated the text
+			secrets[name] = string(value)
+		}
+	}
+	return secrets, nil
+}
+
+// shredFile overwrites a file with random bytes, syncs it and removes it. On SSDs and
+// copy-on-write filesystems old blocks may survive; this only defeats casual recovery.
+func shredFile(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err == nil {
+		noise := make([]byte, info.Size())
+		rand.Read(noise)
+		_, err = f.WriteAt(noise, 0)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
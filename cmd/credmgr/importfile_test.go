@@ -0,0 +1,90 @@
+package main
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"plain", "A=1\nB = two \n", map[string]string{"A": "1", "B": "two"}, false},
+		{"blank lines and comments", "\n# comment\n  # indented\nA=1\n", map[string]string{"A": "1"}, false},
+		{"export prefix", "export TOKEN=abc\n", map[string]string{"TOKEN": "abc"}, false},
+		{"empty value", "A=\n", map[string]string{"A": ""}, false},
+		{"single quotes are literal", `A='x\ny #z "q"'`, map[string]string{"A": `x\ny #z "q"`}, false},
+		{"double quote escapes", `A="l1\nl2\t\"q\" \\ \x"`, map[string]string{"A": "l1\nl2\t\"q\" \\ x"}, false},
+		{"hash inside quotes", `A="p#ss #kept" # dropped`, map[string]string{"A": "p#ss #kept"}, false},
+		{"inline comment", "A=secret # rotate monthly\n", map[string]string{"A": "secret"}, false},
+		{"hash without space", "A=p#ss\n", map[string]string{"A": "p#ss"}, false},
+		{"equals in value", "URL=postgres://u:p@db/x?sslmode=require\n", map[string]string{"URL": "postgres://u:p@db/x?sslmode=require"}, false},
+		{"duplicate keys keep the last", "A=1\nA=2\n", map[string]string{"A": "2"}, false},
+		{"crlf line endings", "A=1\r\nB=2\r\n", map[string]string{"A": "1", "B": "2"}, false},
+		{"no equals", "A=1\njust some text\n", nil, true},
+		{"bad key", "1A=x\n", nil, true},
+		{"key with dash", "MY-KEY=x\n", nil, true},
+		{"unterminated single quote", "A='abc\n", nil, true},
+		{"unterminated double quote", `A="abc`, nil, true},
+		{"trailing backslash", `A="abc\`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDotEnv([]byte(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseDotEnv = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseDotEnv failed: %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("parseDotEnv = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSecretsJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"strings", `{"API_KEY": "abc", "multi": "l1\nl2"}`, map[string]string{"API_KEY": "abc", "multi": "l1\nl2"}, false},
+		{"numbers and booleans", `{"port": 5432, "ratio": 1.5e3, "on": true, "off": false}`, map[string]string{"port": "5432", "ratio": "1.5e3", "on": "true", "off": "false"}, false},
+		{"empty string", `{"a": ""}`, map[string]string{"a": ""}, false},
+		{"empty object", `{}`, map[string]string{}, false},
+		{"top-level null", `null`, nil, true},
+		{"top-level array", `["a", "b"]`, nil, true},
+		{"top-level string", `"abc"`, nil, true},
+		{"null value", `{"a": null}`, nil, true},
+		{"object value", `{"a": {"user": "u"}}`, nil, true},
+		{"array value", `{"a": ["x"]}`, nil, true},
+		{"empty name", `{"": "x"}`, nil, true},
+		{"invalid json", `{"a": "x"`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSecretsJSON([]byte(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseSecretsJSON = %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSecretsJSON failed: %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("parseSecretsJSON = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nzions/fdot/pkg/fdh"
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+	"github.com/nzions/fdot/pkg/fdotconfig"
+	"golang.org/x/term"
+)
+
+// stdin is shared by the wizard's prompts so buffered input is not lost between them
+var stdin = bufio.NewReader(os.Stdin)
+
+// handleInit walks through first-run setup: the data directory, the credential database
+// and where its master key lives, the SSH credentials used by netcrawl, and the config
+// file that ties them together for credmgr and the other fdot tools.
+func handleInit() {
+	fmt.Println("credmgr init - first-run setup")
+	fmt.Println()
+
+	cfg, err := fdotconfig.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading existing config: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	configPath, err := fdotconfig.ConfigPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating config file: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if _, err := os.Stat(configPath); err == nil {
+		fmt.Printf("A configuration already exists at %s; values shown in [brackets] are kept if you press Enter.\n\n", configPath)
+	}
+
+	// Step 1: data directory
+	dataDir := filepath.Dir(configPath)
+	if err := fdh.CreatePrivateDir(dataDir); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating data directory %s: %v\n", dataDir, err)
+		os.Exit(exitCode(err))
+	}
+	fmt.Printf("Data directory: %s\n\n", dataDir)
+
+	// Step 2: credential database
+	dbPath := cfg.CredFile
+	if dbPath == "" {
+		if dbPath, err = credmgr.DefaultFilePath(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error locating credential database: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+	}
+	dbPath = prompt("Credential database", dbPath)
+	if dbPath, err = filepath.Abs(dbPath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving %s: %v\n", dbPath, err)
+		os.Exit(exitCode(err))
+	}
+	if err := fdh.CreatePrivateDir(filepath.Dir(dbPath)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating credential directory: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	cfg.CredFile = dbPath
+
+	// Step 3: master key
+	var opts []credmgr.Option
+	if _, err := os.Stat(dbPath); err == nil {
+		fmt.Println("The credential database exists; its current key source is kept.")
+		if cfg.KeyFile != "" {
+			opts = append(opts, credmgr.WithKeyFile(cfg.KeyFile))
+		}
+	} else if errors.Is(err, fs.ErrNotExist) {
+		keyFile, err := setupMasterKey(dbPath, dataDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error setting up the master key: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		cfg.KeyFile = keyFile
+		if keyFile != "" {
+			opts = append(opts, credmgr.WithKeyFile(keyFile))
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", dbPath, err)
+		os.Exit(exitCode(err))
+	}
+
+	cm, err := credmgr.Open(dbPath, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening credential database: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	// Unlocks the database, creating it if it is new
+	if _, err := cm.List(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error unlocking credential database: %v\n", err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+	fmt.Println()
+
+	// Step 4: SSH credentials
+	if yes(prompt("Store the SSH credentials netcrawl logs in with? (y/n)", "y")) {
+		username := prompt("SSH username", "")
+		password, err := promptHidden("SSH password")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading password: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		if username != "" {
+			if err := cm.WriteUserCred(fdotconfig.SSHCredSecretName, credmgr.NewUnPw(username, password)); err != nil {
+				fmt.Fprintf(os.Stderr, "Error storing SSH credentials: %v\n", err)
+				printHint(err)
+				os.Exit(exitCode(err))
+			}
+			fmt.Printf("SSH credentials stored as '%s'\n", fdotconfig.SSHCredSecretName)
+		}
+	}
+	fmt.Println()
+
+	// Step 5: config file
+	if err := fdotconfig.SaveConfig(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing config: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	fmt.Printf("Configuration written to %s\n", configPath)
+	fmt.Println("credmgr and netcrawl now use this database without further environment variables.")
+	fmt.Println("Additional unlock methods: credmgr fido2 enroll <label>, credmgr yubikey enroll <label>")
+}
+
+// setupMasterKey creates the master key of a new database and returns the key file
+// to record in the config (empty if the key is not kept in a file)
+func setupMasterKey(dbPath, dataDir string) (string, error) {
+	fmt.Println("Where should the master key of the new database be kept?")
+	fmt.Println("  1) Key file readable only by you (default)")
+	fmt.Println("  2) OS keychain (DPAPI, macOS Keychain, Secret Service)")
+	fmt.Printf("  3) %s environment variable, managed by you\n", fdotconfig.CredMgrEnvVarKey)
+
+	switch choice := prompt("Choice", "1"); choice {
+	case "1":
+		keyFile := prompt("Key file", filepath.Join(dataDir, "credmgr.key"))
+		if _, err := os.Stat(keyFile); err == nil {
+			return "", fmt.Errorf("%s already exists; move it aside or choose another path", keyFile)
+		}
+		key, err := newMasterKeyHex()
+		if err != nil {
+			return "", err
+		}
+		if err := fdh.WritePrivateFile(keyFile, []byte(key+"\n")); err != nil {
+			return "", fmt.Errorf("failed to write key file: %w", err)
+		}
+		fmt.Printf("Master key written to %s - back it up; without it the credentials are lost.\n", keyFile)
+		return keyFile, nil
+
+	case "2":
+		if err := credmgr.EnrollKeychain(dbPath); err != nil {
+			return "", err
+		}
+		fmt.Println("Master key stored in the OS keychain.")
+		return "", nil
+
+	case "3":
+		key, err := newMasterKeyHex()
+		if err != nil {
+			return "", err
+		}
+		// Used for this run; the user adds it to their shell profile or secret manager
+		os.Setenv(fdotconfig.CredMgrEnvVarKey, key)
+		fmt.Println("Set this in every environment that opens the database, and keep a copy safe:")
+		fmt.Printf("  export %s=%s\n", fdotconfig.CredMgrEnvVarKey, key)
+		return "", nil
+
+	default:
+		return "", fmt.Errorf("invalid choice %q", choice)
+	}
+}
+
+// newMasterKeyHex generates a random master key encoded as 64 hex characters
+func newMasterKeyHex() (string, error) {
+	key, err := credmgr.GenerateSecret(credmgr.Policy{Length: 64, Charset: credmgr.CharsetHex})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate master key: %w", err)
+	}
+	return key, nil
+}
+
+// prompt asks for a value, returning def when the answer is empty
+func prompt(question, def string) string {
+	if def != "" {
+		fmt.Printf("%s [%s]: ", question, def)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+	answer, err := stdin.ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Println()
+		fmt.Fprintln(os.Stderr, "Aborted")
+		os.Exit(exitFailure)
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return def
+	}
+	return answer
+}
+
+// promptHidden reads a secret without echo when stdin is a terminal
+func promptHidden(question string) (string, error) {
+	fmt.Printf("%s: ", question)
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		answer, err := stdin.ReadString('\n')
+		if err != nil && answer == "" {
+			return "", err
+		}
+		return strings.TrimRight(answer, "\r\n"), nil
+	}
+	secret, err := term.ReadPassword(fd)
+	fmt.Println()
+	return string(secret), err
+}
+
+// yes reports whether answer is affirmative
+func yes(answer string) bool {
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+)
+
+// kubeAPIVersion is the ExecCredential version answered when kubectl does not say
+const kubeAPIVersion = "client.authentication.k8s.io/v1"
+
+// execCredential is the client.authentication.k8s.io ExecCredential object
+type execCredential struct {
+	APIVersion string               `json:"apiVersion"`
+	Kind       string               `json:"kind"`
+	Status     execCredentialStatus `json:"status"`
+}
+
+type execCredentialStatus struct {
+	Token string `json:"token"`
+}
+
+// execAPIVersion returns the ExecCredential version kubectl asked for in
+// KUBERNETES_EXEC_INFO, so v1beta1 kubeconfigs keep working
+func execAPIVersion() string {
+	var info struct {
+		APIVersion string `json:"apiVersion"`
+	}
+	if err := json.Unmarshal([]byte(os.Getenv("KUBERNETES_EXEC_INFO")), &info); err == nil && info.APIVersion != "" {
+		return info.APIVersion
+	}
+	return kubeAPIVersion
+}
+
+// handleKubeToken prints a stored token as an ExecCredential, for use as a kubeconfig
+// exec credential plugin
+func handleKubeToken(cm credmgr.CredManager, args []string) {
+	name := nameArg("kube-token", args)
+
+	token, err := cm.ReadKey(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error retrieving credential '%s': %v\n", name, err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+
+	out, err := json.Marshal(execCredential{
+		APIVersion: execAPIVersion(),
+		Kind:       "ExecCredential",
+		Status:     execCredentialStatus{Token: strings.TrimSpace(token)},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding ExecCredential: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	fmt.Println(string(out))
+}
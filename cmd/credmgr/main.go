@@ -1,157 +1,459 @@
 // Package main implements a simple credential manager CLI tool.
-// Usage:
+// Usage (--db <file> or --profile <name> before the command selects another database):
 //
+//	credmgr init                - Interactive first-run setup
 //	credmgr get <name>          - Retrieve credential
-//	credmgr set <name> <data>   - Store credential
-//	credmgr del <name>          - Delete credential
+//	credmgr set <name> [-]      - Store credential (prompted for, or read from stdin)
+//	credmgr del <name>          - Move credential to the trash
+//	credmgr mv <old> <new>      - Rename a credential
+//	credmgr edit <name>         - Edit a credential in $EDITOR
+//	credmgr restore <name>      - Restore credential from the trash
+//	credmgr purge [name]        - Permanently remove trashed credentials
 //	credmgr deletedb            - Delete entire credential database
+//	credmgr verify              - Check the credential database for corruption
+//	credmgr fido2 <subcommand>  - Manage FIDO2 security key unlock
+//	credmgr keychain <subcommand> - Manage OS keychain unlock
+//	credmgr yubikey <subcommand>  - Manage YubiKey challenge-response unlock
+//	credmgr sync [user@]<host>  - Sync credential file with another host over SSH
+//	credmgr agent [socket]      - Serve the unlocked store to local clients
+//	credmgr agent start|stop|status [socket] - Manage a background agent
+//	credmgr serve --allow <pattern>... - Serve allowed credentials over HTTPS
+//	credmgr git-credential <op> - git credential helper (get/store/erase)
+//	credmgr kube-token <name>   - Print a token as a kubectl ExecCredential
+//	credmgr exec -e VAR=name... -- <cmd> - Run a command with secrets in its environment
+//	credmgr env VAR=name...     - Print export lines for secrets
+//	credmgr render <template> [output] - Render a template with {{ secret "name" }} lookups
+//	credmgr generate <name> [options] - Generate and store a random secret
+//	credmgr totp add|code <name> - Store TOTP seeds and print their codes
+//	credmgr rotate-key          - Re-encrypt the database under a new master key
+//	credmgr backup [list]       - Snapshot the encrypted database with retention
+//	credmgr history|rollback <name> - List or restore a credential's values in the backups
+//	credmgr export <archive>    - Write all credentials to a passphrase-encrypted archive
+//	credmgr import <archive>    - Load credentials from an exported archive
+//	credmgr import-env|import-json <file> - Load a plaintext .env or JSON secrets file
+//	credmgr shell               - Interactive get/set/list/del with one unlock
+//	credmgr completion <shell>  - Print a bash, zsh or fish completion script
+//	credmgr search <pattern>    - List credentials whose name contains pattern
+//	credmgr info <name>         - Show a credential's kind and size
+//	credmgr help [command]      - List the commands, or show one command's usage
 package main
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
 
+	"github.com/nzions/fdot/pkg/fdh"
 	"github.com/nzions/fdot/pkg/fdh/credmgr"
+	"github.com/nzions/fdot/pkg/fdh/credmgr/agent"
+	"github.com/nzions/fdot/pkg/fdh/credsync"
+	"github.com/nzions/fdot/pkg/fdotconfig"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
 )
 
-const Version = "1.1.0"
+const Version = "1.43.0"
 
 func main() {
+	if err := parseGlobalFlags(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
 	if len(os.Args) < 2 {
-		printUsage()
-		os.Exit(1)
-	}
-
-	// Create credential manager instance
-	cm, err := credmgr.Default()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating credential manager: %v\n", err)
-		os.Exit(1)
-	}
-
-	command := strings.ToLower(os.Args[1])
-
-	switch command {
-	case "get":
-		handleGet(cm)
-	case "set":
-		handleSet(cm)
-	case "setssh":
-		handleSetSSH(cm)
-	case "getssh":
-		handleGetSSH(cm)
-	case "getbigkey":
-		handleGetBigKey(cm)
-	case "del", "delete":
-		handleDelete(cm)
-	case "deletedb", "cleardb", "clear":
-		handleDeleteDB(cm)
-	case "list", "ls":
-		handleList(cm)
-	case "version", "-v", "--version":
-		printVersion()
-	case "help", "-h", "--help":
-		printUsage()
-	default:
-		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
-		printUsage()
-		os.Exit(1)
-	}
-}
-
-func printUsage() {
-	fmt.Println("credmgr - Simple Credential Manager CLI")
-	fmt.Printf("Binary Version   %s\n", Version)
-	fmt.Printf("Library Version  %s\n", credmgr.Version)
-	fmt.Println()
-	fmt.Println("Usage:")
-	fmt.Println("  credmgr get <name>          Retrieve credential")
-	fmt.Println("  credmgr set <name> <data>   Store credential")
-	fmt.Println("  credmgr setssh <un> <pw>    Store SSH credentials")
-	fmt.Println("  credmgr getssh              Get SSH credentials")
-	fmt.Println("  credmgr getbigkey           Get or create big key")
-	fmt.Println("  credmgr del <name>          Delete credential")
-	fmt.Println("  credmgr deletedb            Delete ALL credentials (with confirmation)")
-	fmt.Println("  credmgr list                List all credentials")
-	fmt.Println("  credmgr version             Show version information")
-	fmt.Println()
-	fmt.Println("Examples:")
-	fmt.Println("  credmgr set myapp-token secret123")
-	fmt.Println("  credmgr setssh john mypassword")
-	fmt.Println("  credmgr getssh")
-	fmt.Println("  credmgr getbigkey")
-	fmt.Println("  credmgr get myapp-token")
-	fmt.Println("  credmgr del myapp-token")
+		printUsage(os.Stderr)
+		os.Exit(exitUsage)
+	}
+
+	cmd := lookupCommand(os.Args[1])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", os.Args[1])
+		fmt.Fprintf(os.Stderr, "Run 'credmgr help' for the list of commands\n")
+		os.Exit(exitUsage)
+	}
+	args := os.Args[2:]
+	if len(args) > 0 && isHelpFlag(args[0]) {
+		cmd.printHelp(os.Stdout)
+		return
+	}
+
+	var cm credmgr.CredManager
+	if cmd.store != storeNone {
+		var err error
+		if cm, err = openCredManager(cmd.store); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating credential manager: %v\n", err)
+			printHint(err)
+			os.Exit(exitCode(err))
+		}
+	}
+	cmd.run(cm, args)
+}
+
+// openCredManager uses the running agent when CREDMGR_AGENT_SOCK is set, so no master
+// key is needed; commands that work on the credential file itself (storeFile) always
+// open it directly
+func openCredManager(mode storeMode) (credmgr.CredManager, error) {
+	sock := os.Getenv(fdotconfig.CredMgrEnvVarAgentSock)
+	if mode == storeFile {
+		sock = ""
+	}
+	// The agent serves the default database, not the one named by --db or --profile
+	if sock != "" && !storeSelected() {
+		return agent.Dial(sock)
+	}
+
+	if storeSelected() {
+		return credmgr.New(selectedStore.CredFile)
+	}
+	cfg, err := fdotconfig.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.CredFile != "" {
+		return credmgr.New(cfg.CredFile)
+	}
+	return credmgr.Default()
+}
+
+// credFilePath returns the credential database selected with --db or --profile, the
+// one chosen by credmgr init, or the default file
+func credFilePath() (string, error) {
+	if storeSelected() {
+		return selectedStore.CredFile, nil
+	}
+	cfg, err := fdotconfig.LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	if cfg.CredFile != "" {
+		return cfg.CredFile, nil
+	}
+	return credmgr.DefaultFilePath()
+}
+
+// printHint prints guidance for errors the user can fix
+func printHint(err error) {
+	switch {
+	case errors.Is(err, credmgr.ErrWrongKey):
+		fmt.Fprintf(os.Stderr, "Hint: the credential file was encrypted with a different key. Check that %s (or the file\n", fdotconfig.CredMgrEnvVarKey)
+		fmt.Fprintf(os.Stderr, "      named by %s) holds the key used when it was created, or use the enrolled FIDO2 key,\n", fdotconfig.CredMgrEnvVarKeyFile)
+		fmt.Fprintf(os.Stderr, "      or move the file aside to start over.\n")
+	case errors.Is(err, agent.ErrAgentUnavailable):
+		fmt.Fprintf(os.Stderr, "Hint: %s names an agent that is not running. Start one with 'eval \"$(credmgr agent start)\"'\n", fdotconfig.CredMgrEnvVarAgentSock)
+		fmt.Fprintf(os.Stderr, "      or unset %s to open the credential file directly.\n", fdotconfig.CredMgrEnvVarAgentSock)
+	case errors.Is(err, credmgr.ErrCorrupt):
+		fmt.Fprintf(os.Stderr, "Hint: the credential file is damaged and its backup is unusable. Restore it from another host\n")
+		fmt.Fprintf(os.Stderr, "      (credmgr sync pull) or an export, or run 'credmgr deletedb' to start over.\n")
+	case errors.Is(err, credsync.ErrConflict):
+		fmt.Fprintf(os.Stderr, "Hint: sync copies the whole file, so one side's changes would be lost. Pick the copy to\n")
+		fmt.Fprintf(os.Stderr, "      keep with 'credmgr sync push' or 'credmgr sync pull', after exporting the other side's\n")
+		fmt.Fprintf(os.Stderr, "      new entries with 'credmgr export' so they can be imported again.\n")
+	}
 }
 
 func printVersion() {
 	fmt.Println(Version)
 }
 
-func handleGet(cm credmgr.CredManager) {
-	if len(os.Args) < 3 {
-		fmt.Fprintf(os.Stderr, "Error: credential name required\n")
-		fmt.Fprintf(os.Stderr, "Usage: credmgr get <name>\n")
-		os.Exit(1)
-	}
+func handleGet(cm credmgr.CredManager, args []string) {
+	fs := newFlagSet("get")
+	asJSON := fs.Bool("json", false, "print name, type, username and value as JSON")
+	args = parseFlags(fs, args)
+	name := nameArg("get", args)
 
-	name := os.Args[2]
+	if *asJSON {
+		data, err := cm.Read(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading credential '%s': %v\n", name, err)
+			printHint(err)
+			os.Exit(exitCode(err))
+		}
+		printJSON(newCredentialJSON(name, data))
+		clear(data)
+		return
+	}
 
 	data, err := cm.ReadKey(name)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading credential '%s': %v\n", name, err)
-		os.Exit(1)
+		printHint(err)
+		os.Exit(exitCode(err))
 	}
 
 	fmt.Print(data) // No newline to make it easier to pipe/use in scripts
 }
 
-func handleSet(cm credmgr.CredManager) {
-	if len(os.Args) < 4 {
-		fmt.Fprintf(os.Stderr, "Error: credential name and data required\n")
-		fmt.Fprintf(os.Stderr, "Usage: credmgr set <name> <data>\n")
-		os.Exit(1)
+// credentialJSON is the --json form of a credential
+type credentialJSON struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`               // "userpass" or "raw"
+	Username string `json:"username,omitempty"` // userpass only
+	Value    string `json:"value"`              // the password for userpass entries
+	Encoding string `json:"encoding,omitempty"` // "base64" when the value is not UTF-8
+}
+
+// newCredentialJSON describes a stored value. Only typed username/password records
+// are reported as userpass: a legacy "user:pass" value cannot be told apart from a
+// token containing a colon, so it is shown raw.
+func newCredentialJSON(name string, data []byte) credentialJSON {
+	var rec struct {
+		Type     string `json:"type"`
+		Username string `json:"username"`
+		Password []byte `json:"password"`
+	}
+	if bytes.HasPrefix(data, []byte("{")) && json.Unmarshal(data, &rec) == nil && rec.Type == "userpass" {
+		defer clear(rec.Password)
+		c := credentialJSON{Name: name, Type: "userpass", Username: rec.Username}
+		c.Value, c.Encoding = encodeValue(rec.Password)
+		return c
+	}
+	c := credentialJSON{Name: name, Type: "raw"}
+	c.Value, c.Encoding = encodeValue(data)
+	return c
+}
+
+// encodeValue returns data as a JSON-safe string and its encoding ("" for plain text)
+func encodeValue(data []byte) (string, string) {
+	if utf8.Valid(data) {
+		return string(data), ""
 	}
+	return base64.StdEncoding.EncodeToString(data), "base64"
+}
 
-	name := os.Args[2]
-	// Join all remaining args as the data (allows spaces in data)
-	data := strings.Join(os.Args[3:], " ")
+// printJSON writes v to stdout as indented JSON
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing JSON: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+}
 
-	err := cm.WriteKey(name, data)
+// handleSet takes no flags: everything after the name is the secret
+func handleSet(cm credmgr.CredManager, args []string) {
+	if len(args) < 1 {
+		usageError("set", "credential name required")
+	}
+
+	name := args[0]
+	data, err := readSecretArg(name, args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading secret: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	defer clear(data)
+
+	err = cm.Write(name, data)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error storing credential '%s': %v\n", name, err)
-		os.Exit(1)
+		printHint(err)
+		os.Exit(exitCode(err))
 	}
 
 	fmt.Printf("Credential '%s' stored successfully\n", name)
 }
 
-func handleDelete(cm credmgr.CredManager) {
-	if len(os.Args) < 3 {
-		fmt.Fprintf(os.Stderr, "Error: credential name required\n")
-		fmt.Fprintf(os.Stderr, "Usage: credmgr del <name>\n")
-		os.Exit(1)
+// readSecretArg returns the secret for set: read from stdin for "-", prompted for
+// without echo when no data is given, or else the joined arguments (which are
+// visible in shell history and ps, so a warning is printed)
+func readSecretArg(name string, args []string) ([]byte, error) {
+	switch {
+	case len(args) == 1 && args[0] == "-":
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			clear(data)
+			return nil, err
+		}
+		// Drop the newline echo or a here-string adds, but keep any other whitespace
+		data = bytes.TrimSuffix(data, []byte("\n"))
+		return bytes.TrimSuffix(data, []byte("\r")), nil
+
+	case len(args) == 0:
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return nil, fmt.Errorf("no data given and stdin is not a terminal (use 'credmgr set %s -' to read stdin)", name)
+		}
+		secret, err := promptHidden(fmt.Sprintf("Secret for '%s'", name))
+		if err != nil {
+			return nil, err
+		}
+		again, err := promptHidden("Repeat")
+		if err != nil {
+			return nil, err
+		}
+		if secret != again {
+			return nil, errors.New("the secrets do not match")
+		}
+		if secret == "" {
+			return nil, errors.New("empty secret")
+		}
+		return []byte(secret), nil
+
+	default:
+		fmt.Fprintf(os.Stderr, "Warning: secrets given as arguments are visible in shell history and ps;\n")
+		fmt.Fprintf(os.Stderr, "         use 'credmgr set %s' to be prompted, or 'credmgr set %s -' to read stdin\n", name, name)
+		// Join all remaining args as the data (allows spaces in data)
+		return []byte(strings.Join(args, " ")), nil
 	}
+}
 
-	name := os.Args[2]
+func handleDelete(cm credmgr.CredManager, args []string) {
+	name := nameArg("del", args)
 
 	err := cm.Delete(name)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error deleting credential '%s': %v\n", name, err)
-		os.Exit(1)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+
+	fmt.Printf("Credential '%s' moved to trash (restore with: credmgr restore %s)\n", name, name)
+}
+
+// handleRename moves a credential to a new name without its value leaving the store
+func handleRename(cm credmgr.CredManager, args []string) {
+	if len(args) != 2 {
+		usageError("mv", "")
+	}
+
+	oldName, newName := args[0], args[1]
+	if err := cm.Rename(oldName, newName); err != nil {
+		fmt.Fprintf(os.Stderr, "Error renaming credential '%s': %v\n", oldName, err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+
+	fmt.Printf("Credential '%s' renamed to '%s'\n", oldName, newName)
+}
+
+// handleRestore restores a trashed credential, or with --backup the whole database
+func handleRestore(cm credmgr.CredManager, args []string) {
+	fs := newFlagSet("restore")
+	backup := fs.String("backup", "", "replace the database with this snapshot file, or the `latest` one")
+	assumeYes := addYesFlag(fs)
+	args = parseFlags(fs, args)
+	if *backup != "" {
+		if len(args) != 0 {
+			usageError("restore", "--backup takes no credential name")
+		}
+		handleRestoreBackup(*backup, *assumeYes)
+		return
+	}
+	name := nameArg("restore", args)
+
+	if err := cm.Restore(name); err != nil {
+		fmt.Fprintf(os.Stderr, "Error restoring credential '%s': %v\n", name, err)
+		printHint(err)
+		os.Exit(exitCode(err))
 	}
 
-	fmt.Printf("Credential '%s' deleted successfully\n", name)
+	fmt.Printf("Credential '%s' restored successfully\n", name)
 }
 
-func handleList(cm credmgr.CredManager) {
-	names, err := cm.List()
+func handleTrash(cm credmgr.CredManager, args []string) {
+	noArgs("trash", args)
+	entries, err := cm.ListTrash()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing trash: %v\n", err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("Trash is empty")
+		return
+	}
+
+	for _, e := range entries {
+		expires := e.DeletedAt.Add(credmgr.TrashRetention)
+		fmt.Printf("%s\tdeleted %s\tpurged after %s\n",
+			e.Name, e.DeletedAt.Local().Format(time.DateTime), expires.Local().Format(time.DateOnly))
+	}
+}
+
+func handlePurge(cm credmgr.CredManager, args []string) {
+	fs := newFlagSet("purge")
+	assumeYes := addYesFlag(fs)
+	args = parseFlags(fs, args)
+	if len(args) > 1 {
+		usageError("purge", "unexpected argument %q", args[1])
+	}
+	if len(args) == 1 {
+		name := args[0]
+		if err := cm.Purge(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error purging credential '%s': %v\n", name, err)
+			printHint(err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("Credential '%s' permanently deleted\n", name)
+		return
+	}
+
+	// Confirm since this cannot be undone
+	confirm("This will permanently delete ALL credentials in the trash. Are you sure?", *assumeYes)
+
+	if err := cm.Purge(""); err != nil {
+		fmt.Fprintf(os.Stderr, "Error emptying trash: %v\n", err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+
+	fmt.Println("Trash emptied successfully")
+}
+
+func handleList(cm credmgr.CredManager, args []string) {
+	fs := newFlagSet("list")
+	asJSON := fs.Bool("json", false, "print JSON")
+	var long bool
+	fs.BoolVar(&long, "long", false, "also show each credential's kind and size (reads every value)")
+	fs.BoolVar(&long, "l", false, "short for --long")
+	args = parseFlags(fs, args)
+	if len(args) > 1 {
+		usageError("list", "unexpected argument %q", args[1])
+	}
+
+	var names []string
+	var err error
+	if len(args) > 0 {
+		names, err = cm.ListFiltered(args[0])
+	} else {
+		names, err = cm.List()
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error listing credentials: %v\n", err)
-		os.Exit(1)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+
+	if long {
+		listLong(cm, names, *asJSON)
+		return
+	}
+
+	// Listing never reads values, so the JSON form carries names only
+	if *asJSON {
+		entries := make([]struct {
+			Name string `json:"name"`
+		}, len(names))
+		for i, name := range names {
+			entries[i].Name = name
+		}
+		printJSON(entries)
+		return
 	}
 
 	if len(names) == 0 {
@@ -164,79 +466,667 @@ func handleList(cm credmgr.CredManager) {
 	}
 }
 
-func handleDeleteDB(cm credmgr.CredManager) {
-	// Prompt for confirmation since this is destructive
-	fmt.Print("This will delete ALL credentials from the database. Are you sure? (yes/no): ")
+// listLong prints each credential's kind and size, in name order. Unlike a plain
+// listing it reads every value, so it unlocks the store.
+func listLong(cm credmgr.CredManager, names []string, asJSON bool) {
+	infos := make([]credmgr.Info, 0, len(names))
+	for _, name := range names {
+		info, err := credmgr.Describe(cm, name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading credential '%s': %v\n", name, err)
+			printHint(err)
+			os.Exit(exitCode(err))
+		}
+		infos = append(infos, info)
+	}
+	if asJSON {
+		printJSON(infos)
+		return
+	}
+	if len(infos) == 0 {
+		fmt.Println("No credentials found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tSIZE\tNAME")
+	for _, info := range infos {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", info.Kind, info.Size, info.Name)
+	}
+	w.Flush()
+}
+
+// handleInfo prints what a credential holds, never its value
+func handleInfo(cm credmgr.CredManager, args []string) {
+	fs := newFlagSet("info")
+	asJSON := fs.Bool("json", false, "print JSON")
+	args = parseFlags(fs, args)
+	name := nameArg("info", args)
+	info, err := credmgr.Describe(cm, name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading credential '%s': %v\n", name, err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+	if *asJSON {
+		printJSON(info)
+		return
+	}
+	fmt.Printf("Name:     %s\n", info.Name)
+	fmt.Printf("Kind:     %s\n", info.Kind)
+	if info.Username != "" {
+		fmt.Printf("Username: %s\n", info.Username)
+	}
+	fmt.Printf("Size:     %d bytes\n", info.Size)
+}
+
+// handleSearch lists the names containing a substring, or matching a regular expression
+// with --regex. Only names are searched; values are never read.
+func handleSearch(cm credmgr.CredManager, args []string) {
+	fs := newFlagSet("search")
+	ignoreCase := fs.Bool("i", false, "ignore case")
+	isRegexp := fs.Bool("regex", false, "treat pattern as a regular expression")
+	args = parseFlags(fs, args)
+	if len(args) != 1 {
+		usageError("search", "pattern required")
+	}
+
+	expr := args[0]
+	if !*isRegexp {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if *ignoreCase {
+		expr = "(?i)" + expr
+	}
+	names, err := cm.ListFiltered("re:" + expr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error searching credentials: %v\n", err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+	if len(names) == 0 {
+		fmt.Println("No credentials found")
+		return
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+}
 
-	var response string
-	fmt.Scanln(&response)
+func handleVerify(cm credmgr.CredManager, args []string) {
+	noArgs("verify", args)
+	report, err := cm.Verify()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error verifying %s: %v\n", report.Backend, err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
 
-	response = strings.ToLower(strings.TrimSpace(response))
-	if response != "yes" && response != "y" {
-		fmt.Println("Operation cancelled")
+	fmt.Printf("Database: %s\n", report.Backend)
+	if report.Format > 0 || report.Cipher != 0 {
+		fmt.Printf("Format:   %d\n", report.Format)
+		fmt.Printf("Cipher:   %v\n", report.Cipher)
+		fmt.Printf("Key:      decrypts the database\n")
+	}
+	fmt.Printf("Entries:  %d (%d in trash)\n", report.Entries, report.Trashed)
+	if report.OK() {
+		fmt.Println("No problems found")
 		return
 	}
+	for _, a := range report.Anomalies {
+		fmt.Printf("Warning: %s\n", a)
+	}
+	os.Exit(exitFailure)
+}
+
+func handleDeleteDB(cm credmgr.CredManager, args []string) {
+	fs := newFlagSet("deletedb")
+	assumeYes := addYesFlag(fs)
+	noArgs("deletedb", parseFlags(fs, args))
+
+	// Confirm since this is destructive
+	confirm("This will delete ALL credentials from the database. Are you sure?", *assumeYes)
 
 	err := cm.DeleteDB()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error deleting credential database: %v\n", err)
-		os.Exit(1)
+		printHint(err)
+		os.Exit(exitCode(err))
 	}
 
 	fmt.Println("Credential database deleted successfully")
 }
 
-func handleSetSSH(cm credmgr.CredManager) {
-	if len(os.Args) < 4 {
-		fmt.Fprintf(os.Stderr, "Error: username and password required\n")
-		fmt.Fprintf(os.Stderr, "Usage: credmgr setssh <username> <password>\n")
-		os.Exit(1)
+// handleSetSSH stores the SSH login netcrawl uses: a username and password, or with
+// --key a private key file and, with --passphrase-prompt, the key's passphrase
+func handleSetSSH(cm credmgr.CredManager, args []string) {
+	fs := newFlagSet("setssh")
+	keyFile := fs.String("key", "", "log in with this private key `file` instead of a password")
+	askPassphrase := fs.Bool("passphrase-prompt", false, "prompt for the key's passphrase")
+	forSync := fs.Bool("sync", false, "store the login credmgr sync uses instead of a device credential")
+	args = parseFlags(fs, args)
+
+	var cred credmgr.UserCred
+	var site string
+	if *keyFile != "" {
+		if len(args) < 1 || len(args) > 2 {
+			usageError("setssh", "--key takes a username and an optional site")
+		}
+		if len(args) == 2 {
+			site = args[1]
+		}
+		key, err := readSSHKey(args[0], *keyFile, *askPassphrase)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading SSH key: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		cred = key
+	} else {
+		if *askPassphrase {
+			usageError("setssh", "--passphrase-prompt requires --key")
+		}
+		if len(args) < 2 || len(args) > 3 {
+			usageError("setssh", "username and password required")
+		}
+		if len(args) == 3 {
+			site = args[2]
+		}
+		cred = credmgr.NewUnPw(args[0], args[1])
 	}
+	defer cred.Wipe()
 
-	username := os.Args[2]
-	password := os.Args[3]
+	// An optional site stores a site-specific credential that netcrawl -site tries first
+	name := "fdh-user-ssh-creds"
+	if site != "" {
+		name += "-" + site
+	}
+	if *forSync {
+		if site != "" {
+			usageError("setssh", "--sync takes no site")
+		}
+		name = fdotconfig.SyncCredSecretName
+	}
 
-	// Store SSH credentials directly using credmgr
-	cred := credmgr.NewUnPw(username, password)
-	err := cm.WriteUserCred("fdh-user-ssh-creds", cred)
-	if err != nil {
+	if err := cm.WriteUserCred(name, cred); err != nil {
 		fmt.Fprintf(os.Stderr, "Error storing SSH credentials: %v\n", err)
-		os.Exit(1)
+		printHint(err)
+		os.Exit(exitCode(err))
 	}
 
-	fmt.Printf("SSH credentials for '%s' stored successfully\n", username)
+	fmt.Printf("SSH credentials for '%s' stored successfully as '%s'\n", cred.Username(), name)
 }
 
-func handleGetBigKey(cm credmgr.CredManager) {
+// readSSHKey loads a private key file for setssh --key. The key is parsed, with the
+// passphrase when asked for one, so a wrong passphrase is caught before it is stored.
+func readSSHKey(username, path string, askPassphrase bool) (credmgr.SSHKeyCred, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer clear(data)
+
+	var passphrase []byte
+	defer func() { clear(passphrase) }()
+	if askPassphrase {
+		answer, err := promptHidden("Passphrase for " + path)
+		if err != nil {
+			return nil, err
+		}
+		passphrase = []byte(answer)
+		_, err = ssh.ParsePrivateKeyWithPassphrase(data, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	} else if _, err := ssh.ParsePrivateKey(data); err != nil {
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			return nil, fmt.Errorf("%s is encrypted; add --passphrase-prompt", path)
+		}
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return credmgr.NewSSHKey(username, data, passphrase)
+}
+
+func handleGetBigKey(cm credmgr.CredManager, args []string) {
+	noArgs("getbigkey", args)
 	// Try to read existing big key
 	bigKey, err := cm.ReadKey("fdh-user-bigkey")
 	if err == nil {
 		fmt.Print(bigKey)
 		return
 	}
+	if !errors.Is(err, credmgr.ErrNotFound) {
+		fmt.Fprintf(os.Stderr, "Error reading big key: %v\n", err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
 
 	// Create new big key if it doesn't exist
-	randomBytes := make([]byte, 128)
-	if _, err := rand.Read(randomBytes); err != nil {
+	bigKey, err = credmgr.GenerateSecret(credmgr.Policy{Length: fdotconfig.BigKeyLength, Charset: credmgr.CharsetHex})
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error generating big key: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
-
-	bigKey = hex.EncodeToString(randomBytes)
-	if err := cm.WriteKey("fdh-user-bigkey", bigKey); err != nil {
+	err = cm.WriteIfNotExists("fdh-user-bigkey", []byte(bigKey))
+	if errors.Is(err, credmgr.ErrAlreadyExists) {
+		// Created by another process since the read
+		bigKey, err = cm.ReadKey("fdh-user-bigkey")
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error storing big key: %v\n", err)
-		os.Exit(1)
+		printHint(err)
+		os.Exit(exitCode(err))
 	}
 
 	fmt.Print(bigKey) // No newline to make it easier to pipe/use in scripts
 }
 
-func handleGetSSH(cm credmgr.CredManager) {
-	cred, err := cm.ReadUserCred("fdh-user-ssh-creds")
+// handleGetSSH prints the SSH login netcrawl uses and whether it is a password or a
+// key; private keys are never printed
+func handleGetSSH(cm credmgr.CredManager, args []string) {
+	if len(args) > 1 {
+		usageError("getssh", "unexpected argument %q", args[1])
+	}
+	name := "fdh-user-ssh-creds"
+	if len(args) == 1 {
+		name += "-" + args[0]
+	}
+
+	cred, err := cm.ReadUserCred(name)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error getting SSH credentials: %v\n", err)
-		os.Exit(1)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+	defer cred.Wipe()
+
+	fmt.Printf("Username: %s\n", cred.Username())
+	if key, ok := cred.(credmgr.SSHKeyCred); ok {
+		data, passphrase := key.PrivateKey(), key.Passphrase()
+		protection := "unencrypted"
+		if len(passphrase) > 0 {
+			protection = "passphrase stored"
+		}
+		fmt.Printf("Auth:     key (%d bytes, %s)\n", len(data), protection)
+		clear(data)
+		clear(passphrase)
+		return
+	}
+	fmt.Printf("Auth:     password\nPassword: %s\n", cred.Password())
+}
+
+func handleFIDO2(args []string) {
+	if len(args) < 1 {
+		usageError("fido2", "fido2 subcommand required")
 	}
 
-	fmt.Printf("Username: %s\nPassword: %s\n", cred.Username(), cred.Password())
+	dbPath, err := credFilePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating credential database: %v\n", err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+
+	subcommand := strings.ToLower(args[0])
+	if subcommand == "list" {
+		labels, err := credmgr.ListFIDO2(dbPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing FIDO2 keys: %v\n", err)
+			printHint(err)
+			os.Exit(exitCode(err))
+		}
+		if len(labels) == 0 {
+			fmt.Println("No FIDO2 security keys enrolled")
+			return
+		}
+		for _, label := range labels {
+			fmt.Println(label)
+		}
+		return
+	}
+
+	if len(args) != 2 {
+		usageError("fido2", "label required")
+	}
+	label := args[1]
+
+	switch subcommand {
+	case "enroll":
+		fmt.Println("Touch your security key when it blinks...")
+		if err := credmgr.EnrollFIDO2(dbPath, label); err != nil {
+			fmt.Fprintf(os.Stderr, "Error enrolling FIDO2 key: %v\n", err)
+			printHint(err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("FIDO2 security key '%s' enrolled successfully\n", label)
+	case "remove", "del", "delete":
+		if err := credmgr.RemoveFIDO2(dbPath, label); err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing FIDO2 key: %v\n", err)
+			printHint(err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("FIDO2 security key '%s' removed successfully\n", label)
+	default:
+		usageError("fido2", "unknown fido2 subcommand %q", subcommand)
+	}
+}
+
+func handleKeychain(args []string) {
+	if len(args) != 1 {
+		usageError("keychain", "keychain subcommand required")
+	}
+
+	dbPath, err := credFilePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating credential database: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	switch subcommand := strings.ToLower(args[0]); subcommand {
+	case "enroll":
+		if err := credmgr.EnrollKeychain(dbPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error storing master key in the OS keychain: %v\n", err)
+			printHint(err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Println("Master key stored in the OS keychain")
+		fmt.Printf("%s is no longer needed for %s\n", fdotconfig.CredMgrEnvVarKey, dbPath)
+	case "remove", "del", "delete":
+		if err := credmgr.RemoveKeychain(dbPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing master key from the OS keychain: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Println("Master key removed from the OS keychain")
+	case "status":
+		enrolled, err := credmgr.KeychainEnrolled(dbPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading keychain enrollment: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		if enrolled {
+			fmt.Printf("Master key for %s is stored in the OS keychain\n", dbPath)
+		} else {
+			fmt.Printf("Master key for %s is not stored in the OS keychain\n", dbPath)
+		}
+	default:
+		usageError("keychain", "unknown keychain subcommand %q", subcommand)
+	}
+}
+
+func handleYubiKey(args []string) {
+	if len(args) < 1 {
+		usageError("yubikey", "yubikey subcommand required")
+	}
+
+	dbPath, err := credFilePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating credential database: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	subcommand := strings.ToLower(args[0])
+	if subcommand == "list" {
+		labels, err := credmgr.ListYubiKey(dbPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing YubiKeys: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		if len(labels) == 0 {
+			fmt.Println("No YubiKeys enrolled")
+			return
+		}
+		for _, label := range labels {
+			fmt.Println(label)
+		}
+		return
+	}
+
+	if len(args) < 2 {
+		usageError("yubikey", "label required")
+	}
+	label := args[1]
+
+	switch subcommand {
+	case "enroll":
+		slot := 2
+		if len(args) > 3 {
+			usageError("yubikey", "unexpected argument %q", args[3])
+		}
+		if len(args) == 3 {
+			if slot, err = strconv.Atoi(args[2]); err != nil {
+				usageError("yubikey", "invalid slot %q", args[2])
+			}
+		}
+		fmt.Println("Touch your YubiKey if it blinks...")
+		if err := credmgr.EnrollYubiKey(dbPath, label, slot); err != nil {
+			fmt.Fprintf(os.Stderr, "Error enrolling YubiKey: %v\n", err)
+			printHint(err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("YubiKey '%s' (slot %d) enrolled successfully\n", label, slot)
+	case "remove", "del", "delete":
+		if err := credmgr.RemoveYubiKey(dbPath, label); err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing YubiKey: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("YubiKey '%s' removed successfully\n", label)
+	default:
+		usageError("yubikey", "unknown yubikey subcommand %q", subcommand)
+	}
+}
+
+// defaultServeAddr keeps the HTTPS API on loopback unless --addr says otherwise
+const defaultServeAddr = "127.0.0.1:8443"
+
+// handleServe exposes allowed credentials over the HTTPS API. Without --cert and --key
+// it serves a self-signed certificate, written next to the database for clients to pin.
+// The older positional form, serve <addr> <cert> <key> <pattern>..., still works.
+func handleServe(cm credmgr.CredManager, args []string) {
+	fs := newFlagSet("serve")
+	addr := fs.String("addr", defaultServeAddr, "listen `address`")
+	var patterns stringsFlag
+	fs.Var(&patterns, "allow", "serve credentials matching this `pattern` (repeatable)")
+	certFile := fs.String("cert", "", "TLS certificate `file` (default: a self-signed certificate)")
+	keyFile := fs.String("key", "", "TLS private key `file`")
+	readOnly := fs.Bool("read-only", false, "refuse writes and deletes")
+	args = parseFlags(fs, args)
+	if len(args) > 0 {
+		if len(args) < 4 || len(patterns) > 0 {
+			usageError("serve", "address, certificate, key and at least one name pattern required")
+		}
+		*addr, *certFile, *keyFile, patterns = args[0], args[1], args[2], args[3:]
+	}
+	if len(patterns) == 0 {
+		usageError("serve", "at least one --allow pattern required")
+	}
+	if (*certFile == "") != (*keyFile == "") {
+		usageError("serve", "--cert and --key go together")
+	}
+
+	opts := credmgr.ServeOptions{
+		CredManager: cm,
+		Token:       os.Getenv(fdotconfig.CredMgrEnvVarServeToken),
+		CertFile:    *certFile,
+		KeyFile:     *keyFile,
+		Allow:       patterns,
+		ReadOnly:    *readOnly,
+	}
+	if opts.Token == "" {
+		var err error
+		if opts.Token, err = credmgr.GenerateSecret(credmgr.Policy{Length: 64, Charset: credmgr.CharsetHex}); err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating token: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		fmt.Fprintf(os.Stderr, "Bearer token (set %s to choose one): %s\n", fdotconfig.CredMgrEnvVarServeToken, opts.Token)
+	}
+
+	caFlag := ""
+	if *certFile == "" {
+		pemFile, tlsConfig, err := selfSignedServeTLS(*addr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating certificate: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		opts.TLSConfig = tlsConfig
+		caFlag = " --cacert " + pemFile
+		fmt.Fprintf(os.Stderr, "Self-signed certificate written to %s\n", pemFile)
+	}
+
+	fmt.Fprintf(os.Stderr, "Serving %s on https://%s\n", strings.Join(patterns, ", "), *addr)
+	fmt.Fprintf(os.Stderr, "Try: curl%s -H 'Authorization: Bearer <token>' https://%s/v1/credentials\n", caFlag, *addr)
+	if err := credmgr.Serve(*addr, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error serving credentials: %v\n", err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// selfSignedServeTLS creates a certificate for addr's host and the loopback names and
+// writes it to serve-cert.pem next to the database
+func selfSignedServeTLS(addr string) (string, *tls.Config, error) {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if host, _, err := net.SplitHostPort(addr); err == nil && host != "" && !slices.Contains(hosts, host) {
+		hosts = append(hosts, host)
+	}
+	cert, err := credmgr.SelfSignedCertificate(hosts...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	dbPath, err := credFilePath()
+	if err != nil {
+		return "", nil, err
+	}
+	pemFile := filepath.Join(filepath.Dir(dbPath), "serve-cert.pem")
+	if err := fdh.WritePrivateFile(pemFile, credmgr.CertificatePEM(cert)); err != nil {
+		return "", nil, err
+	}
+	return pemFile, &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}, nil
+}
+
+func handleExec(cm credmgr.CredManager, args []string) {
+	fs := newFlagSet("exec")
+	var mappings stringsFlag
+	fs.Var(&mappings, "e", "set `VAR=name`, the variable VAR to credential name (repeatable)")
+	args = parseFlags(fs, args)
+	if len(args) == 0 {
+		usageError("exec", "command required after --")
+	}
+
+	mapping := make(map[string]string)
+	for _, m := range mappings {
+		variable, name, ok := strings.Cut(m, "=")
+		if !ok || variable == "" || name == "" {
+			usageError("exec", "invalid mapping %q, want VAR=credential-name", m)
+		}
+		mapping[variable] = name
+	}
+
+	err := credmgr.ExecWith(cm, args, mapping)
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		os.Exit(exitErr.ExitCode())
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// handleEnv prints export lines for the credentials in VAR=name arguments, for
+// eval "$(credmgr env VAR=name...)". Every credential is read before anything is printed.
+func handleEnv(cm credmgr.CredManager, args []string) {
+	if len(args) < 1 {
+		usageError("env", "")
+	}
+
+	var lines []string
+	for _, arg := range args {
+		variable, name, ok := strings.Cut(arg, "=")
+		if !ok || !validEnvName(variable) || name == "" {
+			usageError("env", "invalid mapping %q, want VAR=credential-name", arg)
+		}
+		value, err := cm.ReadKey(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading credential '%s': %v\n", name, err)
+			printHint(err)
+			os.Exit(exitCode(err))
+		}
+		lines = append(lines, fmt.Sprintf("export %s=%s", variable, shellQuote(value)))
+	}
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
+
+// validEnvName reports whether s is a portable environment variable name
+func validEnvName(s string) bool {
+	if s == "" || s[0] >= '0' && s[0] <= '9' {
+		return false
+	}
+	for _, c := range s {
+		if c != '_' && (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// shellQuote quotes s for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func handleRender(cm credmgr.CredManager, args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		usageError("render", "template file required")
+	}
+	templatePath := args[0]
+
+	var err error
+	if len(args) > 1 {
+		err = credmgr.RenderTemplateFile(cm, templatePath, args[1])
+	} else {
+		var text []byte
+		if text, err = os.ReadFile(templatePath); err == nil {
+			err = credmgr.RenderTemplate(cm, os.Stdout, string(text))
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error rendering %s: %v\n", templatePath, err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+}
+
+func handleGenerate(cm credmgr.CredManager, args []string) {
+	fs := newFlagSet("generate")
+	length := fs.Int("length", credmgr.DefaultGenerateLength, "number of characters")
+	charset := fs.String("charset", "alnum", "character set: alnum, hex, symbols")
+	symbols := fs.Bool("symbols", false, "same as -charset symbols")
+	noAmbiguous := fs.Bool("no-ambiguous", false, "leave out easily confused characters (0 O 1 l I |)")
+	// The name may come before or after the options
+	name := nameArg("generate", parseFlags(fs, args))
+	if *symbols {
+		*charset = "symbols"
+	}
+
+	charsets := map[string]string{
+		"alnum":   credmgr.CharsetAlphanumeric,
+		"hex":     credmgr.CharsetHex,
+		"symbols": credmgr.CharsetSymbols,
+	}
+	chars, ok := charsets[*charset]
+	if !ok {
+		usageError("generate", "unknown charset %q (want alnum, hex or symbols)", *charset)
+	}
+
+	secret, err := credmgr.Generate(cm, name, credmgr.Policy{Length: *length, Charset: chars, NoAmbiguous: *noAmbiguous})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating '%s': %v\n", name, err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+	fmt.Println(secret)
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/nzions/fdot/pkg/fdotconfig"
+)
+
+var (
+	// selectedStore is the database chosen with --db or --profile; empty means the
+	// configured default
+	selectedStore fdotconfig.Profile
+
+	// globalArgs holds --db and --profile as given, for commands that re-run credmgr
+	globalArgs []string
+)
+
+// parseGlobalFlags removes the options given before the command from os.Args:
+//
+//	credmgr [--db <file> | --profile <name>] <command> ...
+//
+// A profile's key file is exported as CREDMGR_KEYFILE unless that is already set, so
+// every key lookup (and a background agent) uses it.
+func parseGlobalFlags() error {
+	args := os.Args[1:]
+	var dbPath, profile string
+	for len(args) > 0 {
+		flag, value, hasValue := strings.Cut(args[0], "=")
+		if flag != "-db" && flag != "--db" && flag != "-profile" && flag != "--profile" {
+			break
+		}
+		n := 1
+		if !hasValue {
+			if len(args) < 2 {
+				return fmt.Errorf("%s requires a value", flag)
+			}
+			value, n = args[1], 2
+		}
+		if value == "" {
+			return fmt.Errorf("%s requires a value", flag)
+		}
+		if strings.HasSuffix(flag, "db") {
+			dbPath = value
+		} else {
+			profile = value
+		}
+		globalArgs = append(globalArgs, args[:n]...)
+		args = args[n:]
+	}
+	os.Args = append(os.Args[:1], args...)
+
+	switch {
+	case dbPath != "" && profile != "":
+		return fmt.Errorf("--db and --profile cannot be combined")
+	case dbPath != "":
+		abs, err := filepath.Abs(dbPath)
+		if err != nil {
+			return err
+		}
+		selectedStore = fdotconfig.Profile{CredFile: abs}
+	case profile != "":
+		cfg, err := fdotconfig.LoadConfig()
+		if err != nil {
+			return err
+		}
+		p, ok := cfg.Profiles[profile]
+		if !ok || p.CredFile == "" {
+			path, _ := fdotconfig.ConfigPath()
+			known := slices.Sorted(maps.Keys(cfg.Profiles))
+			if len(known) == 0 {
+				return fmt.Errorf("unknown profile %q: %s defines no profiles", profile, path)
+			}
+			return fmt.Errorf("unknown profile %q in %s (profiles: %s)", profile, path, strings.Join(known, ", "))
+		}
+		selectedStore = p
+		if p.KeyFile != "" && os.Getenv(fdotconfig.CredMgrEnvVarKeyFile) == "" {
+			os.Setenv(fdotconfig.CredMgrEnvVarKeyFile, p.KeyFile)
+		}
+	}
+	return nil
+}
+
+// storeSelected reports whether --db or --profile chose the database
+func storeSelected() bool {
+	return selectedStore.CredFile != ""
+}
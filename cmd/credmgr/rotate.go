@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh"
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+	"github.com/nzions/fdot/pkg/fdotconfig"
+)
+
+// handleRotateKey re-encrypts the credential database under a new random master key.
+// The new key replaces the old one where it was found: the key file is rewritten and
+// the OS keychain enrollment renewed; a CREDMGR_KEY key is printed for the user to
+// update. A copy of the database under the old key is kept next to it.
+func handleRotateKey(args []string) {
+	fs := newFlagSet("rotate-key")
+	assumeYes := addYesFlag(fs)
+	noArgs("rotate-key", parseFlags(fs, args))
+
+	dbPath, err := credFilePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating credential database: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	current, err := os.ReadFile(dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading credential database: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	// The source that loadMasterKey would use, in the same order
+	keyFile := os.Getenv(fdotconfig.CredMgrEnvVarKeyFile)
+	if keyFile == "" {
+		cfg, err := fdotconfig.LoadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading config: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		keyFile = cfg.KeyFile
+	}
+	fromEnv := os.Getenv(fdotconfig.CredMgrEnvVarKey) != ""
+	keychain, _ := credmgr.KeychainEnrolled(dbPath)
+
+	confirm(fmt.Sprintf("Re-encrypt %s under a new master key?", dbPath), *assumeYes)
+
+	// ReKey replaces the .bak file too, so this copy is the only one the old key opens
+	backup := fmt.Sprintf("%s.pre-rotate-%s", dbPath, time.Now().Format("20060102-150405"))
+	if err := fdh.WritePrivateFile(backup, current); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing backup: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	fmt.Printf("Backup under the old key written to %s\n", backup)
+
+	newKeyHex, err := newMasterKeyHex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	newKey, _ := hex.DecodeString(newKeyHex)
+	defer clear(newKey)
+
+	// A key file is staged first, so the new key is on disk before anything needs it
+	stagedKeyFile := ""
+	if !fromEnv && keyFile != "" {
+		stagedKeyFile = keyFile + ".new"
+		if err := fdh.WritePrivateFile(stagedKeyFile, []byte(newKeyHex+"\n")); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", stagedKeyFile, err)
+			os.Exit(exitCode(err))
+		}
+	}
+
+	if err := credmgr.ReKey(dbPath, newKey); err != nil {
+		if stagedKeyFile != "" {
+			os.Remove(stagedKeyFile)
+		}
+		fmt.Fprintf(os.Stderr, "Error re-encrypting credential database: %v\n", err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+	fmt.Println("Credential database re-encrypted under the new master key")
+
+	switch {
+	case fromEnv:
+		fmt.Printf("Update %s everywhere the database is opened, and keep a copy safe:\n", fdotconfig.CredMgrEnvVarKey)
+		fmt.Printf("  export %s=%s\n", fdotconfig.CredMgrEnvVarKey, newKeyHex)
+	case stagedKeyFile != "":
+		if err := os.Rename(stagedKeyFile, keyFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error replacing %s: %v\n", keyFile, err)
+			fmt.Fprintf(os.Stderr, "The new key is in %s; move it over %s by hand.\n", stagedKeyFile, keyFile)
+			os.Exit(exitCode(err))
+		}
+		fmt.Printf("New master key written to %s - back it up; the old key no longer opens the database.\n", keyFile)
+	case keychain:
+		// EnrollKeychain takes the key to protect from CREDMGR_KEY
+		os.Setenv(fdotconfig.CredMgrEnvVarKey, newKeyHex)
+		err := credmgr.RemoveKeychain(dbPath)
+		if err == nil {
+			err = credmgr.EnrollKeychain(dbPath)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error storing the new master key in the OS keychain: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Keep this key and enroll it again with 'credmgr keychain enroll':\n")
+			fmt.Fprintf(os.Stderr, "  export %s=%s\n", fdotconfig.CredMgrEnvVarKey, newKeyHex)
+			os.Exit(exitCode(err))
+		}
+		fmt.Println("New master key stored in the OS keychain")
+	default:
+		fmt.Println("Keep the new master key safe:")
+		fmt.Printf("  export %s=%s\n", fdotconfig.CredMgrEnvVarKey, newKeyHex)
+	}
+
+	// FIDO2 and YubiKey enrollments wrap the old key and are now useless
+	fido2, _ := credmgr.ListFIDO2(dbPath)
+	yubikeys, _ := credmgr.ListYubiKey(dbPath)
+	if len(fido2) > 0 || len(yubikeys) > 0 {
+		fmt.Println("Enrolled security keys still unlock only the old key; with the new key available, run")
+		for _, label := range fido2 {
+			fmt.Printf("  credmgr fido2 remove %s && credmgr fido2 enroll %s\n", label, label)
+		}
+		for _, label := range yubikeys {
+			fmt.Printf("  credmgr yubikey remove %s && credmgr yubikey enroll %s\n", label, label)
+		}
+	}
+}
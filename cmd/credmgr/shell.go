@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+	"golang.org/x/term"
+)
+
+// shellCommands are the commands the shell understands, for completion
+var shellCommands = []string{"get", "set", "list", "ls", "del", "help", "exit", "quit"}
+
+// credShell is an interactive session on one unlocked CredManager
+type credShell struct {
+	cm    credmgr.CredManager
+	term  *term.Terminal // nil when stdin is not a terminal
+	out   io.Writer
+	names []string // completion candidates, refreshed after changes
+}
+
+// handleShell unlocks the store once and runs get/set/list/del commands read from
+// the terminal, with tab completion of commands and credential names
+func handleShell(cm credmgr.CredManager) {
+	names, err := cm.List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error unlocking credentials: %v\n", err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+	sh := &credShell{cm: cm, out: os.Stdout, names: names}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		// Scripted input: one command per line, no prompt or completion
+		for {
+			line, err := stdin.ReadString('\n')
+			if line != "" && !sh.run(line) {
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting up the terminal: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	defer term.Restore(fd, state)
+
+	sh.term = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "credmgr> ")
+	sh.term.AutoCompleteCallback = sh.complete
+	sh.out = sh.term
+	fmt.Fprintf(sh.out, "%d credentials unlocked. Type help for commands, Tab to complete, exit to leave.\n", len(names))
+
+	for {
+		line, err := sh.term.ReadLine()
+		if err != nil {
+			// Ctrl-D
+			return
+		}
+		if !sh.run(line) {
+			return
+		}
+	}
+}
+
+// run executes one command line and reports whether the shell should continue
+func (sh *credShell) run(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+	cmd, args := strings.ToLower(fields[0]), fields[1:]
+
+	switch cmd {
+	case "exit", "quit":
+		return false
+	case "help", "?":
+		fmt.Fprintln(sh.out, "  get <name>        print a credential")
+		fmt.Fprintln(sh.out, "  set <name>        store a credential, prompting for it without echo")
+		fmt.Fprintln(sh.out, "  list [pattern]    list credentials matching a prefix, glob or re:regex")
+		fmt.Fprintln(sh.out, "  del <name>        move a credential to the trash")
+		fmt.Fprintln(sh.out, "  exit              leave the shell")
+	case "list", "ls":
+		var names []string
+		var err error
+		if len(args) > 0 {
+			names, err = sh.cm.ListFiltered(args[0])
+		} else {
+			names, err = sh.cm.List()
+		}
+		if err != nil {
+			sh.fail(err)
+			break
+		}
+		for _, name := range names {
+			fmt.Fprintln(sh.out, name)
+		}
+	case "get":
+		if len(args) != 1 {
+			fmt.Fprintln(sh.out, "usage: get <name>")
+			break
+		}
+		value, err := sh.cm.ReadKey(args[0])
+		if err != nil {
+			sh.fail(err)
+			break
+		}
+		fmt.Fprintln(sh.out, value)
+	case "set":
+		if len(args) != 1 {
+			fmt.Fprintln(sh.out, "usage: set <name>")
+			break
+		}
+		secret, err := sh.readSecret(fmt.Sprintf("Secret for '%s': ", args[0]))
+		if err == nil && secret == "" {
+			err = errors.New("empty secret")
+		}
+		if err == nil {
+			err = sh.cm.WriteKey(args[0], secret)
+		}
+		if err != nil {
+			sh.fail(err)
+			break
+		}
+		fmt.Fprintf(sh.out, "Credential '%s' stored\n", args[0])
+		sh.refresh()
+	case "del", "delete", "rm":
+		if len(args) != 1 {
+			fmt.Fprintln(sh.out, "usage: del <name>")
+			break
+		}
+		if err := sh.cm.Delete(args[0]); err != nil {
+			sh.fail(err)
+			break
+		}
+		fmt.Fprintf(sh.out, "Credential '%s' moved to trash\n", args[0])
+		sh.refresh()
+	default:
+		fmt.Fprintf(sh.out, "unknown command %q (type help)\n", cmd)
+	}
+	return true
+}
+
+// readSecret prompts for a value without echo
+func (sh *credShell) readSecret(prompt string) (string, error) {
+	if sh.term == nil {
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	return sh.term.ReadPassword(prompt)
+}
+
+// fail prints an error without leaving the shell
+func (sh *credShell) fail(err error) {
+	fmt.Fprintf(sh.out, "Error: %v\n", err)
+}
+
+// refresh reloads the completion candidates
+func (sh *credShell) refresh() {
+	if names, err := sh.cm.List(); err == nil {
+		sh.names = names
+	}
+}
+
+// complete completes the command (first word) or a credential name (second word) on
+// Tab, listing the candidates when the completion is ambiguous
+func (sh *credShell) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' || pos != len(line) {
+		return "", 0, false
+	}
+
+	start := strings.LastIndexByte(line, ' ') + 1
+	word := line[start:]
+	candidates := sh.names
+	if strings.TrimSpace(line[:start]) == "" {
+		candidates = shellCommands
+	}
+
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, word) {
+			matches = append(matches, c)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", 0, false
+	case 1:
+		completed := line[:start] + matches[0] + " "
+		return completed, len(completed), true
+	}
+
+	common := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(common) > len(word) {
+		completed := line[:start] + common
+		return completed, len(completed), true
+	}
+	slices.Sort(matches)
+	fmt.Fprintf(sh.term, "%s\n", strings.Join(matches, "  "))
+	return "", 0, false
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+	"github.com/nzions/fdot/pkg/fdh/credsync"
+	"github.com/nzions/fdot/pkg/fdh/netssh"
+	"github.com/nzions/fdot/pkg/fdotconfig"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// handleSync copies the encrypted database to or from another host over SSH. The host
+// must be listed in ~/.ssh/known_hosts, and the login is the dedicated sync credential
+// (setssh --sync) or the user's ssh-agent, never the network-device credential.
+func handleSync(cm credmgr.CredManager, args []string) {
+	mode := "auto"
+	if len(args) > 0 && (args[0] == "push" || args[0] == "pull") {
+		mode, args = args[0], args[1:]
+	}
+	if len(args) < 1 {
+		usageError("sync", "remote host required")
+	}
+	if len(args) > 2 {
+		usageError("sync", "unexpected argument %q", args[2])
+	}
+
+	login, host, port := "", args[0], 22
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		login, host = host[:i], host[i+1:]
+	}
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host = h
+		if port, err = strconv.Atoi(p); err != nil {
+			usageError("sync", "invalid port %q", p)
+		}
+	}
+
+	localPath, err := credFilePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating credential database: %v\n", err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+
+	// Default remote path mirrors the local path relative to the home directory
+	remotePath := filepath.ToSlash(filepath.Join(".local/credmgr", filepath.Base(localPath)))
+	if len(args) > 1 {
+		remotePath = args[1]
+	}
+
+	cfg := netssh.Config{Host: host, Port: port}
+	if err := syncHostKeys(&cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading known hosts: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	closeAgent, err := syncLogin(cm, &cfg, login)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting sync login: %v\n", err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+	defer closeAgent()
+
+	client := netssh.NewClient(context.Background(), cfg)
+	if err := client.Connect(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error connecting to %s: %v\n", host, err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+	defer client.Close()
+
+	statePath := syncStatePath(localPath, host, port)
+	action := credsync.ActionPushed
+	switch mode {
+	case "push":
+		err = credsync.Push(client, localPath, remotePath, statePath)
+	case "pull":
+		action = credsync.ActionPulled
+		err = credsync.Pull(client, localPath, remotePath, statePath)
+	default:
+		action, err = credsync.Sync(client, localPath, remotePath, statePath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error syncing with %s: %v\n", host, err)
+		printHint(err)
+		os.Exit(exitCode(err))
+	}
+
+	switch action {
+	case credsync.ActionPushed:
+		fmt.Printf("Local credentials pushed to %s:%s\n", host, remotePath)
+	case credsync.ActionPulled:
+		fmt.Printf("Credentials pulled from %s:%s\n", host, remotePath)
+	default:
+		fmt.Println("Credentials already in sync")
+	}
+}
+
+// syncHostKeys checks the host against ~/.ssh/known_hosts and offers only the key types
+// recorded for it, so a host with several keys presents one that can be checked
+func syncHostKeys(cfg *netssh.Config) error {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	callback, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return err
+	}
+	cfg.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			return fmt.Errorf("%s is not in known_hosts; connect once with ssh to check and add its key", hostname)
+		}
+		if errors.As(err, &keyErr) {
+			return fmt.Errorf("host key for %s does not match known_hosts: %w", hostname, err)
+		}
+		return err
+	}
+
+	// Probe with a throwaway key: the mismatch lists the keys known for the host
+	_, probe, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	signer, err := ssh.NewSignerFromKey(probe)
+	if err != nil {
+		return err
+	}
+	address := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	var keyErr *knownhosts.KeyError
+	if errors.As(callback(address, &net.TCPAddr{IP: net.IPv4zero, Port: cfg.Port}, signer.PublicKey()), &keyErr) {
+		for _, known := range keyErr.Want {
+			cfg.HostKeyAlgorithms = append(cfg.HostKeyAlgorithms, hostKeyAlgorithms(known.Key.Type())...)
+		}
+	}
+	return nil
+}
+
+// hostKeyAlgorithms lists the signature algorithms a host key of the given type can use
+func hostKeyAlgorithms(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
+
+// syncLogin fills in the login: the stored sync credential if there is one, otherwise the
+// keys in the ssh-agent named by SSH_AUTH_SOCK as login (default: the local user name).
+// The returned func closes the agent connection.
+func syncLogin(cm credmgr.CredManager, cfg *netssh.Config, login string) (func(), error) {
+	cred, err := cm.ReadUserCred(fdotconfig.SyncCredSecretName)
+	if err == nil {
+		if login != "" && login != cred.Username() {
+			cred.Wipe()
+			return nil, fmt.Errorf("login %q does not match the stored sync credential for %q", login, cred.Username())
+		}
+		cfg.Credentials = cred
+		return cred.Wipe, nil
+	}
+	if !errors.Is(err, credmgr.ErrNotFound) {
+		return nil, err
+	}
+
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, errors.New("no sync credential stored and no ssh-agent running; store one with 'credmgr setssh --sync' or start ssh-agent")
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ssh-agent: %w", err)
+	}
+
+	if login == "" {
+		u, err := user.Current()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		login = u.Username
+	}
+	cfg.User = login
+	cfg.Auth = []ssh.AuthMethod{ssh.PublicKeysCallback(agent.NewClient(conn).Signers)}
+	return func() { conn.Close() }, nil
+}
+
+// syncStatePath names the file next to the database that records the last sync with a host
+func syncStatePath(localPath, host string, port int) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '.' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, host)
+	return fmt.Sprintf("%s.sync-%s-%d", localPath, name, port)
+}
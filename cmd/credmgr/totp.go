@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+)
+
+// handleTOTP stores TOTP seeds and prints their current codes
+func handleTOTP(cm credmgr.CredManager, args []string) {
+	if len(args) < 2 {
+		usageError("totp", "")
+	}
+
+	switch subcommand := strings.ToLower(args[0]); subcommand {
+	case "add":
+		name := args[1]
+		data, err := readSecretArg(name, args[2:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading secret: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		seed, err := credmgr.ParseTOTP(string(data))
+		clear(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		if err := credmgr.WriteTOTP(cm, name, seed); err != nil {
+			fmt.Fprintf(os.Stderr, "Error storing TOTP seed '%s': %v\n", name, err)
+			printHint(err)
+			os.Exit(exitCode(err))
+		}
+		clear(seed.Secret)
+		fmt.Printf("TOTP seed '%s' stored (%d digits, %s period)\n", name, seed.Digits, seed.Period)
+
+	case "code":
+		fs := newFlagSet("totp")
+		watch := fs.Bool("watch", false, "redraw the code and its countdown until interrupted")
+		name := nameArg("totp", parseFlags(fs, args[1:]))
+		seed, err := credmgr.ReadTOTP(cm, name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading TOTP seed '%s': %v\n", name, err)
+			printHint(err)
+			os.Exit(exitCode(err))
+		}
+		defer clear(seed.Secret)
+
+		if !*watch {
+			code, _, _ := seed.Code(time.Now())
+			fmt.Println(code)
+			return
+		}
+
+		// Redraw the code and its countdown every second until interrupted
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			code, left, _ := seed.Code(time.Now())
+			fmt.Printf("\r%s  %2ds ", code, int(left.Round(time.Second)/time.Second))
+			select {
+			case <-ctx.Done():
+				fmt.Println()
+				return
+			case <-ticker.C:
+			}
+		}
+
+	default:
+		usageError("totp", "unknown totp subcommand %q", subcommand)
+	}
+}
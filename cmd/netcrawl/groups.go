@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nzions/fdot/cmd/netcrawl/netcrawl"
+)
+
+// runGroups lists the device groups defined in the groups file, or the members of one group
+func runGroups(args []string) error {
+	fs := flag.NewFlagSet("groups", flag.ContinueOnError)
+	file := fs.String("file", netcrawl.GroupsPath(), "Groups file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	groups, err := netcrawl.LoadGroups(*file)
+	if err != nil {
+		return err
+	}
+
+	names := groups.Names()
+	if fs.NArg() > 0 {
+		names = []string{strings.TrimPrefix(fs.Arg(0), netcrawl.GroupPrefix)}
+	}
+	if len(names) == 0 {
+		fmt.Printf("No groups defined in %s\n", *file)
+		fmt.Println(`Add lines such as: group core = model =~ "8320"`)
+		return nil
+	}
+
+	store, err := netcrawl.OpenDefaultStore()
+	if err != nil {
+		return err
+	}
+	for i, name := range names {
+		query, ok := groups.Query(name)
+		if !ok {
+			return fmt.Errorf("unknown group %q (defined in %s)", name, *file)
+		}
+		members, err := groups.Members(store, name)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s%s = %s\n", netcrawl.GroupPrefix, name, query)
+		for _, d := range members {
+			fmt.Printf("  %-15s %-24s %s\n", d.IPAddress, d.Hostname, d.Model)
+		}
+		if len(members) == 0 {
+			fmt.Println("  (no matching devices)")
+		}
+	}
+	return nil
+}
+
+// resolveTargets expands "@name" into the IPs of the group's stored devices
+func resolveTargets(target string) ([]string, error) {
+	groups, err := netcrawl.LoadGroups(netcrawl.GroupsPath())
+	if err != nil {
+		return nil, err
+	}
+	store, err := netcrawl.OpenDefaultStore()
+	if err != nil {
+		return nil, err
+	}
+	targets, err := netcrawl.ResolveTargets(store, groups, target)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("group %s matches no stored devices", target)
+	}
+	return targets, nil
+}
+
+// isGroups reports whether the command line invokes the groups subcommand
+func isGroups() bool {
+	return len(os.Args) > 1 && os.Args[1] == "groups"
+}
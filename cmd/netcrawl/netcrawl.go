@@ -1,24 +1,38 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/nzions/eventstream"
 	"github.com/nzions/fdot/cmd/netcrawl/netcrawl"
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+	"github.com/nzions/fdot/pkg/fdh/netssh"
+	"golang.org/x/term"
 )
 
 // Version is the semantic version of netcrawl
-const Version = "1.0.0"
+const Version = "1.12.0"
 
 var (
-	deviceIP    = flag.String("device", "", "Target device IP address (required)")
+	deviceIP    = flag.String("device", "", "Target device IP address, or @group for every device in a group (required)")
 	port        = flag.Int("port", 22, "SSH port")
 	timeout     = flag.Duration("timeout", 30*time.Second, "Connection timeout")
 	showVersion = flag.Bool("version", false, "Show version and exit")
+	recordDir   = flag.String("record", "", "Record sanitized command output as fixtures in this directory")
+	replayDir   = flag.String("replay", "", "Replay command output from fixtures in this directory (no network access)")
+	site        = flag.String("site", "", "Site code; the site credential (credmgr setssh <un> <pw> <site>) is tried before the global one")
+	credNames   = flag.String("creds", "", "Comma-separated credmgr credential names to try in order (default: the global SSH credential)")
+	promptCreds = flag.Bool("prompt", false, "Prompt for credentials if no stored credential is accepted")
+	maxAuth     = flag.Int("max-auth-attempts", netcrawl.DefaultMaxAuthAttempts, "Rejected logins allowed per device, and per credential over the run (account lockout protection)")
+	profileName = flag.String("profile", string(netcrawl.ProfileStandard), "Data sets to collect: lite (version+neighbors), standard (+config, interfaces), full (+MAC/ARP, DHCP snooping, inventory)")
+	uploadURL   = flag.String("upload", "", "After the run, upload raw outputs, device records and exports (s3://bucket/prefix, gs://bucket/prefix, azblob://account/container/prefix)")
+	uploadCred  = flag.String("upload-cred", "", "credmgr credential for -upload (access key / HMAC key as user credential, or SAS token for azblob)")
 )
 
 // netcrawl connects to network switches via SSH, executes show commands,
@@ -31,6 +45,17 @@ func main() {
 }
 
 func run() error {
+	// Subcommands
+	if isShowRequirements() {
+		return runShowRequirements(os.Args[2:])
+	}
+	if isGroups() {
+		return runGroups(os.Args[2:])
+	}
+	if isReport() {
+		return runReport(os.Args[2:])
+	}
+
 	// Parse command-line flags
 	flag.Parse()
 
@@ -48,10 +73,145 @@ func run() error {
 		return fmt.Errorf("missing required flag: -device")
 	}
 
+	if *recordDir != "" && *replayDir != "" {
+		return fmt.Errorf("-record and -replay are mutually exclusive")
+	}
+	isGroup := strings.HasPrefix(*deviceIP, netcrawl.GroupPrefix)
+	if isGroup && (*recordDir != "" || *replayDir != "") {
+		return fmt.Errorf("-record and -replay need a single -device, not a group")
+	}
+
+	profile, err := netcrawl.ParseProfile(*profileName)
+	if err != nil {
+		return err
+	}
+
+	opts := netcrawl.Options{
+		DeviceIP: *deviceIP,
+		Port:     *port,
+		Timeout:  *timeout,
+		Profile:  profile,
+		Credentials: netcrawl.CredentialOrder{
+			Site:        *site,
+			MaxAttempts: *maxAuth,
+			Failures:    netcrawl.NewAuthFailures(), // shared by every device of a group
+		},
+	}
+	if *credNames != "" {
+		opts.Credentials.Names = strings.Split(*credNames, ",")
+	}
+	if *promptCreds {
+		opts.Credentials.Prompt = promptCredential
+	}
+	switch {
+	case *recordDir != "":
+		opts.Capture = &netssh.CaptureConfig{Mode: netssh.CaptureRecord, Dir: *recordDir}
+	case *replayDir != "":
+		opts.Capture = &netssh.CaptureConfig{Mode: netssh.CaptureReplay, Dir: *replayDir}
+	}
+
 	log := eventstream.DefaultHandler
 	ctx := eventstream.AddToContext(context.Background(), log)
-	if err := netcrawl.DiscoverDevice(ctx, deviceIP, port, timeout); err != nil {
-		return fmt.Errorf("discovering device: %w", err)
+
+	// Open the upload destination before crawling so a bad URL or credential fails fast
+	var uploader *netcrawl.Uploader
+	if *uploadURL != "" {
+		if *replayDir != "" {
+			return fmt.Errorf("-upload cannot be combined with -replay")
+		}
+		if uploader, err = netcrawl.NewUploader(ctx, netcrawl.UploadConfig{URL: *uploadURL, Credential: *uploadCred}); err != nil {
+			return err
+		}
+	}
+
+	if !isGroup {
+		if err := netcrawl.DiscoverDevice(ctx, opts); err != nil {
+			return fmt.Errorf("discovering device: %w", err)
+		}
+		return uploadArtifacts(ctx, log, uploader, []string{opts.DeviceIP})
+	}
+
+	targets, err := resolveTargets(*deviceIP)
+	if err != nil {
+		return err
+	}
+	log.Send(netcrawl.TargetsResolved{Target: *deviceIP, Devices: targets})
+
+	// One unreachable device must not stop the rest of the group
+	failed := 0
+	var crawled []string
+	for _, ip := range targets {
+		opts.DeviceIP = ip
+		if err := netcrawl.DiscoverDevice(ctx, opts); err != nil {
+			log.Errorf("Discovering %s: %v", ip, err)
+			failed++
+			continue
+		}
+		crawled = append(crawled, ip)
+	}
+	uploadErr := uploadArtifacts(ctx, log, uploader, crawled)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d devices in %s failed", failed, len(targets), *deviceIP)
+	}
+	return uploadErr
+}
+
+// uploadArtifacts uploads the outputs and records of the crawled devices and the run's
+// exports; uploader may be nil when -upload is not set
+func uploadArtifacts(ctx context.Context, log *eventstream.Handler, uploader *netcrawl.Uploader, devices []string) error {
+	if uploader == nil {
+		return nil
+	}
+	store, err := netcrawl.OpenDefaultStore()
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, ip := range devices {
+		files := 0
+		info, err := store.Get(ip)
+		if err == nil {
+			files, err = uploader.UploadDevice(ctx, ip, info)
+		}
+		if err != nil {
+			log.Errorf("Uploading %s: %v", ip, err)
+			log.Send(netcrawl.ArtifactsUploaded{Destination: uploader.String(), Device: ip, Files: files, Error: err.Error()})
+			failed++
+			continue
+		}
+		log.Send(netcrawl.ArtifactsUploaded{Destination: uploader.String(), Device: ip, Files: files})
+	}
+
+	files, err := uploader.UploadExports(ctx)
+	if err != nil {
+		log.Errorf("Uploading exports: %v", err)
+		log.Send(netcrawl.ArtifactsUploaded{Destination: uploader.String(), Error: err.Error()})
+		failed++
+	} else if files > 0 {
+		log.Send(netcrawl.ArtifactsUploaded{Destination: uploader.String(), Files: files})
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d uploads to %s failed", failed, uploader)
 	}
 	return nil
 }
+
+// promptCredential asks for a username and password on the terminal
+func promptCredential(ip string) (credmgr.UserCred, error) {
+	fmt.Fprintf(os.Stderr, "Credentials for %s\nUsername: ", ip)
+	username, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("reading username: %w", err)
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("reading password: %w", err)
+	}
+
+	return credmgr.NewUnPw(strings.TrimSpace(username), string(password)), nil
+}
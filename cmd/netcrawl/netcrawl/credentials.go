@@ -0,0 +1,315 @@
+package netcrawl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nzions/eventstream"
+	"github.com/nzions/fdot/pkg/fdh"
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+	"github.com/nzions/fdot/pkg/fdh/fuser"
+	"github.com/nzions/fdot/pkg/fdh/netssh"
+	"github.com/nzions/fdot/pkg/fdotconfig"
+)
+
+// DefaultMaxAuthAttempts is the number of rejected logins allowed per device, and per
+// credential over a run. It stays below the common 3-5 failure lockout thresholds of
+// TACACS+/RADIUS servers.
+const DefaultMaxAuthAttempts = 3
+
+// promptCredentialName labels credentials entered interactively; they are never remembered
+const promptCredentialName = "prompt"
+
+// ErrNoCredentials is returned when no credential set is available for a device
+var ErrNoCredentials = errors.New("no SSH credentials found")
+
+// CredentialOrder selects the credential sets tried against a device.
+// Candidates are tried in this order, stopping at the first successful login:
+//  1. the credential that last worked for the device (remembered across runs)
+//  2. the site credential, if Site is set
+//  3. Names, or the global SSH credential if Names is empty
+//  4. Prompt, if set
+type CredentialOrder struct {
+	// Site selects the site-specific credential SiteCredentialName(Site)
+	Site string
+	// Names are credmgr username/password credentials tried after the site credential
+	Names []string
+	// Prompt asks for a credential interactively once every stored set has failed
+	Prompt func(ip string) (credmgr.UserCred, error)
+	// MaxAttempts caps rejected logins per device, and per credential across Failures,
+	// so a run never locks out an account. Defaults to DefaultMaxAuthAttempts.
+	MaxAttempts int
+	// Failures counts rejected logins per credential across the devices of a run, since
+	// TACACS+/RADIUS servers lock out accounts rather than devices. Share one across
+	// every DiscoverDevice call of a crawl; nil counts for a single device only.
+	Failures *AuthFailures
+}
+
+// AuthFailures counts rejected logins per credential name. A successful login resets
+// the credential's count. It is safe for concurrent use.
+type AuthFailures struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewAuthFailures returns an empty failure count
+func NewAuthFailures() *AuthFailures {
+	return &AuthFailures{counts: make(map[string]int)}
+}
+
+// Count returns the rejected logins recorded for the credential name
+func (f *AuthFailures) Count(name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counts[name]
+}
+
+// record counts a login with the credential name, resetting the count on success
+func (f *AuthFailures) record(name string, accepted bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if accepted {
+		delete(f.counts, name)
+	} else {
+		f.counts[name]++
+	}
+}
+
+// SiteCredentialName returns the credmgr name of a site's SSH credential
+// (e.g. "fdh-user-ssh-creds-nyc3")
+func SiteCredentialName(site string) string {
+	return fdotconfig.SSHCredSecretName + "-" + site
+}
+
+// names returns the stored credential names to try, most specific first, without duplicates
+func (o CredentialOrder) names(remembered string) []string {
+	var names []string
+	add := func(name string) {
+		if name == "" {
+			return
+		}
+		for _, n := range names {
+			if n == name {
+				return
+			}
+		}
+		names = append(names, name)
+	}
+
+	add(remembered)
+	if o.Site != "" {
+		add(SiteCredentialName(o.Site))
+	}
+	if len(o.Names) == 0 {
+		add(fdotconfig.SSHCredSecretName)
+	}
+	for _, name := range o.Names {
+		add(name)
+	}
+	return names
+}
+
+// credentialRecord remembers which credential logged in to a device
+type credentialRecord struct {
+	Credential  string    `json:"credential"`
+	Username    string    `json:"username"`
+	SucceededAt time.Time `json:"succeeded_at"`
+}
+
+// credentialMemoryPath is the file mapping device IPs to their working credential
+func credentialMemoryPath() string {
+	return filepath.Join(fuser.CurrentUser.DataDir, "device_credentials.json")
+}
+
+// loadCredentialMemory reads the remembered credentials; a missing file yields an empty map
+func loadCredentialMemory(path string) (map[string]credentialRecord, error) {
+	records := make(map[string]credentialRecord)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return records, nil
+		}
+		return nil, fmt.Errorf("failed to read credential memory: %w", err)
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse credential memory %s: %w", path, err)
+	}
+	return records, nil
+}
+
+// rememberCredential records (or, with an empty name, forgets) the credential for ip
+func rememberCredential(path, ip, name, username string) error {
+	records, err := loadCredentialMemory(path)
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		if _, ok := records[ip]; !ok {
+			return nil
+		}
+		delete(records, ip)
+	} else {
+		records[ip] = credentialRecord{Credential: name, Username: username, SucceededAt: time.Now()}
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal credential memory: %w", err)
+	}
+	if err := fdh.WritePrivateFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to save credential memory: %w", err)
+	}
+	return nil
+}
+
+// candidate is a credential set to log in with
+type candidate struct {
+	name string
+	cred credmgr.UserCred
+}
+
+// connectWithCredentials logs in to the device with the first credential set that works.
+// Only rejected logins move on to the next candidate; any other connection error is returned
+// immediately. The returned name identifies the credential that succeeded.
+func connectWithCredentials(ctx context.Context, log *eventstream.Handler, opts Options) (*netssh.Client, string, error) {
+	order := opts.Credentials
+
+	memoryPath := credentialMemoryPath()
+	memory, err := loadCredentialMemory(memoryPath)
+	if err != nil {
+		log.Warnf("Ignoring remembered credentials: %v", err)
+		memory = nil
+	}
+	remembered := memory[opts.DeviceIP].Credential
+
+	var candidates []candidate
+	for _, name := range order.names(remembered) {
+		cred, err := fuser.CurrentUser.CredManager.ReadUserCred(name)
+		if errors.Is(err, credmgr.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("loading ssh creds %q: %w", name, err)
+		}
+		candidates = append(candidates, candidate{name, cred})
+	}
+	if len(candidates) == 0 && order.Prompt == nil {
+		return nil, "", ErrNoCredentials
+	}
+
+	attempts := 0
+	connect := func(c candidate) (*netssh.Client, error) {
+		attempts++
+		client := netssh.NewClient(ctx, netssh.Config{
+			Host:        opts.DeviceIP,
+			Port:        opts.Port,
+			Credentials: c.cred,
+			Timeout:     opts.Timeout,
+			Capture:     opts.Capture,
+		})
+		err := client.Connect()
+
+		event := CredentialAttempted{
+			IP:         opts.DeviceIP,
+			Credential: c.name,
+			Username:   c.cred.Username(),
+			Attempt:    attempts,
+			Success:    err == nil,
+		}
+		if err != nil {
+			event.Error = err.Error()
+		}
+		log.Send(event)
+
+		if errors.Is(err, netssh.ErrAuthFailed) {
+			log.Warnf("Credential %q rejected by %s", c.name, opts.DeviceIP)
+			if c.name == remembered {
+				if err := rememberCredential(memoryPath, opts.DeviceIP, "", ""); err != nil {
+					log.Warnf("Failed to forget credential: %v", err)
+				}
+			}
+		}
+		return client, err
+	}
+
+	client, used, err := order.login(log, opts.DeviceIP, candidates, connect)
+	if err != nil {
+		return nil, "", err
+	}
+	replaying := opts.Capture != nil && opts.Capture.Mode == netssh.CaptureReplay
+	if used.name != promptCredentialName && used.name != remembered && !replaying {
+		if err := rememberCredential(memoryPath, opts.DeviceIP, used.name, used.cred.Username()); err != nil {
+			log.Warnf("Failed to remember credential: %v", err)
+		}
+	}
+	return client, used.name, nil
+}
+
+// login tries candidates, then the prompt, until connect succeeds. It stops after
+// MaxAttempts rejected logins on this device, and skips credentials that Failures
+// already counts MaxAttempts rejections for, so several devices behind the same
+// TACACS+/RADIUS server cannot together lock out an account.
+func (o CredentialOrder) login(log *eventstream.Handler, ip string, candidates []candidate, connect func(candidate) (*netssh.Client, error)) (*netssh.Client, candidate, error) {
+	maxAttempts := o.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAuthAttempts
+	}
+	failures := o.Failures
+	if failures == nil {
+		failures = NewAuthFailures()
+	}
+
+	attempts, skipped := 0, 0
+	try := func(c candidate) (*netssh.Client, bool, error) {
+		if n := failures.Count(c.name); n >= maxAttempts {
+			log.Warnf("Skipping credential %q for %s: rejected %d time(s) this run", c.name, ip, n)
+			skipped++
+			return nil, false, nil
+		}
+		attempts++
+		client, err := connect(c)
+		switch {
+		case err == nil:
+			failures.record(c.name, true)
+			return client, true, nil
+		case errors.Is(err, netssh.ErrAuthFailed):
+			failures.record(c.name, false)
+			return nil, false, nil
+		default:
+			return nil, false, fmt.Errorf("connecting to device: %w", err)
+		}
+	}
+
+	for _, c := range candidates {
+		if attempts >= maxAttempts {
+			break
+		}
+		if client, ok, err := try(c); ok || err != nil {
+			return client, c, err
+		}
+	}
+
+	if o.Prompt != nil && attempts < maxAttempts && failures.Count(promptCredentialName) < maxAttempts {
+		cred, err := o.Prompt(ip)
+		if err != nil {
+			return nil, candidate{}, fmt.Errorf("prompting for credentials: %w", err)
+		}
+		c := candidate{promptCredentialName, cred}
+		if client, ok, err := try(c); ok || err != nil {
+			return client, c, err
+		}
+	}
+
+	if attempts == 0 && skipped > 0 {
+		return nil, candidate{}, fmt.Errorf("connecting to device: %w: every credential reached the limit of %d rejected logins this run", netssh.ErrAuthFailed, maxAttempts)
+	}
+	return nil, candidate{}, fmt.Errorf("connecting to device: %w after %d attempt(s) (limit %d)", netssh.ErrAuthFailed, attempts, maxAttempts)
+}
@@ -0,0 +1,177 @@
+package netcrawl
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/nzions/eventstream"
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+	"github.com/nzions/fdot/pkg/fdh/netssh"
+	"github.com/nzions/fdot/pkg/fdotconfig"
+)
+
+func TestCredentialOrderNames(t *testing.T) {
+	global := fdotconfig.SSHCredSecretName
+	tests := []struct {
+		name       string
+		order      CredentialOrder
+		remembered string
+		want       []string
+	}{
+		{"default", CredentialOrder{}, "", []string{global}},
+		{"site first", CredentialOrder{Site: "nyc3"}, "", []string{SiteCredentialName("nyc3"), global}},
+		{"remembered first", CredentialOrder{Site: "nyc3"}, "lab", []string{"lab", SiteCredentialName("nyc3"), global}},
+		{"names replace global", CredentialOrder{Names: []string{"a", "b"}}, "", []string{"a", "b"}},
+		{"no duplicates", CredentialOrder{Names: []string{"a", "b", "a"}}, "b", []string{"b", "a"}},
+		{"remembered site", CredentialOrder{Site: "nyc3"}, SiteCredentialName("nyc3"), []string{SiteCredentialName("nyc3"), global}},
+		{"empty names skipped", CredentialOrder{Names: []string{"", "a"}}, "", []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.order.names(tt.remembered); !slices.Equal(got, tt.want) {
+				t.Errorf("names(%q) = %v, want %v", tt.remembered, got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeLogins accepts the credentials in accepted and rejects the rest, recording
+// every login attempted
+type fakeLogins struct {
+	accepted map[string]bool
+	failWith error // returned instead of a rejection, when set
+	tried    []string
+}
+
+func (f *fakeLogins) connect(c candidate) (*netssh.Client, error) {
+	f.tried = append(f.tried, c.name)
+	switch {
+	case f.accepted[c.name]:
+		return &netssh.Client{}, nil
+	case f.failWith != nil:
+		return nil, f.failWith
+	}
+	return nil, fmt.Errorf("failed to dial: %w", netssh.ErrAuthFailed)
+}
+
+func testCandidates(names ...string) []candidate {
+	var candidates []candidate
+	for _, name := range names {
+		candidates = append(candidates, candidate{name, credmgr.NewUnPw("admin-"+name, "pw")})
+	}
+	return candidates
+}
+
+func TestLoginFirstAccepted(t *testing.T) {
+	f := &fakeLogins{accepted: map[string]bool{"b": true}}
+	client, used, err := CredentialOrder{}.login(eventstream.DefaultHandler, "10.0.0.1", testCandidates("a", "b", "c"), f.connect)
+	if err != nil || client == nil || used.name != "b" {
+		t.Fatalf("login = %v, %q, %v; want b", client, used.name, err)
+	}
+	if !slices.Equal(f.tried, []string{"a", "b"}) {
+		t.Errorf("tried %v, want [a b]", f.tried)
+	}
+}
+
+func TestLoginDeviceLimit(t *testing.T) {
+	f := &fakeLogins{}
+	order := CredentialOrder{MaxAttempts: 2}
+	_, _, err := order.login(eventstream.DefaultHandler, "10.0.0.1", testCandidates("a", "b", "c"), f.connect)
+	if !errors.Is(err, netssh.ErrAuthFailed) {
+		t.Fatalf("login error = %v, want ErrAuthFailed", err)
+	}
+	if !slices.Equal(f.tried, []string{"a", "b"}) {
+		t.Errorf("tried %v, want the first 2", f.tried)
+	}
+}
+
+func TestLoginOtherErrorStops(t *testing.T) {
+	unreachable := errors.New("connection refused")
+	f := &fakeLogins{failWith: unreachable}
+	_, _, err := CredentialOrder{}.login(eventstream.DefaultHandler, "10.0.0.1", testCandidates("a", "b"), f.connect)
+	if !errors.Is(err, unreachable) {
+		t.Fatalf("login error = %v, want the connection error", err)
+	}
+	if len(f.tried) != 1 {
+		t.Errorf("tried %v after a network error, want one attempt", f.tried)
+	}
+}
+
+func TestLoginFailuresSharedAcrossDevices(t *testing.T) {
+	order := CredentialOrder{MaxAttempts: 3, Failures: NewAuthFailures()}
+	f := &fakeLogins{accepted: map[string]bool{}}
+
+	// A wrong global credential on a group: every device rejects it
+	for i := 1; i <= 3; i++ {
+		if _, _, err := order.login(eventstream.DefaultHandler, fmt.Sprintf("10.0.0.%d", i), testCandidates("global"), f.connect); !errors.Is(err, netssh.ErrAuthFailed) {
+			t.Fatalf("login on device %d error = %v, want ErrAuthFailed", i, err)
+		}
+	}
+	if got := order.Failures.Count("global"); got != 3 {
+		t.Fatalf("Failures.Count = %d, want 3", got)
+	}
+
+	// The account is at the limit, so the fourth device does not try it
+	f.tried = nil
+	_, _, err := order.login(eventstream.DefaultHandler, "10.0.0.4", testCandidates("global"), f.connect)
+	if !errors.Is(err, netssh.ErrAuthFailed) {
+		t.Fatalf("login on device 4 error = %v, want ErrAuthFailed", err)
+	}
+	if len(f.tried) != 0 {
+		t.Errorf("tried %v on device 4, want the credential skipped", f.tried)
+	}
+
+	// Other credentials are still tried
+	f.accepted["site"] = true
+	if _, used, err := order.login(eventstream.DefaultHandler, "10.0.0.5", testCandidates("global", "site"), f.connect); err != nil || used.name != "site" {
+		t.Errorf("login with another credential = %q, %v; want site", used.name, err)
+	}
+	if !slices.Equal(f.tried, []string{"site"}) {
+		t.Errorf("tried %v, want only site", f.tried)
+	}
+}
+
+func TestLoginSuccessResetsFailures(t *testing.T) {
+	order := CredentialOrder{MaxAttempts: 3, Failures: NewAuthFailures()}
+	f := &fakeLogins{accepted: map[string]bool{}}
+	for range 2 {
+		order.login(eventstream.DefaultHandler, "10.0.0.1", testCandidates("global"), f.connect)
+	}
+	f.accepted["global"] = true
+	if _, _, err := order.login(eventstream.DefaultHandler, "10.0.0.2", testCandidates("global"), f.connect); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if got := order.Failures.Count("global"); got != 0 {
+		t.Errorf("Failures.Count after success = %d, want 0", got)
+	}
+}
+
+func TestLoginPrompt(t *testing.T) {
+	prompted := 0
+	order := CredentialOrder{
+		MaxAttempts: 3,
+		Failures:    NewAuthFailures(),
+		Prompt: func(ip string) (credmgr.UserCred, error) {
+			prompted++
+			return credmgr.NewUnPw("typed", "pw"), nil
+		},
+	}
+	f := &fakeLogins{accepted: map[string]bool{promptCredentialName: true}}
+	if _, used, err := order.login(eventstream.DefaultHandler, "10.0.0.1", testCandidates("a"), f.connect); err != nil || used.name != promptCredentialName {
+		t.Fatalf("login = %q, %v; want the prompted credential", used.name, err)
+	}
+	if prompted != 1 || !slices.Equal(f.tried, []string{"a", promptCredentialName}) {
+		t.Errorf("prompted %d time(s), tried %v", prompted, f.tried)
+	}
+
+	// No prompt once the device limit is used up by stored credentials
+	prompted, f.tried = 0, nil
+	if _, _, err := order.login(eventstream.DefaultHandler, "10.0.0.2", testCandidates("b", "c", "d"), f.connect); !errors.Is(err, netssh.ErrAuthFailed) {
+		t.Fatalf("login error = %v, want ErrAuthFailed", err)
+	}
+	if prompted != 0 {
+		t.Errorf("prompted %d time(s) after %d rejected logins", prompted, len(f.tried))
+	}
+}
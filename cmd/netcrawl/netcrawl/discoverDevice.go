@@ -2,76 +2,107 @@ package netcrawl
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
 	"time"
 
-	"github.com/nzions/dsjdb"
 	"github.com/nzions/eventstream"
-	"github.com/nzions/fdot/pkg/fdh/credmgr"
+	"github.com/nzions/fdot/pkg/fdh"
 	"github.com/nzions/fdot/pkg/fdh/fuser"
 	"github.com/nzions/fdot/pkg/fdh/netdevice"
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
 	"github.com/nzions/fdot/pkg/fdh/netssh"
 )
 
-func DiscoverDevice(ctx context.Context, deviceIP *string, port *int, timeout *time.Duration) error {
+// Options holds the parameters for a device discovery run
+type Options struct {
+	DeviceIP string
+	Port     int
+	Timeout  time.Duration
+	Profile  Profile               // Data sets to collect (defaults to ProfileStandard)
+	Capture  *netssh.CaptureConfig // Optional record-and-replay of device sessions
+	Store    Store                 // Optional device store (defaults to dsjdb under the data directory)
+
+	// Credentials selects the credential sets to try (defaults to the global SSH credential)
+	Credentials CredentialOrder
+}
+
+func DiscoverDevice(ctx context.Context, opts Options) error {
 	log := eventstream.GetFromContext(ctx)
 
-	// load ssh creds
-	cred, err := fuser.CurrentUser.SSHCreds()
-	switch err {
-	case nil:
+	profile, err := ParseProfile(string(opts.Profile))
+	if err != nil {
+		return err
+	}
+
+	// Log in with the first credential set the device accepts
+	client, credName, err := connectWithCredentials(ctx, log, opts)
+	switch {
+	case err == nil:
 		// all good
-	case credmgr.ErrNotFound:
+	case errors.Is(err, ErrNoCredentials):
 		log.Errorf("No SSH credentials found - please set them using: credmgr setssh <username> <password>")
 		log.Send(DiscoveryCompleted{
-			IP:       *deviceIP,
-			Port:     *port,
+			IP:       opts.DeviceIP,
+			Port:     opts.Port,
 			Success:  false,
 			ErrorMsg: "No SSH credentials found",
 		})
 		return nil
 	default:
-		return fmt.Errorf("loading ssh creds: %w", err)
+		return err
 	}
+	defer client.Close()
 
 	log.Send(DiscoveryStarted{
-		IP:       *deviceIP,
-		Port:     *port,
-		Username: cred.Username(),
+		IP:         opts.DeviceIP,
+		Port:       opts.Port,
+		Username:   client.Username(),
+		Credential: credName,
 	})
 
-	// Create SSH client, and exec show ver
-	client := netssh.NewClient(ctx, netssh.Config{
-		Host:        *deviceIP,
-		Port:        *port,
-		Credentials: cred,
-		Timeout:     *timeout,
-	})
 	showVersionOutput, err := client.ExecuteCommand("show version")
 	if err != nil {
 		return fmt.Errorf("executing show version: %w", err)
 	}
 
 	// Create output directory for this device
-	deviceDir := filepath.Join(fuser.CurrentUser.NetworkDir, *deviceIP)
-	if err := os.MkdirAll(deviceDir, 0755); err != nil {
+	deviceDir := filepath.Join(fuser.CurrentUser.NetworkDir, opts.DeviceIP)
+	if err := fdh.CreatePrivateDir(deviceDir); err != nil {
 		return fmt.Errorf("failed to create device directory: %w", err)
 	}
 
 	// Save show version output
 	showVerFile := filepath.Join(deviceDir, "show_version.txt")
-	if err := os.WriteFile(showVerFile, []byte(showVersionOutput), 0644); err != nil {
+	if err := fdh.WritePrivateFile(showVerFile, []byte(showVersionOutput)); err != nil {
 		return fmt.Errorf("failed to save show version output: %w", err)
 	}
 
 	log.Send(ShowVersionRetrieved{
-		IP:           *deviceIP,
+		IP:           opts.DeviceIP,
 		OutputLength: len(showVersionOutput),
 		SavedTo:      showVerFile,
 	})
 
+	// Capture the login banner and MOTD before detection so they can identify the device
+	motd, err := client.MOTD()
+	if err != nil {
+		log.Warnf("Failed to capture MOTD: %v", err)
+	}
+	saveLoginText(log, deviceDir, "banner.txt", client.Banner())
+	saveLoginText(log, deviceDir, "motd.txt", motd)
+
+	loginEvent := LoginTextRetrieved{
+		IP:           opts.DeviceIP,
+		BannerLength: len(client.Banner()),
+		MOTDLength:   len(motd),
+	}
+	if err != nil {
+		loginEvent.Error = err.Error()
+	}
+	log.Send(loginEvent)
+
 	// Step 2: Parse show version and create appropriate device instance
 	log.Infof("Detecting device type...")
 	device, err := netdevice.NewDevice(client, showVersionOutput)
@@ -80,10 +111,10 @@ func DiscoverDevice(ctx context.Context, deviceIP *string, port *int, timeout *t
 	}
 
 	// Set the IP address
-	device.SetIPAddress(*deviceIP)
+	device.SetIPAddress(opts.DeviceIP)
 
 	log.Send(DeviceDetected{
-		IP:       *deviceIP,
+		IP:       opts.DeviceIP,
 		Platform: device.GetPlatform(),
 		OS:       device.GetOSVersion(),
 		Model:    device.GetModel(),
@@ -91,23 +122,110 @@ func DiscoverDevice(ctx context.Context, deviceIP *string, port *int, timeout *t
 		Uptime:   device.GetUptime(),
 	})
 
+	if profile.collectsConfig() {
+		if err := collectConfig(log, opts.DeviceIP, deviceDir, device); err != nil {
+			return err
+		}
+	}
+
+	// Step 5: Get neighbors
+	log.Infof("Retrieving neighbors...")
+	neighbors, err := device.GetNeighbors()
+	if err != nil {
+		log.Warnf("Failed to get neighbors: %v", err)
+		log.Send(NeighborsRetrieved{
+			IP:    opts.DeviceIP,
+			Count: 0,
+			Error: err.Error(),
+		})
+	} else {
+		log.Send(NeighborsRetrieved{
+			IP:    opts.DeviceIP,
+			Count: len(neighbors),
+		})
+	}
+
+	if profile.collectsTables() {
+		collectTables(log, opts.DeviceIP, device)
+	}
+
+	// Step 7: Save device info to database
+	log.Infof("Saving to database...")
+	deviceInfo := device.GetDeviceInfo()
+	deviceInfo.RawOutputDir = deviceDir
+	deviceInfo.Banner = client.Banner()
+	deviceInfo.MOTD = motd
+
+	store := opts.Store
+	if store == nil {
+		var err error
+		store, err = OpenDefaultStore()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Preserve data sets collected by heavier profiles on earlier runs
+	if prev, err := store.Get(opts.DeviceIP); err == nil {
+		profile.keepUncollected(deviceInfo, prev)
+	}
+
+	// Use device IP as the key
+	if err := store.Put(opts.DeviceIP, deviceInfo); err != nil {
+		return fmt.Errorf("failed to save device to database: %w", err)
+	}
+
+	log.Send(DeviceSaved{
+		IP:           opts.DeviceIP,
+		DatabasePath: store.Path(),
+		Key:          opts.DeviceIP,
+	})
+
+	// Step 8: Update the host index from MAC/ARP/DHCP observations
+	if profile.collectsTables() {
+		if err := updateHosts(ctx, log, deviceInfo); err != nil {
+			log.Warnf("Failed to update host index: %v", err)
+		}
+	}
+
+	log.Send(DiscoveryCompleted{
+		IP:      opts.DeviceIP,
+		Port:    opts.Port,
+		Success: true,
+	})
+
+	return nil
+}
+
+// saveLoginText writes captured login text next to the other raw outputs
+func saveLoginText(log *eventstream.Handler, deviceDir, name, text string) {
+	if text == "" {
+		return
+	}
+	if err := fdh.WritePrivateFile(filepath.Join(deviceDir, name), []byte(text)); err != nil {
+		log.Warnf("Failed to save %s: %v", name, err)
+	}
+}
+
+// collectConfig retrieves the running config and interfaces (standard and full profiles)
+func collectConfig(log *eventstream.Handler, ip, deviceDir string, device netmodel.Device) error {
 	// Step 3: Get configuration
 	log.Infof("Retrieving configuration...")
 	config, err := device.GetConfig()
 	if err != nil {
 		log.Warnf("Failed to get config: %v", err)
 		log.Send(ConfigurationRetrieved{
-			IP:      *deviceIP,
+			IP:      ip,
 			Success: false,
 			Error:   err.Error(),
 		})
 	} else {
 		configFile := filepath.Join(deviceDir, "show_running_config.txt")
-		if err := os.WriteFile(configFile, []byte(config), 0644); err != nil {
+		if err := fdh.WritePrivateFile(configFile, []byte(config)); err != nil {
 			return fmt.Errorf("failed to save config: %w", err)
 		}
 		log.Send(ConfigurationRetrieved{
-			IP:      *deviceIP,
+			IP:      ip,
 			Success: true,
 			SavedTo: configFile,
 		})
@@ -119,62 +237,100 @@ func DiscoverDevice(ctx context.Context, deviceIP *string, port *int, timeout *t
 	if err != nil {
 		log.Warnf("Failed to get interfaces: %v", err)
 		log.Send(InterfacesRetrieved{
-			IP:    *deviceIP,
+			IP:    ip,
 			Count: 0,
 			Error: err.Error(),
 		})
 	} else {
 		log.Send(InterfacesRetrieved{
-			IP:    *deviceIP,
+			IP:    ip,
 			Count: len(interfaces),
 		})
 	}
 
-	// Step 5: Get neighbors
-	log.Infof("Retrieving neighbors...")
-	neighbors, err := device.GetNeighbors()
-	if err != nil {
-		log.Warnf("Failed to get neighbors: %v", err)
-		log.Send(NeighborsRetrieved{
-			IP:    *deviceIP,
-			Count: 0,
-			Error: err.Error(),
-		})
+	return nil
+}
+
+// collectTables retrieves MAC/ARP tables, inventory and capacity (full profile).
+// Failures are reported but do not abort the crawl.
+func collectTables(log *eventstream.Handler, ip string, device netmodel.Device) {
+	// Step 6: Get forwarding tables and inventory
+	log.Infof("Retrieving MAC address table...")
+	if macs, err := device.GetMACTable(); err != nil {
+		log.Warnf("Failed to get MAC address table: %v", err)
+		log.Send(MACTableRetrieved{IP: ip, Error: err.Error()})
 	} else {
-		log.Send(NeighborsRetrieved{
-			IP:    *deviceIP,
-			Count: len(neighbors),
-		})
+		log.Send(MACTableRetrieved{IP: ip, Count: len(macs)})
 	}
 
-	// Step 6: Save device info to database
-	log.Infof("Saving to database...")
-	deviceInfo := device.GetDeviceInfo()
-	deviceInfo.RawOutputDir = deviceDir
+	log.Infof("Retrieving ARP table...")
+	if arps, err := device.GetARPTable(); err != nil {
+		log.Warnf("Failed to get ARP table: %v", err)
+		log.Send(ARPTableRetrieved{IP: ip, Error: err.Error()})
+	} else {
+		log.Send(ARPTableRetrieved{IP: ip, Count: len(arps)})
+	}
 
-	dbPath := filepath.Join(fuser.CurrentUser.DataDir, "devices")
-	db, err := dsjdb.NewJSDB(dbPath)
-	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+	log.Infof("Retrieving routing tables...")
+	if routes, err := device.GetRoutes(); err != nil {
+		log.Warnf("Failed to get routing tables: %v", err)
+		log.Send(RoutesRetrieved{IP: ip, Error: err.Error()})
+	} else {
+		log.Send(RoutesRetrieved{IP: ip, VRFs: device.GetDeviceInfo().VRFs, Count: len(routes)})
 	}
 
-	// Use device IP as the filename
-	deviceFile := fmt.Sprintf("%s.json", *deviceIP)
-	if err := db.Write(deviceFile, deviceInfo); err != nil {
-		return fmt.Errorf("failed to save device to database: %w", err)
+	log.Infof("Retrieving inventory...")
+	if items, err := device.GetInventory(); err != nil {
+		log.Warnf("Failed to get inventory: %v", err)
+		log.Send(InventoryRetrieved{IP: ip, Error: err.Error()})
+	} else {
+		log.Send(InventoryRetrieved{IP: ip, Count: len(items)})
 	}
 
-	log.Send(DeviceSaved{
-		IP:           *deviceIP,
-		DatabasePath: dbPath,
-		Filename:     deviceFile,
-	})
+	log.Infof("Retrieving DHCP snooping bindings...")
+	if bindings, err := device.GetDHCPBindings(); err != nil {
+		log.Warnf("Failed to get DHCP snooping bindings: %v", err)
+		log.Send(DHCPBindingsRetrieved{IP: ip, Error: err.Error()})
+	} else {
+		log.Send(DHCPBindingsRetrieved{IP: ip, Count: len(bindings)})
+	}
 
-	log.Send(DiscoveryCompleted{
-		IP:      *deviceIP,
-		Port:    *port,
-		Success: true,
-	})
+	log.Infof("Retrieving port, PoE and resource usage...")
+	if capacity, err := device.GetCapacity(); err != nil {
+		log.Warnf("Failed to get capacity: %v", err)
+		log.Send(CapacityRetrieved{IP: ip, Error: err.Error()})
+	} else {
+		log.Send(CapacityRetrieved{IP: ip, Ports: len(capacity.Ports), PoE: capacity.PoE != nil, Resources: len(capacity.Resources)})
+	}
+}
 
+// hostIndexPath returns the location of the host index (~/.fdot/hosts.json)
+func hostIndexPath() string {
+	return filepath.Join(fuser.CurrentUser.DataDir, "hosts.json")
+}
+
+// updateHosts merges this crawl's endpoint observations into the host index
+func updateHosts(ctx context.Context, log *eventstream.Handler, info *netmodel.DeviceInfo) error {
+	path := hostIndexPath()
+	hosts, err := netmodel.LoadHostIndex(path)
+	if err != nil {
+		return err
+	}
+
+	hosts.Observe(info, time.Now())
+
+	resolveCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	hosts.ResolveNames(resolveCtx, nil)
+	cancel()
+
+	if err := hosts.Save(); err != nil {
+		return err
+	}
+
+	log.Send(HostsUpdated{
+		IP:        info.IPAddress,
+		HostCount: len(hosts.Hosts()),
+		SavedTo:   path,
+	})
 	return nil
 }
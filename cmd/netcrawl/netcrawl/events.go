@@ -3,9 +3,19 @@ package netcrawl
 import "time"
 
 type DiscoveryStarted struct {
-	IP       string
-	Port     int
-	Username string
+	IP         string
+	Port       int
+	Username   string
+	Credential string // credmgr name of the credential that logged in
+}
+
+type CredentialAttempted struct {
+	IP         string
+	Credential string
+	Username   string
+	Attempt    int
+	Success    bool
+	Error      string
 }
 
 type ShowVersionRetrieved struct {
@@ -14,6 +24,13 @@ type ShowVersionRetrieved struct {
 	SavedTo      string
 }
 
+type LoginTextRetrieved struct {
+	IP           string
+	BannerLength int
+	MOTDLength   int
+	Error        string
+}
+
 type DeviceDetected struct {
 	IP       string
 	Platform string
@@ -44,8 +61,8 @@ type NeighborsRetrieved struct {
 
 type DeviceSaved struct {
 	IP           string
-	DatabasePath string
-	Filename     string
+	DatabasePath string // Store.Path(), empty for a MemoryStore
+	Key          string
 }
 
 type DiscoveryCompleted struct {
@@ -55,3 +72,60 @@ type DiscoveryCompleted struct {
 	ErrorMsg string
 	Duration time.Duration
 }
+
+type MACTableRetrieved struct {
+	IP    string
+	Count int
+	Error string
+}
+
+type ARPTableRetrieved struct {
+	IP    string
+	Count int
+	Error string
+}
+
+type InventoryRetrieved struct {
+	IP    string
+	Count int
+	Error string
+}
+
+type DHCPBindingsRetrieved struct {
+	IP    string
+	Count int
+	Error string
+}
+
+type CapacityRetrieved struct {
+	IP        string
+	Ports     int
+	PoE       bool
+	Resources int
+	Error     string
+}
+
+type ArtifactsUploaded struct {
+	Destination string
+	Device      string // empty for run-wide exports
+	Files       int
+	Error       string
+}
+
+type HostsUpdated struct {
+	IP        string
+	HostCount int
+	SavedTo   string
+}
+
+type RoutesRetrieved struct {
+	IP    string
+	VRFs  []string
+	Count int
+	Error string
+}
+
+type TargetsResolved struct {
+	Target  string
+	Devices []string
+}
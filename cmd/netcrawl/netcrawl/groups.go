@@ -0,0 +1,155 @@
+package netcrawl
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/nzions/fdot/pkg/fdh/fuser"
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
+)
+
+// GroupPrefix marks a device target as a group name ("@core")
+const GroupPrefix = "@"
+
+// Groups holds named device queries loaded from the groups file, one per line:
+//
+//	# comments and blank lines are ignored
+//	group core = model =~ "8320"
+//	group edge = not @core and platform = ProCurve
+//
+// Membership is evaluated against the device store at use time, so groups follow
+// the inventory as crawls discover or update devices.
+type Groups struct {
+	queries  map[string]string
+	matchers map[string]Matcher
+}
+
+// GroupsPath returns the default groups file (~/.fdot/groups.conf)
+func GroupsPath() string {
+	return filepath.Join(fuser.CurrentUser.DataDir, "groups.conf")
+}
+
+// LoadGroups reads and compiles the groups file at path; a missing file yields no groups
+func LoadGroups(path string) (*Groups, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ParseGroups(nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read groups file: %w", err)
+	}
+	defer f.Close()
+
+	queries := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rest, ok := strings.CutPrefix(line, "group ")
+		name, query, found := strings.Cut(rest, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !found || name == "" {
+			return nil, fmt.Errorf("%s:%d: expected \"group <name> = <query>\"", path, lineNo)
+		}
+		if _, dup := queries[name]; dup {
+			return nil, fmt.Errorf("%s:%d: group %q defined twice", path, lineNo, name)
+		}
+		queries[name] = strings.TrimSpace(query)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read groups file: %w", err)
+	}
+
+	groups, err := ParseGroups(queries)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return groups, nil
+}
+
+// ParseGroups compiles group queries by name, resolving @name references between them
+func ParseGroups(queries map[string]string) (*Groups, error) {
+	g := &Groups{queries: queries, matchers: make(map[string]Matcher)}
+
+	resolving := make(map[string]bool)
+	var compile func(name string) (Matcher, error)
+	compile = func(name string) (Matcher, error) {
+		if m, ok := g.matchers[name]; ok {
+			return m, nil
+		}
+		query, ok := g.queries[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown group %q", name)
+		}
+		if resolving[name] {
+			return nil, fmt.Errorf("group %q is part of a reference cycle", name)
+		}
+		resolving[name] = true
+		defer delete(resolving, name)
+
+		m, err := ParseQuery(query, compile)
+		if err != nil {
+			return nil, fmt.Errorf("group %s: %w", name, err)
+		}
+		g.matchers[name] = m
+		return m, nil
+	}
+
+	for _, name := range g.Names() {
+		if _, err := compile(name); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// Names returns the defined group names in sorted order
+func (g *Groups) Names() []string {
+	var names []string
+	for name := range g.queries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Query returns the expression defining a group
+func (g *Groups) Query(name string) (string, bool) {
+	query, ok := g.queries[name]
+	return query, ok
+}
+
+// Members returns the stored devices in a group, sorted by store key
+func (g *Groups) Members(store Store, name string) ([]*netmodel.DeviceInfo, error) {
+	m, ok := g.matchers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown group %q", name)
+	}
+	return store.Query(m)
+}
+
+// ResolveTargets expands a device target into device IPs: "@name" selects the members
+// of a group, anything else is a single device address
+func ResolveTargets(store Store, groups *Groups, target string) ([]string, error) {
+	name, isGroup := strings.CutPrefix(target, GroupPrefix)
+	if !isGroup {
+		return []string{target}, nil
+	}
+
+	members, err := groups.Members(store, name)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]string, 0, len(members))
+	for _, d := range members {
+		ips = append(ips, d.IPAddress)
+	}
+	return ips, nil
+}
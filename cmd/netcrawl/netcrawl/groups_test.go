@@ -0,0 +1,124 @@
+package netcrawl
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
+)
+
+func TestParseGroups(t *testing.T) {
+	g, err := ParseGroups(map[string]string{
+		"core":     `model = 8320`,
+		"prod":     `not hostname =~ "^lab"`,
+		"prodcore": `@core and @prod`,
+		"rest":     `not @prodcore`,
+	})
+	if err != nil {
+		t.Fatalf("ParseGroups failed: %v", err)
+	}
+	if got, want := g.Names(), []string{"core", "prod", "prodcore", "rest"}; !slices.Equal(got, want) {
+		t.Errorf("Names = %q, want %q", got, want)
+	}
+	if query, ok := g.Query("prodcore"); !ok || query != `@core and @prod` {
+		t.Errorf("Query(prodcore) = %q, %v", query, ok)
+	}
+
+	s := NewMemoryStore()
+	for _, d := range []*netmodel.DeviceInfo{queryCore, queryEdge, queryLab} {
+		if err := s.Put(d.IPAddress, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, want := range map[string][]string{
+		"core":     {queryCore.IPAddress, queryLab.IPAddress},
+		"prodcore": {queryCore.IPAddress},
+		"rest":     {queryEdge.IPAddress, queryLab.IPAddress},
+	} {
+		got, err := ResolveTargets(s, g, GroupPrefix+name)
+		if err != nil {
+			t.Fatalf("ResolveTargets(@%s) failed: %v", name, err)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Errorf("ResolveTargets(@%s) = %q, want %q", name, got, want)
+		}
+	}
+
+	if got, err := ResolveTargets(s, g, "10.0.0.99"); err != nil || !slices.Equal(got, []string{"10.0.0.99"}) {
+		t.Errorf("ResolveTargets of an address = %q, %v", got, err)
+	}
+	if _, err := ResolveTargets(s, g, "@missing"); err == nil {
+		t.Error("ResolveTargets(@missing) succeeded")
+	}
+}
+
+func TestParseGroupsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		queries map[string]string
+		want    string // part of the error
+	}{
+		{"self reference", map[string]string{"a": `@a`}, "reference cycle"},
+		{"cycle", map[string]string{"a": `@b or model = 8320`, "b": `@c`, "c": `not @a`}, "reference cycle"},
+		{"unknown group", map[string]string{"a": `@nope`}, `unknown group "nope"`},
+		{"bad query", map[string]string{"a": `model = 8320`, "b": `model ==`}, "group b"},
+		{"bad query behind a reference", map[string]string{"a": `@b`, "b": `color = red`}, "unknown field"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseGroups(tt.queries)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseGroups error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadGroups(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "groups.conf")
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	g, err := LoadGroups(write(`
+# site groups
+group core = model =~ "8320"
+
+group edge = not @core and platform = ProCurve
+`))
+	if err != nil {
+		t.Fatalf("LoadGroups failed: %v", err)
+	}
+	if got := g.Names(); !slices.Equal(got, []string{"core", "edge"}) {
+		t.Errorf("Names = %q, want [core edge]", got)
+	}
+	if query, _ := g.Query("core"); query != `model =~ "8320"` {
+		t.Errorf("Query(core) = %q", query)
+	}
+
+	g, err = LoadGroups(filepath.Join(dir, "missing.conf"))
+	if err != nil || len(g.Names()) != 0 {
+		t.Errorf("LoadGroups of a missing file = %v, %v; want no groups", g, err)
+	}
+
+	for content, want := range map[string]string{
+		"group core = model = 8320\ngroup core = model = 6300\n": ":2: group \"core\" defined twice",
+		"core = model = 8320\n":                                  ":1: expected",
+		"group = model = 8320\n":                                 ":1: expected",
+		"# ok\ngroup core model 8320\n":                          ":2: expected",
+		"group a = @b\ngroup b = @a\n":                           "reference cycle",
+	} {
+		if _, err := LoadGroups(write(content)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadGroups(%q) error = %v, want %q", content, err, want)
+		}
+	}
+}
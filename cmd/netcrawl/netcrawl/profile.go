@@ -0,0 +1,65 @@
+package netcrawl
+
+import (
+	"fmt"
+
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
+)
+
+// Profile controls which data sets a crawl collects
+type Profile string
+
+const (
+	// ProfileLite collects show version and neighbors only; cheap enough for frequent scheduled runs
+	ProfileLite Profile = "lite"
+	// ProfileStandard adds the running config and interfaces (the default)
+	ProfileStandard Profile = "standard"
+	// ProfileFull adds MAC/ARP and routing tables, DHCP snooping bindings, hardware inventory and capacity
+	ProfileFull Profile = "full"
+)
+
+// Profiles lists the valid crawl profiles, lightest first
+var Profiles = []Profile{ProfileLite, ProfileStandard, ProfileFull}
+
+// ParseProfile validates a profile name; an empty name selects ProfileStandard
+func ParseProfile(name string) (Profile, error) {
+	if name == "" {
+		return ProfileStandard, nil
+	}
+	for _, p := range Profiles {
+		if Profile(name) == p {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown profile %q (want lite, standard or full)", name)
+}
+
+// collectsConfig reports whether the running config and interfaces are collected
+func (p Profile) collectsConfig() bool {
+	return p == ProfileStandard || p == ProfileFull
+}
+
+// collectsTables reports whether MAC/ARP/routing tables and inventory are collected
+func (p Profile) collectsTables() bool {
+	return p == ProfileFull
+}
+
+// keepUncollected copies data sets this profile does not collect from the
+// previously stored record, so a lite run does not erase what a full run found
+func (p Profile) keepUncollected(info, prev *netmodel.DeviceInfo) {
+	if !p.collectsConfig() {
+		info.Interfaces = prev.Interfaces
+	}
+	if !p.collectsTables() {
+		info.MACTable = prev.MACTable
+		info.ARPTable = prev.ARPTable
+		info.VRFs = prev.VRFs
+		info.Routes = prev.Routes
+		info.Inventory = prev.Inventory
+		info.DHCPBindings = prev.DHCPBindings
+		info.Capacity = prev.Capacity
+	}
+	if !prev.DiscoveredAt.IsZero() {
+		info.DiscoveredAt = prev.DiscoveredAt
+	}
+}
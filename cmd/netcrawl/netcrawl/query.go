@@ -0,0 +1,236 @@
+package netcrawl
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
+)
+
+// Query fields and the DeviceInfo values they match
+var queryFields = map[string]func(*netmodel.DeviceInfo) []string{
+	"hostname": func(d *netmodel.DeviceInfo) []string { return []string{d.Hostname} },
+	"ip":       func(d *netmodel.DeviceInfo) []string { return []string{d.IPAddress} },
+	"platform": func(d *netmodel.DeviceInfo) []string { return []string{d.Platform} },
+	"os":       func(d *netmodel.DeviceInfo) []string { return []string{d.OSVersion} },
+	"model":    func(d *netmodel.DeviceInfo) []string { return []string{d.Model} },
+	"serial":   func(d *netmodel.DeviceInfo) []string { return []string{d.Serial} },
+	"banner":   func(d *netmodel.DeviceInfo) []string { return []string{d.Banner, d.MOTD} },
+	"vrf":      func(d *netmodel.DeviceInfo) []string { return d.VRFs },
+	"neighbor": func(d *netmodel.DeviceInfo) []string {
+		var names []string
+		for _, n := range d.Neighbors {
+			names = append(names, n.RemoteHostname)
+		}
+		return names
+	},
+}
+
+// QueryFields returns the field names usable in query expressions
+func QueryFields() []string {
+	var names []string
+	for name := range queryFields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Matcher reports whether a stored device matches a query
+type Matcher func(*netmodel.DeviceInfo) bool
+
+// ParseQuery compiles a device query expression such as
+//
+//	model =~ "8320" and not hostname = lab-core
+//
+// Comparisons are <field> <op> <value> with op one of = and != (case-insensitive
+// equality) or =~ and !~ (regular expression); multi-valued fields (vrf, neighbor,
+// banner) match if any value does. Comparisons combine with not, and, or and
+// parentheses. @name refers to another group and is resolved through lookup;
+// lookup may be nil when group references are not allowed.
+func ParseQuery(expr string, lookup func(name string) (Matcher, error)) (Matcher, error) {
+	tokens, err := tokenizeQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens, lookup: lookup}
+	m, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %q: %w", expr, err)
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("invalid query %q: unexpected %q", expr, p.tokens[p.pos].text)
+	}
+	return m, nil
+}
+
+// queryToken is one lexical element of a query expression
+type queryToken struct {
+	text   string
+	quoted bool // string literal; never a keyword or operator
+}
+
+// tokenizeQuery splits expr into words, quoted strings, operators and parentheses
+func tokenizeQuery(expr string) ([]queryToken, error) {
+	var tokens []queryToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, queryToken{text: string(c)})
+			i++
+		case c == '=' || c == '!':
+			op := string(c)
+			if i+1 < len(expr) && (expr[i+1] == '=' || expr[i+1] == '~') {
+				op += string(expr[i+1])
+			}
+			if op == "!" || op == "==" {
+				return nil, fmt.Errorf("invalid operator %q in query %q", op, expr)
+			}
+			tokens = append(tokens, queryToken{text: op})
+			i += len(op)
+		case c == '"':
+			end := strings.IndexByte(expr[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in query %q", expr)
+			}
+			tokens = append(tokens, queryToken{text: expr[i+1 : i+1+end], quoted: true})
+			i += end + 2
+		default:
+			start := i
+			for i < len(expr) && !unicode.IsSpace(rune(expr[i])) && !strings.ContainsRune("()=!\"", rune(expr[i])) {
+				i++
+			}
+			tokens = append(tokens, queryToken{text: expr[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+// queryParser is a recursive-descent parser; not binds tighter than and, and than or
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	lookup func(name string) (Matcher, error)
+}
+
+// peek returns the next unquoted token text, or "" at the end
+func (p *queryParser) peek() string {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].quoted {
+		return ""
+	}
+	return p.tokens[p.pos].text
+}
+
+// keyword consumes the next token if it is the given keyword
+func (p *queryParser) keyword(word string) bool {
+	if strings.EqualFold(p.peek(), word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) parseOr() (Matcher, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(d *netmodel.DeviceInfo) bool { return l(d) || right(d) }
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (Matcher, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(d *netmodel.DeviceInfo) bool { return l(d) && right(d) }
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseNot() (Matcher, error) {
+	if p.keyword("not") {
+		m, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(d *netmodel.DeviceInfo) bool { return !m(d) }, nil
+	}
+	return p.parseTerm()
+}
+
+// parseTerm parses a parenthesized expression, a group reference or a comparison
+func (p *queryParser) parseTerm() (Matcher, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	switch {
+	case !tok.quoted && tok.text == "(":
+		m, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return m, nil
+	case !tok.quoted && strings.HasPrefix(tok.text, "@"):
+		if p.lookup == nil {
+			return nil, fmt.Errorf("group reference %s not allowed here", tok.text)
+		}
+		return p.lookup(tok.text[1:])
+	}
+
+	field, ok := queryFields[strings.ToLower(tok.text)]
+	if tok.quoted || !ok {
+		return nil, fmt.Errorf("unknown field %q (want %s)", tok.text, strings.Join(QueryFields(), ", "))
+	}
+	if p.pos+1 >= len(p.tokens) {
+		return nil, fmt.Errorf("incomplete comparison on %s", tok.text)
+	}
+	op, value := p.tokens[p.pos], p.tokens[p.pos+1]
+	p.pos += 2
+
+	var match func(string) bool
+	switch {
+	case !op.quoted && (op.text == "=" || op.text == "!="):
+		match = func(s string) bool { return strings.EqualFold(s, value.text) }
+	case !op.quoted && (op.text == "=~" || op.text == "!~"):
+		re, err := regexp.Compile(value.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", value.text, err)
+		}
+		match = re.MatchString
+	default:
+		return nil, fmt.Errorf("expected =, !=, =~ or !~ after %s, got %q", tok.text, op.text)
+	}
+
+	negate := op.text[0] == '!'
+	return func(d *netmodel.DeviceInfo) bool {
+		return slices.ContainsFunc(field(d), match) != negate
+	}, nil
+}
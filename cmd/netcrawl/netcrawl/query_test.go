@@ -0,0 +1,147 @@
+package netcrawl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
+)
+
+var (
+	queryCore = &netmodel.DeviceInfo{
+		Hostname:  "nyc-core1",
+		IPAddress: "10.0.0.1",
+		Platform:  "ArubaOS-CX",
+		Model:     "8320",
+		VRFs:      []string{"default", "mgmt"},
+		Neighbors: []netmodel.Neighbor{{RemoteHostname: "nyc-edge1"}, {RemoteHostname: "nyc-edge2"}},
+		Banner:    "Authorized use only",
+	}
+	queryEdge = &netmodel.DeviceInfo{
+		Hostname:  "nyc-edge1",
+		IPAddress: "10.0.0.11",
+		Platform:  "ProCurve",
+		Model:     "2930F",
+		VRFs:      []string{"default"},
+		Neighbors: []netmodel.Neighbor{{RemoteHostname: "nyc-core1"}},
+		MOTD:      "Lab switch",
+	}
+	queryLab = &netmodel.DeviceInfo{
+		Hostname:  "lab-core",
+		IPAddress: "10.9.0.1",
+		Platform:  "ArubaOS-CX",
+		Model:     "8320",
+	}
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []bool // matches for queryCore, queryEdge and queryLab
+	}{
+		{`hostname = nyc-core1`, []bool{true, false, false}},
+		{`HOSTNAME = NYC-CORE1`, []bool{true, false, false}},
+		{`hostname != nyc-core1`, []bool{false, true, true}},
+		{`model =~ "^83"`, []bool{true, false, true}},
+		{`model !~ "^83"`, []bool{false, true, false}},
+
+		// Quoted values may hold spaces, operators and keywords
+		{`banner = "Authorized use only"`, []bool{true, false, false}},
+		{`platform = "and"`, []bool{false, false, false}},
+		{`hostname =~ "^(nyc|lab)-core"`, []bool{true, false, true}},
+		{`hostname="nyc-edge1"`, []bool{false, true, false}},
+
+		// Multi-valued fields: = and =~ match if any value does, != and !~ if none does
+		{`vrf = mgmt`, []bool{true, false, false}},
+		{`vrf != mgmt`, []bool{false, true, true}},
+		{`vrf != default`, []bool{false, false, true}},
+		{`neighbor =~ edge`, []bool{true, false, false}},
+		{`neighbor !~ edge`, []bool{false, true, true}},
+		{`banner =~ "(?i)lab"`, []bool{false, true, false}},
+		{`banner !~ "(?i)lab"`, []bool{true, false, true}},
+
+		// not binds tighter than and, and tighter than or
+		{`not model = 8320 and platform = ProCurve`, []bool{false, true, false}},
+		{`not (model = 8320 and hostname = lab-core)`, []bool{true, true, false}},
+		{`hostname = lab-core or model = 8320 and vrf = mgmt`, []bool{true, false, true}},
+		{`(hostname = lab-core or model = 8320) and vrf = mgmt`, []bool{true, false, false}},
+		{`model = 2930F or hostname = nyc-core1 and not vrf = mgmt`, []bool{false, true, false}},
+		{`not not hostname = nyc-edge1`, []bool{false, true, false}},
+		{`hostname = nyc-core1 OR hostname = nyc-edge1`, []bool{true, true, false}},
+	}
+	devices := []*netmodel.DeviceInfo{queryCore, queryEdge, queryLab}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			m, err := ParseQuery(tt.expr, nil)
+			if err != nil {
+				t.Fatalf("ParseQuery failed: %v", err)
+			}
+			for i, d := range devices {
+				if got := m(d); got != tt.want[i] {
+					t.Errorf("match %s = %v, want %v", d.Hostname, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseQueryMalformed(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`hostname`,
+		`hostname =`,
+		`hostname == core`,
+		`hostname ! core`,
+		`hostname ~ core`,
+		`color = red`,
+		`"hostname" = core`,
+		`hostname "=" core`,
+		`hostname = "core`,
+		`model =~ "("`,
+		`(hostname = core`,
+		`hostname = core)`,
+		`hostname = core and`,
+		`or hostname = core`,
+		`not`,
+		`hostname = core model = 8320`,
+		`@core`,
+	} {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := ParseQuery(expr, nil); err == nil {
+				t.Errorf("ParseQuery(%q) succeeded, want an error", expr)
+			}
+		})
+	}
+}
+
+func TestParseQueryGroupReference(t *testing.T) {
+	isCore := func(d *netmodel.DeviceInfo) bool { return d.Model == "8320" }
+	errUnknown := errors.New("unknown group")
+	var looked []string
+	lookup := func(name string) (Matcher, error) {
+		looked = append(looked, name)
+		if name == "core" {
+			return isCore, nil
+		}
+		return nil, errUnknown
+	}
+
+	m, err := ParseQuery(`@core and not hostname =~ "^lab"`, lookup)
+	if err != nil {
+		t.Fatalf("ParseQuery failed: %v", err)
+	}
+	if !m(queryCore) || m(queryEdge) || m(queryLab) {
+		t.Errorf("matches = %v %v %v, want only %s", m(queryCore), m(queryEdge), m(queryLab), queryCore.Hostname)
+	}
+	if len(looked) != 1 || looked[0] != "core" {
+		t.Errorf("looked up %q, want [core]", looked)
+	}
+
+	if _, err := ParseQuery(`@edge or model = 8320`, lookup); !errors.Is(err, errUnknown) {
+		t.Errorf("unknown group error = %v, want the lookup's error", err)
+	}
+	// A quoted @name is a value, not a reference
+	if _, err := ParseQuery(`hostname = "@core"`, nil); err != nil {
+		t.Errorf("quoted @name failed: %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package netcrawl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/nzions/dsjdb"
+	"github.com/nzions/fdot/pkg/fdh/fuser"
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
+)
+
+// ErrDeviceNotFound is returned when a device is not present in the store
+var ErrDeviceNotFound = errors.New("device not found in store")
+
+// Store persists discovered device information
+// Keys are device identifiers (currently the device IP address)
+type Store interface {
+	// Put saves (or replaces) the device information for key
+	Put(key string, info *netmodel.DeviceInfo) error
+	// Get returns the device information for key, or ErrDeviceNotFound
+	Get(key string) (*netmodel.DeviceInfo, error)
+	// List returns all stored keys in sorted order
+	List() ([]string, error)
+	// Query returns all stored devices for which match returns true
+	Query(match func(*netmodel.DeviceInfo) bool) ([]*netmodel.DeviceInfo, error)
+	// Path returns where the store keeps its data, or "" for stores held in memory
+	Path() string
+}
+
+// queryStore implements Query on top of List and Get
+func queryStore(s Store, match func(*netmodel.DeviceInfo) bool) ([]*netmodel.DeviceInfo, error) {
+	keys, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*netmodel.DeviceInfo
+	for _, key := range keys {
+		info, err := s.Get(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load device %s: %w", key, err)
+		}
+		if match == nil || match(info) {
+			results = append(results, info)
+		}
+	}
+	return results, nil
+}
+
+// OpenDefaultStore opens the dsjdb store under the data directory (~/.fdot/devices)
+func OpenDefaultStore() (Store, error) {
+	return NewDSJDBStore(filepath.Join(fuser.CurrentUser.DataDir, "devices"))
+}
+
+// dsjdbStore is the default Store, keeping one JSON document per device in a dsjdb directory
+type dsjdbStore struct {
+	path string
+	db   *dsjdb.JSDB
+}
+
+// NewDSJDBStore opens (or creates) a dsjdb-backed device store at path
+func NewDSJDBStore(path string) (Store, error) {
+	db, err := dsjdb.NewJSDB(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return &dsjdbStore{path: path, db: db}, nil
+}
+
+// filename returns the dsjdb document name for a key
+func (s *dsjdbStore) filename(key string) string {
+	return key + ".json"
+}
+
+// Put writes the device document through dsjdb
+func (s *dsjdbStore) Put(key string, info *netmodel.DeviceInfo) error {
+	return s.db.Write(s.filename(key), info)
+}
+
+// Get reads the device document through dsjdb
+func (s *dsjdbStore) Get(key string) (*netmodel.DeviceInfo, error) {
+	var info netmodel.DeviceInfo
+	if err := s.db.Read(s.filename(key), &info); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%s: %w", key, ErrDeviceNotFound)
+		}
+		return nil, fmt.Errorf("failed to read device %s: %w", key, err)
+	}
+	return &info, nil
+}
+
+// List returns the keys of all device documents in the dsjdb directory
+func (s *dsjdbStore) List() ([]string, error) {
+	entries, err := os.ReadDir(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	var keys []string
+	for _, entry := range entries {
+		if key, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !entry.IsDir() {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// Query scans all device documents
+func (s *dsjdbStore) Query(match func(*netmodel.DeviceInfo) bool) ([]*netmodel.DeviceInfo, error) {
+	return queryStore(s, match)
+}
+
+// Path returns the database directory
+func (s *dsjdbStore) Path() string {
+	return s.path
+}
+
+// String returns the database path
+func (s *dsjdbStore) String() string {
+	return s.path
+}
+
+// MemoryStore is an in-memory Store for unit tests and dry runs. Devices are kept
+// as JSON, like the dsjdb documents, so callers never share slices or maps with it.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	devices map[string][]byte
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{devices: make(map[string][]byte)}
+}
+
+// Put saves a copy of the device information
+func (s *MemoryStore) Put(key string, info *netmodel.DeviceInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("failed to encode device %s: %w", key, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.devices[key] = data
+	return nil
+}
+
+// Get returns a copy of the stored device information
+func (s *MemoryStore) Get(key string) (*netmodel.DeviceInfo, error) {
+	s.mu.RLock()
+	data, ok := s.devices[key]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", key, ErrDeviceNotFound)
+	}
+
+	var info netmodel.DeviceInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to decode device %s: %w", key, err)
+	}
+	return &info, nil
+}
+
+// List returns all stored keys in sorted order
+func (s *MemoryStore) List() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]string, 0, len(s.devices))
+	for key := range s.devices {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// Query returns all stored devices for which match returns true
+func (s *MemoryStore) Query(match func(*netmodel.DeviceInfo) bool) ([]*netmodel.DeviceInfo, error) {
+	return queryStore(s, match)
+}
+
+// Path is empty: the devices are not kept on disk
+func (s *MemoryStore) Path() string {
+	return ""
+}
+
+// String identifies the store in logs
+func (s *MemoryStore) String() string {
+	return "memory"
+}
@@ -0,0 +1,109 @@
+package netcrawl
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
+)
+
+func TestMemoryStorePutGet(t *testing.T) {
+	s := NewMemoryStore()
+	if _, err := s.Get("10.0.0.1"); !errors.Is(err, ErrDeviceNotFound) {
+		t.Fatalf("Get of missing device error = %v, want ErrDeviceNotFound", err)
+	}
+
+	info := &netmodel.DeviceInfo{
+		Hostname:   "core1",
+		VRFs:       []string{"mgmt"},
+		Interfaces: []netmodel.Interface{{Name: "Ethernet1"}},
+	}
+	if err := s.Put("10.0.0.1", info); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	got, err := s.Get("10.0.0.1")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Hostname != "core1" || !slices.Equal(got.VRFs, []string{"mgmt"}) || len(got.Interfaces) != 1 {
+		t.Errorf("Get = %+v, want the stored device", got)
+	}
+	if s.Path() != "" {
+		t.Errorf("Path = %q, want empty", s.Path())
+	}
+}
+
+func TestMemoryStoreCopies(t *testing.T) {
+	s := NewMemoryStore()
+	info := &netmodel.DeviceInfo{
+		Hostname:   "core1",
+		VRFs:       []string{"mgmt"},
+		Interfaces: []netmodel.Interface{{Name: "Ethernet1"}},
+	}
+	if err := s.Put("10.0.0.1", info); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the caller's value after Put leaves the stored device alone
+	info.VRFs[0] = "changed"
+	info.Interfaces[0].Name = "changed"
+
+	got, err := s.Get("10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.VRFs[0] != "mgmt" || got.Interfaces[0].Name != "Ethernet1" {
+		t.Errorf("stored device shares memory with the value passed to Put: %+v", got)
+	}
+
+	// So does changing a value returned by Get
+	got.VRFs[0] = "changed"
+	got.Interfaces = append(got.Interfaces[:0], netmodel.Interface{Name: "changed"})
+	again, err := s.Get("10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.VRFs[0] != "mgmt" || again.Interfaces[0].Name != "Ethernet1" {
+		t.Errorf("stored device shares memory with a value returned by Get: %+v", again)
+	}
+}
+
+func TestMemoryStoreListQuery(t *testing.T) {
+	s := NewMemoryStore()
+	for _, dev := range []struct{ ip, platform string }{
+		{"10.0.0.2", "eos"},
+		{"10.0.0.1", "nxos"},
+		{"10.0.0.3", "eos"},
+	} {
+		if err := s.Put(dev.ip, &netmodel.DeviceInfo{IPAddress: dev.ip, Platform: dev.platform}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := s.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if want := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}; !slices.Equal(keys, want) {
+		t.Errorf("List = %v, want %v", keys, want)
+	}
+
+	eos, err := s.Query(func(info *netmodel.DeviceInfo) bool { return info.Platform == "eos" })
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var ips []string
+	for _, info := range eos {
+		ips = append(ips, info.IPAddress)
+	}
+	if want := []string{"10.0.0.2", "10.0.0.3"}; !slices.Equal(ips, want) {
+		t.Errorf("Query = %v, want %v", ips, want)
+	}
+
+	all, err := s.Query(nil)
+	if err != nil || len(all) != 3 {
+		t.Errorf("Query(nil) = %d devices, %v; want 3", len(all), err)
+	}
+}
@@ -0,0 +1,361 @@
+package netcrawl
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/nzions/fdot/pkg/fdh/fuser"
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
+)
+
+// Artifacts are uploaded under lifecycle-friendly keys: the artifact kind comes first so
+// buckets can expire runs, exports and reports with separate prefix rules, then the date
+// so listings sort chronologically:
+//
+//	<prefix>/runs/2026/10/14/20261014T020000Z/10.1.1.1/show_version.txt
+//	<prefix>/exports/2026/10/14/20261014T020000Z/hosts.json
+//	<prefix>/reports/2026/10/14/20261014T020000Z/capacity.txt
+const (
+	ArtifactRuns    = "runs"
+	ArtifactExports = "exports"
+	ArtifactReports = "reports"
+)
+
+// ArtifactStore receives uploaded artifacts
+type ArtifactStore interface {
+	// Put stores data under key (relative to the store's URL)
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// UploadConfig selects where artifacts are uploaded
+type UploadConfig struct {
+	// URL is the destination:
+	//   s3://bucket/prefix       AWS S3 (or an S3-compatible store with ?endpoint=https://host)
+	//   gs://bucket/prefix       Google Cloud Storage through its S3-compatible XML API
+	//   azblob://account/container/prefix  Azure Blob Storage
+	// S3 URLs may set ?region=; otherwise the AWS configuration decides.
+	URL string
+
+	// Credential is the credmgr credential to authenticate with:
+	//   s3, gs: a user credential holding the access key ID and secret (HMAC key for gs);
+	//           for s3 the standard AWS credential chain is used when empty
+	//   azblob: a key holding a SAS token
+	Credential string
+}
+
+// Uploader copies the artifacts of one run to an ArtifactStore
+type Uploader struct {
+	store  ArtifactStore
+	dest   string
+	prefix string
+	runID  string
+	date   string
+}
+
+// NewUploader opens the destination in cfg for a run starting now
+func NewUploader(ctx context.Context, cfg UploadConfig) (*Uploader, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid upload URL %q", cfg.URL)
+	}
+	prefix := strings.Trim(u.Path, "/")
+
+	var store ArtifactStore
+	switch u.Scheme {
+	case "s3":
+		store, err = newS3Store(ctx, u, cfg.Credential)
+	case "gs":
+		store, err = newGCSStore(u.Host, cfg.Credential)
+	case "azblob":
+		container, rest, _ := strings.Cut(prefix, "/")
+		if container == "" {
+			return nil, fmt.Errorf("invalid upload URL %q: want azblob://account/container/prefix", cfg.URL)
+		}
+		prefix = rest
+		store, err = newAzureStore(u.Host, container, cfg.Credential)
+	default:
+		return nil, fmt.Errorf("unsupported upload URL scheme %q (want s3, gs or azblob)", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	return &Uploader{
+		store:  store,
+		dest:   u.Scheme + "://" + u.Host + u.Path,
+		prefix: prefix,
+		runID:  now.Format("20060102T150405Z"),
+		date:   now.Format("2006/01/02"),
+	}, nil
+}
+
+func (u *Uploader) String() string {
+	return u.dest
+}
+
+// key returns the object key for name within kind
+func (u *Uploader) key(kind, name string) string {
+	return path.Join(u.prefix, kind, u.date, u.runID, name)
+}
+
+// Put uploads one artifact of the given kind (ArtifactRuns, ArtifactExports, ArtifactReports)
+func (u *Uploader) Put(ctx context.Context, kind, name string, data []byte) error {
+	if err := u.store.Put(ctx, u.key(kind, name), data); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", name, err)
+	}
+	return nil
+}
+
+// UploadDevice uploads the raw outputs collected from a device and its stored record
+// (device.json) under the device's store key, returning the number of files uploaded
+func (u *Uploader) UploadDevice(ctx context.Context, key string, info *netmodel.DeviceInfo) (int, error) {
+	record, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal device %s: %w", key, err)
+	}
+	if err := u.Put(ctx, ArtifactRuns, path.Join(key, "device.json"), record); err != nil {
+		return 0, err
+	}
+	files := 1
+
+	dir := info.RawOutputDir
+	if dir == "" {
+		return files, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return files, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return files, err
+		}
+		if err := u.Put(ctx, ArtifactRuns, path.Join(key, e.Name()), data); err != nil {
+			return files, err
+		}
+		files++
+	}
+	return files, nil
+}
+
+// UploadExports uploads the run-wide exports that exist (the host index), returning
+// the number of files uploaded
+func (u *Uploader) UploadExports(ctx context.Context) (int, error) {
+	data, err := os.ReadFile(hostIndexPath())
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := u.Put(ctx, ArtifactExports, "hosts.json", data); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// httpStore uploads with one signed PUT request per object
+type httpStore struct {
+	client *http.Client
+	// request builds the signed request for key
+	request func(ctx context.Context, key string, data []byte) (*http.Request, error)
+	// secrets are removed from error text (a SAS token and its signature)
+	secrets []string
+}
+
+func (s *httpStore) Put(ctx context.Context, key string, data []byte) error {
+	req, err := s.request(ctx, key, data)
+	if err != nil {
+		return s.redact(err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// The URL may carry a SAS token; report only the underlying error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return s.redact(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return s.redact(fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body)))
+	}
+	return nil
+}
+
+// redact replaces the store's secrets in err's text, which may quote the request
+// URL (some servers echo it in error bodies)
+func (s *httpStore) redact(err error) error {
+	text := err.Error()
+	for _, secret := range s.secrets {
+		if secret != "" {
+			text = strings.ReplaceAll(text, secret, "<redacted>")
+		}
+	}
+	if text == err.Error() {
+		return err
+	}
+	return errors.New(text)
+}
+
+// uploadTimeout bounds each object upload
+const uploadTimeout = 2 * time.Minute
+
+// escapeKey escapes each segment of an object key for use in a URL path. Everything
+// but RFC 3986 unreserved characters is escaped, as S3 does when it checks the
+// signed path, so keys holding ':' (IPv6 device addresses) or '+' still verify.
+func escapeKey(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		switch c := key[i]; {
+		case c == '/' || c == '-' || c == '.' || c == '_' || c == '~',
+			'0' <= c && c <= '9', 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// credentialPair loads an access key ID and secret stored as a credmgr user credential
+func credentialPair(name string) (aws.Credentials, error) {
+	cred, err := fuser.CurrentUser.CredManager.ReadUserCred(name)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("loading upload credential %q: %w", name, err)
+	}
+	return aws.Credentials{AccessKeyID: cred.Username(), SecretAccessKey: cred.Password(), Source: "credmgr"}, nil
+}
+
+// newSigV4Store returns a store that PUTs objects to endpoint/key, signed with AWS SigV4
+func newSigV4Store(endpoint, region string, creds aws.CredentialsProvider) *httpStore {
+	signer := v4.NewSigner(func(o *v4.SignerOptions) {
+		o.DisableURIPathEscaping = true // S3 signs the path as sent
+	})
+	return &httpStore{
+		client: &http.Client{Timeout: uploadTimeout},
+		request: func(ctx context.Context, key string, data []byte) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint+"/"+escapeKey(key), bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			sum := sha256.Sum256(data)
+			payloadHash := hex.EncodeToString(sum[:])
+			req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+			c, err := creds.Retrieve(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load upload credentials: %w", err)
+			}
+			if err := signer.SignHTTP(ctx, c, req, payloadHash, "s3", region, time.Now()); err != nil {
+				return nil, fmt.Errorf("failed to sign upload: %w", err)
+			}
+			return req, nil
+		},
+	}
+}
+
+// newS3Store uploads to an S3 bucket, or to an S3-compatible store given ?endpoint=
+func newS3Store(ctx context.Context, u *url.URL, credential string) (ArtifactStore, error) {
+	var opts []func(*config.LoadOptions) error
+	if region := u.Query().Get("region"); region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	if awsCfg.Region == "" {
+		return nil, fmt.Errorf("no AWS region configured; add ?region= to the upload URL")
+	}
+
+	creds := awsCfg.Credentials
+	if credential != "" {
+		pair, err := credentialPair(credential)
+		if err != nil {
+			return nil, err
+		}
+		creds = aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) { return pair, nil })
+	}
+	if creds == nil {
+		return nil, fmt.Errorf("no AWS credentials available for upload")
+	}
+
+	endpoint := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", u.Host, awsCfg.Region)
+	if custom := u.Query().Get("endpoint"); custom != "" {
+		endpoint = strings.TrimRight(custom, "/") + "/" + u.Host // path-style for S3-compatible stores
+	}
+	return newSigV4Store(endpoint, awsCfg.Region, creds), nil
+}
+
+// newGCSStore uploads to a GCS bucket using an HMAC key on the S3-compatible XML API
+func newGCSStore(bucket, credential string) (ArtifactStore, error) {
+	if credential == "" {
+		return nil, fmt.Errorf("gs:// uploads need -upload-cred naming a credmgr credential with a GCS HMAC key")
+	}
+	pair, err := credentialPair(credential)
+	if err != nil {
+		return nil, err
+	}
+	creds := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) { return pair, nil })
+	return newSigV4Store("https://storage.googleapis.com/"+bucket, "auto", creds), nil
+}
+
+// newAzureStore uploads block blobs to an Azure Storage container using a SAS token
+func newAzureStore(account, container, credential string) (ArtifactStore, error) {
+	if credential == "" {
+		return nil, fmt.Errorf("azblob:// uploads need -upload-cred naming a credmgr key holding a SAS token")
+	}
+	sas, err := fuser.CurrentUser.CredManager.ReadKey(credential)
+	if err != nil {
+		return nil, fmt.Errorf("loading upload credential %q: %w", credential, err)
+	}
+	endpoint := fmt.Sprintf("https://%s.blob.core.windows.net/%s", account, container)
+	return newSASStore(endpoint, sas), nil
+}
+
+// newSASStore returns a store that PUTs block blobs to endpoint/key, authorized by
+// the SAS token in the query string
+func newSASStore(endpoint, sas string) *httpStore {
+	sas = strings.TrimPrefix(strings.TrimSpace(sas), "?")
+	secrets := []string{sas}
+	if q, err := url.ParseQuery(sas); err == nil && q.Get("sig") != "" {
+		secrets = append(secrets, q.Get("sig"), url.QueryEscape(q.Get("sig")))
+	}
+	return &httpStore{
+		client: &http.Client{Timeout: uploadTimeout},
+		request: func(ctx context.Context, key string, data []byte) (*http.Request, error) {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint+"/"+escapeKey(key)+"?"+sas, bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("x-ms-blob-type", "BlockBlob")
+			return req, nil
+		},
+		secrets: secrets,
+	}
+}
@@ -0,0 +1,444 @@
+package netcrawl
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+	"github.com/nzions/fdot/pkg/fdh/fuser"
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
+)
+
+// objectServer stands in for a bucket: it records every PUT and answers with status
+type objectServer struct {
+	*httptest.Server
+	status int
+	reply  func(r *http.Request) string // response body; the request URL when nil
+
+	mu   sync.Mutex
+	puts []*http.Request
+	data [][]byte
+}
+
+func startObjectServer(t *testing.T, status int) *objectServer {
+	t.Helper()
+	s := &objectServer{status: status}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.puts = append(s.puts, r)
+		s.data = append(s.data, data)
+		s.mu.Unlock()
+		w.WriteHeader(s.status)
+		if s.status/100 != 2 {
+			if s.reply != nil {
+				io.WriteString(w, s.reply(r))
+			} else {
+				io.WriteString(w, "<Error><Code>AuthenticationFailed</Code><URL>"+r.URL.String()+"</URL></Error>")
+			}
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// last returns the most recent request and its body
+func (s *objectServer) last(t *testing.T) (*http.Request, []byte) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.puts) == 0 {
+		t.Fatal("no request reached the server")
+	}
+	return s.puts[len(s.puts)-1], s.data[len(s.data)-1]
+}
+
+// useMemoryCredentials points fuser's credential manager at an empty in-memory
+// store for the test
+func useMemoryCredentials(t *testing.T) credmgr.CredManager {
+	t.Helper()
+	saved := fuser.CurrentUser.CredManager
+	t.Cleanup(func() { fuser.CurrentUser.CredManager = saved })
+	cm := credmgr.NewMemory()
+	fuser.CurrentUser.CredManager = cm
+	return cm
+}
+
+// useAWSChain sets the AWS credential chain to the given environment keys, with
+// no shared config and no instance metadata
+func useAWSChain(t *testing.T, accessKey, secret string) {
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", accessKey)
+	t.Setenv("AWS_SECRET_ACCESS_KEY", secret)
+}
+
+// s3URIEncode encodes a path the way S3 does when it verifies a signature: every
+// byte but the RFC 3986 unreserved characters and '/' is percent-encoded
+func s3URIEncode(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c == '/' || c == '-' || c == '.' || c == '_' || c == '~' ||
+			'0' <= c && c <= '9' || 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// checkSigV4 verifies r's SigV4 signature the way S3 does, rebuilding the canonical
+// path from the decoded object key, and returns the access key ID it was signed with
+func checkSigV4(t *testing.T, r *http.Request, body []byte, secret, region string) string {
+	t.Helper()
+	auth := r.Header.Get("Authorization")
+	fields := map[string]string{}
+	for _, f := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ", ") {
+		k, v, _ := strings.Cut(f, "=")
+		fields[k] = v
+	}
+	credential := strings.Split(fields["Credential"], "/")
+	if len(credential) != 5 || credential[2] != region || credential[3] != "s3" {
+		t.Fatalf("Authorization = %q, want a SigV4 s3 signature for %s", auth, region)
+	}
+
+	sum := sha256.Sum256(body)
+	if got := r.Header.Get("X-Amz-Content-Sha256"); got != hex.EncodeToString(sum[:]) {
+		t.Errorf("X-Amz-Content-Sha256 = %q, want the body's hash", got)
+	}
+
+	var headers strings.Builder
+	for _, name := range strings.Split(fields["SignedHeaders"], ";") {
+		value := r.Header.Get(name)
+		switch name {
+		case "host":
+			value = r.Host
+		case "content-length":
+			value = strconv.FormatInt(r.ContentLength, 10)
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	query := r.URL.Query()
+	var canonicalQuery []string
+	for _, k := range slices.Sorted(maps.Keys(query)) {
+		canonicalQuery = append(canonicalQuery, url.QueryEscape(k)+"="+url.QueryEscape(query.Get(k)))
+	}
+	canonical := strings.Join([]string{
+		r.Method,
+		s3URIEncode(r.URL.Path),
+		strings.Join(canonicalQuery, "&"),
+		headers.String(),
+		fields["SignedHeaders"],
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	canonicalSum := sha256.Sum256([]byte(canonical))
+
+	date := r.Header.Get("X-Amz-Date")
+	scope := strings.Join(credential[1:], "/")
+	toSign := "AWS4-HMAC-SHA256\n" + date + "\n" + scope + "\n" + hex.EncodeToString(canonicalSum[:])
+	key := hmacSHA256([]byte("AWS4"+secret), credential[1])
+	for _, part := range credential[2:] {
+		key = hmacSHA256(key, part)
+	}
+	if want := hex.EncodeToString(hmacSHA256(key, toSign)); fields["Signature"] != want {
+		t.Errorf("signature does not verify for path %q\ncanonical request:\n%s", r.URL.EscapedPath(), canonical)
+	}
+	return credential[0]
+}
+
+func TestSigV4StorePut(t *testing.T) {
+	srv := startObjectServer(t, http.StatusOK)
+	useAWSChain(t, "AKIACHAIN", "chain-secret")
+	ctx := context.Background()
+
+	u, _ := url.Parse("s3://artifacts/fdot?region=us-east-2&endpoint=" + url.QueryEscape(srv.URL+"/"))
+	store, err := newS3Store(ctx, u, "")
+	if err != nil {
+		t.Fatalf("newS3Store failed: %v", err)
+	}
+
+	tests := []struct {
+		key  string
+		path string // as sent
+	}{
+		{"runs/2026/10/14/20261014T020000Z/10.1.1.1/show_version.txt", "/artifacts/runs/2026/10/14/20261014T020000Z/10.1.1.1/show_version.txt"},
+		{"runs/x/show ip route vrf mgmt.txt", "/artifacts/runs/x/show%20ip%20route%20vrf%20mgmt.txt"},
+		{"runs/x/2001:db8::1/device.json", "/artifacts/runs/x/2001%3Adb8%3A%3A1/device.json"},
+		{"runs/x/a+b=c&d@e,f;g$h.txt", "/artifacts/runs/x/a%2Bb%3Dc%26d%40e%2Cf%3Bg%24h.txt"},
+		{"runs/x/100%#?.txt", "/artifacts/runs/x/100%25%23%3F.txt"},
+		{"runs/x/café.txt", "/artifacts/runs/x/caf%C3%A9.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			data := []byte("output of " + tt.key)
+			if err := store.Put(ctx, tt.key, data); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			r, body := srv.last(t)
+			if r.Method != http.MethodPut {
+				t.Errorf("method = %s, want PUT", r.Method)
+			}
+			if got := r.URL.EscapedPath(); got != tt.path {
+				t.Errorf("path = %q, want %q", got, tt.path)
+			}
+			if r.URL.Path != "/artifacts/"+tt.key {
+				t.Errorf("decoded path = %q, want the key", r.URL.Path)
+			}
+			if string(body) != string(data) {
+				t.Errorf("body = %q, want %q", body, data)
+			}
+			if r.Header.Get("X-Amz-Date") == "" {
+				t.Error("X-Amz-Date not set")
+			}
+			if id := checkSigV4(t, r, body, "chain-secret", "us-east-2"); id != "AKIACHAIN" {
+				t.Errorf("signed with %q, want the credential chain's key", id)
+			}
+		})
+	}
+}
+
+func TestS3StoreCredentials(t *testing.T) {
+	srv := startObjectServer(t, http.StatusOK)
+	useAWSChain(t, "AKIACHAIN", "chain-secret")
+	cm := useMemoryCredentials(t)
+	if err := cm.WriteUserCred("upload-s3", credmgr.NewUnPw("AKIACREDMGR", "credmgr-secret")); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	u, _ := url.Parse("s3://artifacts?region=eu-west-1&endpoint=" + url.QueryEscape(srv.URL))
+
+	for _, tt := range []struct {
+		credential string
+		wantID     string
+		secret     string
+	}{
+		{"", "AKIACHAIN", "chain-secret"},
+		{"upload-s3", "AKIACREDMGR", "credmgr-secret"},
+	} {
+		store, err := newS3Store(ctx, u, tt.credential)
+		if err != nil {
+			t.Fatalf("newS3Store(%q) failed: %v", tt.credential, err)
+		}
+		if err := store.Put(ctx, "runs/x/device.json", []byte("{}")); err != nil {
+			t.Fatalf("Put with credential %q failed: %v", tt.credential, err)
+		}
+		r, body := srv.last(t)
+		if id := checkSigV4(t, r, body, tt.secret, "eu-west-1"); id != tt.wantID {
+			t.Errorf("credential %q signed with %q, want %q", tt.credential, id, tt.wantID)
+		}
+	}
+
+	if _, err := newS3Store(ctx, u, "missing"); err == nil || !strings.Contains(err.Error(), `"missing"`) {
+		t.Errorf("newS3Store with a missing credential error = %v", err)
+	}
+	if _, err := newGCSStore("artifacts", ""); err == nil {
+		t.Error("newGCSStore without a credential succeeded")
+	}
+	if _, err := newAzureStore("acct", "runs", ""); err == nil {
+		t.Error("newAzureStore without a credential succeeded")
+	}
+}
+
+func TestS3StoreRejected(t *testing.T) {
+	srv := startObjectServer(t, http.StatusForbidden)
+	srv.reply = func(*http.Request) string { return "<Error><Code>SignatureDoesNotMatch</Code></Error>\n" }
+	useAWSChain(t, "AKIACHAIN", "chain-secret")
+	ctx := context.Background()
+	u, _ := url.Parse("s3://artifacts?region=us-east-1&endpoint=" + url.QueryEscape(srv.URL))
+	store, err := newS3Store(ctx, u, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Put(ctx, "runs/x/device.json", []byte("{}"))
+	if err == nil || err.Error() != "403 Forbidden: <Error><Code>SignatureDoesNotMatch</Code></Error>" {
+		t.Errorf("Put error = %v, want the status and body", err)
+	}
+	if strings.Contains(fmt.Sprint(err), "chain-secret") {
+		t.Errorf("Put error %q holds the secret key", err)
+	}
+}
+
+const testSAS = "sv=2022-11-02&ss=b&srt=o&sp=cw&se=2026-12-31T00:00:00Z&spr=https&sig=Zm9vYmFy%2Bc2VjcmV0c2ln%3D"
+
+func TestSASStorePut(t *testing.T) {
+	srv := startObjectServer(t, http.StatusCreated)
+	store := newSASStore(srv.URL+"/runs", " ?"+testSAS+"\n")
+	data := []byte("hostname sw1\n")
+	if err := store.Put(context.Background(), "fdot/runs/x/show running-config.txt", data); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	r, body := srv.last(t)
+	if r.Method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", r.Method)
+	}
+	if got, want := r.URL.EscapedPath(), "/runs/fdot/runs/x/show%20running-config.txt"; got != want {
+		t.Errorf("path = %q, want %q", got, want)
+	}
+	if r.URL.RawQuery != testSAS {
+		t.Errorf("query = %q, want the SAS token", r.URL.RawQuery)
+	}
+	if got := r.Header.Get("x-ms-blob-type"); got != "BlockBlob" {
+		t.Errorf("x-ms-blob-type = %q, want BlockBlob", got)
+	}
+	if r.Header.Get("Authorization") != "" {
+		t.Errorf("Authorization = %q, want none with a SAS token", r.Header.Get("Authorization"))
+	}
+	if string(body) != string(data) {
+		t.Errorf("body = %q, want %q", body, data)
+	}
+}
+
+func TestSASStoreErrorsHideToken(t *testing.T) {
+	sig, _ := url.ParseQuery(testSAS)
+	secrets := []string{testSAS, sig.Get("sig"), "Zm9vYmFy%2Bc2VjcmV0c2ln"}
+	assertHidden := func(t *testing.T, err error) {
+		t.Helper()
+		if err == nil {
+			t.Fatal("Put succeeded, want an error")
+		}
+		for _, secret := range secrets {
+			if strings.Contains(err.Error(), secret) {
+				t.Errorf("error %q holds the SAS token (%q)", err, secret)
+			}
+		}
+	}
+	ctx := context.Background()
+
+	t.Run("rejected", func(t *testing.T) {
+		srv := startObjectServer(t, http.StatusForbidden)
+		err := newSASStore(srv.URL+"/runs", testSAS).Put(ctx, "x/device.json", []byte("{}"))
+		assertHidden(t, err)
+		if !strings.Contains(err.Error(), "403 Forbidden") {
+			t.Errorf("error %q lacks the status", err)
+		}
+	})
+	t.Run("decoded in the reply", func(t *testing.T) {
+		srv := startObjectServer(t, http.StatusForbidden)
+		srv.reply = func(r *http.Request) string { return "signature " + r.URL.Query().Get("sig") + " did not match" }
+		assertHidden(t, newSASStore(srv.URL+"/runs", testSAS).Put(ctx, "x/device.json", []byte("{}")))
+	})
+	t.Run("unreachable", func(t *testing.T) {
+		srv := startObjectServer(t, http.StatusOK)
+		srv.Close()
+		assertHidden(t, newSASStore(srv.URL+"/runs", testSAS).Put(ctx, "x/device.json", []byte("{}")))
+	})
+	t.Run("invalid endpoint", func(t *testing.T) {
+		assertHidden(t, newSASStore("http://[::1/runs", testSAS).Put(ctx, "x/device.json", []byte("{}")))
+	})
+}
+
+// recordingStore is an ArtifactStore that keeps what it is given
+type recordingStore struct {
+	objects map[string]string
+}
+
+func (s *recordingStore) Put(ctx context.Context, key string, data []byte) error {
+	s.objects[key] = string(data)
+	return nil
+}
+
+func TestUploaderKey(t *testing.T) {
+	tests := []struct {
+		prefix, kind, name string
+		want               string
+	}{
+		{"fdot/prod", ArtifactRuns, "10.1.1.1/show_version.txt", "fdot/prod/runs/2026/10/14/20261014T020000Z/10.1.1.1/show_version.txt"},
+		{"fdot/prod", ArtifactExports, "hosts.json", "fdot/prod/exports/2026/10/14/20261014T020000Z/hosts.json"},
+		{"", ArtifactReports, "capacity.txt", "reports/2026/10/14/20261014T020000Z/capacity.txt"},
+	}
+	for _, tt := range tests {
+		u := &Uploader{prefix: tt.prefix, runID: "20261014T020000Z", date: "2026/10/14"}
+		if got := u.key(tt.kind, tt.name); got != tt.want {
+			t.Errorf("key(%q, %q) with prefix %q = %q, want %q", tt.kind, tt.name, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestNewUploader(t *testing.T) {
+	cm := useMemoryCredentials(t)
+	if err := cm.WriteKey("upload-sas", testSAS); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	u, err := NewUploader(ctx, UploadConfig{URL: "azblob://acct/netcrawl/fdot/lab/", Credential: "upload-sas"})
+	if err != nil {
+		t.Fatalf("NewUploader failed: %v", err)
+	}
+	if u.String() != "azblob://acct/netcrawl/fdot/lab/" {
+		t.Errorf("String = %q", u.String())
+	}
+	key := u.key(ArtifactRuns, "10.1.1.1/device.json")
+	if want := "fdot/lab/runs/" + u.date + "/" + u.runID + "/10.1.1.1/device.json"; key != want {
+		t.Errorf("key = %q, want %q", key, want)
+	}
+	if !strings.HasPrefix(u.runID, strings.ReplaceAll(u.date, "/", "")+"T") || !strings.HasSuffix(u.runID, "Z") {
+		t.Errorf("runID %q does not match date %q", u.runID, u.date)
+	}
+
+	for _, bad := range []string{"ftp://host/x", "s3:///prefix", "azblob://acct", "::"} {
+		if _, err := NewUploader(ctx, UploadConfig{URL: bad, Credential: "upload-sas"}); err == nil {
+			t.Errorf("NewUploader(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestUploadDevice(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"show_version.txt": "ArubaOS-CX", "show_vrf.txt": "VRF Name : default"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &recordingStore{objects: map[string]string{}}
+	u := &Uploader{store: store, prefix: "fdot", runID: "20261014T020000Z", date: "2026/10/14"}
+	n, err := u.UploadDevice(context.Background(), "10.1.1.1", &netmodel.DeviceInfo{Hostname: "sw1", RawOutputDir: dir})
+	if err != nil {
+		t.Fatalf("UploadDevice failed: %v", err)
+	}
+	keys := slices.Sorted(maps.Keys(store.objects))
+	want := []string{
+		"fdot/runs/2026/10/14/20261014T020000Z/10.1.1.1/device.json",
+		"fdot/runs/2026/10/14/20261014T020000Z/10.1.1.1/show_version.txt",
+		"fdot/runs/2026/10/14/20261014T020000Z/10.1.1.1/show_vrf.txt",
+	}
+	if n != 3 || !slices.Equal(keys, want) {
+		t.Errorf("UploadDevice = %d, keys %q; want %q", n, keys, want)
+	}
+	if !strings.Contains(store.objects[want[0]], `"sw1"`) {
+		t.Errorf("device.json = %q, want the stored record", store.objects[want[0]])
+	}
+}
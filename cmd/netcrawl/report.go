@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nzions/fdot/cmd/netcrawl/netcrawl"
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
+)
+
+// runReport prints a report over the stored devices; "capacity" is the only report so far
+func runReport(args []string) error {
+	if len(args) == 0 || args[0] != "capacity" {
+		return fmt.Errorf("usage: netcrawl report capacity [-upload url] [@group|ip ...]")
+	}
+
+	fs := flag.NewFlagSet("report capacity", flag.ContinueOnError)
+	upload := fs.String("upload", "", "Also upload the report to object storage (s3://, gs:// or azblob:// URL)")
+	uploadCred := fs.String("upload-cred", "", "credmgr credential for -upload")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var report bytes.Buffer
+	out := io.Writer(os.Stdout)
+	if *upload != "" {
+		out = io.MultiWriter(os.Stdout, &report)
+	}
+
+	store, err := netcrawl.OpenDefaultStore()
+	if err != nil {
+		return err
+	}
+
+	var devices []*netmodel.DeviceInfo
+	if fs.NArg() == 0 {
+		devices, err = store.Query(func(*netmodel.DeviceInfo) bool { return true })
+		if err != nil {
+			return err
+		}
+	}
+	for _, target := range fs.Args() {
+		ips := []string{target}
+		if strings.HasPrefix(target, netcrawl.GroupPrefix) {
+			if ips, err = resolveTargets(target); err != nil {
+				return err
+			}
+		}
+		for _, ip := range ips {
+			info, err := store.Get(ip)
+			if err != nil {
+				return fmt.Errorf("device %s: %w", ip, err)
+			}
+			devices = append(devices, info)
+		}
+	}
+
+	reported := 0
+	for _, d := range devices {
+		if d.Capacity == nil {
+			continue
+		}
+		if reported > 0 {
+			fmt.Fprintln(out)
+		}
+		printCapacity(out, d)
+		reported++
+	}
+	if reported == 0 {
+		fmt.Println("No capacity data stored; run a crawl with -profile full first")
+		return nil
+	}
+
+	if *upload != "" {
+		ctx := context.Background()
+		uploader, err := netcrawl.NewUploader(ctx, netcrawl.UploadConfig{URL: *upload, Credential: *uploadCred})
+		if err != nil {
+			return err
+		}
+		if err := uploader.Put(ctx, netcrawl.ArtifactReports, "capacity.txt", report.Bytes()); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Report uploaded to %s\n", uploader)
+	}
+	return nil
+}
+
+// printCapacity prints the port, PoE and resource usage of one device
+func printCapacity(w io.Writer, d *netmodel.DeviceInfo) {
+	c := d.Capacity
+	fmt.Fprintf(w, "%s (%s)  collected %s\n", d.Hostname, d.IPAddress, d.LastUpdated.Format("2006-01-02"))
+
+	var ports []string
+	for _, s := range c.PortSummary() {
+		ports = append(ports, s.String())
+	}
+	fmt.Fprintf(w, "  Ports:     %s used\n", strings.Join(ports, ", "))
+
+	if c.PoE != nil {
+		fmt.Fprintf(w, "  PoE:       %.1f/%.1f W used (%.1f W left), %d/%d ports powered\n",
+			c.PoE.UsedWatts, c.PoE.AvailableWatts, c.PoE.RemainingWatts(), c.PoE.PoweredPorts, c.PoE.PoEPorts)
+	}
+
+	for _, r := range c.Resources {
+		slot := ""
+		if r.Slot != "" {
+			slot = " (slot " + r.Slot + ")"
+		}
+		fmt.Fprintf(w, "  Resources: %s%s %d used, %d free (%.0f%%)\n", r.Resource, slot, r.Used, r.Available, r.Percent())
+	}
+}
+
+// isReport reports whether the command line invokes the report subcommand
+func isReport() bool {
+	return len(os.Args) > 1 && os.Args[1] == "report"
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nzions/fdot/pkg/fdh/netdevice"
+)
+
+// runShowRequirements prints the privilege level and exact commands each driver needs,
+// so security teams can build least-privilege command authorization for the crawler account
+func runShowRequirements(args []string) error {
+	fs := flag.NewFlagSet("show-requirements", flag.ContinueOnError)
+	deviceType := fs.String("device-type", "", "Device type to show (default: all supported types)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	types := netdevice.SupportedDeviceTypes()
+	if *deviceType != "" {
+		types = []netdevice.DeviceType{netdevice.DeviceType(*deviceType)}
+	}
+
+	for i, t := range types {
+		req, err := netdevice.Requirements(t)
+		if err != nil {
+			return fmt.Errorf("%w (supported: %s)", err, supportedTypesString())
+		}
+
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("Device type: %s\n", req.DeviceType)
+		fmt.Printf("Privilege:   %s\n", req.Privilege)
+		if req.Notes != "" {
+			fmt.Printf("Notes:       %s\n", req.Notes)
+		}
+		fmt.Println("Commands:")
+		for _, cmd := range req.Commands {
+			fmt.Printf("  %s\n", cmd)
+		}
+	}
+	return nil
+}
+
+// supportedTypesString returns a comma-separated list of supported device types
+func supportedTypesString() string {
+	var names []string
+	for _, t := range netdevice.SupportedDeviceTypes() {
+		names = append(names, string(t))
+	}
+	return strings.Join(names, ", ")
+}
+
+// isShowRequirements reports whether the command line invokes the show-requirements subcommand
+func isShowRequirements() bool {
+	return len(os.Args) > 1 && os.Args[1] == "show-requirements"
+}
@@ -0,0 +1,263 @@
+package credmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrForbidden is returned when an access policy rejects an operation
+var ErrForbidden = errors.New("credential access forbidden")
+
+// AccessRequest describes an operation checked by an AccessPolicy
+type AccessRequest struct {
+	Op         AuditOp
+	Name       string // credential name
+	Executable string // absolute path of the calling binary
+	PID        int
+}
+
+// AccessPolicy decides whether a request is allowed; a non-nil error denies it.
+// Denials are returned to the caller wrapped in ErrForbidden.
+type AccessPolicy func(AccessRequest) error
+
+// WithAccessPolicy checks every credential operation against policy.
+// Whole-store operations (DeleteDB, Export, Import) are checked once per stored
+// credential, so a restricted credential cannot leak through them.
+func WithAccessPolicy(policy AccessPolicy) Option {
+	return func(o *openOptions) {
+		o.accessPolicies = append(o.accessPolicies, policy)
+	}
+}
+
+// RestrictTo limits credentials matching pattern (a path.Match pattern such as
+// "prod-*") to the given binaries. Entries containing a path separator must match
+// the executable path exactly; bare names match its base name.
+//
+//	cm, err := credmgr.Open("", credmgr.RestrictTo("prod-*", "deployer"))
+func RestrictTo(pattern string, binaries ...string) Option {
+	return WithAccessPolicy(func(req AccessRequest) error {
+		if ok, _ := path.Match(pattern, req.Name); !ok {
+			return nil
+		}
+		if binaryAllowed(binaries, req.Executable) {
+			return nil
+		}
+		return fmt.Errorf("%q is restricted to %s", req.Name, strings.Join(binaries, ", "))
+	})
+}
+
+// binaryAllowed matches exe against full paths or base names
+func binaryAllowed(allowed []string, exe string) bool {
+	if exe == "" {
+		return false
+	}
+	for _, a := range allowed {
+		if strings.ContainsRune(a, '/') || strings.ContainsRune(a, filepath.Separator) {
+			if filepath.Clean(a) == filepath.Clean(exe) {
+				return true
+			}
+		} else if a == filepath.Base(exe) {
+			return true
+		}
+	}
+	return false
+}
+
+// aclCredManager enforces access policies on the wrapped CredManager
+type aclCredManager struct {
+	CredManager
+	policies   []AccessPolicy
+	executable string
+	pid        int
+}
+
+// newACLCredManager wraps cm with the policies from o
+func newACLCredManager(cm CredManager, o *openOptions) *aclCredManager {
+	exe, err := os.Executable()
+	if err != nil {
+		exe = os.Args[0]
+	}
+	return &aclCredManager{
+		CredManager: cm,
+		policies:    o.accessPolicies,
+		executable:  exe,
+		pid:         os.Getpid(),
+	}
+}
+
+// check runs every policy for one credential
+func (a *aclCredManager) check(op AuditOp, name string) error {
+	req := AccessRequest{Op: op, Name: name, Executable: a.executable, PID: a.pid}
+	for _, policy := range a.policies {
+		if err := policy(req); err != nil {
+			if errors.Is(err, ErrForbidden) {
+				return err
+			}
+			return fmt.Errorf("%w: %v", ErrForbidden, err)
+		}
+	}
+	return nil
+}
+
+// checkAll runs every policy for each stored credential
+func (a *aclCredManager) checkAll(op AuditOp) error {
+	names, err := a.CredManager.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := a.check(op, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *aclCredManager) Read(name string) ([]byte, error) {
+	if err := a.check(AuditRead, name); err != nil {
+		return nil, err
+	}
+	return a.CredManager.Read(name)
+}
+
+func (a *aclCredManager) ReadInto(name string, buf []byte) (int, error) {
+	if err := a.check(AuditRead, name); err != nil {
+		return 0, err
+	}
+	return a.CredManager.ReadInto(name, buf)
+}
+
+func (a *aclCredManager) Write(name string, data []byte) error {
+	if err := a.check(AuditWrite, name); err != nil {
+		return err
+	}
+	return a.CredManager.Write(name, data)
+}
+
+func (a *aclCredManager) WriteIfNotExists(name string, data []byte) error {
+	if err := a.check(AuditWrite, name); err != nil {
+		return err
+	}
+	return a.CredManager.WriteIfNotExists(name, data)
+}
+
+func (a *aclCredManager) ReadKey(name string) (string, error) {
+	if err := a.check(AuditRead, name); err != nil {
+		return "", err
+	}
+	return a.CredManager.ReadKey(name)
+}
+
+func (a *aclCredManager) WriteKey(name, key string) error {
+	if err := a.check(AuditWrite, name); err != nil {
+		return err
+	}
+	return a.CredManager.WriteKey(name, key)
+}
+
+func (a *aclCredManager) ReadUserCred(name string) (UserCred, error) {
+	if err := a.check(AuditRead, name); err != nil {
+		return nil, err
+	}
+	return a.CredManager.ReadUserCred(name)
+}
+
+func (a *aclCredManager) WriteUserCred(name string, cred UserCred) error {
+	if err := a.check(AuditWrite, name); err != nil {
+		return err
+	}
+	return a.CredManager.WriteUserCred(name, cred)
+}
+
+func (a *aclCredManager) Delete(name string) error {
+	if err := a.check(AuditDelete, name); err != nil {
+		return err
+	}
+	return a.CredManager.Delete(name)
+}
+
+func (a *aclCredManager) WriteBatch(creds map[string][]byte) error {
+	for name := range creds {
+		if err := a.check(AuditWrite, name); err != nil {
+			return err
+		}
+	}
+	return a.CredManager.WriteBatch(creds)
+}
+
+func (a *aclCredManager) DeleteBatch(names []string) error {
+	for _, name := range names {
+		if err := a.check(AuditDelete, name); err != nil {
+			return err
+		}
+	}
+	return a.CredManager.DeleteBatch(names)
+}
+
+// Rename needs delete access to the old name and write access to the new one
+func (a *aclCredManager) Rename(oldName, newName string) error {
+	if err := a.check(AuditDelete, oldName); err != nil {
+		return err
+	}
+	if err := a.check(AuditWrite, newName); err != nil {
+		return err
+	}
+	return a.CredManager.Rename(oldName, newName)
+}
+
+func (a *aclCredManager) Restore(name string) error {
+	if err := a.check(AuditRestore, name); err != nil {
+		return err
+	}
+	return a.CredManager.Restore(name)
+}
+
+func (a *aclCredManager) Purge(name string) error {
+	if name == "" {
+		entries, err := a.CredManager.ListTrash()
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := a.check(AuditPurge, e.Name); err != nil {
+				return err
+			}
+		}
+	} else if err := a.check(AuditPurge, name); err != nil {
+		return err
+	}
+	return a.CredManager.Purge(name)
+}
+
+func (a *aclCredManager) DeleteDB() error {
+	if err := a.checkAll(AuditDeleteDB); err != nil {
+		return err
+	}
+	return a.CredManager.DeleteDB()
+}
+
+// Subscribe reports changes to the credentials the policies allow reading
+func (a *aclCredManager) Subscribe(ctx context.Context, interval time.Duration) (<-chan ChangeEvent, error) {
+	return subscribe(ctx, a, interval)
+}
+
+func (a *aclCredManager) Export(w io.Writer, passphrase string) error {
+	if err := a.checkAll(AuditExport); err != nil {
+		return err
+	}
+	return a.CredManager.Export(w, passphrase)
+}
+
+func (a *aclCredManager) Import(r io.Reader, passphrase string, policy MergePolicy) error {
+	if err := a.checkAll(AuditImport); err != nil {
+		return err
+	}
+	return a.CredManager.Import(r, passphrase, policy)
+}
@@ -0,0 +1,102 @@
+package credmgr
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestrictTo(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	credPath := filepath.Join(t.TempDir(), "credentials.enc")
+	unrestricted, err := Open(credPath)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := unrestricted.WriteKey("prod-api", "secret"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+	if err := unrestricted.WriteKey("dev-api", "dev"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+
+	cm, err := Open(credPath, RestrictTo("prod-*", "deployer"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err := cm.ReadKey("prod-api"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("ReadKey(prod-api) error = %v, want ErrForbidden", err)
+	}
+	if err := cm.Delete("prod-api"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Delete(prod-api) error = %v, want ErrForbidden", err)
+	}
+	if got, err := cm.ReadKey("dev-api"); err != nil || got != "dev" {
+		t.Errorf("ReadKey(dev-api) = %q, %v; want %q, nil", got, err, "dev")
+	}
+	// Renames may neither move a restricted credential out nor a new one in
+	if err := cm.Rename("prod-api", "leaked"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Rename(prod-api) error = %v, want ErrForbidden", err)
+	}
+	if err := cm.Rename("dev-api", "prod-dev"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Rename(dev-api, prod-dev) error = %v, want ErrForbidden", err)
+	}
+
+	// Whole-store operations would expose or destroy the restricted credential
+	if err := cm.Export(&bytes.Buffer{}, "passphrase"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Export error = %v, want ErrForbidden", err)
+	}
+	if err := cm.DeleteDB(); !errors.Is(err, ErrForbidden) {
+		t.Errorf("DeleteDB error = %v, want ErrForbidden", err)
+	}
+
+	// The test binary itself may be allowed by base name or full path
+	exe, _ := os.Executable()
+	for _, allowed := range []string{filepath.Base(exe), exe} {
+		cm, err := Open(credPath, RestrictTo("prod-*", allowed))
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		if _, err := cm.ReadKey("prod-api"); err != nil {
+			t.Errorf("ReadKey allowed for %q: %v", allowed, err)
+		}
+	}
+}
+
+func TestAccessPolicyCallback(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var requests []AccessRequest
+	var events []AuditEvent
+	cm, err := Open(filepath.Join(t.TempDir(), "credentials.enc"),
+		WithAccessPolicy(func(req AccessRequest) error {
+			requests = append(requests, req)
+			if req.Op == AuditWrite && req.Name == "locked" {
+				return errors.New("writes to locked are not allowed")
+			}
+			return nil
+		}),
+		WithAudit(func(e AuditEvent) { events = append(events, e) }),
+	)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	if err := cm.WriteKey("locked", "v"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("WriteKey(locked) error = %v, want ErrForbidden", err)
+	}
+	if err := cm.WriteKey("open", "v"); err != nil {
+		t.Errorf("WriteKey(open) failed: %v", err)
+	}
+
+	if len(requests) != 2 || requests[0].PID != os.Getpid() || requests[0].Executable == "" {
+		t.Errorf("policy requests = %+v", requests)
+	}
+	if len(events) != 2 || events[0].Success || !events[1].Success {
+		t.Errorf("denied operations should be audited as failures: %+v", events)
+	}
+}
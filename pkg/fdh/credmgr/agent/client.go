@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// ErrAgentUnavailable is returned when no agent answers on the socket
+var ErrAgentUnavailable = errors.New("credmgr agent unavailable")
+
+// callTimeout bounds each request; reads may wait for an interactive confirmation
+const callTimeout = 2 * time.Minute
+
+// Remote is a CredManager that forwards to a running agent, so the caller needs no
+// master key. Reads, writes, deletes and listing go to the agent; typed helpers
+// (ReadKey, ReadUserCred, Export, ...) and DeleteBatch are built on those. Trash
+// management and DeleteDB are not served by the agent and return
+// credmgr.ErrNotSupported.
+type Remote struct {
+	credmgr.CredManager
+	conn *grpc.ClientConn
+}
+
+// Dial connects to the agent listening on socketPath. The connection is made lazily,
+// so an agent that is not running surfaces as ErrAgentUnavailable on the first call.
+func Dial(socketPath string) (*Remote, error) {
+	conn, err := grpc.NewClient("unix:"+socketPath,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(codecName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to credmgr agent: %w", err)
+	}
+
+	r := &Remote{conn: conn}
+	r.CredManager = credmgr.NewFromStore(remoteStore{r})
+	return r, nil
+}
+
+// Close releases the connection to the agent
+func (r *Remote) Close() error {
+	return r.conn.Close()
+}
+
+// invoke calls an agent method and maps its error back to credmgr sentinels
+func (r *Remote) invoke(method string, req, resp any) error {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	return fromStatus(r.conn.Invoke(ctx, "/"+serviceName+"/"+method, req, resp))
+}
+
+// Delete moves a credential into the agent store's trash
+func (r *Remote) Delete(name string) error {
+	return r.invoke("Delete", &nameRequest{Name: name}, &empty{})
+}
+
+// DeleteBatch moves each name into the agent store's trash with one Delete per name.
+// Names are checked against the agent's list first, so a missing name changes nothing;
+// a delete the agent refuses part-way stops the batch with the earlier names deleted.
+func (r *Remote) DeleteBatch(names []string) error {
+	listed, err := r.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if !slices.Contains(listed, name) {
+			return fmt.Errorf("%w: %s", credmgr.ErrNotFound, name)
+		}
+	}
+	for _, name := range names {
+		if err := r.Delete(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore is not served by the agent
+func (r *Remote) Restore(name string) error { return credmgr.ErrNotSupported }
+
+// ListTrash is not served by the agent
+func (r *Remote) ListTrash() ([]credmgr.TrashEntry, error) { return nil, credmgr.ErrNotSupported }
+
+// Purge is not served by the agent
+func (r *Remote) Purge(name string) error { return credmgr.ErrNotSupported }
+
+// DeleteDB is not served by the agent
+func (r *Remote) DeleteDB() error { return credmgr.ErrNotSupported }
+
+// Reload is a no-op: the agent reloads the store itself when the file changes
+func (r *Remote) Reload() error { return nil }
+
+// remoteStore is the raw credmgr.Store view of the agent
+type remoteStore struct {
+	r *Remote
+}
+
+func (s remoteStore) Read(name string) ([]byte, error) {
+	var resp readResponse
+	if err := s.r.invoke("Read", &nameRequest{Name: name}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+func (s remoteStore) Write(name string, data []byte) error {
+	return s.r.invoke("Write", &writeRequest{Name: name, Data: data}, &empty{})
+}
+
+func (s remoteStore) Delete(name string) error { return s.r.Delete(name) }
+
+func (s remoteStore) DeleteDB() error { return credmgr.ErrNotSupported }
+
+func (s remoteStore) List() ([]string, error) {
+	var resp listResponse
+	if err := s.r.invoke("List", &empty{}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Names, nil
+}
@@ -0,0 +1,198 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// The framed protocol serves the same operations as the gRPC service in a form shell
+// scripts and other languages can speak with nothing more than socat or nc. Each request
+// is one header line, followed by a payload for PUT:
+//
+//	GET <name>
+//	PUT <name> <length>\n<length bytes>
+//	DELETE <name>
+//	LIST
+//
+// and each response is either a length-prefixed payload or an error line:
+//
+//	OK <length>\n<length bytes>
+//	ERR <code> <message>
+//
+// where code is one of not_found, denied, read_only, invalid or internal. LIST answers
+// with one name per line. A connection may carry any number of requests.
+//
+//	printf 'GET myapp-token\n' | socat - UNIX-CONNECT:"$CREDMGR_AGENT_FRAMED_SOCK" | tail -n +2
+
+const (
+	// maxFrameLine bounds a request header line
+	maxFrameLine = 4096
+	// maxFramePayload bounds the data of a PUT
+	maxFramePayload = 1 << 20
+	// framedIdleTimeout closes connections that send no request for this long
+	framedIdleTimeout = 5 * time.Minute
+)
+
+// FramedSocketPath returns the framed protocol socket that accompanies the gRPC socket sock
+func FramedSocketPath(sock string) string {
+	return strings.TrimSuffix(sock, ".sock") + "-framed.sock"
+}
+
+// ServeFramed accepts framed protocol clients on l until Stop is called.
+// l should come from Listen so only the current user can connect.
+func (s *Server) ServeFramed(l net.Listener) error {
+	if !s.track(l) {
+		l.Close()
+		return nil
+	}
+	defer s.untrack(l)
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isStopped() {
+				return nil
+			}
+			return err
+		}
+		go s.serveFramedConn(conn)
+	}
+}
+
+// serveFramedConn answers the requests of one client until it disconnects
+func (s *Server) serveFramedConn(conn net.Conn) {
+	if !s.track(conn) {
+		conn.Close()
+		return
+	}
+	defer s.untrack(conn)
+	defer conn.Close()
+
+	// Identify the client once, while the peer is certainly the process that connected
+	client, err := identifyPeer(conn, s.uid)
+	if err != nil {
+		writeFrameError(conn, "denied", err.Error())
+		return
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: peerAddr{client: client}})
+
+	r := bufio.NewReaderSize(conn, maxFrameLine)
+	w := bufio.NewWriter(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(framedIdleTimeout))
+		line, err := r.ReadSlice('\n')
+		if err != nil {
+			if errors.Is(err, bufio.ErrBufferFull) {
+				writeFrameError(w, "invalid", "request line too long")
+				w.Flush()
+			}
+			return
+		}
+
+		data, inSync, err := s.handleFrame(ctx, strings.Fields(string(line)), r)
+		if err != nil {
+			writeFrameError(w, frameErrorCode(err), status.Convert(err).Message())
+		} else {
+			fmt.Fprintf(w, "OK %d\n", len(data))
+			w.Write(data)
+		}
+		if err := w.Flush(); err != nil || !inSync {
+			return
+		}
+	}
+}
+
+// handleFrame runs one request and returns the response payload. inSync is false when
+// a malformed PUT leaves the position of the next request unknown.
+func (s *Server) handleFrame(ctx context.Context, fields []string, r *bufio.Reader) (data []byte, inSync bool, err error) {
+	data, err = s.runFrame(ctx, fields, r)
+	if errors.Is(err, errOutOfSync) {
+		return nil, false, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return data, true, err
+}
+
+// errOutOfSync is returned for a PUT whose payload could not be read
+var errOutOfSync = errors.New("invalid payload")
+
+func (s *Server) runFrame(ctx context.Context, fields []string, r *bufio.Reader) ([]byte, error) {
+	if len(fields) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty request")
+	}
+	argc := map[string]int{"GET": 2, "PUT": 3, "DELETE": 2, "LIST": 1}
+	verb := strings.ToUpper(fields[0])
+	if n, ok := argc[verb]; !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown request %q", fields[0])
+	} else if len(fields) != n {
+		return nil, status.Errorf(codes.InvalidArgument, "%s takes %d argument(s)", verb, n-1)
+	}
+
+	switch verb {
+	case "GET":
+		resp, err := s.read(ctx, &nameRequest{Name: fields[1]})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Data, nil
+
+	case "PUT":
+		size, err := strconv.Atoi(fields[2])
+		if err != nil || size < 0 || size > maxFramePayload {
+			return nil, fmt.Errorf("%w: length %q", errOutOfSync, fields[2])
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("%w: %d bytes expected", errOutOfSync, size)
+		}
+		_, err = s.write(ctx, &writeRequest{Name: fields[1], Data: data})
+		return nil, err
+
+	case "DELETE":
+		_, err := s.delete(ctx, &nameRequest{Name: fields[1]})
+		return nil, err
+
+	default: // LIST
+		resp, err := s.list(ctx, &empty{})
+		if err != nil {
+			return nil, err
+		}
+		var b strings.Builder
+		for _, name := range resp.Names {
+			b.WriteString(name)
+			b.WriteByte('\n')
+		}
+		return []byte(b.String()), nil
+	}
+}
+
+// frameErrorCode maps the status codes of toStatus to framed protocol error codes
+func frameErrorCode(err error) string {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return "not_found"
+	case codes.PermissionDenied:
+		return "denied"
+	case codes.FailedPrecondition:
+		return "read_only"
+	case codes.InvalidArgument:
+		return "invalid"
+	default:
+		return "internal"
+	}
+}
+
+// writeFrameError writes an error line; newlines in msg would break the framing
+func writeFrameError(w io.Writer, code, msg string) {
+	fmt.Fprintf(w, "ERR %s %s\n", code, strings.ReplaceAll(msg, "\n", " "))
+}
@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+)
+
+// startFramed serves cm over the framed protocol and returns a connected client
+func startFramed(t *testing.T, cm credmgr.CredManager, enforcer *Enforcer) (net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	sock := FramedSocketPath(filepath.Join(t.TempDir(), "agent.sock"))
+	l, err := Listen(sock)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	srv := NewServer(cm, enforcer)
+	go srv.ServeFramed(l)
+	t.Cleanup(srv.Stop)
+
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, bufio.NewReader(conn)
+}
+
+// frameRequest sends req and returns the response payload, or the error line
+func frameRequest(t *testing.T, conn net.Conn, r *bufio.Reader, req string) (string, error) {
+	t.Helper()
+
+	if _, err := io.WriteString(conn, req); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	header, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	header = strings.TrimSuffix(header, "\n")
+	if strings.HasPrefix(header, "ERR ") {
+		return "", fmt.Errorf("%s", strings.TrimPrefix(header, "ERR "))
+	}
+	var size int
+	if _, err := fmt.Sscanf(header, "OK %d", &size); err != nil {
+		t.Fatalf("bad response header %q", header)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		t.Fatalf("short payload: %v", err)
+	}
+	return string(data), nil
+}
+
+func TestFramedProtocol(t *testing.T) {
+	backing := credmgr.NewFromStore(memStore{})
+	conn, r := startFramed(t, backing, nil)
+
+	if _, err := frameRequest(t, conn, r, "PUT token 6\nsecret"); err != nil {
+		t.Fatalf("PUT failed: %v", err)
+	}
+	if got, err := backing.ReadKey("token"); err != nil || got != "secret" {
+		t.Errorf("backing ReadKey = %q, %v; want %q", got, err, "secret")
+	}
+	if got, err := frameRequest(t, conn, r, "GET token\n"); err != nil || got != "secret" {
+		t.Errorf("GET = %q, %v; want %q", got, err, "secret")
+	}
+	if got, err := frameRequest(t, conn, r, "LIST\n"); err != nil || got != "token\n" {
+		t.Errorf("LIST = %q, %v; want %q", got, err, "token\n")
+	}
+	if _, err := frameRequest(t, conn, r, "DELETE token\n"); err != nil {
+		t.Fatalf("DELETE failed: %v", err)
+	}
+	if _, err := frameRequest(t, conn, r, "GET token\n"); err == nil || !strings.HasPrefix(err.Error(), "not_found ") {
+		t.Errorf("GET after DELETE error = %v, want not_found", err)
+	}
+	if _, err := frameRequest(t, conn, r, "FETCH token\n"); err == nil || !strings.HasPrefix(err.Error(), "invalid ") {
+		t.Errorf("unknown request error = %v, want invalid", err)
+	}
+	// The connection stays usable after request errors
+	if _, err := frameRequest(t, conn, r, "LIST\n"); err != nil {
+		t.Errorf("LIST after errors failed: %v", err)
+	}
+}
+
+func TestFramedPolicyDenied(t *testing.T) {
+	backing := credmgr.NewFromStore(memStore{})
+	if err := backing.WriteKey("prod-api", "v"); err != nil {
+		t.Fatal(err)
+	}
+	enforcer := NewEnforcer(Policies{Credentials: map[string]Policy{
+		"prod-api": {AllowedBinaries: []string{"/opt/deploy/bin/deployer"}},
+	}}, nil)
+	conn, r := startFramed(t, backing, enforcer)
+
+	if _, err := frameRequest(t, conn, r, "GET prod-api\n"); err == nil || !strings.HasPrefix(err.Error(), "denied ") {
+		t.Errorf("GET of restricted credential error = %v, want denied", err)
+	}
+}
+
+func TestFramedBadLength(t *testing.T) {
+	conn, r := startFramed(t, credmgr.NewFromStore(memStore{}), nil)
+
+	if _, err := frameRequest(t, conn, r, "PUT token lots\n"); err == nil || !strings.HasPrefix(err.Error(), "invalid ") {
+		t.Errorf("PUT with bad length error = %v, want invalid", err)
+	}
+	// The stream position is lost, so the agent hangs up
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Errorf("read after bad length = %v, want EOF", err)
+	}
+}
+
+func TestFramedOtherUserRejected(t *testing.T) {
+	if !peerCredentials {
+		t.Skip("peer credentials are not available on " + runtime.GOOS)
+	}
+	sock := FramedSocketPath(filepath.Join(t.TempDir(), "agent.sock"))
+	l, err := Listen(sock)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	srv := NewServer(credmgr.NewFromStore(memStore{}), nil)
+	srv.uid = os.Geteuid() + 1 // as if the agent ran as another user
+	go srv.ServeFramed(l)
+	t.Cleanup(srv.Stop)
+
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	// The agent answers before any request and hangs up
+	if line, err := r.ReadString('\n'); err != nil || !strings.HasPrefix(line, "ERR denied ") {
+		t.Errorf("first line = %q, %v; want ERR denied", line, err)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Errorf("read after denial = %v, want EOF", err)
+	}
+}
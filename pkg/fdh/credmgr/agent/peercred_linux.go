@@ -0,0 +1,36 @@
+//go:build linux
+
+package agent
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerCredentials reports whether peerClient learns the UID of socket peers
+const peerCredentials = true
+
+// peerClient identifies the process on the other end of a Unix socket with SO_PEERCRED
+func peerClient(conn net.Conn) (Client, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return unknownClient, nil
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return unknownClient, err
+	}
+
+	var cred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return unknownClient, err
+	}
+	if credErr != nil {
+		return unknownClient, credErr
+	}
+	return ClientFromPID(int(cred.Pid), int(cred.Uid)), nil
+}
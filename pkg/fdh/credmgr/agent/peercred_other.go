@@ -0,0 +1,14 @@
+//go:build !linux
+
+package agent
+
+import "net"
+
+// peerCredentials reports whether peerClient learns the UID of socket peers
+const peerCredentials = false
+
+// peerClient cannot identify socket peers on this platform; policies that restrict
+// binaries or UIDs deny such clients
+func peerClient(conn net.Conn) (Client, error) {
+	return unknownClient, nil
+}
@@ -0,0 +1,317 @@
+// Package agent contains the credmgr agent: a long-running process that holds the
+// unlocked credential store and hands secrets to local clients.
+//
+// Per-credential policies limit the blast radius of a compromised local process:
+// the agent checks the calling binary and UID on every operation, enforces read-rate
+// limits, can refuse changes, and can require an interactive confirmation (like
+// ssh-add -c) before returning or changing a secret. List shows only the names a
+// client may read.
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDenied is returned when a policy rejects a request
+var ErrDenied = errors.New("access denied by agent policy")
+
+// Policy restricts access to one credential (or a group of credentials)
+type Policy struct {
+	// AllowedBinaries lists client executables allowed to read or change the credential.
+	// Entries containing a path separator must match the executable path exactly;
+	// bare names match the executable's base name. Empty allows any binary.
+	AllowedBinaries []string `json:"allowed_binaries,omitempty"`
+
+	// AllowedUIDs lists user IDs allowed to read or change the credential. Empty allows any UID.
+	AllowedUIDs []int `json:"allowed_uids,omitempty"`
+
+	// Confirm requires an interactive confirmation for every read, write and delete
+	Confirm bool `json:"confirm,omitempty"`
+
+	// ReadOnly refuses writes and deletes of the credential through the agent
+	ReadOnly bool `json:"read_only,omitempty"`
+
+	// MaxReadsPerHour caps reads of the credential across all clients. Zero means unlimited.
+	MaxReadsPerHour int `json:"max_reads_per_hour,omitempty"`
+}
+
+// Policies maps credential names to policies. Keys may be path.Match patterns
+// (e.g. "prod-*"); an exact name takes precedence over patterns, and Default
+// applies to credentials that match nothing.
+type Policies struct {
+	Default     *Policy           `json:"default,omitempty"`
+	Credentials map[string]Policy `json:"credentials,omitempty"`
+}
+
+// LoadPolicies reads policies from a JSON file; a missing file yields no policies
+func LoadPolicies(file string) (Policies, error) {
+	var p Policies
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return p, nil
+		}
+		return p, fmt.Errorf("failed to read agent policies: %w", err)
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("failed to parse agent policies %s: %w", file, err)
+	}
+	for pattern := range p.Credentials {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return p, fmt.Errorf("invalid credential pattern %q: %w", pattern, err)
+		}
+	}
+	return p, nil
+}
+
+// lookup returns the policy for name, or nil if none applies
+func (p Policies) lookup(name string) *Policy {
+	if pol, ok := p.Credentials[name]; ok {
+		return &pol
+	}
+
+	// Longest matching pattern wins so more specific patterns override broad ones
+	var best string
+	for pattern := range p.Credentials {
+		if ok, _ := path.Match(pattern, name); ok && len(pattern) > len(best) {
+			best = pattern
+		}
+	}
+	if best != "" {
+		pol := p.Credentials[best]
+		return &pol
+	}
+
+	return p.Default
+}
+
+// Client identifies the local process requesting a credential
+type Client struct {
+	PID        int
+	UID        int
+	Executable string // absolute path of the client binary, if known
+}
+
+// ClientFromPID builds a Client, resolving the executable from /proc where available
+func ClientFromPID(pid, uid int) Client {
+	c := Client{PID: pid, UID: uid}
+	if exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid)); err == nil {
+		c.Executable = exe
+	}
+	return c
+}
+
+func (c Client) String() string {
+	exe := c.Executable
+	if exe == "" {
+		exe = "unknown binary"
+	}
+	return fmt.Sprintf("%s (pid %d, uid %d)", exe, c.PID, c.UID)
+}
+
+// Confirmer asks the user to approve a credential read
+type Confirmer interface {
+	Confirm(prompt string) (bool, error)
+}
+
+// AskpassConfirmer confirms reads with an ssh-askpass style program.
+// The program is run with the prompt as its only argument and SSH_ASKPASS_PROMPT=confirm;
+// exit status 0 means approved.
+type AskpassConfirmer struct {
+	// Program is the askpass binary; defaults to $SSH_ASKPASS, then "ssh-askpass"
+	Program string
+}
+
+// Confirm runs the askpass program and reports whether the user approved
+func (a AskpassConfirmer) Confirm(prompt string) (bool, error) {
+	program := a.Program
+	if program == "" {
+		program = os.Getenv("SSH_ASKPASS")
+	}
+	if program == "" {
+		program = "ssh-askpass"
+	}
+
+	cmd := exec.Command(program, prompt)
+	cmd.Env = append(os.Environ(), "SSH_ASKPASS_PROMPT=confirm")
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return false, nil
+		}
+		return false, fmt.Errorf("running %s: %w", program, err)
+	}
+	return true, nil
+}
+
+// Enforcer applies Policies to credential operations
+type Enforcer struct {
+	policies Policies
+	confirm  Confirmer
+	now      func() time.Time
+
+	mu    sync.Mutex
+	reads map[string][]time.Time // recent read times per credential
+}
+
+// NewEnforcer returns an Enforcer for the given policies.
+// confirm may be nil, in which case policies requiring confirmation deny every read.
+func NewEnforcer(policies Policies, confirm Confirmer) *Enforcer {
+	return &Enforcer{
+		policies: policies,
+		confirm:  confirm,
+		now:      time.Now,
+		reads:    make(map[string][]time.Time),
+	}
+}
+
+// Authorize decides whether client may read credential name. Cheap checks
+// (binary, UID, rate) run before any confirmation prompt; only approved reads
+// count towards the rate limit, though a pending one holds its place. Denials
+// wrap ErrDenied.
+func (e *Enforcer) Authorize(name string, client Client) error {
+	pol := e.policies.lookup(name)
+	if pol == nil {
+		return nil
+	}
+
+	if err := pol.checkClient("read", name, client); err != nil {
+		return err
+	}
+
+	// The slot is taken before the prompt, so concurrent reads cannot all pass the
+	// check while a confirmation is pending; a denied read gives it back
+	if pol.MaxReadsPerHour > 0 {
+		release, ok := e.reserveRead(name, pol.MaxReadsPerHour)
+		if !ok {
+			return fmt.Errorf("%w: %q exceeded %d reads per hour", ErrDenied, name, pol.MaxReadsPerHour)
+		}
+		if err := e.confirmOp(pol, "read", name, client); err != nil {
+			release()
+			return err
+		}
+		return nil
+	}
+
+	return e.confirmOp(pol, "read", name, client)
+}
+
+// AuthorizeWrite decides whether client may store credential name
+func (e *Enforcer) AuthorizeWrite(name string, client Client) error {
+	return e.authorizeChange("write", name, client)
+}
+
+// AuthorizeDelete decides whether client may delete credential name
+func (e *Enforcer) AuthorizeDelete(name string, client Client) error {
+	return e.authorizeChange("delete", name, client)
+}
+
+// authorizeChange checks a write or delete: the same client checks as a read, then
+// ReadOnly and confirmation. Changes do not count towards the read rate limit.
+func (e *Enforcer) authorizeChange(verb, name string, client Client) error {
+	pol := e.policies.lookup(name)
+	if pol == nil {
+		return nil
+	}
+
+	if err := pol.checkClient(verb, name, client); err != nil {
+		return err
+	}
+	if pol.ReadOnly {
+		return fmt.Errorf("%w: %q is read-only through the agent", ErrDenied, name)
+	}
+	return e.confirmOp(pol, verb, name, client)
+}
+
+// Visible reports whether List shows credential name to client: whether the client's
+// binary and UID may read it. It never prompts and does not count as a read.
+func (e *Enforcer) Visible(name string, client Client) bool {
+	pol := e.policies.lookup(name)
+	return pol == nil || pol.checkClient("read", name, client) == nil
+}
+
+// checkClient applies the binary and UID restrictions of the policy
+func (pol *Policy) checkClient(verb, name string, client Client) error {
+	if len(pol.AllowedBinaries) > 0 && !binaryAllowed(pol.AllowedBinaries, client.Executable) {
+		return fmt.Errorf("%w: %s may not %s %q", ErrDenied, client, verb, name)
+	}
+	if len(pol.AllowedUIDs) > 0 && !slices.Contains(pol.AllowedUIDs, client.UID) {
+		return fmt.Errorf("%w: uid %d may not %s %q", ErrDenied, client.UID, verb, name)
+	}
+	return nil
+}
+
+// confirmOp asks the user to approve the operation if the policy requires it
+func (e *Enforcer) confirmOp(pol *Policy, verb, name string, client Client) error {
+	if !pol.Confirm {
+		return nil
+	}
+	if e.confirm == nil {
+		return fmt.Errorf("%w: %q requires confirmation but no confirmer is configured", ErrDenied, name)
+	}
+	ok, err := e.confirm.Confirm(fmt.Sprintf("Allow %s to %s credential %q?", client, verb, name))
+	if err != nil {
+		return fmt.Errorf("%w: confirmation failed: %v", ErrDenied, err)
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s of %q was not confirmed", ErrDenied, verb, name)
+	}
+	return nil
+}
+
+// reserveRead records a read of name unless max reads happened within the last
+// hour, dropping older entries. The returned func takes the read back.
+func (e *Enforcer) reserveRead(name string, max int) (release func(), ok bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	cutoff := now.Add(-time.Hour)
+	recent := e.reads[name][:0]
+	for _, t := range e.reads[name] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= max {
+		e.reads[name] = recent
+		return nil, false
+	}
+	e.reads[name] = append(recent, now)
+
+	return func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if i := slices.IndexFunc(e.reads[name], now.Equal); i >= 0 {
+			e.reads[name] = slices.Delete(e.reads[name], i, i+1)
+		}
+	}, true
+}
+
+// binaryAllowed matches exe against full paths or base names
+func binaryAllowed(allowed []string, exe string) bool {
+	if exe == "" {
+		return false
+	}
+	for _, a := range allowed {
+		if strings.ContainsRune(a, '/') || strings.ContainsRune(a, filepath.Separator) {
+			if filepath.Clean(a) == filepath.Clean(exe) {
+				return true
+			}
+		} else if a == filepath.Base(exe) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,272 @@
+package agent
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeConfirmer answers every prompt with a fixed response
+type fakeConfirmer struct {
+	approve bool
+	prompts int
+}
+
+func (f *fakeConfirmer) Confirm(prompt string) (bool, error) {
+	f.prompts++
+	return f.approve, nil
+}
+
+var netcrawl = Client{PID: 100, UID: 1000, Executable: "/usr/local/bin/netcrawl"}
+
+func TestAuthorizeBinaryAndUID(t *testing.T) {
+	e := NewEnforcer(Policies{Credentials: map[string]Policy{
+		"ssh":      {AllowedBinaries: []string{"netcrawl"}},
+		"prod-api": {AllowedBinaries: []string{"/opt/deploy/bin/deployer"}},
+		"root-key": {AllowedUIDs: []int{0}},
+	}}, nil)
+
+	if err := e.Authorize("ssh", netcrawl); err != nil {
+		t.Errorf("netcrawl reading ssh: %v", err)
+	}
+	if err := e.Authorize("prod-api", netcrawl); !errors.Is(err, ErrDenied) {
+		t.Errorf("netcrawl reading prod-api error = %v, want ErrDenied", err)
+	}
+	if err := e.Authorize("root-key", netcrawl); !errors.Is(err, ErrDenied) {
+		t.Errorf("uid 1000 reading root-key error = %v, want ErrDenied", err)
+	}
+	if err := e.Authorize("unrestricted", netcrawl); err != nil {
+		t.Errorf("reading credential without policy: %v", err)
+	}
+
+	unknown := Client{PID: 5, UID: 1000}
+	if err := e.Authorize("ssh", unknown); !errors.Is(err, ErrDenied) {
+		t.Errorf("client with unknown binary error = %v, want ErrDenied", err)
+	}
+}
+
+func TestAuthorizeWriteAndDelete(t *testing.T) {
+	e := NewEnforcer(Policies{Credentials: map[string]Policy{
+		"ssh":      {AllowedBinaries: []string{"netcrawl"}},
+		"prod-api": {AllowedBinaries: []string{"/opt/deploy/bin/deployer"}},
+		"root-key": {AllowedUIDs: []int{0}},
+		"pinned":   {ReadOnly: true},
+	}}, nil)
+
+	if err := e.AuthorizeWrite("ssh", netcrawl); err != nil {
+		t.Errorf("netcrawl writing ssh: %v", err)
+	}
+	if err := e.AuthorizeWrite("prod-api", netcrawl); !errors.Is(err, ErrDenied) {
+		t.Errorf("netcrawl writing prod-api error = %v, want ErrDenied", err)
+	}
+	if err := e.AuthorizeDelete("root-key", netcrawl); !errors.Is(err, ErrDenied) {
+		t.Errorf("uid 1000 deleting root-key error = %v, want ErrDenied", err)
+	}
+	if err := e.Authorize("pinned", netcrawl); err != nil {
+		t.Errorf("reading read-only credential: %v", err)
+	}
+	if err := e.AuthorizeWrite("pinned", netcrawl); !errors.Is(err, ErrDenied) {
+		t.Errorf("writing read-only credential error = %v, want ErrDenied", err)
+	}
+	if err := e.AuthorizeDelete("pinned", netcrawl); !errors.Is(err, ErrDenied) {
+		t.Errorf("deleting read-only credential error = %v, want ErrDenied", err)
+	}
+	if err := e.AuthorizeDelete("unrestricted", netcrawl); err != nil {
+		t.Errorf("deleting credential without policy: %v", err)
+	}
+}
+
+func TestAuthorizeChangeConfirm(t *testing.T) {
+	policies := Policies{Credentials: map[string]Policy{"prod-api": {Confirm: true}}}
+
+	approve := &fakeConfirmer{approve: true}
+	if err := NewEnforcer(policies, approve).AuthorizeWrite("prod-api", netcrawl); err != nil {
+		t.Errorf("approved write: %v", err)
+	}
+	if approve.prompts != 1 {
+		t.Errorf("prompts = %d, want 1", approve.prompts)
+	}
+	reject := &fakeConfirmer{approve: false}
+	if err := NewEnforcer(policies, reject).AuthorizeDelete("prod-api", netcrawl); !errors.Is(err, ErrDenied) {
+		t.Errorf("rejected delete error = %v, want ErrDenied", err)
+	}
+}
+
+func TestVisible(t *testing.T) {
+	confirm := &fakeConfirmer{approve: true}
+	e := NewEnforcer(Policies{Credentials: map[string]Policy{
+		"prod-api": {AllowedBinaries: []string{"/opt/deploy/bin/deployer"}},
+		"token":    {Confirm: true, MaxReadsPerHour: 1},
+	}}, confirm)
+
+	if e.Visible("prod-api", netcrawl) {
+		t.Error("prod-api visible to a binary that may not read it")
+	}
+	if !e.Visible("token", netcrawl) || !e.Visible("other", netcrawl) {
+		t.Error("readable credentials hidden")
+	}
+	if confirm.prompts != 0 {
+		t.Errorf("Visible prompted %d times", confirm.prompts)
+	}
+	if err := e.Authorize("token", netcrawl); err != nil {
+		t.Errorf("Visible counted towards the rate limit: %v", err)
+	}
+}
+
+func TestAuthorizePatternsAndDefault(t *testing.T) {
+	e := NewEnforcer(Policies{
+		Default: &Policy{AllowedUIDs: []int{1000}},
+		Credentials: map[string]Policy{
+			"prod-*":    {AllowedBinaries: []string{"deployer"}},
+			"prod-ro-*": {},
+		},
+	}, nil)
+
+	if err := e.Authorize("prod-db", netcrawl); !errors.Is(err, ErrDenied) {
+		t.Errorf("prod-* pattern not applied: %v", err)
+	}
+	if err := e.Authorize("prod-ro-db", netcrawl); err != nil {
+		t.Errorf("more specific pattern should win: %v", err)
+	}
+	if err := e.Authorize("other", Client{UID: 1001, Executable: "/bin/sh"}); !errors.Is(err, ErrDenied) {
+		t.Errorf("default policy not applied: %v", err)
+	}
+}
+
+func TestAuthorizeRateLimit(t *testing.T) {
+	e := NewEnforcer(Policies{Credentials: map[string]Policy{
+		"token": {MaxReadsPerHour: 2},
+	}}, nil)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	e.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if err := e.Authorize("token", netcrawl); err != nil {
+			t.Fatalf("read %d: %v", i, err)
+		}
+	}
+	if err := e.Authorize("token", netcrawl); !errors.Is(err, ErrDenied) {
+		t.Errorf("third read error = %v, want ErrDenied", err)
+	}
+
+	now = now.Add(61 * time.Minute)
+	if err := e.Authorize("token", netcrawl); err != nil {
+		t.Errorf("read after window: %v", err)
+	}
+}
+
+// gateConfirmer approves every prompt once the gate is opened
+type gateConfirmer struct {
+	prompts atomic.Int32
+	gate    chan struct{}
+}
+
+func (g *gateConfirmer) Confirm(prompt string) (bool, error) {
+	g.prompts.Add(1)
+	<-g.gate
+	return true, nil
+}
+
+func TestAuthorizeRateLimitConcurrent(t *testing.T) {
+	const limit, clients = 3, 10
+	confirm := &gateConfirmer{gate: make(chan struct{})}
+	e := NewEnforcer(Policies{Credentials: map[string]Policy{
+		"token": {Confirm: true, MaxReadsPerHour: limit},
+	}}, confirm)
+
+	results := make(chan error, clients)
+	for i := 0; i < clients; i++ {
+		go func() { results <- e.Authorize("token", netcrawl) }()
+	}
+
+	// Reads beyond the limit are refused while the first ones wait for confirmation
+	timeout := time.After(5 * time.Second)
+	for i := 0; i < clients-limit; i++ {
+		select {
+		case err := <-results:
+			if !errors.Is(err, ErrDenied) {
+				t.Fatalf("read over the limit error = %v, want ErrDenied", err)
+			}
+		case <-timeout:
+			t.Fatalf("only %d of %d reads over the limit were refused before confirmation", i, clients-limit)
+		}
+	}
+	close(confirm.gate)
+	for i := 0; i < limit; i++ {
+		if err := <-results; err != nil {
+			t.Errorf("confirmed read: %v", err)
+		}
+	}
+	if n := confirm.prompts.Load(); n != limit {
+		t.Errorf("prompts = %d, want %d", n, limit)
+	}
+}
+
+func TestAuthorizeRateLimitReleasedOnDenial(t *testing.T) {
+	confirm := &fakeConfirmer{approve: false}
+	e := NewEnforcer(Policies{Credentials: map[string]Policy{
+		"token": {Confirm: true, MaxReadsPerHour: 1},
+	}}, confirm)
+
+	if err := e.Authorize("token", netcrawl); !errors.Is(err, ErrDenied) {
+		t.Fatalf("rejected read error = %v, want ErrDenied", err)
+	}
+	confirm.approve = true
+	if err := e.Authorize("token", netcrawl); err != nil {
+		t.Errorf("read after a rejected one: %v", err)
+	}
+	if err := e.Authorize("token", netcrawl); !errors.Is(err, ErrDenied) {
+		t.Errorf("second approved read error = %v, want ErrDenied", err)
+	}
+}
+
+func TestAuthorizeConfirm(t *testing.T) {
+	policies := Policies{Credentials: map[string]Policy{"prod-api": {Confirm: true}}}
+
+	approve := &fakeConfirmer{approve: true}
+	if err := NewEnforcer(policies, approve).Authorize("prod-api", netcrawl); err != nil {
+		t.Errorf("approved read: %v", err)
+	}
+	if approve.prompts != 1 {
+		t.Errorf("prompts = %d, want 1", approve.prompts)
+	}
+
+	reject := &fakeConfirmer{approve: false}
+	if err := NewEnforcer(policies, reject).Authorize("prod-api", netcrawl); !errors.Is(err, ErrDenied) {
+		t.Errorf("rejected read error = %v, want ErrDenied", err)
+	}
+
+	if err := NewEnforcer(policies, nil).Authorize("prod-api", netcrawl); !errors.Is(err, ErrDenied) {
+		t.Errorf("read without confirmer error = %v, want ErrDenied", err)
+	}
+}
+
+func TestLoadPolicies(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policies.json")
+
+	if p, err := LoadPolicies(file); err != nil || p.Credentials != nil {
+		t.Fatalf("LoadPolicies(missing) = %v, %v; want empty, nil", p, err)
+	}
+
+	data := `{"credentials": {"ssh": {"allowed_binaries": ["netcrawl"], "max_reads_per_hour": 10}}}`
+	if err := os.WriteFile(file, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := LoadPolicies(file)
+	if err != nil {
+		t.Fatalf("LoadPolicies failed: %v", err)
+	}
+	if got := p.Credentials["ssh"].MaxReadsPerHour; got != 10 {
+		t.Errorf("MaxReadsPerHour = %d, want 10", got)
+	}
+
+	if err := os.WriteFile(file, []byte(`{"credentials": {"[": {}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPolicies(file); err == nil {
+		t.Error("LoadPolicies should reject invalid patterns")
+	}
+}
@@ -0,0 +1,237 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/nzions/fdot/pkg/fdh"
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+	"github.com/nzions/fdot/pkg/fdotconfig"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// unknownClient stands in for peers whose process cannot be identified
+var unknownClient = Client{UID: -1}
+
+// DefaultSocketPath returns the agent socket path: $CREDMGR_AGENT_SOCK if set,
+// otherwise agent.sock next to the default credential file.
+func DefaultSocketPath() (string, error) {
+	if sock := os.Getenv(fdotconfig.CredMgrEnvVarAgentSock); sock != "" {
+		return sock, nil
+	}
+	credFile, err := credmgr.DefaultFilePath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(credFile), "agent.sock"), nil
+}
+
+// Server serves an unlocked CredManager to local clients over gRPC.
+// Every operation is checked against the Enforcer's policies; the master key never leaves the agent.
+type Server struct {
+	cm       credmgr.CredManager
+	enforcer *Enforcer
+	grpc     *grpc.Server
+	uid      int // the only user whose processes may connect
+
+	mu      sync.Mutex
+	stopped bool
+	framed  map[io.Closer]struct{} // framed protocol listeners and connections
+}
+
+// NewServer returns a Server for cm. enforcer may be nil to allow every operation.
+func NewServer(cm credmgr.CredManager, enforcer *Enforcer) *Server {
+	if enforcer == nil {
+		enforcer = NewEnforcer(Policies{}, nil)
+	}
+	s := &Server{
+		cm:       cm,
+		enforcer: enforcer,
+		grpc:     grpc.NewServer(),
+		uid:      os.Geteuid(),
+		framed:   make(map[io.Closer]struct{}),
+	}
+	s.grpc.RegisterService(&serviceDesc, s)
+	return s
+}
+
+// Listen creates the agent's Unix socket at path, readable only by the current user.
+// A stale socket left by an agent that exited is replaced; a live one is an error.
+func Listen(path string) (net.Listener, error) {
+	if err := fdh.CreatePrivateDir(filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("an agent is already listening on %s", path)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := fdh.RestrictToOwner(path); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Serve accepts clients on l until Stop is called
+func (s *Server) Serve(l net.Listener) error {
+	err := s.grpc.Serve(peerListener{Listener: l, uid: s.uid})
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return nil
+	}
+	return err
+}
+
+// Stop closes the listeners: gRPC clients after in-flight requests finish, framed
+// protocol clients immediately
+func (s *Server) Stop() {
+	s.mu.Lock()
+	s.stopped = true
+	for c := range s.framed {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	s.grpc.GracefulStop()
+}
+
+// track registers a framed listener or connection to be closed by Stop.
+// It reports false once the server is stopped.
+func (s *Server) track(c io.Closer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return false
+	}
+	s.framed[c] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(c io.Closer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.framed, c)
+}
+
+func (s *Server) isStopped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped
+}
+
+func (s *Server) read(ctx context.Context, req *nameRequest) (*readResponse, error) {
+	if err := s.enforcer.Authorize(req.Name, clientFromContext(ctx)); err != nil {
+		return nil, toStatus(err)
+	}
+	data, err := s.cm.Read(req.Name)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &readResponse{Data: data}, nil
+}
+
+func (s *Server) write(ctx context.Context, req *writeRequest) (*empty, error) {
+	if err := s.enforcer.AuthorizeWrite(req.Name, clientFromContext(ctx)); err != nil {
+		return nil, toStatus(err)
+	}
+	return &empty{}, toStatus(s.cm.Write(req.Name, req.Data))
+}
+
+func (s *Server) delete(ctx context.Context, req *nameRequest) (*empty, error) {
+	if err := s.enforcer.AuthorizeDelete(req.Name, clientFromContext(ctx)); err != nil {
+		return nil, toStatus(err)
+	}
+	return &empty{}, toStatus(s.cm.Delete(req.Name))
+}
+
+// list returns only the names the client may read
+func (s *Server) list(ctx context.Context, req *empty) (*listResponse, error) {
+	names, err := s.cm.List()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	client := clientFromContext(ctx)
+	names = slices.DeleteFunc(names, func(name string) bool { return !s.enforcer.Visible(name, client) })
+	return &listResponse{Names: names}, nil
+}
+
+// peerAddr is the remote address of an accepted connection: the peer's identity,
+// since Unix socket clients are usually unnamed
+type peerAddr struct {
+	client Client
+}
+
+func (a peerAddr) Network() string { return "unix" }
+func (a peerAddr) String() string  { return a.client.String() }
+
+// peerListener identifies each client when it connects, while the peer is certainly
+// still the process that opened the connection, and drops clients of other users
+type peerListener struct {
+	net.Listener
+	uid int
+}
+
+func (l peerListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		client, err := identifyPeer(conn, l.uid)
+		if err != nil {
+			// Returning the error would stop the gRPC server
+			conn.Close()
+			continue
+		}
+		return peerConn{Conn: conn, addr: peerAddr{client: client}}, nil
+	}
+}
+
+// identifyPeer identifies the process on the other end of conn and fails unless it
+// runs as uid. The socket is private to its owner, but root can still connect, as
+// can a process that was handed an open descriptor. Where the platform cannot
+// identify peers, the socket permissions are the only check.
+func identifyPeer(conn net.Conn, uid int) (Client, error) {
+	client, err := peerClient(conn)
+	if err != nil {
+		return unknownClient, fmt.Errorf("%w: failed to identify client: %v", ErrDenied, err)
+	}
+	if peerCredentials && client.UID != uid {
+		return client, fmt.Errorf("%w: %s does not run as uid %d", ErrDenied, client, uid)
+	}
+	return client, nil
+}
+
+// peerConn reports peerAddr as its remote address, which gRPC exposes through peer.FromContext
+type peerConn struct {
+	net.Conn
+	addr peerAddr
+}
+
+func (c peerConn) RemoteAddr() net.Addr { return c.addr }
+
+// clientFromContext returns the identity of the client making a request
+func clientFromContext(ctx context.Context) Client {
+	if p, ok := peer.FromContext(ctx); ok {
+		if addr, ok := p.Addr.(peerAddr); ok {
+			return addr.client
+		}
+	}
+	return unknownClient
+}
@@ -0,0 +1,275 @@
+package agent
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+)
+
+// memStore is an in-memory credmgr.Store
+type memStore map[string][]byte
+
+func (m memStore) Read(name string) ([]byte, error) {
+	data, ok := m[name]
+	if !ok {
+		return nil, credmgr.ErrNotFound
+	}
+	return data, nil
+}
+
+func (m memStore) Write(name string, data []byte) error {
+	m[name] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m memStore) Delete(name string) error {
+	if _, ok := m[name]; !ok {
+		return credmgr.ErrNotFound
+	}
+	delete(m, name)
+	return nil
+}
+
+func (m memStore) DeleteDB() error {
+	clear(m)
+	return nil
+}
+
+func (m memStore) List() ([]string, error) {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// startAgent serves cm on a socket in a temporary directory and returns a connected Remote
+func startAgent(t *testing.T, cm credmgr.CredManager, enforcer *Enforcer) *Remote {
+	t.Helper()
+
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := Listen(sock)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	srv := NewServer(cm, enforcer)
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+
+	remote, err := Dial(sock)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { remote.Close() })
+	return remote
+}
+
+func TestRemoteReadWriteList(t *testing.T) {
+	backing := credmgr.NewFromStore(memStore{})
+	remote := startAgent(t, backing, nil)
+
+	if err := remote.WriteKey("token", "secret"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+	if err := remote.WriteUserCred("ssh", credmgr.NewUnPw("admin", "pw")); err != nil {
+		t.Fatalf("WriteUserCred failed: %v", err)
+	}
+
+	if got, err := backing.ReadKey("token"); err != nil || got != "secret" {
+		t.Errorf("backing ReadKey = %q, %v; want %q", got, err, "secret")
+	}
+	if got, err := remote.ReadKey("token"); err != nil || got != "secret" {
+		t.Errorf("remote ReadKey = %q, %v; want %q", got, err, "secret")
+	}
+	uc, err := remote.ReadUserCred("ssh")
+	if err != nil || uc.Username() != "admin" || uc.Password() != "pw" {
+		t.Errorf("remote ReadUserCred = %v, %v", uc, err)
+	}
+
+	names, err := remote.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"ssh", "token"}) {
+		t.Errorf("List = %v, want [ssh token]", names)
+	}
+
+	if err := remote.Delete("token"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := remote.ReadKey("token"); !errors.Is(err, credmgr.ErrNotFound) {
+		t.Errorf("ReadKey after Delete error = %v, want ErrNotFound", err)
+	}
+	// Deletes go to the trash of the agent's store
+	if trash, err := backing.ListTrash(); err != nil || len(trash) != 1 || trash[0].Name != "token" {
+		t.Errorf("backing ListTrash = %v, %v; want token", trash, err)
+	}
+	if err := remote.DeleteDB(); !errors.Is(err, credmgr.ErrNotSupported) {
+		t.Errorf("remote DeleteDB error = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestRemoteDeleteBatch(t *testing.T) {
+	backing := credmgr.NewFromStore(memStore{})
+	for _, name := range []string{"a", "b", "c"} {
+		if err := backing.WriteKey(name, "v"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	remote := startAgent(t, backing, nil)
+
+	if err := remote.DeleteBatch([]string{"a", "b"}); err != nil {
+		t.Fatalf("DeleteBatch failed: %v", err)
+	}
+	if names, err := remote.List(); err != nil || !slices.Equal(names, []string{"c"}) {
+		t.Errorf("List after DeleteBatch = %v, %v; want [c]", names, err)
+	}
+	if trash, err := backing.ListTrash(); err != nil || len(trash) != 2 {
+		t.Errorf("agent store trash = %v, %v; want 2 entries", trash, err)
+	}
+
+	if err := remote.DeleteBatch([]string{"c", "missing"}); !errors.Is(err, credmgr.ErrNotFound) {
+		t.Errorf("DeleteBatch with missing name error = %v, want ErrNotFound", err)
+	}
+	if _, err := backing.ReadKey("c"); err != nil {
+		t.Errorf("failed DeleteBatch removed a credential: %v", err)
+	}
+}
+
+func TestRemotePolicyDenied(t *testing.T) {
+	backing := credmgr.NewFromStore(memStore{})
+	if err := backing.WriteKey("prod-api", "v"); err != nil {
+		t.Fatal(err)
+	}
+	enforcer := NewEnforcer(Policies{Credentials: map[string]Policy{
+		"prod-api": {AllowedBinaries: []string{"/opt/deploy/bin/deployer"}},
+	}}, nil)
+	remote := startAgent(t, backing, enforcer)
+
+	if _, err := remote.ReadKey("prod-api"); !errors.Is(err, ErrDenied) {
+		t.Errorf("ReadKey of restricted credential error = %v, want ErrDenied", err)
+	}
+}
+
+func TestRemotePolicyChangesAndList(t *testing.T) {
+	backing := credmgr.NewFromStore(memStore{})
+	for _, name := range []string{"prod-api", "pinned", "token"} {
+		if err := backing.WriteKey(name, "v"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	enforcer := NewEnforcer(Policies{Credentials: map[string]Policy{
+		"prod-api": {AllowedBinaries: []string{"/opt/deploy/bin/deployer"}},
+		"pinned":   {ReadOnly: true},
+	}}, nil)
+	remote := startAgent(t, backing, enforcer)
+
+	if err := remote.WriteKey("prod-api", "x"); !errors.Is(err, ErrDenied) {
+		t.Errorf("WriteKey of restricted credential error = %v, want ErrDenied", err)
+	}
+	if err := remote.Delete("prod-api"); !errors.Is(err, ErrDenied) {
+		t.Errorf("Delete of restricted credential error = %v, want ErrDenied", err)
+	}
+	if err := remote.WriteKey("pinned", "x"); !errors.Is(err, ErrDenied) {
+		t.Errorf("WriteKey of read-only credential error = %v, want ErrDenied", err)
+	}
+	if got, err := backing.ReadKey("prod-api"); err != nil || got != "v" {
+		t.Errorf("restricted credential changed: %q, %v", got, err)
+	}
+
+	names, err := remote.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	slices.Sort(names)
+	if want := []string{"pinned", "token"}; !slices.Equal(names, want) {
+		t.Errorf("List = %v, want %v", names, want)
+	}
+}
+
+func TestRemotePeerCredentials(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only available on Linux")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backing := credmgr.NewFromStore(memStore{})
+	if err := backing.WriteKey("token", "v"); err != nil {
+		t.Fatal(err)
+	}
+	enforcer := NewEnforcer(Policies{Credentials: map[string]Policy{
+		"token": {AllowedBinaries: []string{filepath.Base(exe)}, AllowedUIDs: []int{os.Getuid()}},
+	}}, nil)
+	remote := startAgent(t, backing, enforcer)
+
+	if got, err := remote.ReadKey("token"); err != nil || got != "v" {
+		t.Errorf("ReadKey by allowed binary = %q, %v; want %q", got, err, "v")
+	}
+}
+
+func TestRemoteOtherUserRejected(t *testing.T) {
+	if !peerCredentials {
+		t.Skip("peer credentials are not available on " + runtime.GOOS)
+	}
+	backing := credmgr.NewFromStore(memStore{})
+	if err := backing.WriteKey("token", "v"); err != nil {
+		t.Fatal(err)
+	}
+
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := Listen(sock)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	srv := NewServer(backing, nil)
+	srv.uid = os.Geteuid() + 1 // as if the agent ran as another user
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+
+	remote, err := Dial(sock)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer remote.Close()
+
+	if got, err := remote.ReadKey("token"); err == nil {
+		t.Errorf("ReadKey by another user = %q, want the connection refused", got)
+	}
+}
+
+func TestRemoteUnavailable(t *testing.T) {
+	remote, err := Dial(filepath.Join(t.TempDir(), "missing.sock"))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer remote.Close()
+
+	if _, err := remote.ReadKey("token"); !errors.Is(err, ErrAgentUnavailable) {
+		t.Errorf("ReadKey without agent error = %v, want ErrAgentUnavailable", err)
+	}
+}
+
+func TestListenRejectsLiveSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := Listen(sock)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer l.Close()
+
+	if info, err := os.Stat(sock); err == nil && info.Mode().Perm()&0o077 != 0 {
+		t.Errorf("socket mode = %v, want owner-only", info.Mode().Perm())
+	}
+	if _, err := Listen(sock); err == nil {
+		t.Error("second Listen on a live socket should fail")
+	}
+}
@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+// The agent's gRPC service is described by hand rather than generated from a .proto:
+// messages are plain Go structs carried by a JSON codec, so building fdot needs no
+// protoc toolchain. Other languages can call it with any gRPC client that sends
+// content-type application/grpc+credmgr-json.
+
+// serviceName is the full gRPC service name
+const serviceName = "credmgr.agent.v1.Agent"
+
+// codecName is the gRPC content subtype of the agent's JSON messages
+const codecName = "credmgr-json"
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// jsonCodec encodes agent messages as JSON
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                       { return codecName }
+
+// Wire messages
+type (
+	nameRequest struct {
+		Name string `json:"name"`
+	}
+	readResponse struct {
+		Data []byte `json:"data"`
+	}
+	writeRequest struct {
+		Name string `json:"name"`
+		Data []byte `json:"data"`
+	}
+	listResponse struct {
+		Names []string `json:"names"`
+	}
+	empty struct{}
+)
+
+// service is the server side of the agent API
+type service interface {
+	read(ctx context.Context, req *nameRequest) (*readResponse, error)
+	write(ctx context.Context, req *writeRequest) (*empty, error)
+	delete(ctx context.Context, req *nameRequest) (*empty, error)
+	list(ctx context.Context, req *empty) (*listResponse, error)
+}
+
+// unaryHandler adapts a typed service method to a gRPC method handler
+func unaryHandler[Req, Resp any](method string, call func(service, context.Context, *Req) (*Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: method,
+		Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+			req := new(Req)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			if interceptor == nil {
+				return call(srv.(service), ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/" + method}
+			return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+				return call(srv.(service), ctx, req.(*Req))
+			})
+		},
+	}
+}
+
+// serviceDesc registers the agent methods with a grpc.Server
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*service)(nil),
+	Methods: []grpc.MethodDesc{
+		unaryHandler("Read", service.read),
+		unaryHandler("Write", service.write),
+		unaryHandler("Delete", service.delete),
+		unaryHandler("List", service.list),
+	},
+}
+
+// toStatus maps credmgr and policy errors to gRPC status codes, so clients can
+// restore the sentinel errors callers check with errors.Is
+func toStatus(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, credmgr.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrDenied), errors.Is(err, credmgr.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, credmgr.ErrReadOnly):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// fromStatus is the client-side inverse of toStatus
+func fromStatus(err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch st.Code() {
+	case codes.OK:
+		return nil
+	case codes.NotFound:
+		return &remoteError{msg: st.Message(), sentinel: credmgr.ErrNotFound}
+	case codes.PermissionDenied:
+		return &remoteError{msg: st.Message(), sentinel: ErrDenied}
+	case codes.FailedPrecondition:
+		return &remoteError{msg: st.Message(), sentinel: credmgr.ErrReadOnly}
+	case codes.Unavailable:
+		return &remoteError{msg: "credmgr agent unavailable: " + st.Message(), sentinel: ErrAgentUnavailable}
+	default:
+		return &remoteError{msg: "credmgr agent: " + st.Message()}
+	}
+}
+
+// remoteError carries the agent's error message and the sentinel it wrapped
+type remoteError struct {
+	msg      string
+	sentinel error
+}
+
+func (e *remoteError) Error() string { return e.msg }
+func (e *remoteError) Unwrap() error { return e.sentinel }
@@ -0,0 +1,232 @@
+package credmgr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh"
+)
+
+// AuditOp names the credential operation recorded in an AuditEvent
+type AuditOp string
+
+// Audited operations
+const (
+	AuditRead      AuditOp = "read"
+	AuditWrite     AuditOp = "write"
+	AuditDelete    AuditOp = "delete"
+	AuditDeleteDB  AuditOp = "delete_db"
+	AuditRestore   AuditOp = "restore"
+	AuditPurge     AuditOp = "purge"
+	AuditExport    AuditOp = "export"
+	AuditImport    AuditOp = "import"
+	AuditVerify    AuditOp = "verify"
+	AuditSubscribe AuditOp = "subscribe"
+	AuditMigrate   AuditOp = "migrate" // a legacy entry was rewritten in the current format
+)
+
+// AuditEvent records one access to the credential store.
+// It is a plain struct so callers can forward it as an eventstream event.
+type AuditEvent struct {
+	Time    time.Time `json:"time"`
+	Op      AuditOp   `json:"op"`
+	Name    string    `json:"name,omitempty"` // empty for whole-store operations
+	Caller  string    `json:"caller"`         // executable name of the calling process
+	PID     int       `json:"pid"`
+	Success bool      `json:"success"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// AuditHook receives an AuditEvent after every audited operation
+type AuditHook func(AuditEvent)
+
+// WithAudit calls hook for every Read, Write, Delete and whole-store operation.
+// Values are never included in events. To forward events to an eventstream handler:
+//
+//	cm, err := credmgr.Open("", credmgr.WithAudit(func(e credmgr.AuditEvent) { log.Send(e) }))
+func WithAudit(hook AuditHook) Option {
+	return func(o *openOptions) {
+		o.auditHooks = append(o.auditHooks, hook)
+	}
+}
+
+// WithAuditFile appends every AuditEvent to path as a JSON line.
+// Open fails if the file cannot be created; later write errors are ignored so
+// that a full disk does not block credential access.
+func WithAuditFile(path string) Option {
+	return func(o *openOptions) {
+		o.auditFiles = append(o.auditFiles, path)
+	}
+}
+
+// auditFileHook returns a hook appending events to path
+func auditFileHook(path string) (AuditHook, error) {
+	if err := fdh.CreatePrivateDir(filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	if err := fdh.AppendPrivateFile(path, nil); err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	return func(e AuditEvent) {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return
+		}
+		_ = fdh.AppendPrivateFile(path, append(line, '\n'))
+	}, nil
+}
+
+// auditCredManager reports every operation on the wrapped CredManager to its hooks
+type auditCredManager struct {
+	CredManager
+	hooks  []AuditHook
+	caller string
+	pid    int
+	now    func() time.Time
+}
+
+// newAuditCredManager wraps cm, turning the audit options into hooks
+func newAuditCredManager(cm CredManager, o *openOptions) (CredManager, error) {
+	hooks := append([]AuditHook(nil), o.auditHooks...)
+	for _, path := range o.auditFiles {
+		hook, err := auditFileHook(path)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+
+	return &auditCredManager{
+		CredManager: cm,
+		hooks:       hooks,
+		caller:      filepath.Base(os.Args[0]),
+		pid:         os.Getpid(),
+		now:         time.Now,
+	}, nil
+}
+
+// emit sends one event to every hook and returns err unchanged
+func (a *auditCredManager) emit(op AuditOp, name string, err error) error {
+	e := AuditEvent{
+		Time:    a.now(),
+		Op:      op,
+		Name:    name,
+		Caller:  a.caller,
+		PID:     a.pid,
+		Success: err == nil,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	for _, hook := range a.hooks {
+		hook(e)
+	}
+	return err
+}
+
+func (a *auditCredManager) Read(name string) ([]byte, error) {
+	data, err := a.CredManager.Read(name)
+	return data, a.emit(AuditRead, name, err)
+}
+
+func (a *auditCredManager) ReadInto(name string, buf []byte) (int, error) {
+	n, err := a.CredManager.ReadInto(name, buf)
+	return n, a.emit(AuditRead, name, err)
+}
+
+func (a *auditCredManager) Write(name string, data []byte) error {
+	return a.emit(AuditWrite, name, a.CredManager.Write(name, data))
+}
+
+func (a *auditCredManager) WriteIfNotExists(name string, data []byte) error {
+	return a.emit(AuditWrite, name, a.CredManager.WriteIfNotExists(name, data))
+}
+
+func (a *auditCredManager) ReadKey(name string) (string, error) {
+	key, err := a.CredManager.ReadKey(name)
+	return key, a.emit(AuditRead, name, err)
+}
+
+func (a *auditCredManager) WriteKey(name, key string) error {
+	return a.emit(AuditWrite, name, a.CredManager.WriteKey(name, key))
+}
+
+func (a *auditCredManager) ReadUserCred(name string) (UserCred, error) {
+	cred, err := a.CredManager.ReadUserCred(name)
+	if uc, ok := cred.(*obfuscatedUserCred); ok && uc.migrated {
+		a.emit(AuditMigrate, name, nil)
+	}
+	return cred, a.emit(AuditRead, name, err)
+}
+
+func (a *auditCredManager) WriteUserCred(name string, cred UserCred) error {
+	return a.emit(AuditWrite, name, a.CredManager.WriteUserCred(name, cred))
+}
+
+func (a *auditCredManager) Delete(name string) error {
+	return a.emit(AuditDelete, name, a.CredManager.Delete(name))
+}
+
+// WriteBatch records one event per credential, all with the batch's result
+func (a *auditCredManager) WriteBatch(creds map[string][]byte) error {
+	err := a.CredManager.WriteBatch(creds)
+	for _, name := range slices.Sorted(maps.Keys(creds)) {
+		a.emit(AuditWrite, name, err)
+	}
+	return err
+}
+
+// DeleteBatch records one event per credential, all with the batch's result
+func (a *auditCredManager) DeleteBatch(names []string) error {
+	err := a.CredManager.DeleteBatch(names)
+	for _, name := range names {
+		a.emit(AuditDelete, name, err)
+	}
+	return err
+}
+
+// Rename records a delete of the old name and a write of the new one
+func (a *auditCredManager) Rename(oldName, newName string) error {
+	err := a.CredManager.Rename(oldName, newName)
+	a.emit(AuditDelete, oldName, err)
+	return a.emit(AuditWrite, newName, err)
+}
+
+func (a *auditCredManager) DeleteDB() error {
+	return a.emit(AuditDeleteDB, "", a.CredManager.DeleteDB())
+}
+
+func (a *auditCredManager) Restore(name string) error {
+	return a.emit(AuditRestore, name, a.CredManager.Restore(name))
+}
+
+func (a *auditCredManager) Purge(name string) error {
+	return a.emit(AuditPurge, name, a.CredManager.Purge(name))
+}
+
+func (a *auditCredManager) Export(w io.Writer, passphrase string) error {
+	return a.emit(AuditExport, "", a.CredManager.Export(w, passphrase))
+}
+
+func (a *auditCredManager) Import(r io.Reader, passphrase string, policy MergePolicy) error {
+	return a.emit(AuditImport, "", a.CredManager.Import(r, passphrase, policy))
+}
+
+func (a *auditCredManager) Verify() (VerifyReport, error) {
+	report, err := a.CredManager.Verify()
+	return report, a.emit(AuditVerify, "", err)
+}
+
+// Subscribe records the subscription once; the polls behind it are not audited
+func (a *auditCredManager) Subscribe(ctx context.Context, interval time.Duration) (<-chan ChangeEvent, error) {
+	ch, err := a.CredManager.Subscribe(ctx, interval)
+	return ch, a.emit(AuditSubscribe, "", err)
+}
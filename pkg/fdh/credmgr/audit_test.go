@@ -0,0 +1,130 @@
+package credmgr
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditHook(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	var events []AuditEvent
+	cm, err := Open(filepath.Join(t.TempDir(), "credentials.enc"), WithAudit(func(e AuditEvent) {
+		events = append(events, e)
+	}))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	if err := cm.WriteKey("token", "secret-value"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+	if _, err := cm.ReadKey("token"); err != nil {
+		t.Fatalf("ReadKey failed: %v", err)
+	}
+	if _, err := cm.Read("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Read(missing) error = %v, want ErrNotFound", err)
+	}
+	if err := cm.Delete("token"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	want := []struct {
+		op      AuditOp
+		name    string
+		success bool
+	}{
+		{AuditWrite, "token", true},
+		{AuditRead, "token", true},
+		{AuditRead, "missing", false},
+		{AuditDelete, "token", true},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, w := range want {
+		e := events[i]
+		if e.Op != w.op || e.Name != w.name || e.Success != w.success {
+			t.Errorf("event %d = %+v, want op=%s name=%s success=%v", i, e, w.op, w.name, w.success)
+		}
+		if e.Caller == "" || e.PID != os.Getpid() || e.Time.IsZero() {
+			t.Errorf("event %d missing caller metadata: %+v", i, e)
+		}
+	}
+	if events[2].Error == "" {
+		t.Error("failed event should carry the error")
+	}
+}
+
+func TestAuditFile(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "audit", "credmgr.log")
+	cm, err := Open(filepath.Join(dir, "credentials.enc"), ReadOnly(), WithAuditFile(logPath))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	// Denied writes are audited too
+	if err := cm.Write("token", []byte("secret-value")); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Write error = %v, want ErrReadOnly", err)
+	}
+	_, _ = cm.Read("token")
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		t.Fatalf("audit log not created: %v", err)
+	}
+	defer f.Close()
+
+	var events []AuditEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid audit line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+	if len(events) != 2 || events[0].Op != AuditWrite || events[0].Success || events[1].Op != AuditRead {
+		t.Errorf("audit log events = %+v", events)
+	}
+
+	data, _ := os.ReadFile(logPath)
+	if strings.Contains(string(data), "secret-value") {
+		t.Error("audit log must never contain credential values")
+	}
+}
+
+func TestAuditLogEnv(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "audit.log")
+	t.Setenv(AuditLogEnv, logPath)
+
+	cm, err := New(filepath.Join(dir, "credentials.enc"))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := cm.WriteKey("token", "v"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("audit log not written: %v", err)
+	}
+	if !strings.Contains(string(data), `"op":"write"`) {
+		t.Errorf("audit log = %q, want a write event", data)
+	}
+}
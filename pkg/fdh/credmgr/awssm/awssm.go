@@ -0,0 +1,266 @@
+// Package awssm provides a credmgr.CredManager backed by AWS Secrets Manager.
+//
+// Each credential is stored as a binary secret named <Prefix><name>. Authentication uses the
+// standard AWS credential chain (environment, shared config, EC2/ECS IAM roles), so CI jobs
+// and EC2-hosted crawls need no local key or encrypted file.
+//
+// With a recovery window, deleted secrets stay scheduled for deletion until it ends. The
+// credmgr trash deletes and recreates names as entries move in and out of it, so writing a
+// scheduled secret restores it first, and reads and deletes treat it as not found.
+package awssm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+)
+
+// DefaultPrefix is the secret name prefix used when Config.Prefix is empty
+const DefaultPrefix = "fdot/credmgr/"
+
+// defaultTimeout bounds each Secrets Manager API call
+const defaultTimeout = 30 * time.Second
+
+// Config holds configuration for the AWS Secrets Manager backend
+type Config struct {
+	// Region is the AWS region; if empty the SDK default resolution is used (AWS_REGION, shared config)
+	Region string
+	// Prefix is prepended to every credential name (default DefaultPrefix)
+	Prefix string
+	// RecoveryWindowDays is the deletion recovery window; 0 deletes immediately without recovery
+	RecoveryWindowDays int64
+	// Timeout bounds each API call (default 30s)
+	Timeout time.Duration
+	// Client is an optional preconfigured Secrets Manager client (Region is ignored when set)
+	Client *secretsmanager.Client
+}
+
+// secretsAPI is the part of the Secrets Manager client the store uses
+type secretsAPI interface {
+	secretsmanager.ListSecretsAPIClient
+	GetSecretValue(ctx context.Context, in *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+	PutSecretValue(ctx context.Context, in *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error)
+	CreateSecret(ctx context.Context, in *secretsmanager.CreateSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error)
+	DeleteSecret(ctx context.Context, in *secretsmanager.DeleteSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DeleteSecretOutput, error)
+	DescribeSecret(ctx context.Context, in *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error)
+	RestoreSecret(ctx context.Context, in *secretsmanager.RestoreSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.RestoreSecretOutput, error)
+}
+
+// store implements credmgr.Store using AWS Secrets Manager
+type store struct {
+	client secretsAPI
+	cfg    Config
+}
+
+// New creates a CredManager that stores credentials in AWS Secrets Manager
+func New(ctx context.Context, cfg Config) (credmgr.CredManager, error) {
+	if cfg.Prefix == "" {
+		cfg.Prefix = DefaultPrefix
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+
+	var client secretsAPI = cfg.Client
+	if cfg.Client == nil {
+		var opts []func(*config.LoadOptions) error
+		if cfg.Region != "" {
+			opts = append(opts, config.WithRegion(cfg.Region))
+		}
+		awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+		}
+		client = secretsmanager.NewFromConfig(awsCfg)
+	}
+
+	return credmgr.NewFromStore(newStore(client, cfg)), nil
+}
+
+// newStore returns the Store behind New; cfg must have its defaults applied
+func newStore(client secretsAPI, cfg Config) *store {
+	return &store{client: client, cfg: cfg}
+}
+
+// secretID returns the Secrets Manager name for a credential
+func (s *store) secretID(name string) string {
+	return s.cfg.Prefix + name
+}
+
+// context returns a context bounded by the configured per-call timeout
+func (s *store) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.cfg.Timeout)
+}
+
+// Read retrieves raw credential bytes by name.
+func (s *store) Read(name string) ([]byte, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	out, err := s.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(s.secretID(name)),
+	})
+	if err != nil {
+		return nil, s.wrapError(name, s.deletedAsNotFound(ctx, name, err))
+	}
+
+	if out.SecretBinary != nil {
+		return out.SecretBinary, nil
+	}
+	return []byte(aws.ToString(out.SecretString)), nil
+}
+
+// Write stores raw credential bytes, creating the secret if it does not exist.
+func (s *store) Write(name string, data []byte) error {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	if data == nil {
+		data = []byte{}
+	}
+
+	_, err := s.client.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(s.secretID(name)),
+		SecretBinary: data,
+	})
+	if err == nil {
+		return nil
+	}
+
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		if !s.pendingDeletion(ctx, name, err) {
+			return s.wrapError(name, err)
+		}
+		// Deleted within the recovery window: bring the secret back, then store the value
+		if _, err := s.client.RestoreSecret(ctx, &secretsmanager.RestoreSecretInput{
+			SecretId: aws.String(s.secretID(name)),
+		}); err != nil {
+			return s.wrapError(name, err)
+		}
+		_, err = s.client.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+			SecretId:     aws.String(s.secretID(name)),
+			SecretBinary: data,
+		})
+		return s.wrapError(name, err)
+	}
+
+	_, err = s.client.CreateSecret(ctx, &secretsmanager.CreateSecretInput{
+		Name:         aws.String(s.secretID(name)),
+		SecretBinary: data,
+		Description:  aws.String("Managed by fdot credmgr"),
+	})
+	if err != nil {
+		return s.wrapError(name, err)
+	}
+	return nil
+}
+
+// Delete removes a credential by name.
+func (s *store) Delete(name string) error {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	input := &secretsmanager.DeleteSecretInput{
+		SecretId: aws.String(s.secretID(name)),
+	}
+	if s.cfg.RecoveryWindowDays > 0 {
+		input.RecoveryWindowInDays = aws.Int64(s.cfg.RecoveryWindowDays)
+	} else {
+		input.ForceDeleteWithoutRecovery = aws.Bool(true)
+	}
+
+	if _, err := s.client.DeleteSecret(ctx, input); err != nil {
+		return s.wrapError(name, s.deletedAsNotFound(ctx, name, err))
+	}
+	return nil
+}
+
+// DeleteDB removes every secret under the configured prefix.
+func (s *store) DeleteDB() error {
+	names, err := s.List()
+	if err != nil {
+		return fmt.Errorf("failed to list credentials: %w", err)
+	}
+
+	var errs []error
+	for _, name := range names {
+		if err := s.Delete(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// List returns all credential names under the configured prefix.
+func (s *store) List() ([]string, error) {
+	ctx, cancel := s.context()
+	defer cancel()
+
+	paginator := secretsmanager.NewListSecretsPaginator(s.client, &secretsmanager.ListSecretsInput{
+		Filters: []types.Filter{{
+			Key:    types.FilterNameStringTypeName,
+			Values: []string{s.cfg.Prefix},
+		}},
+	})
+
+	names := []string{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list secrets: %w", err)
+		}
+		for _, secret := range page.SecretList {
+			// Secrets pending deletion cannot be read or written
+			if secret.DeletedDate != nil {
+				continue
+			}
+			if name, ok := strings.CutPrefix(aws.ToString(secret.Name), s.cfg.Prefix); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
+
+// pendingDeletion reports whether err is Secrets Manager refusing an operation on
+// the credential because the secret is scheduled for deletion
+func (s *store) pendingDeletion(ctx context.Context, name string, err error) bool {
+	var invalid *types.InvalidRequestException
+	if !errors.As(err, &invalid) {
+		return false
+	}
+	out, err := s.client.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(s.secretID(name)),
+	})
+	return err == nil && out.DeletedDate != nil
+}
+
+// deletedAsNotFound reports a secret scheduled for deletion as not found, since the
+// credential has been deleted
+func (s *store) deletedAsNotFound(ctx context.Context, name string, err error) error {
+	if s.pendingDeletion(ctx, name, err) {
+		return &types.ResourceNotFoundException{Message: aws.String("secret is scheduled for deletion")}
+	}
+	return err
+}
+
+// wrapError maps Secrets Manager errors onto credmgr errors; nil stays nil
+func (s *store) wrapError(name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return fmt.Errorf("credential %q %w", name, credmgr.ErrNotFound)
+	}
+	return fmt.Errorf("secrets manager %q: %w", s.secretID(name), err)
+}
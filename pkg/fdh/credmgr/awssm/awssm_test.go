@@ -0,0 +1,242 @@
+package awssm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+)
+
+// fakeSecret is one secret held by fakeSecrets
+type fakeSecret struct {
+	value   []byte
+	deleted bool // scheduled for deletion within the recovery window
+}
+
+// fakeSecrets is an in-memory Secrets Manager that answers like the real service,
+// including InvalidRequestException for secrets scheduled for deletion
+type fakeSecrets struct {
+	secrets map[string]*fakeSecret
+}
+
+func newFakeSecrets() *fakeSecrets {
+	return &fakeSecrets{secrets: make(map[string]*fakeSecret)}
+}
+
+func notFound() error {
+	return &types.ResourceNotFoundException{Message: aws.String("Secrets Manager can't find the specified secret.")}
+}
+
+func markedForDeletion() error {
+	return &types.InvalidRequestException{Message: aws.String("You can't perform this operation on the secret because it was marked for deletion.")}
+}
+
+// live returns the secret with the given ID, failing as the service does if it is
+// missing or scheduled for deletion
+func (f *fakeSecrets) live(id *string) (*fakeSecret, error) {
+	secret, ok := f.secrets[aws.ToString(id)]
+	switch {
+	case !ok:
+		return nil, notFound()
+	case secret.deleted:
+		return nil, markedForDeletion()
+	}
+	return secret, nil
+}
+
+func (f *fakeSecrets) GetSecretValue(_ context.Context, in *secretsmanager.GetSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	secret, err := f.live(in.SecretId)
+	if err != nil {
+		return nil, err
+	}
+	return &secretsmanager.GetSecretValueOutput{SecretBinary: bytes.Clone(secret.value)}, nil
+}
+
+func (f *fakeSecrets) PutSecretValue(_ context.Context, in *secretsmanager.PutSecretValueInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+	secret, err := f.live(in.SecretId)
+	if err != nil {
+		return nil, err
+	}
+	secret.value = bytes.Clone(in.SecretBinary)
+	return &secretsmanager.PutSecretValueOutput{}, nil
+}
+
+func (f *fakeSecrets) CreateSecret(_ context.Context, in *secretsmanager.CreateSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error) {
+	if secret, ok := f.secrets[aws.ToString(in.Name)]; ok {
+		if secret.deleted {
+			return nil, markedForDeletion()
+		}
+		return nil, &types.ResourceExistsException{Message: aws.String("the secret already exists")}
+	}
+	f.secrets[aws.ToString(in.Name)] = &fakeSecret{value: bytes.Clone(in.SecretBinary)}
+	return &secretsmanager.CreateSecretOutput{}, nil
+}
+
+func (f *fakeSecrets) DeleteSecret(_ context.Context, in *secretsmanager.DeleteSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.DeleteSecretOutput, error) {
+	secret, err := f.live(in.SecretId)
+	if err != nil {
+		return nil, err
+	}
+	if aws.ToBool(in.ForceDeleteWithoutRecovery) {
+		delete(f.secrets, aws.ToString(in.SecretId))
+	} else {
+		secret.deleted = true
+	}
+	return &secretsmanager.DeleteSecretOutput{}, nil
+}
+
+func (f *fakeSecrets) DescribeSecret(_ context.Context, in *secretsmanager.DescribeSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error) {
+	secret, ok := f.secrets[aws.ToString(in.SecretId)]
+	if !ok {
+		return nil, notFound()
+	}
+	out := &secretsmanager.DescribeSecretOutput{Name: in.SecretId}
+	if secret.deleted {
+		out.DeletedDate = aws.Time(time.Now())
+	}
+	return out, nil
+}
+
+func (f *fakeSecrets) RestoreSecret(_ context.Context, in *secretsmanager.RestoreSecretInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.RestoreSecretOutput, error) {
+	secret, ok := f.secrets[aws.ToString(in.SecretId)]
+	if !ok {
+		return nil, notFound()
+	}
+	secret.deleted = false
+	return &secretsmanager.RestoreSecretOutput{}, nil
+}
+
+// ListSecrets returns every secret in one page, including ones scheduled for deletion
+func (f *fakeSecrets) ListSecrets(_ context.Context, in *secretsmanager.ListSecretsInput, _ ...func(*secretsmanager.Options)) (*secretsmanager.ListSecretsOutput, error) {
+	prefix := in.Filters[0].Values[0]
+	out := &secretsmanager.ListSecretsOutput{}
+	for id, secret := range f.secrets {
+		if !strings.HasPrefix(id, prefix) {
+			continue
+		}
+		entry := types.SecretListEntry{Name: aws.String(id)}
+		if secret.deleted {
+			entry.DeletedDate = aws.Time(time.Now())
+		}
+		out.SecretList = append(out.SecretList, entry)
+	}
+	return out, nil
+}
+
+// newTestCredManager returns a CredManager over fake with the given recovery window
+func newTestCredManager(fake *fakeSecrets, recoveryDays int64) credmgr.CredManager {
+	return credmgr.NewFromStore(newStore(fake, Config{
+		Prefix:             DefaultPrefix,
+		RecoveryWindowDays: recoveryDays,
+		Timeout:            time.Second,
+	}))
+}
+
+func TestReadWriteList(t *testing.T) {
+	fake := newFakeSecrets()
+	fake.secrets["other/app/key"] = &fakeSecret{value: []byte("not ours")}
+	cm := newTestCredManager(fake, 0)
+
+	if err := cm.WriteKey("token", "v1"); err != nil {
+		t.Fatalf("WriteKey (create) failed: %v", err)
+	}
+	if err := cm.WriteKey("token", "v2"); err != nil {
+		t.Fatalf("WriteKey (update) failed: %v", err)
+	}
+	if got, err := cm.ReadKey("token"); err != nil || got != "v2" {
+		t.Errorf("ReadKey = %q, %v; want v2", got, err)
+	}
+	if _, err := cm.ReadKey("missing"); !errors.Is(err, credmgr.ErrNotFound) {
+		t.Errorf("ReadKey of missing credential error = %v, want ErrNotFound", err)
+	}
+	if names, err := cm.List(); err != nil || !slices.Equal(names, []string{"token"}) {
+		t.Errorf("List = %v, %v; want [token]", names, err)
+	}
+}
+
+func TestDeleteWithoutRecoveryWindow(t *testing.T) {
+	fake := newFakeSecrets()
+	cm := newTestCredManager(fake, 0)
+
+	if err := cm.WriteKey("token", "v"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.Delete("token"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok := fake.secrets[DefaultPrefix+"token"]; ok {
+		t.Error("secret kept after a delete without recovery window")
+	}
+	if err := cm.Restore("token"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if got, err := cm.ReadKey("token"); err != nil || got != "v" {
+		t.Errorf("ReadKey after Restore = %q, %v; want v", got, err)
+	}
+}
+
+func TestRecoveryWindowReusesScheduledSecrets(t *testing.T) {
+	fake := newFakeSecrets()
+	cm := newTestCredManager(fake, 7)
+
+	if err := cm.WriteKey("token", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.Delete("token"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if secret := fake.secrets[DefaultPrefix+"token"]; secret == nil || !secret.deleted {
+		t.Fatal("Delete did not schedule the secret for deletion")
+	}
+	if _, err := cm.ReadKey("token"); !errors.Is(err, credmgr.ErrNotFound) {
+		t.Errorf("ReadKey of scheduled secret error = %v, want ErrNotFound", err)
+	}
+	if names, _ := cm.List(); len(names) != 0 {
+		t.Errorf("List = %v, want scheduled secrets hidden", names)
+	}
+
+	// Restore writes the scheduled name and schedules the trash entry
+	if err := cm.Restore("token"); err != nil {
+		t.Fatalf("Restore of scheduled secret failed: %v", err)
+	}
+	if got, err := cm.ReadKey("token"); err != nil || got != "v1" {
+		t.Errorf("ReadKey after Restore = %q, %v; want v1", got, err)
+	}
+
+	// Deleting again writes the scheduled trash entry
+	if err := cm.Delete("token"); err != nil {
+		t.Fatalf("second Delete failed: %v", err)
+	}
+	if trash, err := cm.ListTrash(); err != nil || len(trash) != 1 || trash[0].Name != "token" {
+		t.Errorf("ListTrash = %v, %v; want token", trash, err)
+	}
+
+	// A new value under a scheduled name
+	if err := cm.WriteKey("token", "v2"); err != nil {
+		t.Fatalf("WriteKey of scheduled secret failed: %v", err)
+	}
+	if got, err := cm.ReadKey("token"); err != nil || got != "v2" {
+		t.Errorf("ReadKey after rewrite = %q, %v; want v2", got, err)
+	}
+}
+
+func TestDeleteScheduledSecret(t *testing.T) {
+	fake := newFakeSecrets()
+	fake.secrets[DefaultPrefix+"gone"] = &fakeSecret{value: []byte("x"), deleted: true}
+	s := newStore(fake, Config{Prefix: DefaultPrefix, RecoveryWindowDays: 7, Timeout: time.Second})
+
+	if err := s.Delete("gone"); !errors.Is(err, credmgr.ErrNotFound) {
+		t.Errorf("Delete of scheduled secret error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete("missing"); !errors.Is(err, credmgr.ErrNotFound) {
+		t.Errorf("Delete of missing secret error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,208 @@
+package credmgr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh"
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/filestore"
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// DefaultBackupKeep is how many backups Backup keeps when keep is zero
+const DefaultBackupKeep = 5
+
+// backupTimeFormat stamps backup file names; it sorts in time order
+const backupTimeFormat = "20060102-150405"
+
+// BackupInfo describes a backup written by Backup
+type BackupInfo struct {
+	Path string
+	Time time.Time
+	Size int64
+}
+
+// BackupDir returns the default backup directory for the credential file at dbPath:
+// "backups" next to it.
+func BackupDir(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "backups")
+}
+
+// backupPrefix is the file name prefix of dbPath's backups, e.g. "credentials-"
+func backupPrefix(dbPath string) string {
+	base := filepath.Base(dbPath)
+	return strings.TrimSuffix(base, filepath.Ext(base)) + "-"
+}
+
+// Backup copies the credential file at dbPath, still encrypted, into dir (BackupDir if
+// empty) as <name>-<timestamp><ext>, then deletes the oldest backups beyond keep
+// (DefaultBackupKeep if zero; negative keeps all). The copy opens with the same
+// master key as the file; a second backup within the same second replaces the first.
+// It returns the new backup's path.
+func Backup(dbPath, dir string, keep int) (string, error) {
+	if dir == "" {
+		dir = BackupDir(dbPath)
+	}
+	if keep == 0 {
+		keep = DefaultBackupKeep
+	}
+
+	data, err := filestore.NewReadOnly(dbPath, nil).Snapshot()
+	if err != nil {
+		return "", err
+	}
+	if err := fdh.CreatePrivateDir(dir); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	path := filepath.Join(dir, backupPrefix(dbPath)+time.Now().Format(backupTimeFormat)+filepath.Ext(dbPath))
+	if err := fdh.WritePrivateFileAtomic(path, data); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	if keep > 0 {
+		backups, err := Backups(dbPath, dir)
+		if err != nil {
+			return path, err
+		}
+		for _, old := range backups[min(keep, len(backups)):] {
+			if err := os.Remove(old.Path); err != nil {
+				return path, fmt.Errorf("failed to prune backup: %w", err)
+			}
+		}
+	}
+	return path, nil
+}
+
+// Backups lists the backups of dbPath in dir (BackupDir if empty), newest first.
+func Backups(dbPath, dir string) ([]BackupInfo, error) {
+	if dir == "" {
+		dir = BackupDir(dbPath)
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, ext := backupPrefix(dbPath), filepath.Ext(dbPath)
+	var backups []BackupInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		t, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, BackupInfo{Path: filepath.Join(dir, name), Time: t, Size: info.Size()})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+	return backups, nil
+}
+
+// RestoreBackup replaces the credential file at dbPath with a backup written by
+// Backup. The backup must open with the file's current master key (after a key
+// rotation, older backups are refused with ErrWrongKey); the replaced version is kept
+// in dbPath's ".bak" file. opts may set WithKeyFile.
+func RestoreBackup(dbPath, backupPath string, opts ...Option) error {
+	data, err := os.ReadFile(backupPath)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	o := collectOptions(opts)
+	store := filestore.New(dbPath, func() ([]byte, error) {
+		return loadMasterKey(dbPath, o.keyFile)
+	})
+	return store.Restore(data)
+}
+
+// ReplaceFile replaces the credential file at dbPath with data, the still-encrypted
+// file of another copy of the same database (as kept in sync between hosts), and sets
+// its modification time to modTime. It holds the file's lock like any credmgr write,
+// running check under it first so the caller can refuse if the file changed in the
+// meantime; check may be nil. No master key is needed, and dbPath's ".bak" file is
+// refreshed to data.
+func ReplaceFile(dbPath string, data []byte, modTime time.Time, check func() error) error {
+	return filestore.New(dbPath, nil).Replace(data, modTime, check)
+}
+
+// OpenBackup returns a read-only CredManager over a backup written by Backup. It opens
+// with the master key of the credential file at dbPath (keychain, FIDO2 and YubiKey
+// enrollments are per database); opts may set WithKeyFile.
+func OpenBackup(dbPath, backupPath string, opts ...Option) CredManager {
+	o := collectOptions(opts)
+	return &readOnlyCredManager{NewFromStore(filestore.NewReadOnly(backupPath, func() ([]byte, error) {
+		return loadMasterKey(dbPath, o.keyFile)
+	}))}
+}
+
+// CredentialVersion is a value of a credential found in the backups
+type CredentialVersion struct {
+	ID     string     // the backup's timestamp, accepted by CredentialFromBackup
+	Backup BackupInfo // the oldest backup holding this value
+	Size   int
+}
+
+// History lists the values name had in dbPath's backups in dir (BackupDir if empty),
+// newest first. Consecutive backups holding the same value are listed once, as the
+// oldest of them; backups without the credential, or that no longer open with the
+// current key, are skipped. Only states captured by Backup appear.
+func History(dbPath, dir, name string, opts ...Option) ([]CredentialVersion, error) {
+	backups, err := Backups(dbPath, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []CredentialVersion
+	var newer []byte // value in the next newer backup
+	defer func() { securemem.Wipe(newer) }()
+	for _, b := range backups {
+		data, err := OpenBackup(dbPath, b.Path, opts...).Read(name)
+		if err != nil {
+			securemem.Wipe(newer)
+			newer = nil
+			continue
+		}
+		v := CredentialVersion{ID: b.Time.Format(backupTimeFormat), Backup: b, Size: len(data)}
+		if newer != nil && bytes.Equal(data, newer) {
+			versions[len(versions)-1] = v
+		} else {
+			versions = append(versions, v)
+		}
+		securemem.Wipe(newer)
+		newer = data
+	}
+	return versions, nil
+}
+
+// CredentialFromBackup returns the value name had in the backup with the given ID
+// (a CredentialVersion ID) in dir (BackupDir if empty).
+func CredentialFromBackup(dbPath, dir, name, id string, opts ...Option) ([]byte, error) {
+	backups, err := Backups(dbPath, dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range backups {
+		if b.Time.Format(backupTimeFormat) == id {
+			return OpenBackup(dbPath, b.Path, opts...).Read(name)
+		}
+	}
+	return nil, fmt.Errorf("%w: no backup %q of %s", ErrNotFound, id, dbPath)
+}
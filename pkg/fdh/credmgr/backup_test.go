@@ -0,0 +1,140 @@
+package credmgr
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBackupRetention(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dbPath := filepath.Join(t.TempDir(), "credentials.enc")
+	if _, err := Backup(dbPath, "", 0); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Backup of a missing file error = %v, want os.ErrNotExist", err)
+	}
+
+	cm, _ := New(dbPath)
+	cm.WriteKey("token", "v1")
+	path, err := Backup(dbPath, "", 0)
+	if err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if filepath.Dir(path) != BackupDir(dbPath) {
+		t.Errorf("backup written to %s, want %s", path, BackupDir(dbPath))
+	}
+
+	// Older backups, as earlier runs would have left them, are pruned oldest first
+	dir := BackupDir(dbPath)
+	data, _ := os.ReadFile(path)
+	for i := 1; i <= 4; i++ {
+		stamp := time.Now().Add(-time.Duration(i) * time.Hour).Format(backupTimeFormat)
+		os.WriteFile(filepath.Join(dir, "credentials-"+stamp+".enc"), data, 0o600)
+	}
+	os.WriteFile(filepath.Join(dir, "unrelated.txt"), nil, 0o600)
+
+	if _, err := Backup(dbPath, "", 3); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	backups, err := Backups(dbPath, "")
+	if err != nil || len(backups) != 3 {
+		t.Fatalf("Backups = %v, %v; want 3", backups, err)
+	}
+	for i := 1; i < len(backups); i++ {
+		if backups[i].Time.After(backups[i-1].Time) {
+			t.Errorf("Backups not newest first: %v", backups)
+		}
+	}
+	// The new backup replaced the first one, taken within the same second
+	if time.Since(backups[len(backups)-1].Time) > 2*time.Hour+time.Minute {
+		t.Errorf("oldest backups kept: %v", backups)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "unrelated.txt")); err != nil {
+		t.Errorf("unrelated file pruned: %v", err)
+	}
+}
+
+func TestRestoreBackup(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dbPath := filepath.Join(t.TempDir(), "credentials.enc")
+	cm, _ := New(dbPath)
+	cm.WriteKey("token", "v1")
+	path, err := Backup(dbPath, t.TempDir(), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cm.WriteKey("token", "v2")
+	if err := RestoreBackup(dbPath, path); err != nil {
+		t.Fatalf("RestoreBackup failed: %v", err)
+	}
+	if got, _ := cm.ReadKey("token"); got != "v1" {
+		t.Errorf("token after RestoreBackup = %q, want v1", got)
+	}
+
+	// After a key change the backup no longer opens and is refused
+	t.Setenv("CREDMGR_KEY", "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+	if err := RestoreBackup(dbPath, path); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("RestoreBackup under another key error = %v, want ErrWrongKey", err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dbPath := filepath.Join(t.TempDir(), "credentials.enc")
+	dir := BackupDir(dbPath)
+	cm, _ := New(dbPath)
+
+	// Backups as successive runs would have left them, an hour apart, oldest first
+	for i, value := range []string{"v1", "v1", "", "v2", "v2", "v3"} {
+		if value == "" {
+			cm.Delete("token")
+		} else {
+			cm.WriteKey("token", value)
+		}
+		cm.WriteKey("other", "x")
+		path, err := Backup(dbPath, dir, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stamp := time.Now().Add(-time.Duration(6-i) * time.Hour).Format(backupTimeFormat)
+		if err := os.Rename(path, filepath.Join(dir, "credentials-"+stamp+".enc")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	versions, err := History(dbPath, "", "token")
+	if err != nil {
+		t.Fatalf("History failed: %v", err)
+	}
+	var got []string
+	for _, v := range versions {
+		data, err := CredentialFromBackup(dbPath, "", "token", v.ID)
+		if err != nil {
+			t.Fatalf("CredentialFromBackup(%s) failed: %v", v.ID, err)
+		}
+		got = append(got, string(data))
+	}
+	// Newest first; runs of equal values are listed once and the gap splits v1 from v2
+	if want := []string{"v3", "v2", "v1"}; !slices.Equal(got, want) {
+		t.Errorf("History values = %q, want %q", got, want)
+	}
+	if len(versions) == 3 && time.Since(versions[1].Backup.Time) < 3*time.Hour {
+		t.Errorf("v2 dated %v, want the oldest backup holding it", versions[1].Backup.Time)
+	}
+
+	if _, err := CredentialFromBackup(dbPath, "", "token", "20000101-000000"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("CredentialFromBackup of an unknown backup error = %v, want ErrNotFound", err)
+	}
+	if err := OpenBackup(dbPath, versions[0].Backup.Path).WriteKey("token", "x"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("write to an opened backup error = %v, want ErrReadOnly", err)
+	}
+}
@@ -0,0 +1,64 @@
+package credmgr
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func testBatch(t *testing.T, cm CredManager) {
+	t.Helper()
+
+	err := cm.WriteBatch(map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": []byte("3")})
+	if err != nil {
+		t.Fatalf("WriteBatch failed: %v", err)
+	}
+	for name, want := range map[string]string{"a": "1", "b": "2", "c": "3"} {
+		if got, err := cm.ReadKey(name); err != nil || got != want {
+			t.Errorf("ReadKey(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+
+	if err := cm.DeleteBatch([]string{"a", "b"}); err != nil {
+		t.Fatalf("DeleteBatch failed: %v", err)
+	}
+	if names, _ := cm.List(); !slices.Equal(names, []string{"c"}) {
+		t.Errorf("List after DeleteBatch = %v, want [c]", names)
+	}
+	trash, err := cm.ListTrash()
+	if err != nil || len(trash) != 2 {
+		t.Errorf("ListTrash after DeleteBatch = %v, %v; want 2 entries", trash, err)
+	}
+	if err := cm.Restore("a"); err != nil {
+		t.Errorf("Restore of batch-deleted credential failed: %v", err)
+	}
+}
+
+func TestBatchFileStore(t *testing.T) {
+	cm, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	testBatch(t, cm)
+
+	// A missing name fails the whole batch
+	if err := cm.DeleteBatch([]string{"c", "missing"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteBatch with missing name error = %v, want ErrNotFound", err)
+	}
+	if _, err := cm.ReadKey("c"); err != nil {
+		t.Errorf("failed DeleteBatch removed a credential: %v", err)
+	}
+}
+
+func TestBatchFallbackStore(t *testing.T) {
+	testBatch(t, NewFromStore(mapStore{}))
+}
+
+func TestBatchReadOnly(t *testing.T) {
+	cm := &readOnlyCredManager{NewFromStore(mapStore{})}
+	if err := cm.WriteBatch(map[string][]byte{"a": nil}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("WriteBatch on read-only error = %v, want ErrReadOnly", err)
+	}
+	if err := cm.DeleteBatch([]string{"a"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("DeleteBatch on read-only error = %v, want ErrReadOnly", err)
+	}
+}
@@ -3,21 +3,36 @@
 package credmgr
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/filestore"
 )
 
 var (
 	// ErrNotFound is returned when a credential is not found.
-	ErrNotFound = errors.New("credential not found")
+	ErrNotFound = filestore.ErrNotFound
 	// ErrNotSupported is returned on unsupported platforms.
 	ErrNotSupported = errors.New("credential manager not supported on this platform")
 	// ErrInvalidFormat is returned when a credential has invalid format.
 	ErrInvalidFormat = errors.New("invalid credential format")
+	// ErrWrongKey is returned when the credential file does not decrypt with the
+	// configured master key (CREDMGR_KEY, CREDMGR_KEYFILE, the OS keychain or FIDO2).
+	ErrWrongKey = filestore.ErrWrongKey
+	// ErrCorrupt is returned when the credential file is truncated or unreadable.
+	ErrCorrupt = filestore.ErrCorrupt
+	// ErrAlreadyExists is returned by WriteIfNotExists when the name is taken.
+	ErrAlreadyExists = errors.New("credential already exists")
 )
 
 const (
 	// Version is the credmgr package version.
-	Version = "3.0.0"
+	Version = "3.46.0"
 )
 
 // CredManager defines the interface for credential management operations.
@@ -25,29 +40,91 @@ type CredManager interface {
 	// Read retrieves raw credential bytes by name.
 	Read(name string) ([]byte, error)
 
+	// ReadInto copies a credential into buf so the caller can wipe it after use,
+	// and returns its length. If buf is too small nothing is copied, the length is
+	// still returned and the error wraps io.ErrShortBuffer.
+	ReadInto(name string, buf []byte) (int, error)
+
 	// Write stores raw credential bytes with the given name.
 	Write(name string, data []byte) error
 
+	// Exists reports whether a credential is stored under name. Errors other than
+	// the name being absent (wrong key, corrupt file) are returned, not reported as false.
+	Exists(name string) (bool, error)
+
+	// WriteIfNotExists stores data only if name is free, and otherwise returns an
+	// error wrapping ErrAlreadyExists. File-backed stores check and write under one lock.
+	WriteIfNotExists(name string, data []byte) error
+
 	// ReadKey retrieves a credential key as a string.
 	ReadKey(name string) (string, error)
 
 	// WriteKey stores a string credential key.
 	WriteKey(name, key string) error
 
-	// ReadUserCred retrieves a username/password credential.
+	// ReadUserCred retrieves a username/password credential; key logins stored
+	// with NewSSHKey are returned as an SSHKeyCred.
 	ReadUserCred(name string) (UserCred, error)
 
-	// WriteUserCred stores a username/password credential.
+	// WriteUserCred stores a username/password credential or an SSHKeyCred.
 	WriteUserCred(name string, cred UserCred) error
 
-	// Delete removes a credential by name.
+	// Delete moves a credential into the trash, where it stays restorable
+	// for TrashRetention. Use Purge to remove it permanently.
 	Delete(name string) error
 
+	// Rename moves a credential to newName. It fails with an error wrapping ErrNotFound
+	// if oldName is missing, or ErrAlreadyExists if newName is taken. File-backed stores
+	// rename in one save, so the credential is never under both names or neither.
+	Rename(oldName, newName string) error
+
+	// WriteBatch stores several credentials. File-backed stores apply the batch in
+	// one load/encrypt/save cycle, all or nothing; other backends write one at a time.
+	WriteBatch(creds map[string][]byte) error
+
+	// DeleteBatch moves several credentials into the trash, like Delete. File-backed
+	// stores apply the batch in one save and change nothing if any name is missing.
+	DeleteBatch(names []string) error
+
 	// DeleteDB removes the entire credential database.
 	DeleteDB() error
 
-	// List returns all credential names.
+	// List returns all credential names. Trashed credentials are not included.
 	List() ([]string, error)
+
+	// ListFiltered returns the credential names matching pattern: a prefix, a glob
+	// (containing *, ? or [) or a regular expression prefixed with "re:".
+	ListFiltered(pattern string) ([]string, error)
+
+	// Restore moves a deleted credential back out of the trash.
+	Restore(name string) error
+
+	// ListTrash returns deleted credentials that can still be restored.
+	ListTrash() ([]TrashEntry, error)
+
+	// Purge permanently removes name from the trash, or empties the trash if name is "".
+	Purge(name string) error
+
+	// Reload discards cached credentials and re-reads them from the backend.
+	// File-backed managers also reload automatically when the file's mtime changes.
+	Reload() error
+
+	// Export writes all credentials to w as an archive encrypted with passphrase.
+	// The archive is portable across backends (e.g. Windows Credential Manager to Linux file).
+	Export(w io.Writer, passphrase string) error
+
+	// Import loads credentials from an archive created by Export.
+	Import(r io.Reader, passphrase string, policy MergePolicy) error
+
+	// Verify checks that the whole store can be read back and reports the entry
+	// count and any anomalies, so corruption is found before a Read fails.
+	Verify() (VerifyReport, error)
+
+	// Subscribe sends a ChangeEvent on the returned channel whenever a credential is
+	// created, updated or deleted, by this process or another (e.g. the credmgr CLI).
+	// The store is polled every interval (DefaultSubscribeInterval if zero); the
+	// channel is closed when ctx is done.
+	Subscribe(ctx context.Context, interval time.Duration) (<-chan ChangeEvent, error)
 }
 
 // New creates a new CredManager with the specified storage path.
@@ -55,7 +132,8 @@ type CredManager interface {
 // Path behavior:
 //   - Empty string ("") or nil: Uses platform default storage
 //   - Windows: Uses Windows Credential Manager
-//   - Linux: Uses default file path (~/.fdot/credentials.enc)
+//   - Linux: Uses default file path (~/.local/credmgr/credentials.enc)
+//   - Windows and Linux: the legacy ~/.fdot/credentials.enc is merged in (see MigrateFile)
 //   - Non-empty string: Uses disk-based storage at specified path
 //   - All platforms: AES-encrypted file storage at the given path
 //
@@ -64,7 +142,7 @@ type CredManager interface {
 //	credmgr := credmgr.New("")                    // Platform default
 //	credmgr := credmgr.New("/custom/creds.enc")   // Custom file path
 func New(path string) (CredManager, error) {
-	return newCredManager(path)
+	return Open(path)
 }
 
 // Default returns a CredManager using the platform's default storage mechanism.
@@ -72,5 +150,44 @@ func New(path string) (CredManager, error) {
 //   - Linux: ~/.local/credmgr/credentials.enc
 //   - Other: Returns error for unsupported operations
 func Default() (CredManager, error) {
-	return defaultCredManager()
+	return Open("")
+}
+
+// DefaultFilePath returns the default location of the encrypted credential file
+// (~/.local/credmgr/credentials.enc).
+func DefaultFilePath() (string, error) {
+	hd, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(hd, ".local/credmgr", "credentials.enc"), nil
+}
+
+// DefaultStoreFile returns the file behind the default store opened by Open(""):
+// DefaultFilePath on Linux, and "" where the default is a native store such as
+// Windows Credential Manager.
+func DefaultStoreFile() (string, error) {
+	if !defaultStoreIsFile {
+		return "", nil
+	}
+	return DefaultFilePath()
+}
+
+// newFileCredManager returns a CredManager backed by the encrypted file at path.
+// The master key comes from loadMasterKey (CREDMGR_KEY, a key file or an enrolled FIDO2 key).
+func newFileCredManager(path string, o *openOptions) CredManager {
+	key := func() ([]byte, error) {
+		return loadMasterKey(path, o.keyFile)
+	}
+	if o.readOnly {
+		return NewFromStore(filestore.NewReadOnly(path, key))
+	}
+	s := filestore.New(path, key)
+	if o.cipher != 0 {
+		s.SetCipher(o.cipher)
+	}
+	if o.compress != nil {
+		s.SetCompression(*o.compress)
+	}
+	return NewFromStore(s)
 }
@@ -2,17 +2,26 @@
 
 package credmgr
 
+import (
+	"context"
+	"io"
+	"time"
+)
+
 // otherCredManager implements CredManager for unsupported platforms
 type otherCredManager struct{}
 
-// newCredManager creates a new CredManager for other platforms (returns not supported)
-func newCredManager(path string) (CredManager, error) {
-	return &otherCredManager{}, nil
-}
+// defaultStoreIsFile reports that there is no default file store on this platform
+const defaultStoreIsFile = false
 
-// defaultCredManager returns the default CredManager for other platforms (returns not supported)
-func defaultCredManager() (CredManager, error) {
-	return &otherCredManager{}, nil
+// newCredManager creates a new CredManager for other platforms.
+// There is no platform default store, but an explicit path opts into
+// AES-encrypted file storage.
+func newCredManager(path string, o *openOptions) (CredManager, error) {
+	if path == "" {
+		return &otherCredManager{}, nil
+	}
+	return newFileCredManager(path, o), nil
 }
 
 // All methods return ErrNotSupported for unsupported platforms
@@ -21,10 +30,22 @@ func (om *otherCredManager) Read(name string) ([]byte, error) {
 	return nil, ErrNotSupported
 }
 
+func (om *otherCredManager) ReadInto(name string, buf []byte) (int, error) {
+	return 0, ErrNotSupported
+}
+
 func (om *otherCredManager) Write(name string, data []byte) error {
 	return ErrNotSupported
 }
 
+func (om *otherCredManager) Exists(name string) (bool, error) {
+	return false, ErrNotSupported
+}
+
+func (om *otherCredManager) WriteIfNotExists(name string, data []byte) error {
+	return ErrNotSupported
+}
+
 func (om *otherCredManager) ReadKey(name string) (string, error) {
 	return "", ErrNotSupported
 }
@@ -45,6 +66,18 @@ func (om *otherCredManager) Delete(name string) error {
 	return ErrNotSupported
 }
 
+func (om *otherCredManager) Rename(oldName, newName string) error {
+	return ErrNotSupported
+}
+
+func (om *otherCredManager) WriteBatch(creds map[string][]byte) error {
+	return ErrNotSupported
+}
+
+func (om *otherCredManager) DeleteBatch(names []string) error {
+	return ErrNotSupported
+}
+
 func (om *otherCredManager) DeleteDB() error {
 	return ErrNotSupported
 }
@@ -52,3 +85,39 @@ func (om *otherCredManager) DeleteDB() error {
 func (om *otherCredManager) List() ([]string, error) {
 	return nil, ErrNotSupported
 }
+
+func (om *otherCredManager) ListFiltered(pattern string) ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+func (om *otherCredManager) Export(w io.Writer, passphrase string) error {
+	return ErrNotSupported
+}
+
+func (om *otherCredManager) Import(r io.Reader, passphrase string, policy MergePolicy) error {
+	return ErrNotSupported
+}
+
+func (om *otherCredManager) Restore(name string) error {
+	return ErrNotSupported
+}
+
+func (om *otherCredManager) ListTrash() ([]TrashEntry, error) {
+	return nil, ErrNotSupported
+}
+
+func (om *otherCredManager) Purge(name string) error {
+	return ErrNotSupported
+}
+
+func (om *otherCredManager) Verify() (VerifyReport, error) {
+	return VerifyReport{}, ErrNotSupported
+}
+
+func (om *otherCredManager) Reload() error {
+	return ErrNotSupported
+}
+
+func (om *otherCredManager) Subscribe(ctx context.Context, interval time.Duration) (<-chan ChangeEvent, error) {
+	return nil, ErrNotSupported
+}
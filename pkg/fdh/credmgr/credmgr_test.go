@@ -21,6 +21,9 @@ func setupTestEnv(t *testing.T) (CredManager, func()) {
 	// Save original env var if it exists
 	originalKey := os.Getenv("CREDMGR_KEY")
 
+	// Keep the user's fdot config (key_file) out of the tests
+	t.Setenv("FDOT_CONFIG", filepath.Join(tempDir, "config.json"))
+
 	// Set a test key (32 bytes = 64 hex chars)
 	testKey := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
 	if err := os.Setenv("CREDMGR_KEY", testKey); err != nil {
@@ -718,3 +721,20 @@ func BenchmarkReadUserCred(b *testing.B) {
 	}
 	cm.Delete(credName)
 }
+
+func TestDefaultStoreFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	got, err := DefaultStoreFile()
+	if err != nil {
+		t.Fatalf("DefaultStoreFile failed: %v", err)
+	}
+	want := ""
+	if defaultStoreIsFile {
+		if want, err = DefaultFilePath(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got != want {
+		t.Errorf("DefaultStoreFile = %q, want %q", got, want)
+	}
+}
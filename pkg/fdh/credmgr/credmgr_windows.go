@@ -3,10 +3,13 @@
 package credmgr
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"syscall"
 	"unsafe"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
 )
 
 var (
@@ -38,35 +41,31 @@ type credential struct {
 	UserName           *uint16
 }
 
-// windowsCredManager implements CredManager for Windows using Windows Credential Manager
-type windowsCredManager struct {
+// windowsStore implements Store for Windows using Windows Credential Manager.
+// NewFromStore layers trash handling and the rest of the CredManager API on top.
+//
+// Username/password entries keep the username in the native UserName field and only
+// the password in the blob, so they display properly in the Credential Manager UI.
+// The Store still reads and writes them in the typed userpass format, so trash,
+// export and batches are unaffected. Entries written before 3.38 keep the whole
+// record in the blob, which still reads, until they are next written.
+type windowsStore struct {
 	// Windows Credential Manager doesn't need a file path
 	// All credentials are stored in the system's credential store
 }
 
-// diskCredManager implements CredManager for any platform using AES-encrypted file storage
-type diskCredManager struct {
-	credFilePath string
-	// This will be the same as linuxCredManager but available on Windows too
-	// Implementation will be similar to Linux version but without platform restrictions
-}
+// defaultStoreIsFile reports that Open("") uses Windows Credential Manager, not a file
+const defaultStoreIsFile = false
 
 // newCredManager creates a new CredManager for Windows
-func newCredManager(path string) (CredManager, error) {
+func newCredManager(path string, o *openOptions) (CredManager, error) {
 	if path == "" {
 		// Use Windows Credential Manager (default)
-		return &windowsCredManager{}, nil
+		return NewFromStore(&windowsStore{}), nil
 	}
 
-	// Use disk-based storage at specified path
-	return &diskCredManager{
-		credFilePath: path,
-	}, nil
-}
-
-// defaultCredManager returns the default CredManager for Windows (Windows Credential Manager)
-func defaultCredManager() (CredManager, error) {
-	return &windowsCredManager{}, nil
+	// Use AES-encrypted file storage at specified path
+	return newFileCredManager(path, o), nil
 }
 
 // utf16PtrToString converts a UTF16 pointer to a Go string
@@ -96,7 +95,7 @@ func utf16PtrToString(ptr *uint16) string {
 // Windows Credential Manager implementation
 
 // Read retrieves raw credential bytes by name.
-func (wm *windowsCredManager) Read(name string) ([]byte, error) {
+func (ws *windowsStore) Read(name string) ([]byte, error) {
 	targetNamePtr, err := syscall.UTF16PtrFromString(name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert target name: %w", err)
@@ -115,23 +114,39 @@ func (wm *windowsCredManager) Read(name string) ([]byte, error) {
 	}
 	defer procCredFree.Call(uintptr(unsafe.Pointer(credPtr)))
 
-	if credPtr.CredentialBlobSize == 0 {
-		return []byte{}, nil
+	var data []byte
+	if credPtr.CredentialBlobSize > 0 {
+		data = (*[1 << 20]byte)(unsafe.Pointer(credPtr.CredentialBlob))[:credPtr.CredentialBlobSize:credPtr.CredentialBlobSize]
+	}
+
+	// A native username makes the blob the password of a userpass entry
+	if username := utf16PtrToString(credPtr.UserName); username != "" {
+		return marshalUserCredRecord(username, data), nil
 	}
 
-	data := (*[1 << 20]byte)(unsafe.Pointer(credPtr.CredentialBlob))[:credPtr.CredentialBlobSize:credPtr.CredentialBlobSize]
 	result := make([]byte, len(data))
 	copy(result, data)
 	return result, nil
 }
 
 // Write stores raw credential bytes with the given name.
-func (wm *windowsCredManager) Write(name string, data []byte) error {
+func (ws *windowsStore) Write(name string, data []byte) error {
 	targetNamePtr, err := syscall.UTF16PtrFromString(name)
 	if err != nil {
 		return fmt.Errorf("failed to convert target name: %w", err)
 	}
 
+	// Username/password entries store the username natively and the password as the blob
+	var userNamePtr *uint16
+	if rec, ok := parseUserCredRecord(data); ok {
+		defer securemem.Wipe(rec.Password)
+		if rec.Username != "" {
+			if userNamePtr, err = syscall.UTF16PtrFromString(rec.Username); err == nil {
+				data = rec.Password
+			}
+		}
+	}
+
 	var dataPtr *byte
 	if len(data) > 0 {
 		dataPtr = &data[0]
@@ -143,6 +158,7 @@ func (wm *windowsCredManager) Write(name string, data []byte) error {
 		CredentialBlobSize: uint32(len(data)),
 		CredentialBlob:     dataPtr,
 		Persist:            credPersistLocalMachine,
+		UserName:           userNamePtr,
 	}
 
 	ret, _, _ := procCredWriteW.Call(
@@ -157,42 +173,8 @@ func (wm *windowsCredManager) Write(name string, data []byte) error {
 	return nil
 }
 
-// ReadKey retrieves a credential key as a string.
-func (wm *windowsCredManager) ReadKey(name string) (string, error) {
-	data, err := wm.Read(name)
-	if err != nil {
-		return "", err
-	}
-	return string(data), nil
-}
-
-// WriteKey stores a string credential key.
-func (wm *windowsCredManager) WriteKey(name, key string) error {
-	return wm.Write(name, []byte(key))
-}
-
-// ReadUserCred retrieves a username/password credential.
-func (wm *windowsCredManager) ReadUserCred(name string) (UserCred, error) {
-	data, err := wm.Read(name)
-	if err != nil {
-		return nil, err
-	}
-	return unmarshalUnPw(data)
-}
-
-// WriteUserCred stores a username/password credential.
-func (wm *windowsCredManager) WriteUserCred(name string, cred UserCred) error {
-	// Type assert to access marshal method
-	if uc, ok := cred.(*obfuscatedUserCred); ok {
-		return wm.Write(name, uc.marshal())
-	}
-	// Fallback: reconstruct from interface
-	reconstructed := newObfuscatedUserCred(cred.Username(), cred.Password())
-	return wm.Write(name, reconstructed.marshal())
-}
-
-// Delete removes a credential by name.
-func (wm *windowsCredManager) Delete(name string) error {
+// Delete removes a credential (or trash entry) from Credential Manager.
+func (ws *windowsStore) Delete(name string) error {
 	targetNamePtr, err := syscall.UTF16PtrFromString(name)
 	if err != nil {
 		return fmt.Errorf("failed to convert target name: %w", err)
@@ -212,38 +194,38 @@ func (wm *windowsCredManager) Delete(name string) error {
 }
 
 // DeleteDB removes all generic credentials from Windows Credential Manager.
-func (wm *windowsCredManager) DeleteDB() error {
-	// First get all credential names
-	names, err := wm.List()
+func (ws *windowsStore) DeleteDB() error {
+	// First get all credential names, including trash entries
+	names, err := ws.List()
 	if err != nil {
 		return fmt.Errorf("failed to list credentials: %w", err)
 	}
 
 	// Delete each credential individually
-	var errors []error
+	var errs []error
 	for _, name := range names {
-		if err := wm.Delete(name); err != nil {
+		if err := ws.Delete(name); err != nil {
 			// Continue deleting others even if one fails
-			errors = append(errors, fmt.Errorf("failed to delete credential %q: %w", name, err))
+			errs = append(errs, fmt.Errorf("failed to delete credential %q: %w", name, err))
 		}
 	}
 
 	// Return combined errors if any occurred
-	if len(errors) > 0 {
+	if len(errs) > 0 {
 		var errStr strings.Builder
 		errStr.WriteString("failed to delete some credentials:")
-		for _, e := range errors {
+		for _, e := range errs {
 			errStr.WriteString("\n  ")
 			errStr.WriteString(e.Error())
 		}
-		return fmt.Errorf(errStr.String())
+		return errors.New(errStr.String())
 	}
 
 	return nil
 }
 
-// List returns all credential names.
-func (wm *windowsCredManager) List() ([]string, error) {
+// List returns every generic credential name, including trash entries.
+func (ws *windowsStore) List() ([]string, error) {
 	var count uint32
 	var creds **credential
 
@@ -270,44 +252,3 @@ func (wm *windowsCredManager) List() ([]string, error) {
 
 	return names, nil
 }
-
-// TODO: Implement diskCredManager methods for when a specific path is provided on Windows
-// For now, we'll implement basic stubs that return ErrNotSupported
-
-// Disk-based storage implementation (when path is specified on Windows)
-
-func (dm *diskCredManager) Read(name string) ([]byte, error) {
-	return nil, ErrNotSupported // TODO: Implement AES file storage
-}
-
-func (dm *diskCredManager) Write(name string, data []byte) error {
-	return ErrNotSupported // TODO: Implement AES file storage
-}
-
-func (dm *diskCredManager) ReadKey(name string) (string, error) {
-	return "", ErrNotSupported
-}
-
-func (dm *diskCredManager) WriteKey(name, key string) error {
-	return ErrNotSupported
-}
-
-func (dm *diskCredManager) ReadUserCred(name string) (UserCred, error) {
-	return nil, ErrNotSupported
-}
-
-func (dm *diskCredManager) WriteUserCred(name string, cred UserCred) error {
-	return ErrNotSupported
-}
-
-func (dm *diskCredManager) Delete(name string) error {
-	return ErrNotSupported
-}
-
-func (dm *diskCredManager) DeleteDB() error {
-	return ErrNotSupported
-}
-
-func (dm *diskCredManager) List() ([]string, error) {
-	return nil, ErrNotSupported
-}
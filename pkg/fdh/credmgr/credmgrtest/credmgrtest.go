@@ -0,0 +1,311 @@
+// Package credmgrtest provides a fake credmgr.CredManager for testing code that uses
+// credentials, without touching the platform store or a credential file.
+//
+//	cm := credmgrtest.New(t)
+//	cm.SetUserCred(t, "router", "admin", "pw")
+//	cm.FailOn("Read", "broken", credmgr.ErrCorrupt)
+//
+//	runCrawl(cm)
+//
+//	if cm.Count("ReadUserCred", "router") != 1 { ... }
+package credmgrtest
+
+import (
+	"context"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+)
+
+// Call is one recorded CredManager method call. Name is empty for whole-store
+// operations; batches record the names involved, sorted, in Names.
+type Call struct {
+	Method string
+	Name   string
+	Names  []string
+}
+
+// failure is an error scripted with FailOn
+type failure struct {
+	method string
+	name   string
+	err    error
+	times  int // remaining failures; < 0 fails until cleared
+}
+
+// Fake is a CredManager backed by credmgr.NewMemory that records every call and
+// returns scripted errors. It is safe for concurrent use.
+type Fake struct {
+	backend credmgr.CredManager
+
+	mu       sync.Mutex
+	calls    []Call
+	failures []*failure
+}
+
+var _ credmgr.CredManager = (*Fake)(nil)
+
+// New returns an empty Fake. t is used only to clear the fake's state when the test
+// ends; it may be nil.
+func New(t testing.TB) *Fake {
+	f := &Fake{backend: credmgr.NewMemory()}
+	if t != nil {
+		t.Cleanup(func() { _ = f.backend.DeleteDB() })
+	}
+	return f
+}
+
+// SetKey stores a string credential, failing the test on error. It is not recorded.
+func (f *Fake) SetKey(t testing.TB, name, key string) {
+	t.Helper()
+	if err := f.backend.WriteKey(name, key); err != nil {
+		t.Fatalf("credmgrtest: WriteKey(%q) failed: %v", name, err)
+	}
+}
+
+// SetUserCred stores a username/password credential, failing the test on error.
+// It is not recorded.
+func (f *Fake) SetUserCred(t testing.TB, name, username, password string) {
+	t.Helper()
+	if err := f.backend.WriteUserCred(name, credmgr.NewUnPw(username, password)); err != nil {
+		t.Fatalf("credmgrtest: WriteUserCred(%q) failed: %v", name, err)
+	}
+}
+
+// FailOn makes every call of method (e.g. "Read", "WriteUserCred") for name return
+// err until Reset. An empty name matches any name, including whole-store operations;
+// batches fail if any of their names matches.
+func (f *Fake) FailOn(method, name string, err error) {
+	f.FailTimes(method, name, err, -1)
+}
+
+// FailTimes is like FailOn but fails only the next n matching calls
+func (f *Fake) FailTimes(method, name string, err error, n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = append(f.failures, &failure{method: method, name: name, err: err, times: n})
+}
+
+// Calls returns the recorded calls in order
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+// Count returns how many times method was called for name; an empty name counts
+// every call of method
+func (f *Fake) Count(method, name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, c := range f.calls {
+		if c.Method == method && (name == "" || c.Name == name || slices.Contains(c.Names, name)) {
+			n++
+		}
+	}
+	return n
+}
+
+// Reset clears the recorded calls and scripted errors; stored credentials are kept
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = nil
+	f.failures = nil
+}
+
+// record logs a call and returns the scripted error for it, if any
+func (f *Fake) record(method, name string, names ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, Call{Method: method, Name: name, Names: names})
+	for _, fl := range f.failures {
+		if fl.method != method || fl.times == 0 {
+			continue
+		}
+		if fl.name != "" && fl.name != name && !slices.Contains(names, fl.name) {
+			continue
+		}
+		if fl.times > 0 {
+			fl.times--
+		}
+		return fl.err
+	}
+	return nil
+}
+
+func (f *Fake) Read(name string) ([]byte, error) {
+	if err := f.record("Read", name); err != nil {
+		return nil, err
+	}
+	return f.backend.Read(name)
+}
+
+func (f *Fake) ReadInto(name string, buf []byte) (int, error) {
+	if err := f.record("ReadInto", name); err != nil {
+		return 0, err
+	}
+	return f.backend.ReadInto(name, buf)
+}
+
+func (f *Fake) Write(name string, data []byte) error {
+	if err := f.record("Write", name); err != nil {
+		return err
+	}
+	return f.backend.Write(name, data)
+}
+
+func (f *Fake) Exists(name string) (bool, error) {
+	if err := f.record("Exists", name); err != nil {
+		return false, err
+	}
+	return f.backend.Exists(name)
+}
+
+func (f *Fake) WriteIfNotExists(name string, data []byte) error {
+	if err := f.record("WriteIfNotExists", name); err != nil {
+		return err
+	}
+	return f.backend.WriteIfNotExists(name, data)
+}
+
+func (f *Fake) ReadKey(name string) (string, error) {
+	if err := f.record("ReadKey", name); err != nil {
+		return "", err
+	}
+	return f.backend.ReadKey(name)
+}
+
+func (f *Fake) WriteKey(name, key string) error {
+	if err := f.record("WriteKey", name); err != nil {
+		return err
+	}
+	return f.backend.WriteKey(name, key)
+}
+
+func (f *Fake) ReadUserCred(name string) (credmgr.UserCred, error) {
+	if err := f.record("ReadUserCred", name); err != nil {
+		return nil, err
+	}
+	return f.backend.ReadUserCred(name)
+}
+
+func (f *Fake) WriteUserCred(name string, cred credmgr.UserCred) error {
+	if err := f.record("WriteUserCred", name); err != nil {
+		return err
+	}
+	return f.backend.WriteUserCred(name, cred)
+}
+
+func (f *Fake) Delete(name string) error {
+	if err := f.record("Delete", name); err != nil {
+		return err
+	}
+	return f.backend.Delete(name)
+}
+
+func (f *Fake) WriteBatch(creds map[string][]byte) error {
+	if err := f.record("WriteBatch", "", slices.Sorted(maps.Keys(creds))...); err != nil {
+		return err
+	}
+	return f.backend.WriteBatch(creds)
+}
+
+func (f *Fake) DeleteBatch(names []string) error {
+	if err := f.record("DeleteBatch", "", slices.Sorted(slices.Values(names))...); err != nil {
+		return err
+	}
+	return f.backend.DeleteBatch(names)
+}
+
+func (f *Fake) Rename(oldName, newName string) error {
+	if err := f.record("Rename", oldName, newName); err != nil {
+		return err
+	}
+	return f.backend.Rename(oldName, newName)
+}
+
+func (f *Fake) DeleteDB() error {
+	if err := f.record("DeleteDB", ""); err != nil {
+		return err
+	}
+	return f.backend.DeleteDB()
+}
+
+func (f *Fake) List() ([]string, error) {
+	if err := f.record("List", ""); err != nil {
+		return nil, err
+	}
+	return f.backend.List()
+}
+
+func (f *Fake) ListFiltered(pattern string) ([]string, error) {
+	if err := f.record("ListFiltered", ""); err != nil {
+		return nil, err
+	}
+	return f.backend.ListFiltered(pattern)
+}
+
+func (f *Fake) Restore(name string) error {
+	if err := f.record("Restore", name); err != nil {
+		return err
+	}
+	return f.backend.Restore(name)
+}
+
+func (f *Fake) ListTrash() ([]credmgr.TrashEntry, error) {
+	if err := f.record("ListTrash", ""); err != nil {
+		return nil, err
+	}
+	return f.backend.ListTrash()
+}
+
+func (f *Fake) Purge(name string) error {
+	if err := f.record("Purge", name); err != nil {
+		return err
+	}
+	return f.backend.Purge(name)
+}
+
+func (f *Fake) Reload() error {
+	if err := f.record("Reload", ""); err != nil {
+		return err
+	}
+	return f.backend.Reload()
+}
+
+func (f *Fake) Export(w io.Writer, passphrase string) error {
+	if err := f.record("Export", ""); err != nil {
+		return err
+	}
+	return f.backend.Export(w, passphrase)
+}
+
+func (f *Fake) Import(r io.Reader, passphrase string, policy credmgr.MergePolicy) error {
+	if err := f.record("Import", ""); err != nil {
+		return err
+	}
+	return f.backend.Import(r, passphrase, policy)
+}
+
+func (f *Fake) Verify() (credmgr.VerifyReport, error) {
+	if err := f.record("Verify", ""); err != nil {
+		return credmgr.VerifyReport{}, err
+	}
+	return f.backend.Verify()
+}
+
+func (f *Fake) Subscribe(ctx context.Context, interval time.Duration) (<-chan credmgr.ChangeEvent, error) {
+	if err := f.record("Subscribe", ""); err != nil {
+		return nil, err
+	}
+	return f.backend.Subscribe(ctx, interval)
+}
@@ -0,0 +1,84 @@
+package credmgrtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+)
+
+func TestFakeRecordsCalls(t *testing.T) {
+	f := New(t)
+	f.SetUserCred(t, "router", "admin", "pw")
+	if len(f.Calls()) != 0 {
+		t.Errorf("seeding was recorded: %v", f.Calls())
+	}
+
+	var cm credmgr.CredManager = f
+	cred, err := cm.ReadUserCred("router")
+	if err != nil || cred.Password() != "pw" {
+		t.Fatalf("ReadUserCred = %v, %v", cred, err)
+	}
+	if _, err := cm.ReadKey("missing"); !errors.Is(err, credmgr.ErrNotFound) {
+		t.Errorf("ReadKey of missing credential error = %v, want ErrNotFound", err)
+	}
+	if err := cm.DeleteBatch([]string{"router"}); err != nil {
+		t.Fatalf("DeleteBatch failed: %v", err)
+	}
+
+	want := []Call{
+		{Method: "ReadUserCred", Name: "router"},
+		{Method: "ReadKey", Name: "missing"},
+		{Method: "DeleteBatch", Names: []string{"router"}},
+	}
+	calls := f.Calls()
+	if len(calls) != len(want) {
+		t.Fatalf("Calls = %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i].Method != want[i].Method || calls[i].Name != want[i].Name {
+			t.Errorf("call %d = %+v, want %+v", i, calls[i], want[i])
+		}
+	}
+	if n := f.Count("DeleteBatch", "router"); n != 1 {
+		t.Errorf("Count(DeleteBatch, router) = %d, want 1", n)
+	}
+}
+
+func TestFakeScriptedErrors(t *testing.T) {
+	f := New(t)
+	f.SetKey(t, "token", "abc")
+	f.SetKey(t, "other", "xyz")
+
+	f.FailOn("ReadKey", "token", credmgr.ErrCorrupt)
+	if _, err := f.ReadKey("token"); !errors.Is(err, credmgr.ErrCorrupt) {
+		t.Errorf("ReadKey error = %v, want ErrCorrupt", err)
+	}
+	if got, err := f.ReadKey("other"); err != nil || got != "xyz" {
+		t.Errorf("ReadKey of another name = %q, %v", got, err)
+	}
+
+	f.FailTimes("List", "", credmgr.ErrWrongKey, 1)
+	if _, err := f.List(); !errors.Is(err, credmgr.ErrWrongKey) {
+		t.Errorf("first List error = %v, want ErrWrongKey", err)
+	}
+	if names, err := f.List(); err != nil || len(names) != 2 {
+		t.Errorf("second List = %v, %v", names, err)
+	}
+
+	f.FailOn("WriteBatch", "bad", credmgr.ErrForbidden)
+	if err := f.WriteBatch(map[string][]byte{"ok": nil, "bad": nil}); !errors.Is(err, credmgr.ErrForbidden) {
+		t.Errorf("WriteBatch error = %v, want ErrForbidden", err)
+	}
+	if ok, _ := f.Exists("ok"); ok {
+		t.Error("a failed WriteBatch reached the backend")
+	}
+
+	f.Reset()
+	if _, err := f.ReadKey("token"); err != nil {
+		t.Errorf("ReadKey after Reset failed: %v", err)
+	}
+	if len(f.Calls()) != 1 {
+		t.Errorf("Calls after Reset = %v, want one", f.Calls())
+	}
+}
@@ -0,0 +1,39 @@
+package credmgr
+
+// RedactedValue replaces the credential value in every CredentialEvent
+const RedactedValue = "[REDACTED]"
+
+// EventSender receives credential usage events.
+// *eventstream.Handler satisfies it, so a handler can be passed directly.
+type EventSender interface {
+	Send(data any)
+}
+
+// CredentialEvent is sent to an EventSender for every credential operation.
+// Value is RedactedValue when the operation carried a secret and empty otherwise;
+// the secret itself is never included.
+type CredentialEvent struct {
+	AuditEvent
+	Value string `json:"value,omitempty"`
+}
+
+// WithEvents sends a CredentialEvent to sender for every Read, Write, Delete and
+// whole-store operation, for alerting on unexpected access patterns.
+//
+//	log := eventstream.GetFromContext(ctx)
+//	cm, err := credmgr.Open("", credmgr.WithEvents(log))
+func WithEvents(sender EventSender) Option {
+	return WithAudit(func(e AuditEvent) {
+		sender.Send(newCredentialEvent(e))
+	})
+}
+
+// newCredentialEvent wraps e, marking operations that move secret values
+func newCredentialEvent(e AuditEvent) CredentialEvent {
+	ce := CredentialEvent{AuditEvent: e}
+	switch e.Op {
+	case AuditRead, AuditWrite, AuditExport, AuditImport:
+		ce.Value = RedactedValue
+	}
+	return ce
+}
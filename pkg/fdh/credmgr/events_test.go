@@ -0,0 +1,77 @@
+package credmgr
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type recordingSender struct {
+	events []any
+}
+
+func (r *recordingSender) Send(data any) {
+	r.events = append(r.events, data)
+}
+
+func TestWithEventsOnCustomBackend(t *testing.T) {
+	sender := &recordingSender{}
+	cm, err := Wrap(NewFromStore(mapStore{}), WithEvents(sender))
+	if err != nil {
+		t.Fatalf("Wrap failed: %v", err)
+	}
+
+	if err := cm.WriteUserCred("switch", NewUnPw("admin", "secret-value")); err != nil {
+		t.Fatalf("WriteUserCred failed: %v", err)
+	}
+	if _, err := cm.ReadUserCred("switch"); err != nil {
+		t.Fatalf("ReadUserCred failed: %v", err)
+	}
+	if err := cm.Delete("switch"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	want := []struct {
+		op    AuditOp
+		value string
+	}{
+		{AuditWrite, RedactedValue},
+		{AuditRead, RedactedValue},
+		{AuditDelete, ""},
+	}
+	if len(sender.events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(sender.events), len(want))
+	}
+	for i, w := range want {
+		e, ok := sender.events[i].(CredentialEvent)
+		if !ok {
+			t.Fatalf("event %d is %T, want CredentialEvent", i, sender.events[i])
+		}
+		if e.Op != w.op || e.Name != "switch" || !e.Success || e.Value != w.value {
+			t.Errorf("event %d = %+v, want op=%s value=%q", i, e, w.op, w.value)
+		}
+
+		data, err := json.Marshal(e)
+		if err != nil {
+			t.Fatalf("marshal event %d: %v", i, err)
+		}
+		if strings.Contains(string(data), "secret-value") {
+			t.Errorf("event %d leaks the credential value: %s", i, data)
+		}
+	}
+}
+
+func TestWrapReadOnly(t *testing.T) {
+	store := mapStore{"token": []byte("v")}
+	cm, err := Wrap(NewFromStore(store), ReadOnly())
+	if err != nil {
+		t.Fatalf("Wrap failed: %v", err)
+	}
+
+	if err := cm.WriteKey("token", "changed"); err != ErrReadOnly {
+		t.Errorf("WriteKey error = %v, want ErrReadOnly", err)
+	}
+	if got, err := cm.ReadKey("token"); err != nil || got != "v" {
+		t.Errorf("ReadKey = %q, %v; want \"v\"", got, err)
+	}
+}
@@ -0,0 +1,66 @@
+package credmgr
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+)
+
+// Exec runs cmd with the credentials in mapping (environment variable → credential
+// name) added to its environment, using the Default() store. See ExecWith.
+func Exec(cmd []string, mapping map[string]string) error {
+	cm, err := Default()
+	if err != nil {
+		return err
+	}
+	return ExecWith(cm, cmd, mapping)
+}
+
+// ExecWith runs cmd with the credentials in mapping read from cm and added to its
+// environment, so they never appear on a command line, in shell history or on disk:
+//
+//	err := credmgr.ExecWith(cm, []string{"terraform", "apply"}, map[string]string{
+//		"TF_VAR_api_token": "myapp-token",
+//	})
+//
+// Every credential is read before the command starts. Stdin, stdout and stderr are
+// shared with the child, and interrupts are forwarded to it. A non-zero exit status is
+// returned as an *exec.ExitError. Note that the child's environment is readable by
+// processes of the same user (/proc/<pid>/environ on Linux).
+func ExecWith(cm CredManager, cmd []string, mapping map[string]string) error {
+	if len(cmd) == 0 {
+		return errors.New("credmgr: no command to run")
+	}
+
+	env := os.Environ()
+	for variable, name := range mapping {
+		value, err := cm.ReadKey(name)
+		if err != nil {
+			return fmt.Errorf("failed to read %q for $%s: %w", name, variable, err)
+		}
+		env = append(env, variable+"="+value)
+	}
+
+	c := exec.Command(cmd[0], cmd[1:]...)
+	c.Env = env
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", cmd[0], err)
+	}
+
+	// The child decides how to react to Ctrl-C; this process waits for it either way
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer func() {
+		signal.Stop(signals)
+		close(signals)
+	}()
+	go func() {
+		for sig := range signals {
+			c.Process.Signal(sig)
+		}
+	}()
+	return c.Wait()
+}
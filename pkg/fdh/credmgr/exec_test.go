@@ -0,0 +1,40 @@
+package credmgr
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+	"testing"
+)
+
+func TestExecWith(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	cm := NewFromStore(mapStore{})
+	if err := cm.WriteKey("myapp-token", "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	mapping := map[string]string{"TOKEN": "myapp-token"}
+	if err := ExecWith(cm, []string{"sh", "-c", `test "$TOKEN" = abc`}, mapping); err != nil {
+		t.Errorf("child did not see the secret: %v", err)
+	}
+
+	var exitErr *exec.ExitError
+	err := ExecWith(cm, []string{"sh", "-c", "exit 3"}, nil)
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("ExecWith exit status error = %v, want exit code 3", err)
+	}
+}
+
+func TestExecWithMissingSecret(t *testing.T) {
+	cm := NewFromStore(mapStore{})
+	err := ExecWith(cm, []string{"does-not-run"}, map[string]string{"TOKEN": "missing"})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("ExecWith with missing secret error = %v, want ErrNotFound", err)
+	}
+	if err := ExecWith(cm, nil, nil); err == nil {
+		t.Error("ExecWith without a command should fail")
+	}
+}
@@ -0,0 +1,78 @@
+package credmgr
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func testExists(t *testing.T, cm CredManager) {
+	t.Helper()
+
+	if ok, err := cm.Exists("token"); err != nil || ok {
+		t.Errorf("Exists before write = %v, %v; want false", ok, err)
+	}
+	if err := cm.WriteIfNotExists("token", []byte("first")); err != nil {
+		t.Fatalf("WriteIfNotExists on free name failed: %v", err)
+	}
+	if ok, err := cm.Exists("token"); err != nil || !ok {
+		t.Errorf("Exists after write = %v, %v; want true", ok, err)
+	}
+
+	err := cm.WriteIfNotExists("token", []byte("second"))
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("WriteIfNotExists on taken name error = %v, want ErrAlreadyExists", err)
+	}
+	if got, _ := cm.ReadKey("token"); got != "first" {
+		t.Errorf("value after refused write = %q, want %q", got, "first")
+	}
+
+	// Empty credentials exist too
+	if err := cm.Write("empty", nil); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := cm.Exists("empty"); err != nil || !ok {
+		t.Errorf("Exists of empty credential = %v, %v; want true", ok, err)
+	}
+
+	// Trashed credentials do not
+	if err := cm.Delete("token"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := cm.Exists("token"); err != nil || ok {
+		t.Errorf("Exists after Delete = %v, %v; want false", ok, err)
+	}
+}
+
+func TestExistsFileStore(t *testing.T) {
+	cm, cleanup := setupTestEnv(t)
+	defer cleanup()
+	testExists(t, cm)
+}
+
+func TestExistsFallbackStore(t *testing.T) {
+	testExists(t, NewFromStore(mapStore{}))
+}
+
+func TestExistsReportsWrongKey(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials.enc")
+	t.Setenv("FDOT_CONFIG", filepath.Join(dir, "config.json"))
+	t.Setenv("CREDMGR_KEY", "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+
+	cm, err := New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.WriteKey("token", "v"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CREDMGR_KEY", "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+	if cm, err = New(path); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := cm.Exists("token"); ok || !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Exists with wrong key = %v, %v; want ErrWrongKey", ok, err)
+	}
+}
@@ -0,0 +1,228 @@
+package credmgr
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/filestore"
+	"golang.org/x/crypto/argon2"
+)
+
+// ErrBadPassphrase is returned when an archive cannot be decrypted with the given passphrase.
+var ErrBadPassphrase = errors.New("wrong passphrase or corrupted archive")
+
+// MergePolicy controls how Import handles credentials that already exist in the store.
+type MergePolicy int
+
+const (
+	// MergeSkipExisting keeps existing credentials and only adds new ones.
+	MergeSkipExisting MergePolicy = iota
+	// MergeOverwrite replaces existing credentials with the archived values.
+	MergeOverwrite
+	// MergeReplace deletes the entire database before importing the archive.
+	MergeReplace
+)
+
+const (
+	exportFormat  = "fdot-credmgr-export"
+	exportVersion = 1
+
+	// Argon2id parameters (RFC 9106 second recommended option)
+	exportKDFTime    = 3
+	exportKDFMemory  = 64 * 1024
+	exportKDFThreads = 4
+	exportKeyLen     = 32
+	exportSaltLen    = 16
+
+	// Limits on the parameters an imported archive may ask for: argon2 panics on zero
+	// rounds or threads, and the memory cost is allocated up front
+	importMaxKDFTime   = 64
+	importMaxKDFMemory = 1024 * 1024 // KiB, 1 GiB
+)
+
+// exportArchive is the on-disk envelope for an exported credential database.
+// The payload is a JSON map of name to raw bytes, AES-256-GCM encrypted with a key
+// derived from the passphrase, so archives are independent of the source backend.
+type exportArchive struct {
+	Format     string `json:"format"`
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	KDFTime    uint32 `json:"kdf_time"`
+	KDFMemory  uint32 `json:"kdf_memory"`
+	KDFThreads uint8  `json:"kdf_threads"`
+	Salt       []byte `json:"salt"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// exportCredentials writes every credential in cm to w as a passphrase-encrypted archive.
+func exportCredentials(cm CredManager, w io.Writer, passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("export passphrase must not be empty")
+	}
+
+	names, err := cm.List()
+	if err != nil {
+		return fmt.Errorf("failed to list credentials: %w", err)
+	}
+
+	creds := make(map[string][]byte, len(names))
+	for _, name := range names {
+		data, err := cm.Read(name)
+		if err != nil {
+			return fmt.Errorf("failed to read credential %q: %w", name, err)
+		}
+		creds[name] = data
+	}
+
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+
+	salt := make([]byte, exportSaltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	archive := exportArchive{
+		Format:     exportFormat,
+		Version:    exportVersion,
+		KDF:        "argon2id",
+		KDFTime:    exportKDFTime,
+		KDFMemory:  exportKDFMemory,
+		KDFThreads: exportKDFThreads,
+		Salt:       salt,
+	}
+
+	archive.Ciphertext, err = filestore.Encrypt(plaintext, archive.deriveKey(passphrase))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt archive: %w", err)
+	}
+
+	if err := json.NewEncoder(w).Encode(archive); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	return nil
+}
+
+// importCredentials reads a passphrase-encrypted archive from r and stores its credentials in cm.
+func importCredentials(cm CredManager, r io.Reader, passphrase string, policy MergePolicy) error {
+	var archive exportArchive
+	if err := json.NewDecoder(r).Decode(&archive); err != nil {
+		return fmt.Errorf("%w: failed to parse archive: %v", ErrInvalidFormat, err)
+	}
+
+	if archive.Format != exportFormat || archive.KDF != "argon2id" {
+		return fmt.Errorf("%w: not a credmgr export archive", ErrInvalidFormat)
+	}
+	if archive.Version > exportVersion {
+		return fmt.Errorf("%w: unsupported archive version %d", ErrInvalidFormat, archive.Version)
+	}
+	if err := archive.checkKDF(); err != nil {
+		return err
+	}
+
+	plaintext, err := filestore.Decrypt(archive.Ciphertext, archive.deriveKey(passphrase))
+	if err != nil {
+		return ErrBadPassphrase
+	}
+
+	var creds map[string][]byte
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return fmt.Errorf("%w: failed to unmarshal archive: %v", ErrInvalidFormat, err)
+	}
+
+	existing := make(map[string]bool)
+	switch policy {
+	case MergeReplace:
+		return replaceCredentials(cm, creds)
+	case MergeSkipExisting:
+		names, err := cm.List()
+		if err != nil {
+			return fmt.Errorf("failed to list credentials: %w", err)
+		}
+		for _, name := range names {
+			existing[name] = true
+		}
+	case MergeOverwrite:
+		// Archive values win
+	default:
+		return fmt.Errorf("unknown merge policy %d", policy)
+	}
+
+	for name := range existing {
+		delete(creds, name)
+	}
+	if err := cm.WriteBatch(creds); err != nil {
+		return fmt.Errorf("failed to import credentials: %w", err)
+	}
+
+	return nil
+}
+
+// replaceCredentials makes creds the whole content of cm. Stores that support Update
+// swap everything in one save; otherwise the archive is written first and only then
+// are the other credentials and the trash removed, so a failed write loses nothing.
+func replaceCredentials(cm CredManager, creds map[string][]byte) error {
+	if sm, ok := cm.(*storeCredManager); ok {
+		if u, ok := sm.Store.(updater); ok {
+			err := u.Update(func(stored map[string][]byte) error {
+				clear(stored)
+				for name, data := range creds {
+					stored[name] = bytes.Clone(data)
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to import credentials: %w", err)
+			}
+			return nil
+		}
+	}
+
+	if err := cm.WriteBatch(creds); err != nil {
+		return fmt.Errorf("failed to import credentials: %w", err)
+	}
+	names, err := cm.List()
+	if err != nil {
+		return fmt.Errorf("failed to list credentials: %w", err)
+	}
+	var stale []string
+	for _, name := range names {
+		if _, ok := creds[name]; !ok {
+			stale = append(stale, name)
+		}
+	}
+	if len(stale) > 0 {
+		if err := cm.DeleteBatch(stale); err != nil {
+			return fmt.Errorf("failed to remove credentials not in the archive: %w", err)
+		}
+	}
+	if err := cm.Purge(""); err != nil {
+		return fmt.Errorf("failed to empty the trash: %w", err)
+	}
+	return nil
+}
+
+// checkKDF rejects Argon2 parameters that would panic or exhaust memory
+func (a *exportArchive) checkKDF() error {
+	switch {
+	case a.KDFTime < 1 || a.KDFTime > importMaxKDFTime:
+		return fmt.Errorf("%w: kdf_time %d out of range 1-%d", ErrInvalidFormat, a.KDFTime, importMaxKDFTime)
+	case a.KDFThreads < 1:
+		return fmt.Errorf("%w: kdf_threads must be at least 1", ErrInvalidFormat)
+	case a.KDFMemory > importMaxKDFMemory:
+		return fmt.Errorf("%w: kdf_memory %d KiB exceeds %d KiB", ErrInvalidFormat, a.KDFMemory, importMaxKDFMemory)
+	}
+	return nil
+}
+
+// deriveKey derives the archive encryption key from a passphrase.
+func (a *exportArchive) deriveKey(passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), a.Salt, a.KDFTime, a.KDFMemory, a.KDFThreads, exportKeyLen)
+}
@@ -0,0 +1,252 @@
+package credmgr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// newTestCredManager creates an additional CredManager in its own temp directory
+// (setupTestEnv must have been called to provide the encryption key)
+func newTestCredManager(t *testing.T) CredManager {
+	t.Helper()
+
+	cm, err := New(filepath.Join(t.TempDir(), "credentials.enc"))
+	if err != nil {
+		t.Fatalf("Failed to create CredManager: %v", err)
+	}
+	return cm
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	want := map[string][]byte{
+		"api-key":  []byte("sk-proj-123"),
+		"binary":   {0x00, 0x01, 0xff},
+		"usercred": []byte("alice:pa:ss"),
+	}
+	for name, data := range want {
+		if err := src.Write(name, data); err != nil {
+			t.Fatalf("Write(%q) failed: %v", name, err)
+		}
+	}
+
+	var archive bytes.Buffer
+	if err := src.Export(&archive, "correct horse"); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	if bytes.Contains(archive.Bytes(), []byte("sk-proj-123")) {
+		t.Error("Archive contains plaintext secret")
+	}
+
+	dst := newTestCredManager(t)
+	if err := dst.Import(&archive, "correct horse", MergeOverwrite); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	for name, data := range want {
+		got, err := dst.Read(name)
+		if err != nil {
+			t.Fatalf("Read(%q) failed: %v", name, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("Read(%q) = %v, want %v", name, got, data)
+		}
+	}
+}
+
+func TestImportWrongPassphrase(t *testing.T) {
+	src, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if err := src.WriteKey("token", "secret"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+
+	var archive bytes.Buffer
+	if err := src.Export(&archive, "right"); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	dst := newTestCredManager(t)
+	err := dst.Import(&archive, "wrong", MergeOverwrite)
+	if !errors.Is(err, ErrBadPassphrase) {
+		t.Errorf("Import with wrong passphrase error = %v, want ErrBadPassphrase", err)
+	}
+}
+
+func TestImportInvalidArchive(t *testing.T) {
+	cm, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	err := cm.Import(bytes.NewBufferString(`{"format":"something-else"}`), "pass", MergeOverwrite)
+	if !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Import of foreign JSON error = %v, want ErrInvalidFormat", err)
+	}
+}
+
+func TestExportEmptyPassphrase(t *testing.T) {
+	cm, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if err := cm.Export(&bytes.Buffer{}, ""); err == nil {
+		t.Error("Export with empty passphrase should fail")
+	}
+}
+
+func TestImportMergePolicies(t *testing.T) {
+	src, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if err := src.WriteKey("shared", "from-archive"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+	if err := src.WriteKey("archive-only", "new"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+
+	var archive bytes.Buffer
+	if err := src.Export(&archive, "pass"); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		policy     MergePolicy
+		wantShared string
+		wantLocal  bool
+	}{
+		{name: "skip existing", policy: MergeSkipExisting, wantShared: "local", wantLocal: true},
+		{name: "overwrite", policy: MergeOverwrite, wantShared: "from-archive", wantLocal: true},
+		{name: "replace", policy: MergeReplace, wantShared: "from-archive", wantLocal: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := newTestCredManager(t)
+			if err := dst.WriteKey("shared", "local"); err != nil {
+				t.Fatalf("WriteKey failed: %v", err)
+			}
+			if err := dst.WriteKey("local-only", "keep?"); err != nil {
+				t.Fatalf("WriteKey failed: %v", err)
+			}
+
+			if err := dst.Import(bytes.NewReader(archive.Bytes()), "pass", tt.policy); err != nil {
+				t.Fatalf("Import failed: %v", err)
+			}
+
+			shared, err := dst.ReadKey("shared")
+			if err != nil {
+				t.Fatalf("ReadKey(shared) failed: %v", err)
+			}
+			if shared != tt.wantShared {
+				t.Errorf("shared = %q, want %q", shared, tt.wantShared)
+			}
+
+			if _, err := dst.ReadKey("archive-only"); err != nil {
+				t.Errorf("archive-only not imported: %v", err)
+			}
+
+			_, err = dst.ReadKey("local-only")
+			if gotLocal := err == nil; gotLocal != tt.wantLocal {
+				t.Errorf("local-only present = %v, want %v", gotLocal, tt.wantLocal)
+			}
+		})
+	}
+}
+
+func TestImportRejectsKDFParameters(t *testing.T) {
+	src := NewMemory()
+	if err := src.WriteKey("token", "secret"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := src.Export(&buf, "pass"); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		patch func(a *exportArchive)
+	}{
+		{name: "zero time", patch: func(a *exportArchive) { a.KDFTime = 0 }},
+		{name: "huge time", patch: func(a *exportArchive) { a.KDFTime = 1 << 30 }},
+		{name: "zero threads", patch: func(a *exportArchive) { a.KDFThreads = 0 }},
+		{name: "huge memory", patch: func(a *exportArchive) { a.KDFMemory = 1 << 31 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var archive exportArchive
+			if err := json.Unmarshal(buf.Bytes(), &archive); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			tt.patch(&archive)
+			data, err := json.Marshal(archive)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+
+			err = NewMemory().Import(bytes.NewReader(data), "pass", MergeOverwrite)
+			if !errors.Is(err, ErrInvalidFormat) {
+				t.Errorf("Import error = %v, want ErrInvalidFormat", err)
+			}
+		})
+	}
+}
+
+// failingWriteStore is a Store without Update whose writes fail
+type failingWriteStore struct {
+	mapStore
+}
+
+func (s failingWriteStore) Write(name string, data []byte) error {
+	return errors.New("disk full")
+}
+
+func TestImportReplaceKeepsDatabaseOnFailedWrite(t *testing.T) {
+	src := NewMemory()
+	if err := src.WriteKey("archived", "new"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+	var archive bytes.Buffer
+	if err := src.Export(&archive, "pass"); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	store := failingWriteStore{mapStore{"local": []byte("keep")}}
+	if err := NewFromStore(store).Import(&archive, "pass", MergeReplace); err == nil {
+		t.Fatal("Import into a failing store should fail")
+	}
+	if got, err := store.Read("local"); err != nil || string(got) != "keep" {
+		t.Errorf("local = %q, %v after a failed replace, want it kept", got, err)
+	}
+}
+
+func TestImportReplaceWithoutUpdate(t *testing.T) {
+	src := NewMemory()
+	if err := src.WriteKey("archived", "new"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+	var archive bytes.Buffer
+	if err := src.Export(&archive, "pass"); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	dst := NewFromStore(mapStore{})
+	if err := dst.WriteKey("local", "old"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+	if err := dst.Import(&archive, "pass", MergeReplace); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	names, _ := dst.List()
+	trash, _ := dst.ListTrash()
+	if len(names) != 1 || names[0] != "archived" || len(trash) != 0 {
+		t.Errorf("after replace names = %v, trash = %v, want [archived] and an empty trash", names, trash)
+	}
+}
@@ -0,0 +1,312 @@
+package credmgr
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh"
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/filestore"
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+	"github.com/nzions/fdot/pkg/fdotconfig"
+)
+
+// FIDO2 unlock wraps the master key with the hmac-secret output of a FIDO2 security key.
+// Enrollments are stored next to the credential file in <path>.fido2 and several keys
+// (e.g. a primary and a backup) can be enrolled for the same database.
+
+// ErrFIDO2NotEnrolled is returned when no FIDO2 security key is enrolled for a database.
+var ErrFIDO2NotEnrolled = errors.New("no FIDO2 security key enrolled")
+
+// fido2RelyingParty is the relying party ID used for credmgr FIDO2 credentials
+const fido2RelyingParty = "fdot-credmgr"
+
+// fido2Enrollment records one enrolled security key and the master key wrapped by it
+type fido2Enrollment struct {
+	Label        string    `json:"label"`
+	CredentialID []byte    `json:"credential_id"`
+	Salt         []byte    `json:"salt"`
+	WrappedKey   []byte    `json:"wrapped_key"`
+	EnrolledAt   time.Time `json:"enrolled_at"`
+}
+
+// fido2Token is a FIDO2 authenticator supporting the hmac-secret extension
+type fido2Token interface {
+	// MakeCredential creates a new hmac-secret capable credential and returns its ID
+	MakeCredential(rpID, user string) ([]byte, error)
+	// HMACSecret returns the 32-byte hmac-secret for the credential and salt
+	HMACSecret(rpID string, credentialID, salt []byte) ([]byte, error)
+}
+
+// fido2Authenticator is the token used for enrollment and unlock (replaced in tests)
+var fido2Authenticator fido2Token = libfido2Tools{}
+
+// fido2EnrollmentPath returns the enrollment file path for a credential database
+func fido2EnrollmentPath(dbPath string) string {
+	return dbPath + ".fido2"
+}
+
+// EnrollFIDO2 enrolls the connected FIDO2 security key as an unlock method for the
+// credential database at dbPath. The current master key must be available
+// (CREDMGR_KEY, CREDMGR_KEYFILE or an already-enrolled security key).
+func EnrollFIDO2(dbPath, label string) error {
+	if label == "" {
+		return fmt.Errorf("enrollment label must not be empty")
+	}
+
+	enrollments, err := readFIDO2Enrollments(dbPath)
+	if err != nil {
+		return err
+	}
+	for _, e := range enrollments {
+		if e.Label == label {
+			return fmt.Errorf("FIDO2 key %q is already enrolled", label)
+		}
+	}
+
+	masterKey, err := loadMasterKey(dbPath, "")
+	if err != nil {
+		return fmt.Errorf("failed to load master key: %w", err)
+	}
+	defer securemem.Wipe(masterKey)
+
+	credentialID, err := fido2Authenticator.MakeCredential(fido2RelyingParty, label)
+	if err != nil {
+		return fmt.Errorf("failed to create FIDO2 credential: %w", err)
+	}
+
+	salt := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	kek, err := fido2Authenticator.HMACSecret(fido2RelyingParty, credentialID, salt)
+	if err != nil {
+		return fmt.Errorf("failed to get hmac-secret: %w", err)
+	}
+	defer securemem.Wipe(kek)
+
+	wrapped, err := filestore.Encrypt(masterKey, kek)
+	if err != nil {
+		return fmt.Errorf("failed to wrap master key: %w", err)
+	}
+
+	enrollments = append(enrollments, fido2Enrollment{
+		Label:        label,
+		CredentialID: credentialID,
+		Salt:         salt,
+		WrappedKey:   wrapped,
+		EnrolledAt:   time.Now(),
+	})
+
+	return writeFIDO2Enrollments(dbPath, enrollments)
+}
+
+// RemoveFIDO2 removes the enrolled security key with the given label.
+// The enrollment file is deleted when the last key is removed.
+func RemoveFIDO2(dbPath, label string) error {
+	enrollments, err := readFIDO2Enrollments(dbPath)
+	if err != nil {
+		return err
+	}
+
+	kept := enrollments[:0]
+	for _, e := range enrollments {
+		if e.Label != label {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == len(enrollments) {
+		return fmt.Errorf("FIDO2 key %q %w", label, ErrNotFound)
+	}
+
+	if len(kept) == 0 {
+		if err := os.Remove(fido2EnrollmentPath(dbPath)); err != nil {
+			return fmt.Errorf("failed to remove FIDO2 enrollment file: %w", err)
+		}
+		return nil
+	}
+
+	return writeFIDO2Enrollments(dbPath, kept)
+}
+
+// ListFIDO2 returns the labels of all security keys enrolled for the database.
+func ListFIDO2(dbPath string) ([]string, error) {
+	enrollments, err := readFIDO2Enrollments(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]string, 0, len(enrollments))
+	for _, e := range enrollments {
+		labels = append(labels, e.Label)
+	}
+	return labels, nil
+}
+
+// unlockFIDO2 recovers the master key using any enrolled security key that is present.
+// The returned bool reports whether any key is enrolled for the database.
+func unlockFIDO2(dbPath string) ([]byte, bool, error) {
+	enrollments, err := readFIDO2Enrollments(dbPath)
+	if err != nil {
+		return nil, true, err
+	}
+	if len(enrollments) == 0 {
+		return nil, false, ErrFIDO2NotEnrolled
+	}
+
+	var errs []error
+	for _, e := range enrollments {
+		kek, err := fido2Authenticator.HMACSecret(fido2RelyingParty, e.CredentialID, e.Salt)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Label, err))
+			continue
+		}
+
+		key, err := filestore.Decrypt(e.WrappedKey, kek)
+		securemem.Wipe(kek)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: failed to unwrap master key: %w", e.Label, err))
+			continue
+		}
+		return key, true, nil
+	}
+
+	return nil, true, fmt.Errorf("FIDO2 unlock failed: %w", errors.Join(errs...))
+}
+
+// readFIDO2Enrollments loads the enrollment file, returning nil if it does not exist
+func readFIDO2Enrollments(dbPath string) ([]fido2Enrollment, error) {
+	data, err := os.ReadFile(fido2EnrollmentPath(dbPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FIDO2 enrollment file: %w", err)
+	}
+
+	var enrollments []fido2Enrollment
+	if err := json.Unmarshal(data, &enrollments); err != nil {
+		return nil, fmt.Errorf("%w: FIDO2 enrollment file: %v", ErrInvalidFormat, err)
+	}
+	return enrollments, nil
+}
+
+// writeFIDO2Enrollments saves the enrollment file with owner-only permissions
+func writeFIDO2Enrollments(dbPath string, enrollments []fido2Enrollment) error {
+	data, err := json.MarshalIndent(enrollments, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal FIDO2 enrollments: %w", err)
+	}
+
+	if err := fdh.WritePrivateFileAtomic(fido2EnrollmentPath(dbPath), data); err != nil {
+		return fmt.Errorf("failed to write FIDO2 enrollment file: %w", err)
+	}
+	return nil
+}
+
+// libfido2Tools talks to security keys using the libfido2 command-line tools
+// (fido2-token, fido2-cred, fido2-assert), which must be installed and on PATH.
+type libfido2Tools struct{}
+
+// device returns the authenticator device path from CREDMGR_FIDO2_DEVICE or the first listed token
+func (libfido2Tools) device() (string, error) {
+	if dev := os.Getenv(fdotconfig.CredMgrEnvVarFIDO2Device); dev != "" {
+		return dev, nil
+	}
+
+	out, err := exec.Command("fido2-token", "-L").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to list FIDO2 devices: %w", err)
+	}
+
+	for line := range strings.Lines(string(out)) {
+		if dev, _, found := strings.Cut(line, ": "); found {
+			return dev, nil
+		}
+	}
+	return "", fmt.Errorf("no FIDO2 device found")
+}
+
+// run executes a libfido2 tool with base64/UTF-8 lines on stdin and returns its output lines
+func (t libfido2Tools) run(tool string, input []string, args ...string) ([]string, error) {
+	dev, err := t.device()
+	if err != nil {
+		return nil, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(tool, append(args, dev)...)
+	cmd.Stdin = strings.NewReader(strings.Join(input, "\n") + "\n")
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w (%s)", tool, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\n"), nil
+}
+
+// MakeCredential runs fido2-cred -M -h; the credential ID is the fifth output line
+func (t libfido2Tools) MakeCredential(rpID, user string) ([]byte, error) {
+	clientDataHash, userID := make([]byte, 32), make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, clientDataHash); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(rand.Reader, userID); err != nil {
+		return nil, err
+	}
+
+	lines, err := t.run("fido2-cred", []string{
+		base64.StdEncoding.EncodeToString(clientDataHash),
+		rpID,
+		user,
+		base64.StdEncoding.EncodeToString(userID),
+	}, "-M", "-h")
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) < 5 {
+		return nil, fmt.Errorf("unexpected fido2-cred output (%d lines)", len(lines))
+	}
+
+	return base64.StdEncoding.DecodeString(lines[4])
+}
+
+// HMACSecret runs fido2-assert -G -h; the hmac-secret is the last output line
+func (t libfido2Tools) HMACSecret(rpID string, credentialID, salt []byte) ([]byte, error) {
+	clientDataHash := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, clientDataHash); err != nil {
+		return nil, err
+	}
+
+	lines, err := t.run("fido2-assert", []string{
+		base64.StdEncoding.EncodeToString(clientDataHash),
+		rpID,
+		base64.StdEncoding.EncodeToString(credentialID),
+		base64.StdEncoding.EncodeToString(salt),
+	}, "-G", "-h")
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("empty fido2-assert output")
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(lines[len(lines)-1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid hmac-secret output: %w", err)
+	}
+	if len(secret) != masterKeyLen {
+		return nil, fmt.Errorf("unexpected hmac-secret length %d", len(secret))
+	}
+	return secret, nil
+}
@@ -0,0 +1,137 @@
+package credmgr
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeFIDO2Token emulates an hmac-secret authenticator with a per-device secret
+type fakeFIDO2Token struct {
+	deviceSecret []byte
+	created      int
+}
+
+func (f *fakeFIDO2Token) MakeCredential(rpID, user string) ([]byte, error) {
+	f.created++
+	return fmt.Appendf(nil, "%s-cred-%d", f.deviceSecret, f.created), nil
+}
+
+func (f *fakeFIDO2Token) HMACSecret(rpID string, credentialID, salt []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, f.deviceSecret)
+	mac.Write(credentialID)
+	mac.Write(salt)
+	return mac.Sum(nil), nil
+}
+
+// useFakeFIDO2 installs a fake authenticator for the duration of the test
+func useFakeFIDO2(t *testing.T, token fido2Token) {
+	t.Helper()
+	original := fido2Authenticator
+	fido2Authenticator = token
+	t.Cleanup(func() { fido2Authenticator = original })
+}
+
+func TestFIDO2EnrollAndUnlock(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+	useFakeFIDO2(t, &fakeFIDO2Token{deviceSecret: []byte("yubikey-1")})
+
+	dbPath := filepath.Join(t.TempDir(), "credentials.enc")
+
+	// Write a credential using CREDMGR_KEY
+	cm, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := cm.WriteKey("token", "secret"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+
+	if err := EnrollFIDO2(dbPath, "primary"); err != nil {
+		t.Fatalf("EnrollFIDO2 failed: %v", err)
+	}
+
+	info, err := os.Stat(fido2EnrollmentPath(dbPath))
+	if err != nil {
+		t.Fatalf("Enrollment file not created: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Enrollment file permissions = %o, want 600", perm)
+	}
+
+	// Unlock without CREDMGR_KEY
+	os.Unsetenv("CREDMGR_KEY")
+	cm, err = New(dbPath)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	got, err := cm.ReadKey("token")
+	if err != nil {
+		t.Fatalf("ReadKey with FIDO2 unlock failed: %v", err)
+	}
+	if got != "secret" {
+		t.Errorf("ReadKey = %q, want %q", got, "secret")
+	}
+}
+
+func TestFIDO2WrongToken(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+	useFakeFIDO2(t, &fakeFIDO2Token{deviceSecret: []byte("yubikey-1")})
+
+	dbPath := filepath.Join(t.TempDir(), "credentials.enc")
+	if err := EnrollFIDO2(dbPath, "primary"); err != nil {
+		t.Fatalf("EnrollFIDO2 failed: %v", err)
+	}
+
+	os.Unsetenv("CREDMGR_KEY")
+	useFakeFIDO2(t, &fakeFIDO2Token{deviceSecret: []byte("someone-elses-key")})
+
+	if _, err := loadMasterKey(dbPath, ""); err == nil {
+		t.Error("loadMasterKey with wrong security key should fail")
+	}
+}
+
+func TestFIDO2ListRemove(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+	useFakeFIDO2(t, &fakeFIDO2Token{deviceSecret: []byte("yubikey-1")})
+
+	dbPath := filepath.Join(t.TempDir(), "credentials.enc")
+	for _, label := range []string{"primary", "backup"} {
+		if err := EnrollFIDO2(dbPath, label); err != nil {
+			t.Fatalf("EnrollFIDO2(%q) failed: %v", label, err)
+		}
+	}
+
+	if err := EnrollFIDO2(dbPath, "primary"); err == nil {
+		t.Error("Enrolling a duplicate label should fail")
+	}
+
+	labels, err := ListFIDO2(dbPath)
+	if err != nil {
+		t.Fatalf("ListFIDO2 failed: %v", err)
+	}
+	if len(labels) != 2 {
+		t.Errorf("ListFIDO2 = %v, want 2 labels", labels)
+	}
+
+	if err := RemoveFIDO2(dbPath, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RemoveFIDO2(missing) error = %v, want ErrNotFound", err)
+	}
+
+	for _, label := range []string{"primary", "backup"} {
+		if err := RemoveFIDO2(dbPath, label); err != nil {
+			t.Fatalf("RemoveFIDO2(%q) failed: %v", label, err)
+		}
+	}
+
+	if _, err := os.Stat(fido2EnrollmentPath(dbPath)); !os.IsNotExist(err) {
+		t.Error("Enrollment file should be removed with the last key")
+	}
+}
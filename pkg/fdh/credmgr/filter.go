@@ -0,0 +1,58 @@
+package credmgr
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ListFiltered patterns select names in one of three ways:
+//
+//	myapp-          prefix: names starting with "myapp-"
+//	myapp-*         glob (path.Match): any pattern containing *, ? or [
+//	re:^(dev|qa)-   regular expression, after the "re:" prefix
+//
+// An empty pattern selects every name.
+
+// regexpPrefix marks a ListFiltered pattern as a regular expression
+const regexpPrefix = "re:"
+
+// nameMatcher compiles a ListFiltered pattern
+func nameMatcher(pattern string) (func(string) bool, error) {
+	switch {
+	case strings.HasPrefix(pattern, regexpPrefix):
+		re, err := regexp.Compile(strings.TrimPrefix(pattern, regexpPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		return re.MatchString, nil
+
+	case strings.ContainsAny(pattern, "*?["):
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		return func(name string) bool {
+			ok, _ := path.Match(pattern, name)
+			return ok
+		}, nil
+
+	default:
+		return func(name string) bool { return strings.HasPrefix(name, pattern) }, nil
+	}
+}
+
+// filterNames returns the names matching pattern, keeping their order
+func filterNames(names []string, pattern string) ([]string, error) {
+	match, err := nameMatcher(pattern)
+	if err != nil {
+		return nil, err
+	}
+	matched := []string{}
+	for _, name := range names {
+		if match(name) {
+			matched = append(matched, name)
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,47 @@
+package credmgr
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestListFiltered(t *testing.T) {
+	cm := NewFromStore(mapStore{})
+	for _, name := range []string{"myapp-token", "myapp-db", "dev-api", "qa-api", "prod-api"} {
+		if err := cm.WriteKey(name, "v"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cm.Delete("myapp-db"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"", []string{"dev-api", "myapp-token", "prod-api", "qa-api"}},
+		{"myapp-", []string{"myapp-token"}},
+		{"*-api", []string{"dev-api", "prod-api", "qa-api"}},
+		{"[dq]*", []string{"dev-api", "qa-api"}},
+		{"re:^(dev|prod)-", []string{"dev-api", "prod-api"}},
+		{"none", []string{}},
+	}
+	for _, tt := range tests {
+		got, err := cm.ListFiltered(tt.pattern)
+		if err != nil {
+			t.Errorf("ListFiltered(%q) failed: %v", tt.pattern, err)
+			continue
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("ListFiltered(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"re:(", "[a"} {
+		if _, err := cm.ListFiltered(bad); err == nil {
+			t.Errorf("ListFiltered(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package credmgr
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// Character sets for Policy.Charset
+const (
+	CharsetAlphanumeric = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	CharsetHex          = "0123456789abcdef"
+	CharsetSymbols      = CharsetAlphanumeric + "!#$%&()*+,-./:;<=>?@[]^_{|}~"
+)
+
+// ambiguousChars are easily confused when a secret is read or typed by hand
+const ambiguousChars = "0O1lI|"
+
+// DefaultGenerateLength is the length used when Policy.Length is zero
+const DefaultGenerateLength = 32
+
+// Policy describes a generated secret
+type Policy struct {
+	// Length is the number of characters (DefaultGenerateLength if zero)
+	Length int
+	// Charset is the set of characters to draw from (CharsetAlphanumeric if empty)
+	Charset string
+	// NoAmbiguous drops characters that are easily confused (0 O 1 l I |)
+	NoAmbiguous bool
+}
+
+// GenerateSecret returns a random secret following p, each character drawn uniformly
+// from the charset with crypto/rand
+func GenerateSecret(p Policy) (string, error) {
+	length := p.Length
+	if length == 0 {
+		length = DefaultGenerateLength
+	}
+	if length < 0 {
+		return "", errors.New("credmgr: negative secret length")
+	}
+
+	charset := p.Charset
+	if charset == "" {
+		charset = CharsetAlphanumeric
+	}
+	if p.NoAmbiguous {
+		charset = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(ambiguousChars, r) {
+				return -1
+			}
+			return r
+		}, charset)
+	}
+	chars := []rune(charset)
+	if len(chars) < 2 {
+		return "", errors.New("credmgr: charset needs at least two characters")
+	}
+
+	size := big.NewInt(int64(len(chars)))
+	var b strings.Builder
+	for range length {
+		n, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", err
+		}
+		b.WriteRune(chars[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+// Generate creates a random secret following p, stores it under name (replacing any
+// existing value) and returns it:
+//
+//	password, err := credmgr.Generate(cm, "db-password", credmgr.Policy{Length: 24, NoAmbiguous: true})
+func Generate(cm CredManager, name string, p Policy) (string, error) {
+	secret, err := GenerateSecret(p)
+	if err != nil {
+		return "", err
+	}
+	if err := cm.WriteKey(name, secret); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
@@ -0,0 +1,59 @@
+package credmgr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateSecret(t *testing.T) {
+	tests := []struct {
+		policy  Policy
+		length  int
+		charset string
+	}{
+		{Policy{}, DefaultGenerateLength, CharsetAlphanumeric},
+		{Policy{Length: 256, Charset: CharsetHex}, 256, CharsetHex},
+		{Policy{Length: 64, Charset: CharsetSymbols, NoAmbiguous: true}, 64, CharsetSymbols},
+	}
+	for _, tt := range tests {
+		secret, err := GenerateSecret(tt.policy)
+		if err != nil {
+			t.Fatalf("GenerateSecret(%+v) failed: %v", tt.policy, err)
+		}
+		if len(secret) != tt.length {
+			t.Errorf("GenerateSecret(%+v) length = %d, want %d", tt.policy, len(secret), tt.length)
+		}
+		for _, r := range secret {
+			if !strings.ContainsRune(tt.charset, r) {
+				t.Errorf("GenerateSecret(%+v) produced %q outside the charset", tt.policy, r)
+			}
+			if tt.policy.NoAmbiguous && strings.ContainsRune(ambiguousChars, r) {
+				t.Errorf("GenerateSecret(%+v) produced ambiguous %q", tt.policy, r)
+			}
+		}
+	}
+
+	if a, _ := GenerateSecret(Policy{}); a == "" {
+		t.Error("GenerateSecret returned an empty secret")
+	} else if b, _ := GenerateSecret(Policy{}); a == b {
+		t.Error("two generated secrets are equal")
+	}
+
+	if _, err := GenerateSecret(Policy{Charset: "a"}); err == nil {
+		t.Error("GenerateSecret with a one-character charset should fail")
+	}
+	if _, err := GenerateSecret(Policy{Charset: "01", NoAmbiguous: true}); err == nil {
+		t.Error("GenerateSecret with a charset emptied by NoAmbiguous should fail")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	cm := NewFromStore(mapStore{})
+	secret, err := Generate(cm, "db-password", Policy{Length: 20})
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if stored, err := cm.ReadKey("db-password"); err != nil || stored != secret {
+		t.Errorf("stored = %q, %v; want %q", stored, err, secret)
+	}
+}
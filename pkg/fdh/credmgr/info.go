@@ -0,0 +1,82 @@
+package credmgr
+
+import (
+	"bytes"
+	"encoding/json"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// Kinds of stored value reported by Describe
+const (
+	KindUserPass = "userpass" // username/password written by WriteUserCred
+	KindTOTP     = "totp"     // TOTP seed written by WriteTOTP
+	KindSSHKey   = "ssh-key"  // PEM or OpenSSH private key, or a login stored with NewSSHKey
+	KindKey      = "key"      // printable text, e.g. a token written by WriteKey
+	KindRaw      = "raw"      // anything else
+)
+
+// Info describes a stored credential without its secret
+type Info struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Size     int    `json:"size"`               // length of the stored value in bytes
+	Username string `json:"username,omitempty"` // userpass and SSH key logins only
+}
+
+// Describe reports the kind and size of the credential stored under name. The value
+// is read to classify it and wiped before Describe returns.
+func Describe(cm CredManager, name string) (Info, error) {
+	data, err := cm.Read(name)
+	if err != nil {
+		return Info{}, err
+	}
+	defer securemem.Wipe(data)
+
+	info := Info{Name: name, Kind: KindRaw, Size: len(data)}
+	// Typed records carry their kind; the secret fields are not decoded
+	var rec struct {
+		Type     string `json:"type"`
+		Username string `json:"username"`
+	}
+	if bytes.HasPrefix(data, []byte("{")) && json.Unmarshal(data, &rec) != nil {
+		rec.Type = ""
+	}
+	switch {
+	case rec.Type == userCredType:
+		info.Kind, info.Username = KindUserPass, rec.Username
+	case rec.Type == totpType:
+		info.Kind = KindTOTP
+	case rec.Type == sshKeyType:
+		info.Kind, info.Username = KindSSHKey, rec.Username
+	case isPrivateKey(data):
+		info.Kind = KindSSHKey
+	case isText(data):
+		info.Kind = KindKey
+	}
+	return info, nil
+}
+
+// isPrivateKey reports whether data is a PEM-armoured private key
+func isPrivateKey(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return bytes.HasPrefix(data, []byte("-----BEGIN ")) && bytes.Contains(data, []byte("PRIVATE KEY-----"))
+}
+
+// isText reports whether data is non-empty UTF-8 without control characters other
+// than whitespace
+func isText(data []byte) bool {
+	if len(data) == 0 || !utf8.Valid(data) {
+		return false
+	}
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return false
+		}
+		data = data[size:]
+	}
+	return true
+}
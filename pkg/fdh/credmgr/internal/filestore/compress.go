@@ -0,0 +1,84 @@
+package filestore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// flagGzip in the header of a format 3 file marks the plaintext as gzip-compressed
+const flagGzip = 1 << 0
+
+// knownFlags are the header flags this version understands
+const knownFlags = flagGzip
+
+// compress gzips plaintext, returning nil if that does not make it smaller.
+// The result is wiped by the caller; the compressor's internal window is heap
+// memory that cannot be wiped.
+func compress(plaintext []byte) ([]byte, error) {
+	out := &fixedBuffer{buf: make([]byte, 0, len(plaintext))}
+	zw, err := gzip.NewWriterLevel(out, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	_, err = zw.Write(plaintext)
+	if err == nil {
+		err = zw.Close()
+	}
+	if errors.Is(err, errNotSmaller) || len(out.buf) == len(plaintext) {
+		securemem.Wipe(out.buf)
+		return nil, nil
+	}
+	if err != nil {
+		securemem.Wipe(out.buf)
+		return nil, err
+	}
+	return out.buf, nil
+}
+
+// errNotSmaller is returned by fixedBuffer when the compressed data outgrows the input
+var errNotSmaller = errors.New("compressed data is not smaller")
+
+// fixedBuffer is a writer that never reallocates, so no copy of its contents is
+// left behind
+type fixedBuffer struct {
+	buf []byte
+}
+
+func (b *fixedBuffer) Write(p []byte) (int, error) {
+	if len(b.buf)+len(p) > cap(b.buf) {
+		return 0, errNotSmaller
+	}
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+// decompress reverses compress. The output buffer is sized from the gzip trailer,
+// so the plaintext is written once and never left behind in a grown buffer.
+func decompress(compressed []byte) ([]byte, error) {
+	if len(compressed) < 4 {
+		return nil, fmt.Errorf("compressed data is truncated")
+	}
+	size := binary.LittleEndian.Uint32(compressed[len(compressed)-4:])
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, size)
+	if _, err := io.ReadFull(zr, plaintext); err != nil {
+		securemem.Wipe(plaintext)
+		return nil, fmt.Errorf("failed to decompress credentials: %w", err)
+	}
+	// Reading to EOF verifies the checksum and that the size was not truncated
+	if n, err := zr.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		securemem.Wipe(plaintext)
+		return nil, fmt.Errorf("failed to decompress credentials: size mismatch")
+	}
+	return plaintext, nil
+}
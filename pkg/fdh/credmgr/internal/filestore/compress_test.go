@@ -0,0 +1,71 @@
+package filestore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestCompression(t *testing.T) {
+	kubeconfig := bytes.Repeat([]byte("apiVersion: v1\nclusters:\n- cluster:\n    server: https://k8s.example.com\n"), 200)
+
+	plain, _ := newTestStore(t)
+	if err := plain.Write("kubeconfig", kubeconfig); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	s, _ := newTestStore(t)
+	s.SetCompression(true)
+	if err := s.Write("kubeconfig", kubeconfig); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	compressed := mustRead(t, s.Path())
+	if e, err := parseEnvelope(compressed); err != nil || e.flags&flagGzip == 0 {
+		t.Fatalf("compressed file flags = %v, %v", e, err)
+	}
+	if size := len(mustRead(t, plain.Path())); len(compressed) >= size/4 {
+		t.Errorf("compressed file is %d bytes, uncompressed %d", len(compressed), size)
+	}
+
+	// Another Store reads it and keeps it compressed
+	other := New(s.Path(), staticKey)
+	if got, err := other.Read("kubeconfig"); err != nil || !bytes.Equal(got, kubeconfig) {
+		t.Fatalf("Read = %d bytes, %v", len(got), err)
+	}
+	if err := other.Write("a", []byte("1")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if e, _ := parseEnvelope(mustRead(t, s.Path())); e.flags&flagGzip == 0 {
+		t.Error("write by a Store without a setting dropped compression")
+	}
+
+	other.SetCompression(false)
+	if err := other.Write("a", []byte("2")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if e, _ := parseEnvelope(mustRead(t, s.Path())); e.flags&flagGzip != 0 {
+		t.Error("SetCompression(false) left the file compressed")
+	}
+}
+
+func TestCompressionSkippedWhenNotSmaller(t *testing.T) {
+	random := make([]byte, 4096)
+	rand.Read(random)
+	if out, err := compress(random); err != nil || out != nil {
+		t.Errorf("compress(random) = %d bytes, %v; want nil", len(out), err)
+	}
+}
+
+func TestDecompressRejectsBadSize(t *testing.T) {
+	out, err := compress(bytes.Repeat([]byte("x"), 1000))
+	if err != nil || out == nil {
+		t.Fatalf("compress = %v, %v", out, err)
+	}
+	if got, err := decompress(out); err != nil || len(got) != 1000 {
+		t.Fatalf("decompress = %d bytes, %v", len(got), err)
+	}
+	out[len(out)-4]++ // size trailer
+	if _, err := decompress(out); err == nil {
+		t.Error("decompress with a wrong size trailer should fail")
+	}
+}
@@ -0,0 +1,398 @@
+package filestore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nzions/fdot/pkg/fdh"
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// DirStore keeps each credential in its own encrypted file under a directory, so a
+// write replaces one small file instead of re-encrypting every credential, and List
+// decrypts only names.
+//
+// Files are named by a keyed hash of the credential name, so the directory reveals
+// neither names nor which files belong together. Each file holds the sealed name
+// and the sealed value, bound to the file name:
+//
+//	magic "FDCE" | version (1 byte) | cipher (1 byte) | name length (2 bytes) |
+//	sealed name | sealed value
+//
+// Every write is an atomic rename of one file, so concurrent writers of different
+// credentials never conflict and there is no lock file; the last writer of one
+// credential wins. Nothing is cached: each Read decrypts one file.
+type DirStore struct {
+	dir      string
+	readOnly bool
+	cipher   Cipher
+
+	keyFunc KeyFunc
+	keyOnce sync.Once
+	keyErr  error
+	nameKey *securemem.Buffer // keys the file name hash
+	dataKey *securemem.Buffer // encrypts names and values
+}
+
+// entryMagic starts every file of a DirStore
+var entryMagic = []byte("FDCE")
+
+const (
+	entryVersion    = 1
+	entryHeaderSize = 8
+	entryExt        = ".cred"
+)
+
+// errNotEntry is returned for a file that does not start with entryMagic
+var errNotEntry = errors.New("not a credential entry")
+
+// NewDir returns a DirStore for the directory dir, created on first write.
+func NewDir(dir string, key KeyFunc) *DirStore {
+	return &DirStore{dir: dir, keyFunc: key}
+}
+
+// NewDirReadOnly returns a DirStore that only reads dir. Mutations return ErrReadOnly.
+func NewDirReadOnly(dir string, key KeyFunc) *DirStore {
+	s := NewDir(dir, key)
+	s.readOnly = true
+	return s
+}
+
+// SetCipher selects the cipher new and rewritten entries use (AES-256-GCM by
+// default); each entry records its own, so a directory may mix both.
+func (s *DirStore) SetCipher(c Cipher) {
+	s.cipher = c
+}
+
+// Path returns the directory
+func (s *DirStore) Path() string {
+	return s.dir
+}
+
+// String returns the directory (used in log and error output)
+func (s *DirStore) String() string {
+	return s.dir
+}
+
+// keys derives the name and data keys from the master key once, wiping the master key
+func (s *DirStore) keys() (nameKey, dataKey []byte, err error) {
+	s.keyOnce.Do(func() {
+		key, err := s.keyFunc()
+		if err != nil {
+			s.keyErr = err
+			return
+		}
+		defer securemem.Wipe(key)
+		s.nameKey = securemem.FromBytes(deriveKey(key, "credmgr dir name"))
+		s.dataKey = securemem.FromBytes(deriveKey(key, "credmgr dir data"))
+	})
+	if s.keyErr != nil {
+		return nil, nil, s.keyErr
+	}
+	return s.nameKey.Bytes(), s.dataKey.Bytes(), nil
+}
+
+// deriveKey derives a purpose-specific 32-byte key from the master key
+func deriveKey(master []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// entryPath returns the file of a credential
+func (s *DirStore) entryPath(nameKey []byte, name string) (path, id string) {
+	mac := hmac.New(sha256.New, nameKey)
+	mac.Write([]byte(name))
+	id = hex.EncodeToString(mac.Sum(nil)[:16])
+	return filepath.Join(s.dir, id+entryExt), id
+}
+
+// sealEntry encodes the file for one credential
+func sealEntry(c Cipher, dataKey []byte, id, name string, data []byte) ([]byte, error) {
+	header := append(bytes.Clone(entryMagic), entryVersion, byte(c), 0, 0)
+	aad := append(bytes.Clone(header[:6]), id...)
+
+	sealedName, err := seal(c, dataKey, []byte(name), append(aad, 'n'))
+	if err != nil {
+		return nil, err
+	}
+	if len(sealedName) > 0xffff {
+		return nil, fmt.Errorf("credential name is too long (%d bytes)", len(name))
+	}
+	binary.BigEndian.PutUint16(header[6:], uint16(len(sealedName)))
+
+	sealedData, err := seal(c, dataKey, data, append(aad, 'v'))
+	if err != nil {
+		return nil, err
+	}
+	out := append(header, sealedName...)
+	return append(out, sealedData...), nil
+}
+
+// parseEntryHeader returns the cipher and sealed name length of an entry
+func parseEntryHeader(header []byte) (Cipher, int, error) {
+	if len(header) < entryHeaderSize {
+		return 0, 0, errTruncated
+	}
+	if !bytes.Equal(header[:4], entryMagic) {
+		return 0, 0, errNotEntry
+	}
+	if header[4] != entryVersion {
+		return 0, 0, fmt.Errorf("%w %d", errUnsupportedFormat, header[4])
+	}
+	return Cipher(header[5]), int(binary.BigEndian.Uint16(header[6:])), nil
+}
+
+// openEntryName decrypts the name of an entry from the start of its file
+func openEntryName(r io.Reader, dataKey []byte, id string) (string, []byte, error) {
+	header := make([]byte, entryHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", nil, errTruncated
+	}
+	c, nameLen, err := parseEntryHeader(header)
+	if err != nil {
+		return "", nil, err
+	}
+	sealedName := make([]byte, nameLen)
+	if _, err := io.ReadFull(r, sealedName); err != nil {
+		return "", nil, errTruncated
+	}
+	aad := append(header[:6:6], id...)
+	name, err := open(c, dataKey, sealedName, append(aad, 'n'))
+	if err != nil {
+		return "", nil, err
+	}
+	return string(name), aad, nil
+}
+
+// decodeEntry decrypts the file of the credential name
+func (s *DirStore) decodeEntry(path, id, name string, dataKey []byte) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("credential %q %w", name, ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to read credential file: %w", err)
+	}
+
+	r := bytes.NewReader(raw)
+	stored, aad, err := openEntryName(r, dataKey, id)
+	if err != nil {
+		return nil, entryError(path, err)
+	}
+	if stored != name {
+		return nil, fmt.Errorf("%w: %s belongs to another credential", ErrCorrupt, path)
+	}
+
+	c := Cipher(raw[5])
+	sealedData := raw[len(raw)-r.Len():]
+	data, err := open(c, dataKey, sealedData, append(aad, 'v'))
+	if err != nil {
+		return nil, entryError(path, err)
+	}
+	return data, nil
+}
+
+// entryError maps a failure to open an entry to ErrCorrupt or ErrWrongKey
+func entryError(path string, err error) error {
+	switch {
+	case errors.Is(err, errTruncated):
+		return fmt.Errorf("%w: %s is truncated", ErrCorrupt, path)
+	case errors.Is(err, errUnsupportedFormat), errors.Is(err, errUnsupportedCipher):
+		return fmt.Errorf("%w: %s: %v; upgrade credmgr", ErrCorrupt, path, err)
+	case errors.Is(err, errNotEntry):
+		return fmt.Errorf("%w: %s is not a credential entry", ErrCorrupt, path)
+	default:
+		return fmt.Errorf("failed to decrypt credential: %w: %s was encrypted with a different key", ErrWrongKey, path)
+	}
+}
+
+// Read retrieves raw credential bytes by name.
+func (s *DirStore) Read(name string) ([]byte, error) {
+	nameKey, dataKey, err := s.keys()
+	if err != nil {
+		return nil, err
+	}
+	path, id := s.entryPath(nameKey, name)
+	return s.decodeEntry(path, id, name, dataKey)
+}
+
+// Write stores raw credential bytes with the given name, replacing its file atomically.
+func (s *DirStore) Write(name string, data []byte) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	nameKey, dataKey, err := s.keys()
+	if err != nil {
+		return err
+	}
+	if err := fdh.CreatePrivateDir(s.dir); err != nil {
+		return err
+	}
+
+	c := s.cipher
+	if c == 0 {
+		c = CipherAES256GCM
+	}
+	path, id := s.entryPath(nameKey, name)
+	entry, err := sealEntry(c, dataKey, id, name, data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt credential: %w", err)
+	}
+	if err := fdh.WritePrivateFileAtomic(path, entry); err != nil {
+		return fmt.Errorf("failed to write credential file: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a credential's file.
+func (s *DirStore) Delete(name string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	nameKey, _, err := s.keys()
+	if err != nil {
+		return err
+	}
+	path, _ := s.entryPath(nameKey, name)
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("credential %q %w", name, ErrNotFound)
+		}
+		return fmt.Errorf("failed to delete credential file: %w", err)
+	}
+	return nil
+}
+
+// DeleteDB removes every credential file, and the directory if that leaves it empty.
+func (s *DirStore) DeleteDB() error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	paths, err := s.entryFiles()
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to delete credentials database: %w", err)
+		}
+	}
+	os.Remove(s.dir) // only succeeds if nothing else lives there
+	return nil
+}
+
+// List returns every stored name, sorted. Only the names are decrypted.
+func (s *DirStore) List() ([]string, error) {
+	_, dataKey, err := s.keys()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := s.entryFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		name, err := readEntryName(path, dataKey)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // deleted meanwhile
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readEntryName decrypts the name stored in one file
+func readEntryName(path string, dataKey []byte) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	id := strings.TrimSuffix(filepath.Base(path), entryExt)
+	name, _, err := openEntryName(f, dataKey, id)
+	if err != nil {
+		return "", entryError(path, err)
+	}
+	return name, nil
+}
+
+// entryFiles returns the credential files in the directory
+func (s *DirStore) entryFiles() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read credential directory: %w", err)
+	}
+	var paths []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && strings.HasSuffix(e.Name(), entryExt) {
+			paths = append(paths, filepath.Join(s.dir, e.Name()))
+		}
+	}
+	return paths, nil
+}
+
+// Verify decrypts every file and checks that it sits under its name's hash. Files
+// that fail are reported as anomalies, unless none can be read: then the first
+// failure is returned (typically ErrWrongKey).
+func (s *DirStore) Verify() (Report, error) {
+	report := Report{Path: s.dir}
+	nameKey, dataKey, err := s.keys()
+	if err != nil {
+		return report, err
+	}
+	paths, err := s.entryFiles()
+	if err != nil {
+		return report, err
+	}
+
+	var firstErr error
+	for _, path := range paths {
+		name, err := readEntryName(path, dataKey)
+		if err == nil {
+			want, id := s.entryPath(nameKey, name)
+			if want != path {
+				err = fmt.Errorf("%w: %s holds %q but is not named after it", ErrCorrupt, path, name)
+			} else {
+				var data []byte
+				data, err = s.decodeEntry(path, id, name, dataKey)
+				securemem.Wipe(data)
+			}
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			report.Anomalies = append(report.Anomalies, err.Error())
+			continue
+		}
+		report.Names = append(report.Names, name)
+	}
+	if firstErr != nil && len(report.Names) == 0 {
+		return report, firstErr
+	}
+	sort.Strings(report.Names)
+	return report, nil
+}
@@ -0,0 +1,138 @@
+package filestore
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestDirStore(t *testing.T) *DirStore {
+	t.Helper()
+	return NewDir(filepath.Join(t.TempDir(), "creds"), staticKey)
+}
+
+func TestDirStoreReadWriteDelete(t *testing.T) {
+	s := newTestDirStore(t)
+
+	if _, err := s.Read("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Read of missing credential error = %v, want ErrNotFound", err)
+	}
+	if names, err := s.List(); err != nil || len(names) != 0 {
+		t.Errorf("List of missing directory = %v, %v", names, err)
+	}
+
+	for name, value := range map[string]string{"b": "2", "a": "1", "ssh/router": "secret"} {
+		if err := s.Write(name, []byte(value)); err != nil {
+			t.Fatalf("Write(%q) failed: %v", name, err)
+		}
+	}
+	if err := s.Write("a", []byte("updated")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if got, err := s.Read("a"); err != nil || string(got) != "updated" {
+		t.Errorf("Read = %q, %v", got, err)
+	}
+	names, err := s.List()
+	if err != nil || strings.Join(names, ",") != "a,b,ssh/router" {
+		t.Errorf("List = %v, %v", names, err)
+	}
+
+	// One file per credential, none revealing its name
+	entries, _ := os.ReadDir(s.Path())
+	if len(entries) != 3 {
+		t.Errorf("directory has %d files, want 3", len(entries))
+	}
+	for _, e := range entries {
+		data, _ := os.ReadFile(filepath.Join(s.Path(), e.Name()))
+		if strings.Contains(e.Name(), "router") || bytes.Contains(data, []byte("router")) {
+			t.Errorf("%s reveals a credential name", e.Name())
+		}
+	}
+
+	if err := s.Delete("b"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := s.Delete("b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete error = %v, want ErrNotFound", err)
+	}
+
+	if err := s.DeleteDB(); err != nil {
+		t.Fatalf("DeleteDB failed: %v", err)
+	}
+	if _, err := os.Stat(s.Path()); !os.IsNotExist(err) {
+		t.Errorf("directory still exists after DeleteDB: %v", err)
+	}
+}
+
+func TestDirStoreWrongKeyAndTampering(t *testing.T) {
+	s := newTestDirStore(t)
+	if err := s.Write("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Write("b", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+
+	other := NewDir(s.Path(), keyFunc(otherKey))
+	if _, err := other.List(); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("List with another key error = %v, want ErrWrongKey", err)
+	}
+	if _, err := other.Verify(); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Verify with another key error = %v, want ErrWrongKey", err)
+	}
+
+	// Moving one entry over another is detected
+	nameKey, _, _ := s.keys()
+	pathA, _ := s.entryPath(nameKey, "a")
+	pathB, _ := s.entryPath(nameKey, "b")
+	if err := os.Rename(pathA, pathB); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Read("b"); err == nil {
+		t.Error("Read of an entry moved under another name should fail")
+	}
+	report, err := s.Verify()
+	if err == nil && len(report.Anomalies) == 0 {
+		t.Errorf("Verify = %+v, want an anomaly for the moved entry", report)
+	}
+}
+
+func TestDirStoreReadOnly(t *testing.T) {
+	s := newTestDirStore(t)
+	if err := s.Write("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	ro := NewDirReadOnly(s.Path(), staticKey)
+	if got, err := ro.Read("a"); err != nil || string(got) != "1" {
+		t.Errorf("Read = %q, %v", got, err)
+	}
+	if err := ro.Write("b", nil); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Write error = %v, want ErrReadOnly", err)
+	}
+	if err := ro.Delete("a"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Delete error = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestDirStoreCiphers(t *testing.T) {
+	s := newTestDirStore(t)
+	s.SetCipher(CipherXChaCha20Poly1305)
+	if err := s.Write("a", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	def := NewDir(s.Path(), staticKey)
+	if err := def.Write("b", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if _, err := def.Read(name); err != nil {
+			t.Errorf("Read(%q) failed: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,684 @@
+// Package filestore implements credmgr's encrypted credential file.
+// It is compiled on every platform: it is the default backend on Linux and is used
+// on Windows and macOS whenever credmgr.New is given an explicit path.
+//
+// # File Format
+//
+// Credentials are stored as a JSON map of name to raw bytes, encrypted with AES-256-GCM
+// or XChaCha20-Poly1305 (see SetCipher):
+//   - Header: magic, format version and cipher, authenticated with the ciphertext;
+//     the cipher is taken from the header on read, and headerless files from older
+//     versions are read as AES-256-GCM
+//   - Envelope encryption: each save uses a new random data key, wrapped with every
+//     master key allowed to open the file (see AddKey, RemoveKey and ReKey)
+//   - Compression: optional gzip of the plaintext before encryption (see SetCompression)
+//   - Versions: files in an older format are migrated when loaded; a newer format
+//     is reported as ErrCorrupt instead of being overwritten
+//   - Permissions: owner only (0600, or a protected DACL on Windows)
+//   - Writes: temp file + fsync + rename, so a crash never leaves a partial file
+//   - Backup: the previous version is kept in <path>.bak and used
+//     automatically if the main file fails to decrypt
+//
+// # Concurrency
+//
+// Several processes (e.g. the credmgr CLI and netcrawl) may share one file.
+// Every read-modify-write holds an exclusive lock on a sibling ".lock" file and
+// re-reads the file under that lock before applying the change, so concurrent
+// writers never drop each other's updates. Loads take a shared lock.
+//
+// # Memory
+//
+// The master key and the decrypted cache live in securemem buffers (locked into
+// RAM, excluded from core dumps, wiped on release). Intermediate plaintext such as
+// the decrypted JSON is wiped as soon as it has been parsed, and Read returns a
+// copy the caller owns.
+//
+// The cache is keyed on the file's modification time and size: every access
+// stats the file and reloads it if another process has replaced it, so readers
+// never serve stale credentials. Reload forces a refresh.
+package filestore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/nzions/fdot/pkg/fdh"
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// ErrNotFound is returned when a credential is not found.
+// credmgr.ErrNotFound is this same value.
+var ErrNotFound = errors.New("credential not found")
+
+// ErrReadOnly is returned by mutating operations on a read-only Store.
+// credmgr.ErrReadOnly is this same value.
+var ErrReadOnly = errors.New("credential store is read-only")
+
+// ErrWrongKey is returned when the file fails authentication with the configured key.
+// AES-GCM cannot tell a wrong key from a tampered file, so a file that is intact in
+// size but does not authenticate (and has no readable backup) reports ErrWrongKey.
+// credmgr.ErrWrongKey is this same value.
+var ErrWrongKey = errors.New("wrong encryption key")
+
+// ErrCorrupt is returned when the file is truncated or decrypts to invalid content.
+// credmgr.ErrCorrupt is this same value.
+var ErrCorrupt = errors.New("credentials file is corrupt")
+
+// KeyFunc returns the 32-byte master key; it is called at most once per Store.
+// The Store takes ownership of the returned slice: it is copied into locked
+// memory and then wiped.
+type KeyFunc func() ([]byte, error)
+
+// Store is an encrypted credential file with an in-memory cache.
+// Delete is a hard delete and List returns every stored name; credmgr layers
+// trash handling and the rest of its API on top.
+type Store struct {
+	path     string
+	readOnly bool
+	cipher   Cipher
+
+	// compress is the compression setting when compressSet; otherwise a file keeps its own
+	compress    bool
+	compressSet bool
+
+	keyFunc KeyFunc
+	key     *securemem.Buffer
+	keyOnce sync.Once
+	keyErr  error
+
+	// In-memory cache of decrypted credentials and the file version it came from.
+	// Values are slices of arena.
+	mu     sync.RWMutex
+	cache  map[string][]byte
+	arena  *securemem.Buffer
+	stamp  fileStamp
+	loaded bool
+}
+
+// fileStamp identifies a version of the credentials file; missing files have the zero stamp
+type fileStamp struct {
+	modTime int64
+	size    int64
+}
+
+// currentStamp stats the credentials file
+func (s *Store) currentStamp() fileStamp {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime().UnixNano(), size: info.Size()}
+}
+
+// New returns a Store for the encrypted file at path. The file is created on first write.
+func New(path string, key KeyFunc) *Store {
+	return &Store{
+		path:    path,
+		keyFunc: key,
+		cache:   make(map[string][]byte),
+	}
+}
+
+// NewReadOnly returns a Store that only reads the file at path. Mutations return
+// ErrReadOnly, and no file, lock file or directory is ever created.
+func NewReadOnly(path string, key KeyFunc) *Store {
+	s := New(path, key)
+	s.readOnly = true
+	return s
+}
+
+// SetCipher selects the cipher used when the file is next written. Without it the
+// file keeps its current cipher (AES-256-GCM for new files). Reads always use the
+// cipher recorded in the file, so a store can be switched at any time and is
+// converted on its next write.
+func (s *Store) SetCipher(c Cipher) {
+	s.cipher = c
+}
+
+// SetCompression turns gzip compression of the plaintext on or off for subsequent
+// writes. Without it the file keeps its current setting (off for new files).
+// Compression only applies when it makes the data smaller, and reads decompress
+// automatically. It helps stores holding large text secrets such as kubeconfigs
+// or certificates; note that the compressor's working memory cannot be wiped.
+func (s *Store) SetCompression(on bool) {
+	s.compress = on
+	s.compressSet = true
+}
+
+// Path returns the location of the encrypted file
+func (s *Store) Path() string {
+	return s.path
+}
+
+// String returns the file path (used in log and error output)
+func (s *Store) String() string {
+	return s.path
+}
+
+// getKey loads the master key once into locked memory, wiping the copy returned by keyFunc
+func (s *Store) getKey() ([]byte, error) {
+	s.keyOnce.Do(func() {
+		key, err := s.keyFunc()
+		if err != nil {
+			s.keyErr = err
+			return
+		}
+		s.key = securemem.FromBytes(key)
+	})
+	return s.key.Bytes(), s.keyErr
+}
+
+// setCache moves creds into a new locked arena, wiping the values in creds and
+// releasing the previous arena; the caller holds s.mu
+func (s *Store) setCache(creds map[string][]byte) {
+	size := 0
+	for _, data := range creds {
+		size += len(data)
+	}
+
+	arena := securemem.New(size)
+	cache := make(map[string][]byte, len(creds))
+	buf := arena.Bytes()
+	for name, data := range creds {
+		n := copy(buf, data)
+		cache[name] = buf[:n:n]
+		buf = buf[n:]
+		securemem.Wipe(data)
+	}
+
+	s.arena.Destroy()
+	s.arena = arena
+	s.cache = cache
+}
+
+// backupPath is where the previous version of the file is kept
+func (s *Store) backupPath() string {
+	return s.path + ".bak"
+}
+
+// lockFile takes an advisory lock on the sibling lock file and returns a
+// function that releases it
+func (s *Store) lockFile(exclusive bool) (func(), error) {
+	if s.readOnly {
+		return s.lockFileReadOnly(exclusive)
+	}
+
+	if err := fdh.CreatePrivateDir(filepath.Dir(s.path)); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(s.path+".lock", os.O_CREATE|os.O_RDWR, fdh.PrivateFileMode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := lockFile(f, exclusive); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock credentials file: %w", err)
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// lockFileReadOnly takes a shared lock without creating the lock file. If no
+// writer has ever created it there is nothing to serialize against.
+func (s *Store) lockFileReadOnly(exclusive bool) (func(), error) {
+	if exclusive {
+		return nil, ErrReadOnly
+	}
+
+	f, err := os.Open(s.path + ".lock")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return func() {}, nil
+		}
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := lockFile(f, false); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock credentials file: %w", err)
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// load reads and decrypts the file under a shared lock, returning the
+// version of the file that was read
+func (s *Store) load() (map[string][]byte, fileStamp, error) {
+	// If file doesn't exist, return empty map
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		return make(map[string][]byte), fileStamp{}, nil
+	}
+
+	unlock, err := s.lockFile(false)
+	if err != nil {
+		return nil, fileStamp{}, err
+	}
+	defer unlock()
+
+	stamp := s.currentStamp()
+	creds, err := s.read()
+	return creds, stamp, err
+}
+
+// read reads and decrypts the file; the caller holds the lock.
+// If the file cannot be decrypted (e.g. a torn write from an older version) the
+// previous version in the ".bak" file is used instead.
+func (s *Store) read() (map[string][]byte, error) {
+	// If file doesn't exist, return empty map
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		return make(map[string][]byte), nil
+	}
+
+	key, err := s.getKey()
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := decodeFile(s.path, key)
+	if err == nil {
+		return creds, nil
+	}
+	// Falling back to the older backup of a file written by a newer version would
+	// let the next write discard the newer file
+	if format, _ := s.fileFormat(); format > formatCurrent {
+		return nil, err
+	}
+
+	if backup, bakErr := decodeFile(s.backupPath(), key); bakErr == nil {
+		return backup, nil
+	}
+	return nil, err
+}
+
+// decodeFile reads, decrypts and unmarshals one credentials file
+func decodeFile(path string, key []byte) (map[string][]byte, error) {
+	// Read encrypted file
+	encrypted, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	return decodeData(path, encrypted, key)
+}
+
+// decodeData decrypts and unmarshals the contents of a credentials file; path names
+// it in errors
+func decodeData(path string, encrypted, key []byte) (map[string][]byte, error) {
+	// Decrypt with the cipher named in the header
+	plaintext, err := openFile(encrypted, key)
+	switch {
+	case errors.Is(err, errTruncated):
+		// Anything shorter than header + nonce + tag was never a complete file
+		return nil, fmt.Errorf("%w: %s is truncated (%d bytes); restore it from a backup or an export", ErrCorrupt, path, len(encrypted))
+	case errors.Is(err, errUnsupportedFormat):
+		return nil, fmt.Errorf("%w: %s uses file format %d, which this version cannot read; upgrade credmgr", ErrCorrupt, path, formatOf(encrypted))
+	case errors.Is(err, errUnsupportedCipher):
+		_, c, _ := parseHeader(encrypted)
+		return nil, fmt.Errorf("%w: %s is encrypted with %v, which this version cannot read; upgrade credmgr", ErrCorrupt, path, c)
+	case err != nil:
+		return nil, fmt.Errorf("failed to decrypt credentials: %w: %s was encrypted with a different key; use the original key or move the file aside", ErrWrongKey, path)
+	}
+	defer securemem.Wipe(plaintext)
+
+	// Unmarshal JSON
+	var creds map[string][]byte
+	if err := json.Unmarshal(plaintext, &creds); err != nil {
+		return nil, fmt.Errorf("%w: failed to unmarshal %s: %v", ErrCorrupt, path, err)
+	}
+	if creds == nil {
+		creds = make(map[string][]byte)
+	}
+
+	return creds, nil
+}
+
+// readCurrent returns the contents of the file, or nil if it does not exist
+func (s *Store) readCurrent() ([]byte, error) {
+	current, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	return current, nil
+}
+
+// backup writes current, the file about to be replaced, to the ".bak" file. A file
+// that no longer decrypts is not backed up, so a good backup is never replaced by a
+// corrupt one.
+func (s *Store) backup(current, key []byte) error {
+	if current == nil {
+		return nil
+	}
+	plaintext, err := openFile(current, key)
+	if err != nil {
+		return nil
+	}
+	securemem.Wipe(plaintext)
+	if err := fdh.WritePrivateFileAtomic(s.backupPath(), current); err != nil {
+		return fmt.Errorf("failed to write credentials backup: %w", err)
+	}
+	return nil
+}
+
+// save encrypts and writes the file; the caller holds the lock
+func (s *Store) save(creds map[string][]byte) error {
+	// Ensure directory exists
+	if err := fdh.CreatePrivateDir(filepath.Dir(s.path)); err != nil {
+		return err
+	}
+
+	// Marshal to JSON
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+	defer securemem.Wipe(plaintext)
+
+	// Get encryption key
+	key, err := s.getKey()
+	if err != nil {
+		return err
+	}
+
+	// Encrypt
+	current, err := s.readCurrent()
+	if err != nil {
+		return err
+	}
+	c, compress := s.sealOptions(current)
+	encrypted, err := sealFile(c, plaintext, key, current, compress)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt credentials: %w", err)
+	}
+
+	// Keep the previous version for recovery
+	if err := s.backup(current, key); err != nil {
+		return err
+	}
+
+	// Replace the file atomically (temp file, fsync, rename) with secure permissions
+	if err := fdh.WritePrivateFileAtomic(s.path, encrypted); err != nil {
+		return fmt.Errorf("failed to write credentials file: %w", err)
+	}
+
+	return nil
+}
+
+// sealOptions returns the cipher and compression for the next save: the Store's
+// settings, or else those of current, the file being replaced
+func (s *Store) sealOptions(current []byte) (Cipher, bool) {
+	c := s.cipher
+	if c == 0 {
+		c = CipherAES256GCM
+		if _, fileCipher, ok := parseHeader(current); ok {
+			c = fileCipher
+		}
+	}
+	compress := s.compress
+	if !s.compressSet {
+		if e, err := parseEnvelope(current); err == nil {
+			compress = e.flags&flagGzip != 0
+		}
+	}
+	return c, compress
+}
+
+// getCache makes sure the in-memory cache reflects the file on disk,
+// loading it on first use and reloading it if the file has changed since
+func (s *Store) getCache() error {
+	stamp := s.currentStamp()
+
+	s.mu.RLock()
+	fresh := s.loaded && s.stamp == stamp
+	s.mu.RUnlock()
+
+	if fresh {
+		return nil
+	}
+	return s.Reload()
+}
+
+// Reload discards the in-memory cache and re-reads the file from disk.
+// A file in an older format is then migrated to the current one (see migrate).
+func (s *Store) Reload() error {
+	creds, stamp, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.setCache(creds)
+	s.stamp = stamp
+	s.loaded = true
+	s.mu.Unlock()
+
+	s.migrate()
+	return nil
+}
+
+// migrate rewrites a file in an older format in the current one. The previous
+// version is kept in the ".bak" file as on any write. Read-only Stores never
+// migrate, and failures are ignored: the file stays readable as it is and the
+// migration is retried on the next load.
+func (s *Store) migrate() {
+	if s.readOnly || !s.outdated() {
+		return
+	}
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.outdated() {
+		return // migrated by another process meanwhile
+	}
+	key, err := s.getKey()
+	if err != nil {
+		return
+	}
+	// Only a main file that reads on its own, never one recovered from the backup
+	creds, err := decodeFile(s.path, key)
+	if err != nil {
+		return
+	}
+	if err := s.save(creds); err != nil {
+		wipeCreds(creds)
+		return
+	}
+
+	s.setCache(creds)
+	s.stamp = s.currentStamp()
+	s.loaded = true
+}
+
+// outdated reports whether the file exists in a format older than the current one
+func (s *Store) outdated() bool {
+	format, ok := s.fileFormat()
+	return ok && format < formatCurrent
+}
+
+// fileFormat returns the format version in the file header; ok is false if the file
+// cannot be opened
+func (s *Store) fileFormat() (format int, ok bool) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	header := make([]byte, headerSize)
+	n, _ := io.ReadFull(f, header)
+	return formatOf(header[:n]), true
+}
+
+// update applies fn to the latest on-disk credentials while holding an
+// exclusive lock, saves the result and refreshes the in-memory cache.
+// Re-reading under the lock merges changes made by other processes since
+// this process last loaded the file.
+func (s *Store) update(fn func(creds map[string][]byte) error) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if err := s.getCache(); err != nil {
+		return err
+	}
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	creds, err := s.read()
+	if err != nil {
+		return err
+	}
+	if err := fn(creds); err != nil {
+		return err
+	}
+	if err := s.save(creds); err != nil {
+		return err
+	}
+
+	s.setCache(creds)
+	s.stamp = s.currentStamp()
+	s.loaded = true
+	return nil
+}
+
+// Update applies fn to the credentials and saves the result in one load, encrypt and
+// save cycle, so a batch of changes rewrites the file once. Nothing is saved if fn
+// returns an error.
+func (s *Store) Update(fn func(creds map[string][]byte) error) error {
+	return s.update(fn)
+}
+
+// Read retrieves raw credential bytes by name.
+func (s *Store) Read(name string) ([]byte, error) {
+	if err := s.getCache(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, exists := s.cache[name]
+	if !exists {
+		return nil, fmt.Errorf("credential %q %w", name, ErrNotFound)
+	}
+
+	// The cache arena is released on the next reload, so the caller gets its own copy
+	return bytes.Clone(data), nil
+}
+
+// ReadInto copies the credential straight from the locked cache into buf, without
+// an intermediate copy. It returns the credential length; if buf is too small
+// nothing is copied and the error wraps io.ErrShortBuffer.
+func (s *Store) ReadInto(name string, buf []byte) (int, error) {
+	if err := s.getCache(); err != nil {
+		return 0, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, exists := s.cache[name]
+	if !exists {
+		return 0, fmt.Errorf("credential %q %w", name, ErrNotFound)
+	}
+	if len(buf) < len(data) {
+		return len(data), fmt.Errorf("credential %q needs %d bytes: %w", name, len(data), io.ErrShortBuffer)
+	}
+	return copy(buf, data), nil
+}
+
+// Write stores raw credential bytes with the given name.
+func (s *Store) Write(name string, data []byte) error {
+	return s.update(func(creds map[string][]byte) error {
+		// Cloned so wiping the map after the update never touches the caller's buffer
+		creds[name] = bytes.Clone(data)
+		return nil
+	})
+}
+
+// Delete removes a credential from the file permanently.
+func (s *Store) Delete(name string) error {
+	return s.update(func(creds map[string][]byte) error {
+		if _, exists := creds[name]; !exists {
+			return fmt.Errorf("credential %q %w", name, ErrNotFound)
+		}
+		delete(creds, name)
+		return nil
+	})
+}
+
+// DeleteDB removes the encrypted file and its backup.
+func (s *Store) DeleteDB() error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Clear the in-memory cache first
+	s.mu.Lock()
+	s.setCache(nil)
+	s.stamp = fileStamp{}
+	s.loaded = true
+	s.mu.Unlock()
+
+	// Remove the encrypted file and its backup if they exist
+	for _, path := range []string{s.path, s.backupPath()} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete credentials database: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// List returns every stored name, sorted.
+func (s *Store) List() ([]string, error) {
+	if err := s.getCache(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.cache))
+	for name := range s.cache {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
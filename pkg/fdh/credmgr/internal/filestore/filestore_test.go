@@ -0,0 +1,481 @@
+package filestore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var testKey = bytes.Repeat([]byte{0x42}, 32)
+
+// staticKey returns a copy of testKey, since Stores wipe the key they are given
+func staticKey() ([]byte, error) {
+	return bytes.Clone(testKey), nil
+}
+
+// newTestStore creates a Store in its own temp directory
+func newTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	dir := t.TempDir()
+	return New(filepath.Join(dir, "credentials.enc"), staticKey), dir
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	plaintext := []byte("sk-proj-123")
+
+	ciphertext, err := Encrypt(plaintext, testKey)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if bytes.Contains(ciphertext, plaintext) {
+		t.Error("ciphertext contains plaintext")
+	}
+
+	again, _ := Encrypt(plaintext, testKey)
+	if bytes.Equal(ciphertext, again) {
+		t.Error("two encryptions produced identical output (nonce reuse)")
+	}
+
+	got, err := Decrypt(ciphertext, testKey)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypt = %q, want %q", got, plaintext)
+	}
+}
+
+func TestDecryptRejectsTamperingAndWrongKey(t *testing.T) {
+	ciphertext, err := Encrypt([]byte("secret"), testKey)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	tampered := bytes.Clone(ciphertext)
+	tampered[len(tampered)-1] ^= 0x01
+	if _, err := Decrypt(tampered, testKey); err == nil {
+		t.Error("Decrypt of tampered ciphertext should fail")
+	}
+
+	if _, err := Decrypt(ciphertext, bytes.Repeat([]byte{0x24}, 32)); err == nil {
+		t.Error("Decrypt with wrong key should fail")
+	}
+
+	if _, err := Decrypt([]byte("short"), testKey); err == nil {
+		t.Error("Decrypt of short input should fail")
+	}
+}
+
+func TestStoreReadWriteDelete(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	if err := s.Write("a", []byte("1")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// A second Store on the same file sees the data
+	reopened := New(s.Path(), staticKey)
+	got, err := reopened.Read("a")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(got) != "1" {
+		t.Errorf("Read = %q, want %q", got, "1")
+	}
+
+	if err := reopened.Delete("a"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := reopened.Read("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Read after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := reopened.Delete("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreKeyError(t *testing.T) {
+	keyErr := errors.New("no key")
+	s := New(filepath.Join(t.TempDir(), "credentials.enc"), func() ([]byte, error) {
+		return nil, keyErr
+	})
+
+	if err := s.Write("a", []byte("1")); !errors.Is(err, keyErr) {
+		t.Errorf("Write error = %v, want key error", err)
+	}
+}
+
+func TestConcurrentWritersSharedFile(t *testing.T) {
+	// Separate Stores on the same file stand in for separate processes
+	credPath := filepath.Join(t.TempDir(), "credentials.enc")
+	const writers, perWriter = 4, 10
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		s := New(credPath, staticKey)
+		wg.Add(1)
+		go func(w int, s *Store) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if err := s.Write(fmt.Sprintf("w%d-%d", w, i), []byte("v")); err != nil {
+					t.Errorf("Write failed: %v", err)
+				}
+			}
+		}(w, s)
+	}
+	wg.Wait()
+
+	names, err := New(credPath, staticKey).List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(names) != writers*perWriter {
+		t.Errorf("List returned %d credentials, want %d (lost updates)", len(names), writers*perWriter)
+	}
+}
+
+func TestBackupRecovery(t *testing.T) {
+	s, dir := newTestStore(t)
+	credPath := s.Path()
+
+	if err := s.Write("first", []byte("1")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := s.Write("second", []byte("2")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Only the credential file, its backup and the lock file remain; temp files are renamed away
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		switch e.Name() {
+		case "credentials.enc", "credentials.enc.bak", "credentials.enc.lock":
+		default:
+			t.Errorf("unexpected file left behind: %s", e.Name())
+		}
+	}
+
+	// Simulate a torn write of the main file
+	if err := os.WriteFile(credPath, []byte("garbage"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	recovered := New(credPath, staticKey)
+	got, err := recovered.Read("first")
+	if err != nil {
+		t.Fatalf("Read after corruption should recover from backup: %v", err)
+	}
+	if string(got) != "1" {
+		t.Errorf("Read(first) = %q, want %q", got, "1")
+	}
+
+	if err := recovered.DeleteDB(); err != nil {
+		t.Fatalf("DeleteDB failed: %v", err)
+	}
+	if _, err := os.Stat(credPath + ".bak"); !os.IsNotExist(err) {
+		t.Error("DeleteDB should remove the backup file")
+	}
+}
+
+func TestWrongKeyAndCorruptErrors(t *testing.T) {
+	s, _ := newTestStore(t)
+	if err := s.Write("a", []byte("1")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	otherKey := func() ([]byte, error) { return bytes.Repeat([]byte{0x24}, 32), nil }
+	_, err := New(s.Path(), otherKey).Read("a")
+	if !errors.Is(err, ErrWrongKey) || errors.Is(err, ErrCorrupt) {
+		t.Errorf("Read with another key error = %v, want ErrWrongKey", err)
+	}
+
+	// Truncate without a backup to fall back on
+	if err := os.Remove(s.backupPath()); err != nil && !os.IsNotExist(err) {
+		t.Fatalf("Remove backup failed: %v", err)
+	}
+	if err := os.WriteFile(s.Path(), []byte("short"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	_, err = New(s.Path(), staticKey).Read("a")
+	if !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Read of truncated file error = %v, want ErrCorrupt", err)
+	}
+
+	// Valid ciphertext that is not a credential map
+	encrypted, err := Encrypt([]byte("not json"), testKey)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if err := os.WriteFile(s.Path(), encrypted, 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	_, err = New(s.Path(), staticKey).Read("a")
+	if !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Read of undecodable file error = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestCipherSelectionAndDetection(t *testing.T) {
+	for _, c := range []Cipher{CipherAES256GCM, CipherXChaCha20Poly1305} {
+		t.Run(c.String(), func(t *testing.T) {
+			s, _ := newTestStore(t)
+			s.SetCipher(c)
+			if err := s.Write("a", []byte("1")); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+
+			data, err := os.ReadFile(s.Path())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, got, ok := parseHeader(data); !ok || got != c {
+				t.Fatalf("header cipher = %v, %v; want %v", got, ok, c)
+			}
+
+			// A store configured with the default cipher detects it from the header
+			// and keeps it on write
+			def := New(s.Path(), staticKey)
+			got, err := def.Read("a")
+			if err != nil || string(got) != "1" {
+				t.Errorf("Read = %q, %v; want 1", got, err)
+			}
+			if err := def.Write("b", []byte("2")); err != nil {
+				t.Fatalf("Write failed: %v", err)
+			}
+			if _, got, _ := parseHeader(mustRead(t, s.Path())); got != c {
+				t.Errorf("cipher after default write = %v, want %v", got, c)
+			}
+
+			// Changing a header byte breaks authentication
+			tampered := bytes.Clone(data)
+			tampered[5] = byte(CipherAES256GCM + CipherXChaCha20Poly1305 - c)
+			if _, err := openFile(tampered, testKey); err == nil {
+				t.Error("file with a rewritten cipher byte should not decrypt")
+			}
+		})
+	}
+
+	if c, err := ParseCipher("XChaCha20-Poly1305"); err != nil || c != CipherXChaCha20Poly1305 {
+		t.Errorf("ParseCipher = %v, %v", c, err)
+	}
+	if _, err := ParseCipher("rot13"); err == nil {
+		t.Error("ParseCipher of an unknown name should fail")
+	}
+}
+
+func TestMigrateOlderFormats(t *testing.T) {
+	legacy, err := Encrypt([]byte(`{"a":"MQ=="}`), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := append([]byte("FDCM"), formatV1, byte(CipherXChaCha20Poly1305))
+	sealed, err := seal(CipherXChaCha20Poly1305, testKey, []byte(`{"a":"MQ=="}`), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		old    []byte
+		cipher Cipher
+	}{
+		{"headerless", legacy, CipherAES256GCM},
+		{"v1", append(header, sealed...), CipherXChaCha20Poly1305},
+	}
+	for _, tt := range tests {
+		old := tt.old
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestStore(t)
+			if err := os.WriteFile(s.Path(), old, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			// A read-only Store leaves the file alone
+			if got, err := NewReadOnly(s.Path(), staticKey).Read("a"); err != nil || string(got) != "1" {
+				t.Fatalf("read-only Read = %q, %v; want 1", got, err)
+			}
+			if !bytes.Equal(mustRead(t, s.Path()), old) {
+				t.Fatal("read-only Store rewrote the file")
+			}
+
+			// Loading migrates it, keeping the cipher and the old file as the backup
+			if got, err := s.Read("a"); err != nil || string(got) != "1" {
+				t.Fatalf("Read = %q, %v; want 1", got, err)
+			}
+			version, c, _ := parseHeader(mustRead(t, s.Path()))
+			if version != formatCurrent || c != tt.cipher {
+				t.Errorf("migrated file has format %d, %v", version, c)
+			}
+			if !bytes.Equal(mustRead(t, s.backupPath()), old) {
+				t.Error("backup does not hold the pre-migration file")
+			}
+		})
+	}
+}
+
+func TestNewerFormatVersion(t *testing.T) {
+	s, _ := newTestStore(t)
+	data := append([]byte("FDCM"), formatCurrent+1, byte(CipherAES256GCM))
+	data = append(data, make([]byte, 64)...)
+	if err := os.WriteFile(s.Path(), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// An older backup must not be used: a write would then replace the newer file
+	if err := New(s.backupPath(), staticKey).Write("a", []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+	_, err := s.Read("a")
+	if !errors.Is(err, ErrCorrupt) || !strings.Contains(err.Error(), "upgrade credmgr") {
+		t.Errorf("Read error = %v, want ErrCorrupt for a newer format", err)
+	}
+	if err := s.Write("b", []byte("2")); err == nil {
+		t.Error("Write over a newer format should fail")
+	}
+	if !bytes.Equal(mustRead(t, s.Path()), data) {
+		t.Error("file in a newer format was rewritten")
+	}
+}
+
+func TestUnsupportedCipher(t *testing.T) {
+	s, _ := newTestStore(t)
+	data := append([]byte("FDCM"), formatV2, 0x7f)
+	data = append(data, make([]byte, 64)...)
+	if err := os.WriteFile(s.Path(), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := s.Read("a")
+	if !errors.Is(err, ErrCorrupt) || !strings.Contains(err.Error(), "cannot read") {
+		t.Errorf("Read error = %v, want ErrCorrupt for an unsupported cipher", err)
+	}
+}
+
+func TestVerifyReportsPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not enforced on Windows")
+	}
+	s, _ := newTestStore(t)
+	if err := s.Write("a", []byte("1")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	report, err := s.Verify()
+	if err != nil || len(report.Names) != 1 || len(report.Anomalies) != 0 {
+		t.Fatalf("Verify = %+v, %v; want one name and no anomalies", report, err)
+	}
+	if report.Format != formatCurrent || report.Cipher != CipherAES256GCM {
+		t.Errorf("Verify header = format %d, %v; want %d, %v", report.Format, report.Cipher, formatCurrent, CipherAES256GCM)
+	}
+
+	if err := os.Chmod(s.Path(), 0644); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+	if report, _ := s.Verify(); len(report.Anomalies) != 1 {
+		t.Errorf("Verify anomalies = %q, want the file mode reported", report.Anomalies)
+	}
+}
+
+func TestExternalChangeInvalidatesCache(t *testing.T) {
+	s, _ := newTestStore(t)
+	other := New(s.Path(), staticKey)
+
+	if err := s.Write("token", []byte("old")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if got, _ := other.Read("token"); string(got) != "old" {
+		t.Fatalf("Read = %q, want %q", got, "old")
+	}
+
+	// Another process replaces the file; the next read must not serve the cached value
+	if err := s.Write("token", []byte("new-value")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	got, err := other.Read("token")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(got) != "new-value" {
+		t.Errorf("Read after external change = %q, want %q", got, "new-value")
+	}
+
+	if err := s.DeleteDB(); err != nil {
+		t.Fatalf("DeleteDB failed: %v", err)
+	}
+	if err := other.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if _, err := other.Read("token"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Read after DeleteDB + Reload error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestReadReturnsCopy(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	data := []byte("secret")
+	if err := s.Write("a", data); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if string(data) != "secret" {
+		t.Errorf("Write modified the caller's buffer: %q", data)
+	}
+
+	got, err := s.Read("a")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	got[0] = 'X'
+
+	again, _ := s.Read("a")
+	if string(again) != "secret" {
+		t.Errorf("modifying a Read result changed the cache: %q", again)
+	}
+}
+
+func TestReadInto(t *testing.T) {
+	s, _ := newTestStore(t)
+	if err := s.Write("a", []byte("secret")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	small := make([]byte, 3)
+	n, err := s.ReadInto("a", small)
+	if !errors.Is(err, io.ErrShortBuffer) || n != 6 {
+		t.Fatalf("ReadInto(short) = %d, %v; want 6, io.ErrShortBuffer", n, err)
+	}
+	if !bytes.Equal(small, make([]byte, 3)) {
+		t.Errorf("short buffer was written: %q", small)
+	}
+
+	buf := make([]byte, 16)
+	n, err = s.ReadInto("a", buf)
+	if err != nil || string(buf[:n]) != "secret" {
+		t.Errorf("ReadInto = %q, %v; want \"secret\"", buf[:n], err)
+	}
+
+	if _, err := s.ReadInto("missing", buf); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReadInto(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestKeyIsWipedAfterLoad(t *testing.T) {
+	key := bytes.Clone(testKey)
+	s := New(filepath.Join(t.TempDir(), "credentials.enc"), func() ([]byte, error) {
+		return key, nil
+	})
+	if err := s.Write("a", []byte("1")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !bytes.Equal(key, make([]byte, len(key))) {
+		t.Error("key returned by KeyFunc was not wiped")
+	}
+	if got, err := s.Read("a"); err != nil || string(got) != "1" {
+		t.Errorf("Read = %q, %v; want %q, nil", got, err, "1")
+	}
+}
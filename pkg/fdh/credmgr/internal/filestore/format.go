@@ -0,0 +1,417 @@
+package filestore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// Cipher identifies the AEAD algorithm a credentials file is encrypted with
+type Cipher byte
+
+// Supported ciphers; the value is the algorithm byte recorded in the file header
+const (
+	// CipherAES256GCM is AES-256-GCM, the default (fastest on CPUs with AES-NI)
+	CipherAES256GCM Cipher = 1
+	// CipherXChaCha20Poly1305 is XChaCha20-Poly1305, constant-time in software
+	// and faster than AES on CPUs without AES instructions
+	CipherXChaCha20Poly1305 Cipher = 2
+)
+
+// String returns the name accepted by ParseCipher
+func (c Cipher) String() string {
+	switch c {
+	case CipherAES256GCM:
+		return "aes-256-gcm"
+	case CipherXChaCha20Poly1305:
+		return "xchacha20-poly1305"
+	default:
+		return fmt.Sprintf("cipher(%d)", byte(c))
+	}
+}
+
+// ParseCipher returns the cipher with the given name ("aes-256-gcm" or "xchacha20-poly1305")
+func ParseCipher(name string) (Cipher, error) {
+	for _, c := range []Cipher{CipherAES256GCM, CipherXChaCha20Poly1305} {
+		if strings.EqualFold(name, c.String()) {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown cipher %q (want aes-256-gcm or xchacha20-poly1305)", name)
+}
+
+// aead returns the AEAD for c keyed with key
+func (c Cipher) aead(key []byte) (cipher.AEAD, error) {
+	switch c {
+	case CipherAES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case CipherXChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	default:
+		return nil, fmt.Errorf("%w %v", errUnsupportedCipher, c)
+	}
+}
+
+// overhead is the nonce plus tag size of c, the length seal adds to its input
+func (c Cipher) overhead() (int, error) {
+	switch c {
+	case CipherAES256GCM:
+		return 12 + 16, nil
+	case CipherXChaCha20Poly1305:
+		return chacha20poly1305.NonceSizeX + chacha20poly1305.Overhead, nil
+	default:
+		return 0, fmt.Errorf("%w %v", errUnsupportedCipher, c)
+	}
+}
+
+// The file starts with a header:
+//
+//	magic "FDCM" | format version (1 byte) | cipher (1 byte) | flags (1 byte, format 3)
+//
+// Format 3 (current) and 2 use envelope encryption. The credentials are encrypted with
+// a random data key, and the data key is wrapped with each master key allowed to open
+// the file; a master key is found by its key ID:
+//
+//	header | slot count (1 byte) | slots | nonce | ciphertext + tag
+//	slot:  key ID (8 bytes) | cipher (1 byte) | nonce | wrapped data key + tag
+//
+// Format 3 adds the flags byte; flagGzip marks a gzip-compressed plaintext. Format 2
+// has no flags.
+//
+// Format 1 encrypts the credentials directly with the master key:
+//
+//	header | nonce | ciphertext + tag
+//
+// The header is the additional data of the ciphertext, and a slot is authenticated
+// with its own key ID and cipher, so slots can be added or removed without touching
+// the ciphertext. Files written before the header was introduced (format 0) are a
+// bare AES-256-GCM blob (nonce | ciphertext + tag).
+//
+// Every older format is still read, and a writable Store migrates a file to the
+// current format as soon as it loads it (see Store.Reload). A file in a format newer
+// than this version fails with ErrCorrupt rather than being mistaken for a wrong key.
+var fileMagic = []byte("FDCM")
+
+const (
+	formatV0      = 0 // headerless
+	formatV1      = 1
+	formatV2      = 2
+	formatV3      = 3
+	formatCurrent = formatV3
+	headerSize    = 6
+
+	keyIDSize   = 8
+	dataKeySize = 32
+)
+
+var (
+	// errTruncated is returned by openFile for input too short to be a complete file
+	errTruncated = errors.New("truncated")
+	// errUnsupportedCipher is returned for a header naming a cipher this version cannot read
+	errUnsupportedCipher = errors.New("unsupported")
+	// errUnsupportedFormat is returned for a header naming a newer format version
+	errUnsupportedFormat = errors.New("unsupported format")
+	// errNoKeySlot is returned when the master key is not one of the file's keys
+	errNoKeySlot = errors.New("key is not enrolled in the file")
+)
+
+// keyID derives the public identifier of a master key
+func keyID(key []byte) [keyIDSize]byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("credmgr key id"))
+	var id [keyIDSize]byte
+	copy(id[:], mac.Sum(nil))
+	return id
+}
+
+// KeyID returns the identifier recorded in the file for a master key, as hex.
+// It does not reveal the key.
+func KeyID(key []byte) string {
+	id := keyID(key)
+	return hex.EncodeToString(id[:])
+}
+
+// keySlot is a data key wrapped with one master key
+type keySlot struct {
+	id      [keyIDSize]byte
+	cipher  Cipher
+	wrapped []byte // nonce | data key ciphertext + tag
+}
+
+// aad is the additional data a slot is sealed with; it is the same in every format,
+// so slots carry over when a file is migrated
+func (k keySlot) aad() []byte {
+	return append(append(append(bytes.Clone(fileMagic), formatV2), k.id[:]...), byte(k.cipher))
+}
+
+// newKeySlot wraps dataKey with masterKey
+func newKeySlot(c Cipher, masterKey, dataKey []byte) (keySlot, error) {
+	slot := keySlot{id: keyID(masterKey), cipher: c}
+	wrapped, err := seal(c, masterKey, dataKey, slot.aad())
+	if err != nil {
+		return keySlot{}, err
+	}
+	slot.wrapped = wrapped
+	return slot, nil
+}
+
+// envelope is a parsed format 2 or 3 file
+type envelope struct {
+	version byte
+	cipher  Cipher
+	flags   byte
+	slots   []keySlot
+	body    []byte // nonce | ciphertext + tag
+}
+
+// header returns the file header, the additional data of the body
+func (e *envelope) header() []byte {
+	header := append(bytes.Clone(fileMagic), e.version, byte(e.cipher))
+	if e.version >= formatV3 {
+		header = append(header, e.flags)
+	}
+	return header
+}
+
+// slot returns the index of the slot for the master key id, or -1
+func (e *envelope) slot(id [keyIDSize]byte) int {
+	for i, s := range e.slots {
+		if s.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// dataKey unwraps the data key with masterKey; the caller wipes it
+func (e *envelope) dataKey(masterKey []byte) ([]byte, error) {
+	i := e.slot(keyID(masterKey))
+	if i < 0 {
+		return nil, errNoKeySlot
+	}
+	return open(e.slots[i].cipher, masterKey, e.slots[i].wrapped, e.slots[i].aad())
+}
+
+// open decrypts (and decompresses) the credentials with masterKey
+func (e *envelope) open(masterKey []byte) ([]byte, error) {
+	dataKey, err := e.dataKey(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	defer securemem.Wipe(dataKey)
+	plaintext, err := open(e.cipher, dataKey, e.body, e.header())
+	if err != nil || e.flags&flagGzip == 0 {
+		return plaintext, err
+	}
+	defer securemem.Wipe(plaintext)
+	return decompress(plaintext)
+}
+
+// marshal encodes the envelope as a file
+func (e *envelope) marshal() []byte {
+	out := append(e.header(), byte(len(e.slots)))
+	for _, s := range e.slots {
+		out = append(out, s.id[:]...)
+		out = append(out, byte(s.cipher))
+		out = append(out, s.wrapped...)
+	}
+	return append(out, e.body...)
+}
+
+// parseEnvelope decodes a format 2 or 3 file without decrypting it
+func parseEnvelope(data []byte) (*envelope, error) {
+	version, c, ok := parseHeader(data)
+	if !ok || version != formatV2 && version != formatV3 {
+		return nil, errors.New("not an envelope-encrypted file")
+	}
+	e := &envelope{version: version, cipher: c}
+	rest := data[headerSize:]
+	if version >= formatV3 {
+		if len(rest) == 0 {
+			return nil, errTruncated
+		}
+		e.flags, rest = rest[0], rest[1:]
+		if e.flags&^knownFlags != 0 {
+			return nil, fmt.Errorf("%w %d (flags %#x)", errUnsupportedFormat, version, e.flags)
+		}
+	}
+	if len(rest) == 0 {
+		return nil, errTruncated
+	}
+
+	slots := int(rest[0])
+	rest = rest[1:]
+	for range slots {
+		if len(rest) < keyIDSize+1 {
+			return nil, errTruncated
+		}
+		slot := keySlot{cipher: Cipher(rest[keyIDSize])}
+		copy(slot.id[:], rest)
+		overhead, err := slot.cipher.overhead()
+		if err != nil {
+			return nil, err
+		}
+		size := overhead + dataKeySize
+		rest = rest[keyIDSize+1:]
+		if len(rest) < size {
+			return nil, errTruncated
+		}
+		slot.wrapped, rest = rest[:size], rest[size:]
+		e.slots = append(e.slots, slot)
+	}
+
+	overhead, err := c.overhead()
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) < overhead {
+		return nil, errTruncated
+	}
+	e.body = rest
+	return e, nil
+}
+
+// seal encrypts plaintext with a random nonce, returning nonce | ciphertext + tag
+func seal(c Cipher, key, plaintext, aad []byte) ([]byte, error) {
+	aead, err := c.aead(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, out); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, out, plaintext, aad), nil
+}
+
+// open decrypts the output of seal
+func open(c Cipher, key, sealed, aad []byte) ([]byte, error) {
+	aead, err := c.aead(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return nil, errTruncated
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
+
+// sealFile encrypts plaintext into the current file format under a new random data
+// key wrapped with masterKey, gzipping it first if gzip is set and that makes it
+// smaller. If prev, the file being replaced, opens with masterKey and also holds
+// slots for other master keys (a key rotation in progress), its data key and slots
+// are kept so that every one of those keys still opens the new file. A data key
+// that is kept is known to every key that was ever enrolled while it was in use;
+// only a file with one slot, or ReKey, gets a fresh one.
+func sealFile(c Cipher, plaintext, masterKey, prev []byte, gzip bool) ([]byte, error) {
+	e := &envelope{version: formatCurrent, cipher: c}
+	if gzip {
+		compressed, err := compress(plaintext)
+		if err != nil {
+			return nil, err
+		}
+		if compressed != nil {
+			defer securemem.Wipe(compressed)
+			plaintext = compressed
+			e.flags |= flagGzip
+		}
+	}
+
+	var dataKey []byte
+	if p, err := parseEnvelope(prev); err == nil && len(p.slots) > 1 {
+		if k, err := p.dataKey(masterKey); err == nil {
+			dataKey, e.slots = k, p.slots
+		}
+	}
+	if dataKey == nil {
+		dataKey = make([]byte, dataKeySize)
+		if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+			return nil, err
+		}
+		slot, err := newKeySlot(c, masterKey, dataKey)
+		if err != nil {
+			return nil, err
+		}
+		e.slots = []keySlot{slot}
+	}
+	defer securemem.Wipe(dataKey)
+
+	body, err := seal(c, dataKey, plaintext, e.header())
+	if err != nil {
+		return nil, err
+	}
+	e.body = body
+	return e.marshal(), nil
+}
+
+// openFile decrypts a file in any supported format, including legacy headerless files.
+// A truncated file returns errTruncated, an unknown cipher errUnsupportedCipher and a
+// newer format errUnsupportedFormat; any other failure means the file does not
+// authenticate with key.
+func openFile(data, key []byte) ([]byte, error) {
+	version, c, ok := parseHeader(data)
+	if !ok {
+		if len(data) < minCiphertextSize {
+			return nil, errTruncated
+		}
+		return Decrypt(data, key)
+	}
+
+	var plaintext []byte
+	var err error
+	switch version {
+	case formatV1:
+		plaintext, err = open(c, key, data[headerSize:], data[:headerSize])
+	case formatV2, formatV3:
+		var e *envelope
+		if e, err = parseEnvelope(data); err == nil {
+			plaintext, err = e.open(key)
+		}
+	default:
+		err = fmt.Errorf("%w %d", errUnsupportedFormat, version)
+	}
+	if err == nil {
+		return plaintext, nil
+	}
+
+	// A legacy file whose random nonce happens to start with the magic
+	if legacy, legacyErr := Decrypt(data, key); legacyErr == nil {
+		return legacy, nil
+	}
+	return nil, err
+}
+
+// parseHeader returns the format version and cipher recorded in data's header, if it
+// has one. The version may be newer than formatCurrent.
+func parseHeader(data []byte) (version byte, c Cipher, ok bool) {
+	if len(data) < headerSize || !bytes.Equal(data[:len(fileMagic)], fileMagic) || data[4] == formatV0 {
+		return 0, 0, false
+	}
+	return data[4], Cipher(data[5]), true
+}
+
+// formatOf returns the format version of a file's contents
+func formatOf(data []byte) int {
+	version, _, ok := parseHeader(data)
+	if !ok {
+		return formatV0
+	}
+	return int(version)
+}
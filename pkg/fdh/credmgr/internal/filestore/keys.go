@@ -0,0 +1,236 @@
+package filestore
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/nzions/fdot/pkg/fdh"
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// maxKeySlots is the most master keys one file can hold (the slot count is one byte)
+const maxKeySlots = 255
+
+// KeyIDs returns the IDs of the master keys that can open the file (see KeyID).
+// Files in an older format, which are encrypted directly with the master key,
+// report none until they are next written.
+func (s *Store) KeyIDs() ([]string, error) {
+	unlock, err := s.lockFile(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	current, err := s.readCurrent()
+	if err != nil || current == nil {
+		return nil, err
+	}
+	e, err := parseEnvelope(current)
+	if err != nil {
+		return nil, nil
+	}
+	ids := make([]string, len(e.slots))
+	for i, slot := range e.slots {
+		ids[i] = hex.EncodeToString(slot.id[:])
+	}
+	return ids, nil
+}
+
+// AddKey allows newKey to open the file as well as the current master key, e.g.
+// while a new key is rolled out. Only the key slots are rewritten; the data key
+// and the encrypted credentials are unchanged, and later writes keep every slot and
+// the data key.
+func (s *Store) AddKey(newKey []byte) error {
+	if len(newKey) != dataKeySize {
+		return fmt.Errorf("master key must be %d bytes, got %d", dataKeySize, len(newKey))
+	}
+	return s.rewrap(func(e *envelope, dataKey []byte) error {
+		if e.slot(keyID(newKey)) >= 0 {
+			return nil
+		}
+		if len(e.slots) >= maxKeySlots {
+			return fmt.Errorf("%s already has %d keys", s.path, maxKeySlots)
+		}
+		slot, err := newKeySlot(e.cipher, newKey, dataKey)
+		if err != nil {
+			return err
+		}
+		e.slots = append(e.slots, slot)
+		return nil
+	})
+}
+
+// RemoveKey removes the slot of the master key with the given ID (see KeyIDs), so it
+// no longer opens the file or its backup. The key the Store is opened with cannot be
+// removed. While other keys stay enrolled the data key is kept, since only this
+// Store's key is at hand to wrap a new one: the removed key, with any copy of the
+// file taken while it was enrolled, still yields the data key that later writes are
+// sealed with. Use ReKey to revoke a key fully. Once this Store's key is the only one
+// left, the credentials are sealed again under a fresh data key straight away.
+func (s *Store) RemoveKey(id string) error {
+	var remaining int
+	err := s.rewrap(func(e *envelope, dataKey []byte) error {
+		key, err := s.getKey()
+		if err != nil {
+			return err
+		}
+		if id == KeyID(key) {
+			return errors.New("cannot remove the key the credentials file is opened with")
+		}
+		for i, slot := range e.slots {
+			if hex.EncodeToString(slot.id[:]) == id {
+				e.slots = append(e.slots[:i], e.slots[i+1:]...)
+				remaining = len(e.slots)
+				return nil
+			}
+		}
+		return fmt.Errorf("%s has no key with ID %s", s.path, id)
+	})
+	if err != nil || remaining > 1 {
+		return err
+	}
+
+	key, err := s.getKey()
+	if err != nil {
+		return err
+	}
+	return s.ReKey(key)
+}
+
+// ReKey makes newKey the only master key of the file. The credentials are sealed
+// again under a fresh data key, so neither the old master key nor the old data key
+// opens the result, and the backup is replaced by the same file. After ReKey this
+// Store can no longer open the file; open a new Store with newKey.
+func (s *Store) ReKey(newKey []byte) error {
+	if len(newKey) != dataKeySize {
+		return fmt.Errorf("master key must be %d bytes, got %d", dataKeySize, len(newKey))
+	}
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The file changes underneath the cache, which is reloaded on next use
+	defer func() { s.loaded = false }()
+
+	key, err := s.getKey()
+	if err != nil {
+		return err
+	}
+
+	current, err := s.readCurrent()
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return fmt.Errorf("credentials file %s does not exist", s.path)
+	}
+	// Only the file itself: re-keying an older backup would drop the latest writes
+	creds, err := decodeFile(s.path, key)
+	if err != nil {
+		return err
+	}
+	defer wipeCreds(creds)
+	plaintext, err := json.Marshal(creds)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+	defer securemem.Wipe(plaintext)
+
+	c, compress := s.sealOptions(current)
+	next, err := sealFile(c, plaintext, newKey, nil, compress)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt credentials: %w", err)
+	}
+	for _, path := range []string{s.backupPath(), s.path} {
+		if err := fdh.WritePrivateFileAtomic(path, next); err != nil {
+			return fmt.Errorf("failed to write credentials file: %w", err)
+		}
+	}
+	return nil
+}
+
+// rewrap applies fn to the key slots of the file under an exclusive lock and writes
+// the new slots, leaving the encrypted credentials untouched. A file in an older
+// format is first rewritten as an envelope.
+func (s *Store) rewrap(fn func(e *envelope, dataKey []byte) error) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The file changes underneath the cache, which is reloaded on next use
+	defer func() { s.loaded = false }()
+
+	key, err := s.getKey()
+	if err != nil {
+		return err
+	}
+
+	current, err := s.readCurrent()
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return fmt.Errorf("credentials file %s does not exist", s.path)
+	}
+	e, err := parseEnvelope(current)
+	if err != nil || e.slot(keyID(key)) < 0 {
+		if current, err = s.upgrade(); err != nil {
+			return err
+		}
+		if e, err = parseEnvelope(current); err != nil {
+			return err
+		}
+	}
+
+	dataKey, err := e.dataKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to unwrap data key: %w: %s", ErrWrongKey, s.path)
+	}
+	defer securemem.Wipe(dataKey)
+
+	if err := fn(e, dataKey); err != nil {
+		return err
+	}
+
+	// The credentials are unchanged, so the backup gets the new slots too and a
+	// revoked key cannot open it either
+	next := e.marshal()
+	for _, path := range []string{s.backupPath(), s.path} {
+		if err := fdh.WritePrivateFileAtomic(path, next); err != nil {
+			return fmt.Errorf("failed to write credentials file: %w", err)
+		}
+	}
+	return nil
+}
+
+// upgrade rewrites the file in the current format and returns it; the caller holds
+// the exclusive lock and s.mu
+func (s *Store) upgrade() ([]byte, error) {
+	creds, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	defer wipeCreds(creds)
+	if err := s.save(creds); err != nil {
+		return nil, err
+	}
+	return s.readCurrent()
+}
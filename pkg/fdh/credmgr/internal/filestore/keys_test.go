@@ -0,0 +1,223 @@
+package filestore
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+var otherKey = bytes.Repeat([]byte{0x24}, 32)
+
+func keyFunc(key []byte) KeyFunc {
+	return func() ([]byte, error) { return bytes.Clone(key), nil }
+}
+
+func TestDataKeyPerSave(t *testing.T) {
+	s, _ := newTestStore(t)
+	if err := s.Write("a", []byte("1")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	first, _ := os.ReadFile(s.Path())
+	if err := s.Write("a", []byte("1")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	second, _ := os.ReadFile(s.Path())
+
+	e1, err1 := parseEnvelope(first)
+	e2, err2 := parseEnvelope(second)
+	if err1 != nil || err2 != nil {
+		t.Fatalf("parseEnvelope = %v, %v", err1, err2)
+	}
+	if bytes.Equal(e1.slots[0].wrapped, e2.slots[0].wrapped) {
+		t.Error("two saves wrapped the same data key")
+	}
+	if ids, err := s.KeyIDs(); err != nil || len(ids) != 1 || ids[0] != KeyID(testKey) {
+		t.Errorf("KeyIDs = %v, %v; want [%s]", ids, err, KeyID(testKey))
+	}
+}
+
+func TestAddAndRemoveKey(t *testing.T) {
+	s, _ := newTestStore(t)
+	if err := s.Write("a", []byte("1")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	before, _ := parseEnvelope(mustRead(t, s.Path()))
+
+	if err := s.AddKey(otherKey); err != nil {
+		t.Fatalf("AddKey failed: %v", err)
+	}
+	after, _ := parseEnvelope(mustRead(t, s.Path()))
+	if !bytes.Equal(before.body, after.body) {
+		t.Error("AddKey re-encrypted the credentials")
+	}
+
+	// Both keys open the file, also after a write by either of them
+	other := New(s.Path(), keyFunc(otherKey))
+	if got, err := other.Read("a"); err != nil || string(got) != "1" {
+		t.Fatalf("Read with added key = %q, %v", got, err)
+	}
+	if err := other.Write("b", []byte("2")); err != nil {
+		t.Fatalf("Write with added key failed: %v", err)
+	}
+	if got, err := New(s.Path(), staticKey).Read("b"); err != nil || string(got) != "2" {
+		t.Errorf("Read with original key after rotation write = %q, %v", got, err)
+	}
+	if ids, _ := s.KeyIDs(); len(ids) != 2 {
+		t.Errorf("KeyIDs = %v, want two", ids)
+	}
+
+	if err := other.RemoveKey(KeyID(otherKey)); err == nil {
+		t.Error("removing the key the store is opened with should fail")
+	}
+	if err := other.RemoveKey(KeyID(testKey)); err != nil {
+		t.Fatalf("RemoveKey failed: %v", err)
+	}
+	for _, path := range []string{s.Path(), s.backupPath()} {
+		if _, err := decodeFile(path, testKey); !errors.Is(err, ErrWrongKey) {
+			t.Errorf("decode %s with removed key error = %v, want ErrWrongKey", path, err)
+		}
+	}
+	if got, err := other.Read("b"); err != nil || string(got) != "2" {
+		t.Errorf("Read after RemoveKey = %q, %v", got, err)
+	}
+}
+
+// TestRemoveKeyKeepsDataKey pins down what RemoveKey does not do: while other keys
+// stay enrolled, a removed key with a copy of the file from before the removal still
+// unwraps the data key of later writes. Removing the last other key replaces it.
+func TestRemoveKeyKeepsDataKey(t *testing.T) {
+	thirdKey := bytes.Repeat([]byte{0x57}, 32)
+	s, _ := newTestStore(t)
+	if err := s.Write("a", []byte("1")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	for _, key := range [][]byte{otherKey, thirdKey} {
+		if err := s.AddKey(key); err != nil {
+			t.Fatalf("AddKey failed: %v", err)
+		}
+	}
+	preRemoval, err := parseEnvelope(mustRead(t, s.Path()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leakedDataKey, err := preRemoval.dataKey(thirdKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RemoveKey(KeyID(thirdKey)); err != nil {
+		t.Fatalf("RemoveKey failed: %v", err)
+	}
+	if err := s.Write("b", []byte("written after removal")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	e, err := parseEnvelope(mustRead(t, s.Path()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.dataKey(thirdKey); err == nil {
+		t.Error("the removed key still has a slot")
+	}
+	plaintext, err := open(e.cipher, leakedDataKey, e.body, e.header())
+	if err != nil {
+		t.Fatalf("data key from before the removal no longer opens later writes: %v", err)
+	}
+	if !bytes.Contains(plaintext, []byte("b")) {
+		t.Errorf("decrypted post-removal write = %q, want it to hold b", plaintext)
+	}
+
+	// Down to the Store's own key: a fresh data key, also for the backup
+	if err := s.RemoveKey(KeyID(otherKey)); err != nil {
+		t.Fatalf("RemoveKey of the last other key failed: %v", err)
+	}
+	for _, path := range []string{s.Path(), s.backupPath()} {
+		e, err := parseEnvelope(mustRead(t, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := open(e.cipher, leakedDataKey, e.body, e.header()); err == nil {
+			t.Errorf("%s still opens with the data key shared with removed keys", path)
+		}
+	}
+	if got, err := New(s.Path(), staticKey).Read("b"); err != nil || string(got) != "written after removal" {
+		t.Errorf("Read after removing all other keys = %q, %v", got, err)
+	}
+}
+
+func TestReKey(t *testing.T) {
+	s, _ := newTestStore(t)
+	if err := s.Write("a", []byte("1")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := s.ReKey(otherKey); err != nil {
+		t.Fatalf("ReKey failed: %v", err)
+	}
+
+	if _, err := New(s.Path(), staticKey).Read("a"); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Read with old key error = %v, want ErrWrongKey", err)
+	}
+	if got, err := New(s.Path(), keyFunc(otherKey)).Read("a"); err != nil || string(got) != "1" {
+		t.Errorf("Read with new key = %q, %v", got, err)
+	}
+}
+
+func TestReKeyReplacesDataKey(t *testing.T) {
+	s, _ := newTestStore(t)
+	if err := s.Write("a", []byte("1")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	before, err := parseEnvelope(mustRead(t, s.Path()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldDataKey, err := before.dataKey(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ReKey(otherKey); err != nil {
+		t.Fatalf("ReKey failed: %v", err)
+	}
+	after := mustRead(t, s.Path())
+	e, err := parseEnvelope(after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := open(e.cipher, oldDataKey, e.body, e.header()); err == nil {
+		t.Error("the old data key still opens the re-keyed file")
+	}
+	if !bytes.Equal(mustRead(t, s.backupPath()), after) {
+		t.Error("backup was not replaced by the re-keyed file")
+	}
+}
+
+func TestReKeyUpgradesOlderFormats(t *testing.T) {
+	s, _ := newTestStore(t)
+	legacy, err := Encrypt([]byte(`{"a":"MQ=="}`), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(s.Path(), legacy, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := s.KeyIDs(); err != nil || len(ids) != 0 {
+		t.Errorf("KeyIDs of legacy file = %v, %v; want none", ids, err)
+	}
+
+	if err := s.ReKey(otherKey); err != nil {
+		t.Fatalf("ReKey failed: %v", err)
+	}
+	if got, err := New(s.Path(), keyFunc(otherKey)).Read("a"); err != nil || string(got) != "1" {
+		t.Errorf("Read with new key = %q, %v", got, err)
+	}
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,108 @@
+package filestore
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh"
+)
+
+// Snapshot returns the encrypted contents of the file, read under the shared lock so
+// it is never a version a writer is replacing. It does not need the master key.
+func (s *Store) Snapshot() ([]byte, error) {
+	unlock, err := s.lockFile(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	return data, nil
+}
+
+// Restore replaces the file with data, a Snapshot of a credentials file. data must
+// decrypt with this Store's master key, so a snapshot under another key is refused
+// with ErrWrongKey instead of locking the user out. The replaced file is kept in the
+// ".bak" file as on any write.
+func (s *Store) Restore(data []byte) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	key, err := s.getKey()
+	if err != nil {
+		return err
+	}
+	creds, err := decodeData("snapshot", data, key)
+	if err != nil {
+		return err
+	}
+	wipeCreds(creds)
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.readCurrent()
+	if err != nil {
+		return err
+	}
+	if err := s.backup(current, key); err != nil {
+		return err
+	}
+	if err := fdh.WritePrivateFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	// The next access reloads the restored file
+	s.loaded = false
+	return nil
+}
+
+// Replace writes data, an encrypted copy of this database taken elsewhere, in place of
+// the file without decrypting it, and gives it modTime. check runs under the exclusive
+// lock first, so the caller can refuse if the file changed since it decided to replace
+// it. The ".bak" file is set to data as well, since the version being replaced is not
+// an older state of data that a failed read should fall back to.
+func (s *Store) Replace(data []byte, modTime time.Time, check func() error) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	unlock, err := s.lockFile(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if check != nil {
+		if err := check(); err != nil {
+			return err
+		}
+	}
+	if err := fdh.WritePrivateFileAtomic(s.backupPath(), data); err != nil {
+		return fmt.Errorf("failed to write credentials backup: %w", err)
+	}
+	if err := os.Chtimes(s.backupPath(), modTime, modTime); err != nil {
+		return fmt.Errorf("failed to set backup modification time: %w", err)
+	}
+	if err := fdh.WritePrivateFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to write credentials file: %w", err)
+	}
+	if err := os.Chtimes(s.path, modTime, modTime); err != nil {
+		return fmt.Errorf("failed to set credentials file modification time: %w", err)
+	}
+	// The next access reloads the replaced file
+	s.loaded = false
+	return nil
+}
@@ -0,0 +1,60 @@
+package filestore
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	s, _ := newTestStore(t)
+	if _, err := s.Snapshot(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Snapshot of a missing file error = %v, want os.ErrNotExist", err)
+	}
+
+	s.Write("token", []byte("v1"))
+	snap, err := s.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	if bytes.Contains(snap, []byte("v1")) {
+		t.Error("snapshot contains the plaintext")
+	}
+
+	s.Write("token", []byte("v2"))
+	s.Write("other", []byte("x"))
+	if err := s.Restore(snap); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if got, err := s.Read("token"); err != nil || string(got) != "v1" {
+		t.Errorf("Read after Restore = %q, %v; want v1", got, err)
+	}
+	if _, err := s.Read("other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("credential written after the snapshot survived Restore: %v", err)
+	}
+
+	// The replaced version is the backup
+	bak, err := decodeFile(s.backupPath(), testKey)
+	if err != nil || string(bak["token"]) != "v2" {
+		t.Errorf("backup after Restore = %q, %v; want token v2", bak["token"], err)
+	}
+
+	// A snapshot under another key, or garbage, is refused and changes nothing
+	other := New(s.path+".other", func() ([]byte, error) { return bytes.Repeat([]byte{0x07}, 32), nil })
+	other.Write("token", []byte("foreign"))
+	foreign, _ := os.ReadFile(s.path + ".other")
+	if err := s.Restore(foreign); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Restore of a foreign snapshot error = %v, want ErrWrongKey", err)
+	}
+	if err := s.Restore(snap[:10]); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Restore of a truncated snapshot error = %v, want ErrCorrupt", err)
+	}
+	if got, _ := s.Read("token"); string(got) != "v1" {
+		t.Errorf("Read after refused Restore = %q, want v1", got)
+	}
+
+	if err := NewReadOnly(s.path, staticKey).Restore(snap); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Restore on a read-only Store error = %v, want ErrReadOnly", err)
+	}
+}
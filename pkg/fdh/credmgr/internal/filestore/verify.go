@@ -0,0 +1,109 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"runtime"
+	"sort"
+
+	"github.com/nzions/fdot/pkg/fdh"
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// Report is the result of Verify
+type Report struct {
+	Path      string
+	Format    int      // file format version of the readable version, 0 if there is no file
+	Cipher    Cipher   // cipher of the readable version, 0 if there is no file
+	Names     []string // credential names in the readable version of the file, sorted
+	Anomalies []string // problems that do not prevent reading the store
+}
+
+// Verify re-reads the file from disk under a shared lock, bypassing the cache, and
+// checks that it decrypts and decodes. A main file that fails while its backup is
+// intact is reported as an anomaly, since reads silently fall back to the backup;
+// an error is returned only when neither version is readable.
+func (s *Store) Verify() (Report, error) {
+	report := Report{Path: s.path}
+
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		return report, nil
+	}
+	if err != nil {
+		return report, fmt.Errorf("failed to stat credentials file: %w", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&^fdh.PrivateFileMode != 0 {
+		report.Anomalies = append(report.Anomalies,
+			fmt.Sprintf("%s is accessible by other users (mode %04o)", s.path, info.Mode().Perm()))
+	}
+
+	unlock, err := s.lockFile(false)
+	if err != nil {
+		return report, err
+	}
+	defer unlock()
+
+	key, err := s.getKey()
+	if err != nil {
+		return report, err
+	}
+
+	creds, mainErr := decodeFile(s.path, key)
+	backup, bakErr := decodeFile(s.backupPath(), key)
+	defer wipeCreds(backup)
+
+	readable := s.path
+	switch {
+	case mainErr == nil:
+		defer wipeCreds(creds)
+		if bakErr != nil && !errors.Is(bakErr, fs.ErrNotExist) {
+			report.Anomalies = append(report.Anomalies, fmt.Sprintf("backup is unusable: %v", bakErr))
+		}
+	case bakErr == nil:
+		creds, readable = backup, s.backupPath()
+		report.Anomalies = append(report.Anomalies, fmt.Sprintf("main file is unusable, reads fall back to the backup: %v", mainErr))
+	default:
+		return report, mainErr
+	}
+
+	report.Format, report.Cipher = fileHeader(readable)
+
+	for name := range creds {
+		if name == "" {
+			report.Anomalies = append(report.Anomalies, "credential with an empty name")
+			continue
+		}
+		report.Names = append(report.Names, name)
+	}
+	sort.Strings(report.Names)
+	return report, nil
+}
+
+// fileHeader returns the format version and cipher of the file at path; headerless
+// format 0 files are AES-256-GCM
+func fileHeader(path string) (int, Cipher) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+
+	header := make([]byte, headerSize)
+	n, _ := io.ReadFull(f, header)
+	version, c, ok := parseHeader(header[:n])
+	if !ok {
+		return formatV0, CipherAES256GCM
+	}
+	return int(version), c
+}
+
+// wipeCreds zeroes every value of a decoded credential map
+func wipeCreds(creds map[string][]byte) {
+	for _, data := range creds {
+		securemem.Wipe(data)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !windows
+
+package securemem
+
+// alloc uses the Go heap where memory locking is unavailable
+func alloc(size int) ([]byte, region) {
+	return make([]byte, size), region{}
+}
+
+// free is a no-op for heap memory; Destroy has already wiped it
+func free(data []byte, r region) {}
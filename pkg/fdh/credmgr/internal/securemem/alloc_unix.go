@@ -0,0 +1,25 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package securemem
+
+import "golang.org/x/sys/unix"
+
+// alloc maps anonymous memory and locks it, falling back to the Go heap if mmap fails
+func alloc(size int) ([]byte, region) {
+	data, err := unix.Mmap(-1, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return make([]byte, size), region{}
+	}
+	excludeFromDumps(data)
+	return data, region{mapped: true, locked: unix.Mlock(data) == nil}
+}
+
+// free unlocks and unmaps memory returned by alloc
+func free(data []byte, r region) {
+	if r.locked {
+		_ = unix.Munlock(data)
+	}
+	if r.mapped {
+		_ = unix.Munmap(data)
+	}
+}
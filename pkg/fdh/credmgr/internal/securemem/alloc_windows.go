@@ -0,0 +1,29 @@
+package securemem
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// alloc reserves committed pages with VirtualAlloc and locks them, falling back to the Go heap
+func alloc(size int) ([]byte, region) {
+	addr, err := windows.VirtualAlloc(0, uintptr(size), windows.MEM_COMMIT|windows.MEM_RESERVE, windows.PAGE_READWRITE)
+	if err != nil {
+		return make([]byte, size), region{}
+	}
+	// The pages are outside the Go heap, so converting the address is safe
+	data := unsafe.Slice((*byte)(unsafe.Add(nil, addr)), size)
+	return data, region{mapped: true, locked: windows.VirtualLock(addr, uintptr(size)) == nil}
+}
+
+// free unlocks and releases memory returned by alloc
+func free(data []byte, r region) {
+	addr := uintptr(unsafe.Pointer(unsafe.SliceData(data)))
+	if r.locked {
+		_ = windows.VirtualUnlock(addr, uintptr(len(data)))
+	}
+	if r.mapped {
+		_ = windows.VirtualFree(addr, 0, windows.MEM_RELEASE)
+	}
+}
@@ -0,0 +1,8 @@
+package securemem
+
+import "golang.org/x/sys/unix"
+
+// excludeFromDumps keeps the memory out of core dumps
+func excludeFromDumps(data []byte) {
+	_ = unix.Madvise(data, unix.MADV_DONTDUMP)
+}
@@ -0,0 +1,6 @@
+//go:build darwin || freebsd || netbsd || openbsd
+
+package securemem
+
+// excludeFromDumps is a no-op where madvise(MADV_DONTDUMP) is unavailable
+func excludeFromDumps(data []byte) {}
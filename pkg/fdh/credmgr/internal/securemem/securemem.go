@@ -0,0 +1,87 @@
+// Package securemem provides buffers for secrets that are kept out of swap and
+// wiped when released.
+//
+// Buffers are allocated outside the Go heap (mmap on Unix, VirtualAlloc on
+// Windows) and locked into RAM with mlock/VirtualLock, so the garbage collector
+// never copies them and they are never written to swap. On Linux they are also
+// excluded from core dumps. Locking is best effort: when the mlock limit
+// (RLIMIT_MEMLOCK) is exhausted the buffer is still usable but Locked reports false.
+package securemem
+
+import "runtime"
+
+// Buffer is a fixed-size block of memory for secret data
+type Buffer struct {
+	data    []byte
+	region  region
+	cleanup runtime.Cleanup
+}
+
+// region is platform allocation state released by free
+type region struct {
+	mapped bool // allocated outside the Go heap
+	locked bool // locked into RAM
+}
+
+// New allocates a zeroed buffer of size bytes.
+// Buffers not destroyed explicitly are wiped and released once unreachable.
+func New(size int) *Buffer {
+	if size <= 0 {
+		return &Buffer{}
+	}
+
+	data, r := alloc(size)
+	b := &Buffer{data: data, region: r}
+	b.cleanup = runtime.AddCleanup(b, func(data []byte) {
+		Wipe(data)
+		free(data, r)
+	}, data)
+	return b
+}
+
+// FromBytes copies src into a new buffer and wipes src
+func FromBytes(src []byte) *Buffer {
+	b := New(len(src))
+	copy(b.data, src)
+	Wipe(src)
+	return b
+}
+
+// Bytes returns the buffer contents. The slice is only valid until Destroy;
+// callers must not retain it.
+func (b *Buffer) Bytes() []byte {
+	if b == nil {
+		return nil
+	}
+	return b.data
+}
+
+// Len returns the buffer size
+func (b *Buffer) Len() int {
+	if b == nil {
+		return 0
+	}
+	return len(b.data)
+}
+
+// Locked reports whether the buffer is locked into RAM
+func (b *Buffer) Locked() bool {
+	return b != nil && b.region.locked
+}
+
+// Destroy wipes and releases the buffer. It is safe to call more than once.
+func (b *Buffer) Destroy() {
+	if b == nil || b.data == nil {
+		return
+	}
+	b.cleanup.Stop()
+	Wipe(b.data)
+	free(b.data, b.region)
+	b.data = nil
+}
+
+// Wipe overwrites b with zeros
+func Wipe(b []byte) {
+	clear(b)
+	runtime.KeepAlive(b)
+}
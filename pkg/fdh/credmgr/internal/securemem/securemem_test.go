@@ -0,0 +1,42 @@
+package securemem
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBufferLifecycle(t *testing.T) {
+	src := []byte("master-key-material")
+	b := FromBytes(src)
+
+	if !bytes.Equal(b.Bytes(), []byte("master-key-material")) {
+		t.Errorf("Bytes = %q, want copied secret", b.Bytes())
+	}
+	if !bytes.Equal(src, make([]byte, len(src))) {
+		t.Error("FromBytes did not wipe the source")
+	}
+	if b.Len() != len(src) {
+		t.Errorf("Len = %d, want %d", b.Len(), len(src))
+	}
+	t.Logf("locked into RAM: %v", b.Locked())
+
+	b.Destroy()
+	if b.Bytes() != nil || b.Len() != 0 {
+		t.Error("Destroy should release the buffer")
+	}
+	b.Destroy() // second call is a no-op
+}
+
+func TestEmptyAndNilBuffers(t *testing.T) {
+	empty := New(0)
+	if empty.Len() != 0 || empty.Bytes() != nil {
+		t.Errorf("New(0) = %d bytes, want none", empty.Len())
+	}
+	empty.Destroy()
+
+	var nilBuf *Buffer
+	if nilBuf.Bytes() != nil || nilBuf.Len() != 0 || nilBuf.Locked() {
+		t.Error("nil Buffer should behave as empty")
+	}
+	nilBuf.Destroy()
+}
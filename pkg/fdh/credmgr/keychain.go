@@ -0,0 +1,180 @@
+package credmgr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh"
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// Keychain unlock keeps the master key of a credential file in the OS-native secret
+// store (DPAPI on Windows, the login Keychain on macOS, the Secret Service on Linux),
+// so the user never handles CREDMGR_KEY. Enrollment is recorded in <path>.keychain
+// next to the credential file.
+
+// ErrKeychainNotEnrolled is returned when the master key of a database is not stored in the OS keychain.
+var ErrKeychainNotEnrolled = errors.New("master key not stored in the OS keychain")
+
+// keychainService is the service name credmgr master keys are stored under
+const keychainService = "fdot-credmgr"
+
+// keychainEnrollment records where the master key of a database is kept
+type keychainEnrollment struct {
+	Backend    string    `json:"backend"`
+	Account    string    `json:"account"`       // absolute path of the credential file
+	Ref        []byte    `json:"ref,omitempty"` // backend data, e.g. the DPAPI-protected key
+	EnrolledAt time.Time `json:"enrolled_at"`
+}
+
+// osKeychain is an OS-native secret store
+type osKeychain interface {
+	// Name identifies the backend, e.g. "dpapi"
+	Name() string
+	// Protect stores secret for account and returns data to keep in the enrollment file
+	Protect(account string, secret []byte) ([]byte, error)
+	// Unprotect returns the secret stored for account; ref is the data returned by Protect
+	Unprotect(account string, ref []byte) ([]byte, error)
+	// Remove deletes the secret stored for account
+	Remove(account string, ref []byte) error
+}
+
+// keychainBackend is the secret store for this platform (replaced in tests)
+var keychainBackend osKeychain = platformKeychain{}
+
+// keychainEnrollmentPath returns the enrollment file path for a credential database
+func keychainEnrollmentPath(dbPath string) string {
+	return dbPath + ".keychain"
+}
+
+// EnrollKeychain stores the master key of the credential database at dbPath in the
+// OS keychain; afterwards the database unlocks without CREDMGR_KEY. For an existing
+// database the current master key must be available (CREDMGR_KEY, CREDMGR_KEYFILE or
+// an enrolled FIDO2 key); for a new database a random master key is generated.
+func EnrollKeychain(dbPath string) error {
+	if enrolled, err := KeychainEnrolled(dbPath); err != nil || enrolled {
+		if err == nil {
+			err = fmt.Errorf("master key is already stored in the OS keychain")
+		}
+		return err
+	}
+
+	account, err := filepath.Abs(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve credential file path: %w", err)
+	}
+
+	masterKey, err := enrollmentMasterKey(dbPath)
+	if err != nil {
+		return err
+	}
+	defer securemem.Wipe(masterKey)
+
+	ref, err := keychainBackend.Protect(account, masterKey)
+	if err != nil {
+		return fmt.Errorf("failed to store master key in %s: %w", keychainBackend.Name(), err)
+	}
+
+	return writeKeychainEnrollment(dbPath, keychainEnrollment{
+		Backend:    keychainBackend.Name(),
+		Account:    account,
+		Ref:        ref,
+		EnrolledAt: time.Now(),
+	})
+}
+
+// RemoveKeychain deletes the master key of the database from the OS keychain.
+// Make sure another key source works first: without one the database cannot be opened.
+func RemoveKeychain(dbPath string) error {
+	e, err := readKeychainEnrollment(dbPath)
+	if err != nil {
+		return err
+	}
+
+	if err := keychainBackend.Remove(e.Account, e.Ref); err != nil {
+		return fmt.Errorf("failed to remove master key from %s: %w", e.Backend, err)
+	}
+	if err := os.Remove(keychainEnrollmentPath(dbPath)); err != nil {
+		return fmt.Errorf("failed to remove keychain enrollment file: %w", err)
+	}
+	return nil
+}
+
+// KeychainEnrolled reports whether the master key of the database is stored in the OS keychain.
+func KeychainEnrolled(dbPath string) (bool, error) {
+	_, err := readKeychainEnrollment(dbPath)
+	if errors.Is(err, ErrKeychainNotEnrolled) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// unlockKeychain loads the master key from the OS keychain.
+// The returned bool reports whether the database is enrolled.
+func unlockKeychain(dbPath string) ([]byte, bool, error) {
+	e, err := readKeychainEnrollment(dbPath)
+	if errors.Is(err, ErrKeychainNotEnrolled) {
+		return nil, false, err
+	}
+	if err != nil {
+		return nil, true, err
+	}
+
+	key, err := keychainBackend.Unprotect(e.Account, e.Ref)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to load master key from %s: %w", e.Backend, err)
+	}
+	if len(key) != masterKeyLen {
+		securemem.Wipe(key)
+		return nil, true, fmt.Errorf("%w: master key from %s has %d bytes", ErrInvalidFormat, e.Backend, len(key))
+	}
+	return key, true, nil
+}
+
+// readKeychainEnrollment loads the enrollment file, or returns ErrKeychainNotEnrolled
+func readKeychainEnrollment(dbPath string) (keychainEnrollment, error) {
+	var e keychainEnrollment
+
+	data, err := os.ReadFile(keychainEnrollmentPath(dbPath))
+	if os.IsNotExist(err) {
+		return e, ErrKeychainNotEnrolled
+	}
+	if err != nil {
+		return e, fmt.Errorf("failed to read keychain enrollment file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &e); err != nil {
+		return e, fmt.Errorf("%w: keychain enrollment file: %v", ErrInvalidFormat, err)
+	}
+	return e, nil
+}
+
+// writeKeychainEnrollment saves the enrollment file with owner-only permissions
+func writeKeychainEnrollment(dbPath string, e keychainEnrollment) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal keychain enrollment: %w", err)
+	}
+
+	if err := fdh.WritePrivateFileAtomic(keychainEnrollmentPath(dbPath), data); err != nil {
+		return fmt.Errorf("failed to write keychain enrollment file: %w", err)
+	}
+	return nil
+}
+
+// decodeKeychainSecret decodes the hex secret printed by a keychain tool
+func decodeKeychainSecret(out []byte) ([]byte, error) {
+	encoded := bytes.TrimSpace(out)
+	secret := make([]byte, hex.DecodedLen(len(encoded)))
+	if _, err := hex.Decode(secret, encoded); err != nil || len(encoded) == 0 {
+		securemem.Wipe(secret)
+		return nil, fmt.Errorf("%w: stored secret is not hex", ErrInvalidFormat)
+	}
+	return secret, nil
+}
@@ -0,0 +1,59 @@
+//go:build darwin
+
+package credmgr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// platformKeychain stores secrets as generic passwords in the login Keychain using
+// the security tool that ships with macOS.
+type platformKeychain struct{}
+
+func (platformKeychain) Name() string { return "keychain" }
+
+// Protect runs add-generic-password through "security -i", which reads the command
+// from stdin so the secret never appears in the process list
+func (platformKeychain) Protect(account string, secret []byte) ([]byte, error) {
+	if strings.ContainsAny(account, "\"\n") {
+		return nil, fmt.Errorf("credential file path %q cannot be used as a keychain account", account)
+	}
+
+	prefix := fmt.Sprintf("add-generic-password -U -s %s -a \"%s\" -w ", keychainService, account)
+	command := make([]byte, 0, len(prefix)+hex.EncodedLen(len(secret))+1) // sized so the secret is never reallocated
+	command = append(command, prefix...)
+	command = hex.AppendEncode(command, secret)
+	command = append(command, '\n')
+	defer securemem.Wipe(command)
+
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = bytes.NewReader(command)
+	if out, err := cmd.CombinedOutput(); err != nil || len(bytes.TrimSpace(out)) > 0 {
+		return nil, fmt.Errorf("security add-generic-password failed: %v (%s)", err, bytes.TrimSpace(out))
+	}
+	return nil, nil
+}
+
+// Unprotect runs security find-generic-password
+func (platformKeychain) Unprotect(account string, _ []byte) ([]byte, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", keychainService, "-a", account, "-w").Output()
+	defer securemem.Wipe(out)
+	if err != nil {
+		return nil, fmt.Errorf("security find-generic-password failed: %w", err)
+	}
+	return decodeKeychainSecret(out)
+}
+
+// Remove runs security delete-generic-password
+func (platformKeychain) Remove(account string, _ []byte) error {
+	if out, err := exec.Command("security", "delete-generic-password", "-s", keychainService, "-a", account).CombinedOutput(); err != nil {
+		return fmt.Errorf("security delete-generic-password failed: %w (%s)", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
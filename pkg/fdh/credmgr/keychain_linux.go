@@ -0,0 +1,51 @@
+//go:build linux
+
+package credmgr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// platformKeychain stores secrets in the freedesktop Secret Service (GNOME Keyring,
+// KWallet) using secret-tool from libsecret, which must be installed and on PATH.
+type platformKeychain struct{}
+
+func (platformKeychain) Name() string { return "secret-service" }
+
+// Protect runs secret-tool store; the secret is passed on stdin, never on the command line
+func (platformKeychain) Protect(account string, secret []byte) ([]byte, error) {
+	encoded := make([]byte, hex.EncodedLen(len(secret)))
+	hex.Encode(encoded, secret)
+	defer securemem.Wipe(encoded)
+
+	cmd := exec.Command("secret-tool", "store", "--label", "fdot credmgr master key ("+account+")",
+		"service", keychainService, "account", account)
+	cmd.Stdin = bytes.NewReader(encoded)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("secret-tool store failed: %w (%s)", err, bytes.TrimSpace(out))
+	}
+	return nil, nil
+}
+
+// Unprotect runs secret-tool lookup
+func (platformKeychain) Unprotect(account string, _ []byte) ([]byte, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", keychainService, "account", account).Output()
+	defer securemem.Wipe(out)
+	if err != nil {
+		return nil, fmt.Errorf("secret-tool lookup failed: %w", err)
+	}
+	return decodeKeychainSecret(out)
+}
+
+// Remove runs secret-tool clear
+func (platformKeychain) Remove(account string, _ []byte) error {
+	if out, err := exec.Command("secret-tool", "clear", "service", keychainService, "account", account).CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool clear failed: %w (%s)", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build !windows && !linux && !darwin
+
+package credmgr
+
+// platformKeychain reports that no OS keychain is supported on this platform
+type platformKeychain struct{}
+
+func (platformKeychain) Name() string { return "none" }
+
+func (platformKeychain) Protect(account string, secret []byte) ([]byte, error) {
+	return nil, ErrNotSupported
+}
+
+func (platformKeychain) Unprotect(account string, ref []byte) ([]byte, error) {
+	return nil, ErrNotSupported
+}
+
+func (platformKeychain) Remove(account string, ref []byte) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,129 @@
+package credmgr
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeKeychain holds secrets in memory by account
+type fakeKeychain map[string][]byte
+
+func (fakeKeychain) Name() string { return "fake" }
+
+func (f fakeKeychain) Protect(account string, secret []byte) ([]byte, error) {
+	f[account] = bytes.Clone(secret)
+	return []byte("ref"), nil
+}
+
+func (f fakeKeychain) Unprotect(account string, ref []byte) ([]byte, error) {
+	secret, ok := f[account]
+	if !ok || string(ref) != "ref" {
+		return nil, ErrNotFound
+	}
+	return bytes.Clone(secret), nil
+}
+
+func (f fakeKeychain) Remove(account string, ref []byte) error {
+	delete(f, account)
+	return nil
+}
+
+// useFakeKeychain installs a fake keychain for the duration of the test
+func useFakeKeychain(t *testing.T) fakeKeychain {
+	t.Helper()
+	fake := fakeKeychain{}
+	original := keychainBackend
+	keychainBackend = fake
+	t.Cleanup(func() { keychainBackend = original })
+	return fake
+}
+
+func TestKeychainEnrollAndUnlock(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+	useFakeKeychain(t)
+
+	dbPath := filepath.Join(t.TempDir(), "credentials.enc")
+
+	// Write a credential using CREDMGR_KEY
+	cm, _ := New(dbPath)
+	if err := cm.WriteKey("token", "secret"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+
+	if err := EnrollKeychain(dbPath); err != nil {
+		t.Fatalf("EnrollKeychain failed: %v", err)
+	}
+	if err := EnrollKeychain(dbPath); err == nil {
+		t.Error("second EnrollKeychain should fail")
+	}
+	if enrolled, err := KeychainEnrolled(dbPath); err != nil || !enrolled {
+		t.Errorf("KeychainEnrolled = %v, %v; want true", enrolled, err)
+	}
+
+	// Unlock without CREDMGR_KEY
+	os.Unsetenv("CREDMGR_KEY")
+	cm, _ = New(dbPath)
+	if got, err := cm.ReadKey("token"); err != nil || got != "secret" {
+		t.Errorf("ReadKey with keychain unlock = %q, %v; want %q", got, err, "secret")
+	}
+
+	if err := RemoveKeychain(dbPath); err != nil {
+		t.Fatalf("RemoveKeychain failed: %v", err)
+	}
+	if _, err := os.Stat(keychainEnrollmentPath(dbPath)); !os.IsNotExist(err) {
+		t.Error("enrollment file still exists after RemoveKeychain")
+	}
+	if _, err := loadMasterKey(dbPath, ""); err == nil {
+		t.Error("loadMasterKey after RemoveKeychain should fail without CREDMGR_KEY")
+	}
+}
+
+func TestKeychainEnrollNewDatabase(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+	fake := useFakeKeychain(t)
+	os.Unsetenv("CREDMGR_KEY")
+
+	dbPath := filepath.Join(t.TempDir(), "credentials.enc")
+	if err := EnrollKeychain(dbPath); err != nil {
+		t.Fatalf("EnrollKeychain on a new database failed: %v", err)
+	}
+
+	account, _ := filepath.Abs(dbPath)
+	if len(fake[account]) != masterKeyLen {
+		t.Fatalf("stored key length = %d, want %d", len(fake[account]), masterKeyLen)
+	}
+
+	cm, _ := New(dbPath)
+	if err := cm.WriteKey("token", "v"); err != nil {
+		t.Fatalf("WriteKey with generated key failed: %v", err)
+	}
+	if got, err := cm.ReadKey("token"); err != nil || got != "v" {
+		t.Errorf("ReadKey = %q, %v; want %q", got, err, "v")
+	}
+}
+
+func TestKeychainEnrollExistingNeedsKey(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+	useFakeKeychain(t)
+
+	dbPath := filepath.Join(t.TempDir(), "credentials.enc")
+	cm, _ := New(dbPath)
+	if err := cm.WriteKey("token", "v"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+
+	// Without the current key a fresh one must not be generated for an existing file
+	os.Unsetenv("CREDMGR_KEY")
+	if err := EnrollKeychain(dbPath); err == nil {
+		t.Error("EnrollKeychain on an existing database without its key should fail")
+	}
+	if err := RemoveKeychain(dbPath); !errors.Is(err, ErrKeychainNotEnrolled) {
+		t.Errorf("RemoveKeychain error = %v, want ErrKeychainNotEnrolled", err)
+	}
+}
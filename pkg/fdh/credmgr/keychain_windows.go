@@ -0,0 +1,89 @@
+//go:build windows
+
+package credmgr
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+var (
+	crypt32                = syscall.NewLazyDLL("crypt32.dll")
+	procCryptProtectData   = crypt32.NewProc("CryptProtectData")
+	procCryptUnprotectData = crypt32.NewProc("CryptUnprotectData")
+	kernel32               = syscall.NewLazyDLL("kernel32.dll")
+	procLocalFree          = kernel32.NewProc("LocalFree")
+)
+
+// cryptProtectUIForbidden fails instead of showing a prompt
+const cryptProtectUIForbidden = 0x1
+
+// dataBlob is the DATA_BLOB structure used by DPAPI
+type dataBlob struct {
+	Size uint32
+	Data *byte
+}
+
+func newDataBlob(data []byte) *dataBlob {
+	if len(data) == 0 {
+		return &dataBlob{}
+	}
+	return &dataBlob{Size: uint32(len(data)), Data: &data[0]}
+}
+
+// platformKeychain protects secrets with DPAPI under the current user's logon
+// credentials. The protected key is kept in the enrollment file itself, so only
+// the same Windows user account can unwrap it.
+type platformKeychain struct{}
+
+func (platformKeychain) Name() string { return "dpapi" }
+
+// Protect encrypts secret with CryptProtectData
+func (platformKeychain) Protect(account string, secret []byte) ([]byte, error) {
+	return dpapiCall(procCryptProtectData, secret, account)
+}
+
+// Unprotect decrypts the protected key with CryptUnprotectData
+func (platformKeychain) Unprotect(account string, ref []byte) ([]byte, error) {
+	return dpapiCall(procCryptUnprotectData, ref, "")
+}
+
+// Remove has nothing to delete: the enrollment file holds the only copy
+func (platformKeychain) Remove(account string, ref []byte) error {
+	return nil
+}
+
+// dpapiCall runs CryptProtectData or CryptUnprotectData on data and returns a copy of
+// the output, wiping the buffer DPAPI allocated. description labels protected data.
+func dpapiCall(proc *syscall.LazyProc, data []byte, description string) ([]byte, error) {
+	var descPtr *uint16
+	if description != "" {
+		p, err := syscall.UTF16PtrFromString(description)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert description: %w", err)
+		}
+		descPtr = p
+	}
+
+	var out dataBlob
+	ret, _, err := proc.Call(
+		uintptr(unsafe.Pointer(newDataBlob(data))),
+		uintptr(unsafe.Pointer(descPtr)),
+		0, 0, 0,
+		cryptProtectUIForbidden,
+		uintptr(unsafe.Pointer(&out)),
+	)
+	if ret == 0 {
+		return nil, fmt.Errorf("%s failed: %w", proc.Name, err)
+	}
+	defer procLocalFree.Call(uintptr(unsafe.Pointer(out.Data)))
+
+	buf := unsafe.Slice(out.Data, out.Size)
+	result := make([]byte, len(buf))
+	copy(result, buf)
+	securemem.Wipe(buf)
+	return result, nil
+}
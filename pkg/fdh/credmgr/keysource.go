@@ -0,0 +1,133 @@
+package credmgr
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"runtime"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+
+	"github.com/nzions/fdot/pkg/fdotconfig"
+)
+
+// masterKeyLen is the size of the file-encryption master key (AES-256)
+const masterKeyLen = 32
+
+// keyFromEnv loads the master key from the CREDMGR_KEY environment variable.
+// Returns a nil key and nil error if the variable is not set.
+func keyFromEnv() ([]byte, error) {
+	keyHex := os.Getenv(fdotconfig.CredMgrEnvVarKey)
+	if keyHex == "" {
+		return nil, nil
+	}
+
+	key, err := hex.DecodeString(keyHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s format (expected 64 hex chars): %w", fdotconfig.CredMgrEnvVarKey, err)
+	}
+
+	if len(key) != masterKeyLen {
+		return nil, fmt.Errorf("invalid %s length (expected %d bytes, got %d)", fdotconfig.CredMgrEnvVarKey, masterKeyLen, len(key))
+	}
+
+	return key, nil
+}
+
+// keyFromFile loads the master key from a key file: either the 32 raw key bytes or
+// 64 hex characters (optionally followed by a newline, as written by openssl rand -hex 32).
+// The file must not be accessible by group or others, since the key is in the clear.
+func keyFromFile(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return nil, fmt.Errorf("key file %s has permissions %04o; it must not be accessible by group or others (chmod 600)", path, info.Mode().Perm())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	defer securemem.Wipe(data)
+
+	if len(data) == masterKeyLen {
+		return bytes.Clone(data), nil
+	}
+
+	keyHex := bytes.TrimSpace(data)
+	key := make([]byte, hex.DecodedLen(len(keyHex)))
+	n, err := hex.Decode(key, keyHex)
+	if err != nil || n != masterKeyLen {
+		securemem.Wipe(key)
+		return nil, fmt.Errorf("invalid key file %s (expected %d raw bytes or %d hex chars)", path, masterKeyLen, 2*masterKeyLen)
+	}
+	return key, nil
+}
+
+// loadMasterKey resolves the master key for the credential file at dbPath.
+// Sources are tried in order: CREDMGR_KEY, the key file (keyFile, else CREDMGR_KEYFILE,
+// else key_file in the fdot config), then the enrolled unlock methods: the OS keychain,
+// FIDO2 security keys and YubiKey challenge-response slots.
+func loadMasterKey(dbPath, keyFile string) ([]byte, error) {
+	key, err := keyFromEnv()
+	if err != nil || key != nil {
+		return key, err
+	}
+
+	if keyFile == "" {
+		keyFile = os.Getenv(fdotconfig.CredMgrEnvVarKeyFile)
+	}
+	if keyFile == "" {
+		cfg, err := fdotconfig.LoadConfig()
+		if err != nil {
+			return nil, err
+		}
+		keyFile = cfg.KeyFile
+	}
+	if keyFile != "" {
+		return keyFromFile(keyFile)
+	}
+
+	// Any enrolled method can open the database, e.g. a YubiKey when the keychain is locked
+	var errs []error
+	for _, unlock := range []func(string) ([]byte, bool, error){unlockKeychain, unlockFIDO2, unlockYubiKey} {
+		key, enrolled, err := unlock(dbPath)
+		if !enrolled {
+			continue
+		}
+		if err == nil {
+			return key, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return nil, fmt.Errorf("%s environment variable not set (or set %s to a key file)", fdotconfig.CredMgrEnvVarKey, fdotconfig.CredMgrEnvVarKeyFile)
+}
+
+// enrollmentMasterKey returns the master key to protect with a new unlock method:
+// the current key of an existing database, or a new random key if dbPath does not exist yet
+func enrollmentMasterKey(dbPath string) ([]byte, error) {
+	key, err := loadMasterKey(dbPath, "")
+	if err == nil {
+		return key, nil
+	}
+	if _, statErr := os.Stat(dbPath); !errors.Is(statErr, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to load master key: %w", err)
+	}
+
+	key = make([]byte, masterKeyLen)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate master key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,139 @@
+package credmgr
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/nzions/fdot/pkg/fdotconfig"
+)
+
+func TestKeyFromFile(t *testing.T) {
+	raw := bytes.Repeat([]byte{0x5a}, masterKeyLen)
+	hexKey := strings.Repeat("5a", masterKeyLen)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{"hex", []byte(hexKey), false},
+		{"hex with newline", []byte(hexKey + "\n"), false},
+		{"raw bytes", raw, false},
+		{"short hex", []byte(hexKey[:62]), true},
+		{"not hex", []byte(strings.Repeat("zz", masterKeyLen)), true},
+		{"empty", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "credmgr.key")
+			if err := os.WriteFile(path, tt.data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			key, err := keyFromFile(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("keyFromFile() expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("keyFromFile() unexpected error: %v", err)
+			}
+			if !bytes.Equal(key, raw) {
+				t.Errorf("keyFromFile() = %x, want %x", key, raw)
+			}
+		})
+	}
+}
+
+func TestKeyFromFilePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not enforced on Windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "credmgr.key")
+	if err := os.WriteFile(path, []byte(strings.Repeat("5a", masterKeyLen)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keyFromFile(path); err == nil || !strings.Contains(err.Error(), "chmod 600") {
+		t.Errorf("keyFromFile() on a 0644 file error = %v, want a permissions error", err)
+	}
+}
+
+func TestOpenWithKeyFile(t *testing.T) {
+	t.Setenv("CREDMGR_KEY", "")
+	t.Setenv("CREDMGR_KEYFILE", "")
+
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "credmgr.key")
+	if err := os.WriteFile(keyPath, []byte(strings.Repeat("5a", masterKeyLen)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(dir, "credentials.enc")
+
+	cm, err := Open(dbPath, WithKeyFile(keyPath))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := cm.WriteKey("token", "secret"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+
+	// The same key through CREDMGR_KEYFILE, and as CREDMGR_KEY, opens the file
+	t.Setenv("CREDMGR_KEYFILE", keyPath)
+	viaEnv, _ := New(dbPath)
+	if got, err := viaEnv.ReadKey("token"); err != nil || got != "secret" {
+		t.Errorf("ReadKey via CREDMGR_KEYFILE = %q, %v; want %q", got, err, "secret")
+	}
+
+	t.Setenv("CREDMGR_KEYFILE", "")
+	t.Setenv("CREDMGR_KEY", strings.Repeat("5a", masterKeyLen))
+	viaKey, _ := New(dbPath)
+	if got, err := viaKey.ReadKey("token"); err != nil || got != "secret" {
+		t.Errorf("ReadKey via CREDMGR_KEY = %q, %v; want %q", got, err, "secret")
+	}
+}
+
+func TestLoadMasterKeyNoSource(t *testing.T) {
+	t.Setenv("CREDMGR_KEY", "")
+	t.Setenv("CREDMGR_KEYFILE", "")
+	t.Setenv("FDOT_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+
+	_, err := loadMasterKey(filepath.Join(t.TempDir(), "credentials.enc"), "")
+	if err == nil || !strings.Contains(err.Error(), "CREDMGR_KEYFILE") {
+		t.Errorf("loadMasterKey() error = %v, want a hint naming CREDMGR_KEYFILE", err)
+	}
+}
+
+func TestLoadMasterKeyConfigKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CREDMGR_KEY", "")
+	t.Setenv("CREDMGR_KEYFILE", "")
+	t.Setenv("FDOT_CONFIG", filepath.Join(dir, "config.json"))
+
+	keyFile := filepath.Join(dir, "credmgr.key")
+	if err := os.WriteFile(keyFile, []byte(strings.Repeat("5a", masterKeyLen)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fdotconfig.SaveConfig(fdotconfig.Config{KeyFile: keyFile}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	key, err := loadMasterKey(filepath.Join(dir, "credentials.enc"), "")
+	if err != nil {
+		t.Fatalf("loadMasterKey with configured key file: %v", err)
+	}
+	if !bytes.Equal(key, bytes.Repeat([]byte{0x5a}, masterKeyLen)) {
+		t.Error("loadMasterKey did not return the configured key file's key")
+	}
+}
@@ -0,0 +1,92 @@
+package credmgr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/filestore"
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// MigratedSuffix is appended to a credential file once MigrateFile has merged it
+const MigratedSuffix = ".migrated"
+
+// LegacyFilePath returns the credential file used by the v2 package-level API and
+// by fuser before 3.37 (~/.fdot/credentials.enc). Open("") merges it into the
+// default store (see MigrateFile).
+func LegacyFilePath() (string, error) {
+	hd, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(hd, ".fdot", "credentials.enc"), nil
+}
+
+// MigrateFile copies the credentials of the file at path into cm and renames the file
+// to path+MigratedSuffix, so it is merged only once. Names cm already holds are kept;
+// trashed entries are not copied but stay in the renamed file. The file is opened
+// with the master key New(path) would use; opts may set WithKeyFile. It returns the
+// number of credentials copied.
+func MigrateFile(cm CredManager, path string, opts ...Option) (int, error) {
+	o := collectOptions(opts)
+	src := filestore.NewReadOnly(path, func() ([]byte, error) {
+		return loadMasterKey(path, o.keyFile)
+	})
+
+	names, err := src.List()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	batch := make(map[string][]byte)
+	defer func() {
+		for _, data := range batch {
+			securemem.Wipe(data)
+		}
+	}()
+	for _, name := range visibleNames(names) {
+		exists, err := cm.Exists(name)
+		if err != nil {
+			return 0, err
+		}
+		if exists {
+			continue
+		}
+		data, err := src.Read(name)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read %q from %s: %w", name, path, err)
+		}
+		batch[name] = data
+	}
+
+	if len(batch) > 0 {
+		if err := cm.WriteBatch(batch); err != nil {
+			return 0, fmt.Errorf("failed to migrate %s: %w", path, err)
+		}
+	}
+	if err := os.Rename(path, path+MigratedSuffix); err != nil {
+		return len(batch), fmt.Errorf("failed to retire %s: %w", path, err)
+	}
+	return len(batch), nil
+}
+
+// migrateLegacy merges LegacyFilePath into the default store cm on first Open. It is
+// best effort: a legacy file that cannot be merged (no master key yet, a different
+// key, an unsupported platform) is left in place, and MigrateFile reports why.
+func migrateLegacy(cm CredManager, o *openOptions) {
+	if o.readOnly {
+		return
+	}
+	legacy, err := LegacyFilePath()
+	if err != nil {
+		return
+	}
+	if current, err := DefaultFilePath(); err == nil && filepath.Clean(current) == filepath.Clean(legacy) {
+		return
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		return
+	}
+	_, _ = MigrateFile(cm, legacy, WithKeyFile(o.keyFile))
+}
@@ -0,0 +1,95 @@
+package credmgr
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestMigrateFile(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	legacyPath := filepath.Join(t.TempDir(), "credentials.enc")
+	legacy, _ := New(legacyPath)
+	if err := legacy.WriteKey("old-only", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.WriteKey("both", "legacy"); err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.WriteKey("trashed", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.Delete("trashed"); err != nil {
+		t.Fatal(err)
+	}
+
+	cm := NewMemory()
+	if err := cm.WriteKey("both", "current"); err != nil {
+		t.Fatal(err)
+	}
+	n, err := MigrateFile(cm, legacyPath)
+	if err != nil || n != 1 {
+		t.Fatalf("MigrateFile = %d, %v; want 1", n, err)
+	}
+
+	for name, want := range map[string]string{"old-only": "1", "both": "current"} {
+		if got, err := cm.ReadKey(name); err != nil || got != want {
+			t.Errorf("ReadKey(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if ok, _ := cm.Exists("trashed"); ok {
+		t.Error("a trashed legacy entry was migrated")
+	}
+	if _, err := os.Stat(legacyPath); !os.IsNotExist(err) {
+		t.Errorf("legacy file still in place: %v", err)
+	}
+	if _, err := os.Stat(legacyPath + MigratedSuffix); err != nil {
+		t.Errorf("legacy file not retired: %v", err)
+	}
+}
+
+func TestOpenMigratesLegacyFile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the default store is a file only on Linux")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("FDOT_CONFIG", filepath.Join(home, "config.json"))
+	t.Setenv("CREDMGR_KEY", hex.EncodeToString(make([]byte, 32)))
+
+	legacyPath, _ := LegacyFilePath()
+	if err := os.MkdirAll(filepath.Dir(legacyPath), 0700); err != nil {
+		t.Fatal(err)
+	}
+	legacy, _ := New(legacyPath)
+	if err := legacy.WriteKey("token", "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	cm, err := Open("")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if got, err := cm.ReadKey("token"); err != nil || got != "abc" {
+		t.Errorf("ReadKey after migration = %q, %v", got, err)
+	}
+
+	// Read-only opens and unreadable legacy files leave the legacy file alone
+	if err := os.Rename(legacyPath+MigratedSuffix, legacyPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open("", ReadOnly()); err != nil {
+		t.Fatalf("Open(ReadOnly) failed: %v", err)
+	}
+	t.Setenv("CREDMGR_KEY", hex.EncodeToString(make([]byte, 31))+"01")
+	if _, err := Open(""); err != nil {
+		t.Fatalf("Open with a legacy file under another key failed: %v", err)
+	}
+	if _, err := os.Stat(legacyPath); err != nil {
+		t.Errorf("legacy file was moved: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package credmgr
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// NewMemory returns a CredManager that keeps credentials in process memory only,
+// for unit tests and short-lived tools. Nothing is written to disk and no master key
+// is needed; the credentials are gone when the manager is garbage collected.
+// Batches are atomic and the trash works as with the file store. Apply options
+// (audit, access policies, ...) with Wrap.
+func NewMemory() CredManager {
+	return NewFromStore(&memoryStore{creds: make(map[string][]byte)})
+}
+
+// memoryStore is a Store backed by a map. Values are copied in and out so callers
+// can wipe their buffers, and wiped when deleted or overwritten.
+type memoryStore struct {
+	mu    sync.RWMutex
+	creds map[string][]byte
+}
+
+func (m *memoryStore) Read(name string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data, ok := m.creds[name]
+	if !ok {
+		return nil, fmt.Errorf("credential %q %w", name, ErrNotFound)
+	}
+	return bytes.Clone(data), nil
+}
+
+// ReadInto copies the credential into buf without an intermediate copy
+func (m *memoryStore) ReadInto(name string, buf []byte) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data, ok := m.creds[name]
+	if !ok {
+		return 0, fmt.Errorf("credential %q %w", name, ErrNotFound)
+	}
+	if len(buf) < len(data) {
+		return len(data), fmt.Errorf("credential %q needs %d bytes: %w", name, len(data), io.ErrShortBuffer)
+	}
+	return copy(buf, data), nil
+}
+
+func (m *memoryStore) Write(name string, data []byte) error {
+	return m.Update(func(creds map[string][]byte) error {
+		creds[name] = bytes.Clone(data)
+		return nil
+	})
+}
+
+func (m *memoryStore) Delete(name string) error {
+	return m.Update(func(creds map[string][]byte) error {
+		if _, ok := creds[name]; !ok {
+			return fmt.Errorf("credential %q %w", name, ErrNotFound)
+		}
+		delete(creds, name)
+		return nil
+	})
+}
+
+func (m *memoryStore) DeleteDB() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, data := range m.creds {
+		securemem.Wipe(data)
+	}
+	clear(m.creds)
+	return nil
+}
+
+func (m *memoryStore) List() ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Sorted(maps.Keys(m.creds)), nil
+}
+
+// Update applies fn to a copy of the credentials and keeps the result only if fn
+// succeeds, so batches are all or nothing. Values no longer stored are wiped.
+func (m *memoryStore) Update(fn func(creds map[string][]byte) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	next := maps.Clone(m.creds)
+	if err := fn(next); err != nil {
+		return err
+	}
+
+	// fn may move a buffer to another name (e.g. into the trash), so only buffers
+	// that no name refers to any more are wiped
+	kept := make(map[*byte]bool, len(next))
+	for _, data := range next {
+		if len(data) > 0 {
+			kept[&data[0]] = true
+		}
+	}
+	for _, old := range m.creds {
+		if len(old) > 0 && !kept[&old[0]] {
+			securemem.Wipe(old)
+		}
+	}
+	m.creds = next
+	return nil
+}
@@ -0,0 +1,69 @@
+package credmgr
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMemory(t *testing.T) {
+	cm := NewMemory()
+	testBatch(t, cm)
+	testExists(t, NewMemory())
+
+	if err := cm.WriteUserCred("router", NewUnPw("admin", "pw")); err != nil {
+		t.Fatalf("WriteUserCred failed: %v", err)
+	}
+	if cred, err := cm.ReadUserCred("router"); err != nil || cred.Password() != "pw" {
+		t.Errorf("ReadUserCred = %v, %v", cred, err)
+	}
+
+	// A failed batch leaves the store unchanged
+	if err := cm.DeleteBatch([]string{"c", "missing"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteBatch with missing name error = %v, want ErrNotFound", err)
+	}
+	if _, err := cm.ReadKey("c"); err != nil {
+		t.Errorf("failed DeleteBatch removed a credential: %v", err)
+	}
+
+	report, err := cm.Verify()
+	if err != nil || !report.OK() || report.Entries != 3 {
+		t.Errorf("Verify = %+v, %v; want 3 entries", report, err)
+	}
+
+	if err := cm.DeleteDB(); err != nil {
+		t.Fatalf("DeleteDB failed: %v", err)
+	}
+	if names, err := cm.List(); err != nil || len(names) != 0 {
+		t.Errorf("List after DeleteDB = %v, %v", names, err)
+	}
+}
+
+func TestMemoryCopiesValues(t *testing.T) {
+	cm := NewMemory()
+	secret := []byte("secret")
+	if err := cm.Write("token", secret); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	secret[0] = 'X'
+
+	got, err := cm.Read("token")
+	if err != nil || string(got) != "secret" {
+		t.Fatalf("Read = %q, %v; the caller's buffer was kept", got, err)
+	}
+	got[0] = 'X'
+	if again, _ := cm.Read("token"); !bytes.Equal(again, []byte("secret")) {
+		t.Errorf("Read = %q; modifying a returned value changed the store", again)
+	}
+
+	// Moving into the trash and back keeps the value intact
+	if err := cm.Delete("token"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := cm.Restore("token"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if got, err := cm.ReadKey("token"); err != nil || got != "secret" {
+		t.Errorf("ReadKey after Restore = %q, %v", got, err)
+	}
+}
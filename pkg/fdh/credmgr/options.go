@@ -0,0 +1,231 @@
+package credmgr
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/filestore"
+)
+
+// ErrReadOnly is returned by mutating operations on a CredManager opened with ReadOnly.
+var ErrReadOnly = filestore.ErrReadOnly
+
+// Option configures a CredManager created by Open
+type Option func(*openOptions)
+
+// openOptions holds the settings collected from Option values
+type openOptions struct {
+	readOnly   bool
+	keyFile    string
+	cipher     Cipher
+	compress   *bool
+	auditHooks []AuditHook
+	auditFiles []string
+
+	accessPolicies []AccessPolicy
+}
+
+// ReadOnly opens the store for reading only: Write, Delete, DeleteDB, Restore,
+// Purge and Import return ErrReadOnly, and no files or directories are ever
+// created (useful for audit tooling and crawls under restricted accounts).
+func ReadOnly() Option {
+	return func(o *openOptions) {
+		o.readOnly = true
+	}
+}
+
+// WithKeyFile reads the master key of file-backed stores from the key file at path
+// instead of CREDMGR_KEYFILE (CREDMGR_KEY still takes precedence). The file holds the
+// 32 raw key bytes or 64 hex chars and must have mode 0600, since the key stays out of
+// the environment and so is not inherited by child processes or visible in /proc.
+func WithKeyFile(path string) Option {
+	return func(o *openOptions) {
+		o.keyFile = path
+	}
+}
+
+// Cipher is the AEAD algorithm of a file-backed store
+type Cipher = filestore.Cipher
+
+// Ciphers for WithCipher
+const (
+	CipherAES256GCM         = filestore.CipherAES256GCM
+	CipherXChaCha20Poly1305 = filestore.CipherXChaCha20Poly1305
+)
+
+// ParseCipher returns the cipher named "aes-256-gcm" or "xchacha20-poly1305"
+func ParseCipher(name string) (Cipher, error) {
+	return filestore.ParseCipher(name)
+}
+
+// CipherEnv names an environment variable holding the cipher used by file-backed
+// stores when WithCipher is not given
+const CipherEnv = "CREDMGR_CIPHER"
+
+// WithCipher selects the cipher file-backed stores are written with. Without it a file
+// keeps the cipher it has (AES-256-GCM for new files). XChaCha20-Poly1305 is faster on
+// CPUs without AES instructions. The cipher is recorded in the file header and
+// detected on read, so an existing file is read whatever cipher it uses and converted
+// on its next write.
+func WithCipher(c Cipher) Option {
+	return func(o *openOptions) {
+		o.cipher = c
+	}
+}
+
+// CompressEnv names an environment variable that, when set to "1" or "true", turns on
+// compression for file-backed stores opened without WithCompression ("0" or "false"
+// turns it off)
+const CompressEnv = "CREDMGR_COMPRESS"
+
+// WithCompression turns gzip compression of file-backed stores on or off. Without it a
+// file keeps its current setting (off for new files). The plaintext is compressed before
+// encryption, which keeps stores of large secrets such as kubeconfigs and certificates
+// small; it is skipped when it does not make the data smaller, and reads decompress
+// automatically. The compressor's working memory cannot be wiped like the rest of the
+// decrypted data.
+func WithCompression(enabled bool) Option {
+	return func(o *openOptions) {
+		o.compress = &enabled
+	}
+}
+
+// AuditLogEnv names an environment variable that, when set, makes every CredManager
+// created by New, Default, Open or Wrap append audit events to the file it names.
+const AuditLogEnv = "CREDMGR_AUDIT_LOG"
+
+// Open creates a CredManager for path (see New for path behavior) configured by opts.
+//
+// Examples:
+//
+//	cm, err := credmgr.Open("")                                     // same as New("")
+//	cm, err := credmgr.Open("/srv/creds.enc", credmgr.ReadOnly())   // audit access
+func Open(path string, opts ...Option) (CredManager, error) {
+	o := collectOptions(opts)
+	if err := fileOptionsFromEnv(&o); err != nil {
+		return nil, err
+	}
+
+	cm, err := newCredManager(path, &o)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		migrateLegacy(cm, &o)
+	}
+	return wrap(cm, &o)
+}
+
+// OpenDir creates a CredManager that keeps each credential in its own encrypted file
+// under dir, named by a keyed hash of the credential name. Writes replace one small
+// file instead of re-encrypting the whole store, and List decrypts only names, so it
+// suits stores with many or large credentials. The master key is loaded as for New
+// (unlock methods are enrolled for dir like for a file path); WithCipher applies,
+// while compression and master key rotation (ReKey) are specific to single-file stores.
+//
+//	cm, err := credmgr.OpenDir(filepath.Join(home, ".fdot", "credentials.d"))
+func OpenDir(dir string, opts ...Option) (CredManager, error) {
+	o := collectOptions(opts)
+	if err := fileOptionsFromEnv(&o); err != nil {
+		return nil, err
+	}
+
+	key := func() ([]byte, error) {
+		return loadMasterKey(dir, o.keyFile)
+	}
+	var s *filestore.DirStore
+	if o.readOnly {
+		s = filestore.NewDirReadOnly(dir, key)
+	} else {
+		s = filestore.NewDir(dir, key)
+	}
+	if o.cipher != 0 {
+		s.SetCipher(o.cipher)
+	}
+	return wrap(NewFromStore(s), &o)
+}
+
+// fileOptionsFromEnv applies CipherEnv and CompressEnv unless the options set them
+func fileOptionsFromEnv(o *openOptions) error {
+	if name := os.Getenv(CipherEnv); name != "" && o.cipher == 0 {
+		c, err := ParseCipher(name)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", CipherEnv, err)
+		}
+		o.cipher = c
+	}
+	if value := os.Getenv(CompressEnv); value != "" && o.compress == nil {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", CompressEnv, err)
+		}
+		o.compress = &enabled
+	}
+	return nil
+}
+
+// Wrap applies opts to a CredManager from any backend (e.g. awssm.New or NewFromStore),
+// so read-only mode, access policies, audit hooks and events cover it as well.
+// ReadOnly only rejects mutating calls here; the backend itself is left untouched.
+//
+//	sm, err := awssm.New(ctx, awssm.Config{})
+//	cm, err := credmgr.Wrap(sm, credmgr.WithEvents(log))
+func Wrap(cm CredManager, opts ...Option) (CredManager, error) {
+	o := collectOptions(opts)
+	return wrap(cm, &o)
+}
+
+// collectOptions applies opts, plus the options enabled through the environment
+func collectOptions(opts []Option) openOptions {
+	var o openOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if logPath := os.Getenv(AuditLogEnv); logPath != "" {
+		WithAuditFile(logPath)(&o)
+	}
+	return o
+}
+
+// wrap layers the configured wrappers around cm; audit is outermost so that
+// denied operations are recorded too
+func wrap(cm CredManager, o *openOptions) (CredManager, error) {
+	if o.readOnly {
+		cm = &readOnlyCredManager{cm}
+	}
+	if len(o.accessPolicies) > 0 {
+		cm = newACLCredManager(cm, o)
+	}
+	if len(o.auditHooks) > 0 || len(o.auditFiles) > 0 {
+		return newAuditCredManager(cm, o)
+	}
+	return cm, nil
+}
+
+// readOnlyCredManager rejects every mutating operation with ErrReadOnly
+type readOnlyCredManager struct {
+	CredManager
+}
+
+func (r *readOnlyCredManager) Write(name string, data []byte) error           { return ErrReadOnly }
+func (r *readOnlyCredManager) WriteKey(name, key string) error                { return ErrReadOnly }
+func (r *readOnlyCredManager) WriteIfNotExists(string, []byte) error          { return ErrReadOnly }
+func (r *readOnlyCredManager) WriteUserCred(name string, cred UserCred) error { return ErrReadOnly }
+func (r *readOnlyCredManager) Delete(name string) error                       { return ErrReadOnly }
+func (r *readOnlyCredManager) Rename(oldName, newName string) error           { return ErrReadOnly }
+func (r *readOnlyCredManager) WriteBatch(map[string][]byte) error             { return ErrReadOnly }
+func (r *readOnlyCredManager) DeleteBatch([]string) error                     { return ErrReadOnly }
+func (r *readOnlyCredManager) DeleteDB() error                                { return ErrReadOnly }
+func (r *readOnlyCredManager) Restore(name string) error                      { return ErrReadOnly }
+func (r *readOnlyCredManager) Purge(name string) error                        { return ErrReadOnly }
+func (r *readOnlyCredManager) Import(io.Reader, string, MergePolicy) error    { return ErrReadOnly }
+
+// ReadUserCred leaves legacy entries in place instead of migrating them
+func (r *readOnlyCredManager) ReadUserCred(name string) (UserCred, error) {
+	if sm, ok := r.CredManager.(*storeCredManager); ok {
+		return sm.readUserCred(name, false)
+	}
+	return r.CredManager.ReadUserCred(name)
+}
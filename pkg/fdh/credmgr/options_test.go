@@ -0,0 +1,172 @@
+package credmgr
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenReadOnly(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	credPath := filepath.Join(t.TempDir(), "credentials.enc")
+	rw, err := Open(credPath)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := rw.WriteKey("token", "abc"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+
+	ro, err := Open(credPath, ReadOnly())
+	if err != nil {
+		t.Fatalf("Open(ReadOnly) failed: %v", err)
+	}
+
+	if got, err := ro.ReadKey("token"); err != nil || got != "abc" {
+		t.Errorf("ReadKey = %q, %v; want %q, nil", got, err, "abc")
+	}
+
+	mutations := map[string]func() error{
+		"Write":    func() error { return ro.Write("x", []byte("y")) },
+		"WriteKey": func() error { return ro.WriteKey("x", "y") },
+		"Delete":   func() error { return ro.Delete("token") },
+		"DeleteDB": ro.DeleteDB,
+		"Restore":  func() error { return ro.Restore("token") },
+		"Purge":    func() error { return ro.Purge("") },
+	}
+	for name, fn := range mutations {
+		if err := fn(); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s error = %v, want ErrReadOnly", name, err)
+		}
+	}
+
+	if _, err := rw.ReadKey("token"); err != nil {
+		t.Errorf("credential changed through read-only manager: %v", err)
+	}
+}
+
+func TestOpenReadOnlyCreatesNothing(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := filepath.Join(t.TempDir(), "missing")
+	ro, err := Open(filepath.Join(dir, "credentials.enc"), ReadOnly())
+	if err != nil {
+		t.Fatalf("Open(ReadOnly) failed: %v", err)
+	}
+
+	names, err := ro.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(names) != 0 {
+		t.Errorf("List = %v, want empty", names)
+	}
+	if _, err := ro.Read("anything"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Read error = %v, want ErrNotFound", err)
+	}
+
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("read-only open created %s", dir)
+	}
+}
+
+func TestWithCipher(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	credPath := filepath.Join(t.TempDir(), "credentials.enc")
+	cm, err := Open(credPath, WithCipher(CipherXChaCha20Poly1305))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := cm.WriteKey("token", "abc"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+
+	// The default cipher reads the file and converts it on the next write
+	def, err := Open(credPath)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if got, err := def.ReadKey("token"); err != nil || got != "abc" {
+		t.Errorf("ReadKey = %q, %v; want abc", got, err)
+	}
+
+	t.Setenv(CipherEnv, "des")
+	if _, err := Open(credPath); err == nil {
+		t.Errorf("Open with %s=des should fail", CipherEnv)
+	}
+}
+
+func TestWithCompression(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	credPath := filepath.Join(t.TempDir(), "credentials.enc")
+	cert := strings.Repeat("-----BEGIN CERTIFICATE-----\nMIIB...\n-----END CERTIFICATE-----\n", 100)
+	cm, err := Open(credPath, WithCompression(true))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if err := cm.WriteKey("cert", cert); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+	if info, err := os.Stat(credPath); err != nil || info.Size() >= int64(len(cert))/4 {
+		t.Errorf("compressed store size = %v, %v", info.Size(), err)
+	}
+
+	reopened, err := Open(credPath)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if got, err := reopened.ReadKey("cert"); err != nil || got != cert {
+		t.Errorf("ReadKey = %d bytes, %v", len(got), err)
+	}
+
+	t.Setenv(CompressEnv, "maybe")
+	if _, err := Open(credPath); err == nil {
+		t.Errorf("Open with %s=maybe should fail", CompressEnv)
+	}
+}
+
+func TestOpenDir(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	dir := filepath.Join(t.TempDir(), "credentials.d")
+	cm, err := OpenDir(dir)
+	if err != nil {
+		t.Fatalf("OpenDir failed: %v", err)
+	}
+	if err := cm.WriteUserCred("router", NewUnPw("admin", "pw")); err != nil {
+		t.Fatalf("WriteUserCred failed: %v", err)
+	}
+	if err := cm.WriteKey("token", "abc"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+	if err := cm.Delete("token"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	ro, err := OpenDir(dir, ReadOnly())
+	if err != nil {
+		t.Fatalf("OpenDir(ReadOnly) failed: %v", err)
+	}
+	if cred, err := ro.ReadUserCred("router"); err != nil || cred.Password() != "pw" {
+		t.Errorf("ReadUserCred = %v, %v", cred, err)
+	}
+	if names, err := ro.List(); err != nil || len(names) != 1 || names[0] != "router" {
+		t.Errorf("List = %v, %v; want [router]", names, err)
+	}
+	if trash, err := ro.ListTrash(); err != nil || len(trash) != 1 {
+		t.Errorf("ListTrash = %v, %v; want the deleted token", trash, err)
+	}
+	if report, err := ro.Verify(); err != nil || report.Entries != 1 || report.Trashed != 1 || !report.OK() {
+		t.Errorf("Verify = %+v, %v", report, err)
+	}
+}
@@ -0,0 +1,65 @@
+package credmgr
+
+import (
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/filestore"
+)
+
+// File-backed stores use envelope encryption: a save encrypts the credentials with a
+// new random data key, and the data key is wrapped with every master key the
+// file lists by key ID. While several keys are enrolled, saves keep the data key so
+// that all of them still open the file. Adding and removing master keys therefore
+// rewrites only the wrapped keys; ReKey, or removing all but the current key, also
+// replaces the data key.
+//
+// A rotation that keeps every client working looks like:
+//
+//	credmgr.AddMasterKey(path, newKey)                       // old and new key both open the file
+//	// roll newKey out to every client (CREDMGR_KEY, key files, ...)
+//	credmgr.RemoveMasterKey(path, credmgr.MasterKeyID(oldKey)) // run with the new key
+//
+// or, when all clients switch at once, ReKey(path, newKey).
+//
+// These functions load the current master key the same way New does. Unlock methods
+// (EnrollKeychain, EnrollFIDO2, EnrollYubiKey) protect one master key: after a
+// rotation, remove them and enroll them again with the new key in CREDMGR_KEY.
+
+// MasterKeyID returns the public identifier of a 32-byte master key, as listed by
+// MasterKeyIDs. It does not reveal the key.
+func MasterKeyID(key []byte) string {
+	return filestore.KeyID(key)
+}
+
+// MasterKeyIDs returns the IDs of the master keys that open the credential file at
+// dbPath. Files written by credmgr < 3.30 report none until they are next written.
+func MasterKeyIDs(dbPath string) ([]string, error) {
+	return openKeyStore(dbPath).KeyIDs()
+}
+
+// AddMasterKey lets key open the credential file at dbPath in addition to the
+// current master key, until one of them is removed with RemoveMasterKey.
+func AddMasterKey(dbPath string, key []byte) error {
+	return openKeyStore(dbPath).AddKey(key)
+}
+
+// RemoveMasterKey removes the master key with the given ID from the credential file
+// at dbPath. The currently loaded master key cannot be removed. While other keys stay
+// enrolled the data key is unchanged, so someone holding the removed key and an older
+// copy of the file can still read later writes; ReKey revokes it fully. Removing the
+// last other key seals the file under a fresh data key.
+func RemoveMasterKey(dbPath, keyID string) error {
+	return openKeyStore(dbPath).RemoveKey(keyID)
+}
+
+// ReKey makes newKey the only master key of the credential file at dbPath. The
+// credentials are sealed again under a new data key, and the backup is replaced by the
+// same file, so neither the old master key nor the old data key opens either one.
+func ReKey(dbPath string, newKey []byte) error {
+	return openKeyStore(dbPath).ReKey(newKey)
+}
+
+// openKeyStore opens the credential file at dbPath with the current master key
+func openKeyStore(dbPath string) *filestore.Store {
+	return filestore.New(dbPath, func() ([]byte, error) {
+		return loadMasterKey(dbPath, "")
+	})
+}
@@ -0,0 +1,53 @@
+package credmgr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestMasterKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	oldKey := bytes.Repeat([]byte{0xa5}, 32)
+	newKey := bytes.Repeat([]byte{0x5a}, 32)
+	t.Setenv("FDOT_CONFIG", filepath.Join(dir, "config.json"))
+	t.Setenv("CREDMGR_KEY", hex.EncodeToString(oldKey))
+
+	credPath := filepath.Join(dir, "credentials.enc")
+	cm, err := New(credPath)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := cm.WriteKey("token", "abc"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+
+	if err := AddMasterKey(credPath, newKey); err != nil {
+		t.Fatalf("AddMasterKey failed: %v", err)
+	}
+	ids, err := MasterKeyIDs(credPath)
+	if err != nil || len(ids) != 2 || ids[0] != MasterKeyID(oldKey) || ids[1] != MasterKeyID(newKey) {
+		t.Fatalf("MasterKeyIDs = %v, %v", ids, err)
+	}
+
+	// Switch to the new key and revoke the old one
+	t.Setenv("CREDMGR_KEY", hex.EncodeToString(newKey))
+	if err := RemoveMasterKey(credPath, MasterKeyID(oldKey)); err != nil {
+		t.Fatalf("RemoveMasterKey failed: %v", err)
+	}
+	reopened, _ := New(credPath)
+	if got, err := reopened.ReadKey("token"); err != nil || got != "abc" {
+		t.Errorf("ReadKey with new key = %q, %v", got, err)
+	}
+
+	// ReKey back to the old key in one step
+	if err := ReKey(credPath, oldKey); err != nil {
+		t.Fatalf("ReKey failed: %v", err)
+	}
+	reopened, _ = New(credPath)
+	if _, err := reopened.ReadKey("token"); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("ReadKey with replaced key error = %v, want ErrWrongKey", err)
+	}
+}
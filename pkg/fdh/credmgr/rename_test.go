@@ -0,0 +1,68 @@
+package credmgr
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func testRename(t *testing.T, cm CredManager) {
+	t.Helper()
+
+	cm.WriteKey("old", "secret")
+	cm.WriteKey("taken", "other")
+
+	if err := cm.Rename("old", "team/new"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if got, err := cm.ReadKey("team/new"); err != nil || got != "secret" {
+		t.Errorf("renamed value = %q, %v; want %q", got, err, "secret")
+	}
+	if ok, _ := cm.Exists("old"); ok {
+		t.Error("old name still exists after Rename")
+	}
+	// A rename is not a delete: nothing goes to the trash
+	if entries, _ := cm.ListTrash(); len(entries) != 0 {
+		t.Errorf("trash after Rename = %v, want empty", entries)
+	}
+
+	if err := cm.Rename("team/new", "taken"); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Rename onto a taken name error = %v, want ErrAlreadyExists", err)
+	}
+	if got, _ := cm.ReadKey("taken"); got != "other" {
+		t.Errorf("taken value after refused rename = %q, want %q", got, "other")
+	}
+	if err := cm.Rename("missing", "x"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Rename of a missing name error = %v, want ErrNotFound", err)
+	}
+	if err := cm.Rename("taken", "taken"); err != nil {
+		t.Errorf("Rename onto itself failed: %v", err)
+	}
+
+	cm.Delete("taken")
+	if err := cm.Rename("team/new", trashName("taken")); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Rename into the trash error = %v, want ErrInvalidFormat", err)
+	}
+	if err := cm.Rename(trashName("taken"), "back"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Rename out of the trash error = %v, want ErrNotFound", err)
+	}
+
+	names, _ := cm.List()
+	if !slices.Equal(names, []string{"team/new"}) {
+		t.Errorf("List after renames = %v", names)
+	}
+}
+
+func TestRenameFileStore(t *testing.T) {
+	cm, cleanup := setupTestEnv(t)
+	defer cleanup()
+	testRename(t, cm)
+}
+
+func TestRenameFallbackStore(t *testing.T) {
+	testRename(t, NewFromStore(mapStore{}))
+}
+
+func TestRenameMemory(t *testing.T) {
+	testRename(t, NewMemory())
+}
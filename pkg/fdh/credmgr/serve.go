@@ -0,0 +1,298 @@
+package credmgr
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// maxServeBody bounds the credential data accepted by a PUT
+const maxServeBody = 1 << 20
+
+// ServeOptions configures Serve
+type ServeOptions struct {
+	// CredManager is the store to serve; Default() is opened when nil
+	CredManager CredManager
+
+	// Token is the bearer token clients must send (Authorization: Bearer <token>).
+	// Required.
+	Token string
+
+	// CertFile and KeyFile hold the TLS certificate and key, unless TLSConfig
+	// provides certificates
+	CertFile, KeyFile string
+	TLSConfig         *tls.Config
+
+	// Allow lists path.Match patterns ("myapp-*") of the credentials clients may read,
+	// write and delete; all others are invisible. Required: nothing is served without it.
+	Allow []string
+
+	// ReadOnly rejects writes and deletes
+	ReadOnly bool
+}
+
+// Serve exposes the credentials named by opts.Allow over HTTPS on addr and blocks
+// until the server fails. Clients authenticate with opts.Token:
+//
+//	GET    /v1/credentials         {"names": [...]}, allowed credentials only
+//	GET    /v1/credentials/{name}  raw credential bytes
+//	PUT    /v1/credentials/{name}  store the request body
+//	DELETE /v1/credentials/{name}  move to the trash
+//
+// Errors are JSON {"error": "..."} with status 401 (bad token), 403 (not allowed,
+// read-only), 404 (not found) or 500.
+func Serve(addr string, opts ServeOptions) error {
+	if opts.TLSConfig == nil && (opts.CertFile == "" || opts.KeyFile == "") {
+		return errors.New("credmgr: Serve requires a TLS certificate (CertFile and KeyFile or TLSConfig)")
+	}
+	handler, err := NewHandler(opts)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.TLSConfig != nil {
+		tlsConfig = opts.TLSConfig.Clone()
+	}
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+	return srv.ListenAndServeTLS(opts.CertFile, opts.KeyFile)
+}
+
+// NewHandler returns the HTTP handler used by Serve, for callers that run their own
+// server. TLS is then the caller's responsibility.
+func NewHandler(opts ServeOptions) (http.Handler, error) {
+	if opts.Token == "" {
+		return nil, errors.New("credmgr: Serve requires a bearer token")
+	}
+	if len(opts.Allow) == 0 {
+		return nil, errors.New("credmgr: Serve requires at least one Allow pattern")
+	}
+	for _, pattern := range opts.Allow {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("credmgr: invalid Allow pattern %q: %w", pattern, err)
+		}
+	}
+	cm := opts.CredManager
+	if cm == nil {
+		var err error
+		if cm, err = Default(); err != nil {
+			return nil, err
+		}
+	}
+
+	h := &httpHandler{cm: cm, token: sha256.Sum256([]byte(opts.Token)), allow: opts.Allow, readOnly: opts.ReadOnly}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/credentials", h.list)
+	mux.HandleFunc("GET /v1/credentials/{name}", h.read)
+	mux.HandleFunc("PUT /v1/credentials/{name}", h.write)
+	mux.HandleFunc("DELETE /v1/credentials/{name}", h.delete)
+	return h.authenticate(mux), nil
+}
+
+// httpHandler serves a CredManager over HTTP
+type httpHandler struct {
+	cm       CredManager
+	token    [sha256.Size]byte
+	allow    []string
+	readOnly bool
+}
+
+// authenticate rejects requests without the bearer token
+func (h *httpHandler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Compare digests so the comparison time does not depend on the token length
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		sum := sha256.Sum256([]byte(token))
+		if !ok || subtle.ConstantTimeCompare(sum[:], h.token[:]) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="credmgr"`)
+			writeHTTPError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowed reports whether name matches one of the Allow patterns
+func (h *httpHandler) allowed(name string) bool {
+	for _, pattern := range h.allow {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// credentialName returns the requested name, or writes an error if it is not allowed
+func (h *httpHandler) credentialName(w http.ResponseWriter, r *http.Request) (string, bool) {
+	name := r.PathValue("name")
+	if !h.allowed(name) {
+		writeHTTPError(w, http.StatusForbidden, fmt.Sprintf("credential %q is not served", name))
+		return "", false
+	}
+	return name, true
+}
+
+func (h *httpHandler) list(w http.ResponseWriter, r *http.Request) {
+	names, err := h.cm.List()
+	if err != nil {
+		writeCredError(w, err)
+		return
+	}
+	allowed := []string{}
+	for _, name := range names {
+		if h.allowed(name) {
+			allowed = append(allowed, name)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"names": allowed})
+}
+
+func (h *httpHandler) read(w http.ResponseWriter, r *http.Request) {
+	name, ok := h.credentialName(w, r)
+	if !ok {
+		return
+	}
+	data, err := h.cm.Read(name)
+	if err != nil {
+		writeCredError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(data)
+}
+
+func (h *httpHandler) write(w http.ResponseWriter, r *http.Request) {
+	name, ok := h.credentialName(w, r)
+	if !ok {
+		return
+	}
+	if h.readOnly {
+		writeCredError(w, ErrReadOnly)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxServeBody))
+	if err != nil {
+		writeHTTPError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	if err := h.cm.Write(name, data); err != nil {
+		writeCredError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *httpHandler) delete(w http.ResponseWriter, r *http.Request) {
+	name, ok := h.credentialName(w, r)
+	if !ok {
+		return
+	}
+	if h.readOnly {
+		writeCredError(w, ErrReadOnly)
+		return
+	}
+	if err := h.cm.Delete(name); err != nil {
+		writeCredError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeCredError maps credmgr errors to HTTP status codes
+func writeCredError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeHTTPError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrForbidden), errors.Is(err, ErrReadOnly):
+		writeHTTPError(w, http.StatusForbidden, err.Error())
+	default:
+		writeHTTPError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func writeHTTPError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// SelfSignedCertificate returns a new self-signed TLS certificate for hosts (names or
+// IP addresses), for serving on a loopback address without a certificate file. It is
+// valid for a week and its private key exists only in memory; clients trust it by
+// pinning the certificate, which CertificatePEM encodes.
+func SelfSignedCertificate(hosts ...string) (tls.Certificate, error) {
+	if len(hosts) == 0 {
+		return tls.Certificate{}, errors.New("credmgr: SelfSignedCertificate requires at least one host")
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "credmgr serve"},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(7 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// CertificatePEM encodes cert's leaf certificate (not its key) as PEM, for clients
+// such as curl --cacert
+func CertificatePEM(cert tls.Certificate) []byte {
+	if len(cert.Certificate) == 0 {
+		return nil
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+}
@@ -0,0 +1,151 @@
+package credmgr
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// startServe runs NewHandler over TLS and returns its base URL and a client trusting it
+func startServe(t *testing.T, opts ServeOptions) (string, *http.Client) {
+	t.Helper()
+
+	handler, err := NewHandler(opts)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(handler)
+	srv.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv.URL, srv.Client()
+}
+
+// do sends an authenticated request and returns the status and body
+func do(t *testing.T, client *http.Client, method, url, token, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func TestServe(t *testing.T) {
+	cm := NewFromStore(mapStore{})
+	if err := cm.WriteKey("app-token", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.WriteKey("root-key", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	url, client := startServe(t, ServeOptions{CredManager: cm, Token: "t0ken", Allow: []string{"app-*"}})
+	creds := url + "/v1/credentials"
+
+	if code, _ := do(t, client, "GET", creds+"/app-token", "", ""); code != http.StatusUnauthorized {
+		t.Errorf("GET without token = %d, want 401", code)
+	}
+	if code, _ := do(t, client, "GET", creds+"/app-token", "wrong", ""); code != http.StatusUnauthorized {
+		t.Errorf("GET with wrong token = %d, want 401", code)
+	}
+	if code, body := do(t, client, "GET", creds+"/app-token", "t0ken", ""); code != http.StatusOK || body != "abc" {
+		t.Errorf("GET = %d %q, want 200 %q", code, body, "abc")
+	}
+	if code, _ := do(t, client, "GET", creds+"/root-key", "t0ken", ""); code != http.StatusForbidden {
+		t.Errorf("GET of name outside Allow = %d, want 403", code)
+	}
+	if code, _ := do(t, client, "GET", creds+"/app-missing", "t0ken", ""); code != http.StatusNotFound {
+		t.Errorf("GET of missing credential = %d, want 404", code)
+	}
+
+	if code, _ := do(t, client, "PUT", creds+"/app-new", "t0ken", "xyz"); code != http.StatusNoContent {
+		t.Errorf("PUT = %d, want 204", code)
+	}
+	if got, err := cm.ReadKey("app-new"); err != nil || got != "xyz" {
+		t.Errorf("ReadKey after PUT = %q, %v; want %q", got, err, "xyz")
+	}
+
+	code, body := do(t, client, "GET", creds, "t0ken", "")
+	var list struct{ Names []string }
+	if err := json.Unmarshal([]byte(body), &list); code != http.StatusOK || err != nil {
+		t.Fatalf("GET list = %d %q", code, body)
+	}
+	slices.Sort(list.Names)
+	if !slices.Equal(list.Names, []string{"app-new", "app-token"}) {
+		t.Errorf("list = %v, want [app-new app-token]", list.Names)
+	}
+
+	if code, _ := do(t, client, "DELETE", creds+"/app-new", "t0ken", ""); code != http.StatusNoContent {
+		t.Errorf("DELETE = %d, want 204", code)
+	}
+	if _, err := cm.ReadKey("app-new"); err == nil {
+		t.Error("credential still readable after DELETE")
+	}
+}
+
+func TestServeReadOnly(t *testing.T) {
+	cm := NewFromStore(mapStore{})
+	url, client := startServe(t, ServeOptions{CredManager: cm, Token: "t", Allow: []string{"*"}, ReadOnly: true})
+
+	if code, _ := do(t, client, "PUT", url+"/v1/credentials/x", "t", "v"); code != http.StatusForbidden {
+		t.Errorf("PUT on read-only server = %d, want 403", code)
+	}
+}
+
+func TestNewHandlerRequiresTokenAndAllow(t *testing.T) {
+	cm := NewFromStore(mapStore{})
+	if _, err := NewHandler(ServeOptions{CredManager: cm, Allow: []string{"*"}}); err == nil {
+		t.Error("NewHandler without token should fail")
+	}
+	if _, err := NewHandler(ServeOptions{CredManager: cm, Token: "t"}); err == nil {
+		t.Error("NewHandler without Allow should fail")
+	}
+	if err := Serve("127.0.0.1:0", ServeOptions{CredManager: cm, Token: "t", Allow: []string{"*"}}); err == nil {
+		t.Error("Serve without TLS certificate should fail")
+	}
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	if _, err := SelfSignedCertificate(); err == nil {
+		t.Error("SelfSignedCertificate without hosts should fail")
+	}
+	cert, err := SelfSignedCertificate("127.0.0.1", "localhost")
+	if err != nil {
+		t.Fatalf("SelfSignedCertificate failed: %v", err)
+	}
+
+	handler, err := NewHandler(ServeOptions{CredManager: NewFromStore(mapStore{}), Token: "t", Allow: []string{"*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(handler)
+	srv.TLS = &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}
+	srv.StartTLS()
+	defer srv.Close()
+
+	// A client pinning the PEM certificate verifies the server on its IP address
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(CertificatePEM(cert)) {
+		t.Fatal("CertificatePEM did not produce a usable certificate")
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	if code, body := do(t, client, "GET", srv.URL+"/v1/credentials", "t", ""); code != http.StatusOK {
+		t.Errorf("GET with pinned certificate = %d %s, want 200", code, body)
+	}
+}
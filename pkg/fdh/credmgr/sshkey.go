@@ -0,0 +1,115 @@
+package credmgr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// sshKeyType identifies an SSH private key login in the typed format
+const sshKeyType = "sshkey"
+
+// SSHKeyCred is a UserCred that logs in with an SSH private key instead of a
+// password. ReadUserCred returns one for entries stored with NewSSHKey; Password
+// returns "" so the key's passphrase is never sent as a password.
+type SSHKeyCred interface {
+	UserCred
+
+	// PrivateKey returns a copy of the PEM or OpenSSH private key; the caller wipes it
+	PrivateKey() []byte
+	// Passphrase returns a copy of the key's passphrase, empty for an unencrypted key;
+	// the caller wipes it
+	Passphrase() []byte
+}
+
+// sshKeyCred keeps the key and passphrase XOR-encoded like obfuscatedUserCred
+type sshKeyCred struct {
+	username       string
+	obfuscatedKey  []byte
+	obfuscatedPass []byte
+	obfuscationKey []byte
+}
+
+// sshKeyRecord is the stored form of an SSHKeyCred:
+//
+//	{"type":"sshkey","username":"admin","private_key":"<base64>","passphrase":"<base64>"}
+type sshKeyRecord struct {
+	Type       string `json:"type"`
+	Username   string `json:"username"`
+	PrivateKey []byte `json:"private_key"`
+	Passphrase []byte `json:"passphrase,omitempty"`
+}
+
+// NewSSHKey creates a key-based login credential from a PEM or OpenSSH private key
+// and its passphrase (nil for an unencrypted key). Neither slice is retained. The key
+// is checked to be PEM-armoured, not parsed; a wrong passphrase surfaces at login.
+func NewSSHKey(username string, privateKey, passphrase []byte) (SSHKeyCred, error) {
+	if !isPrivateKey(privateKey) {
+		return nil, fmt.Errorf("%w: not a PEM or OpenSSH private key", ErrInvalidFormat)
+	}
+	return newSSHKeyCred(username, bytes.TrimSpace(privateKey), passphrase), nil
+}
+
+func newSSHKeyCred(username string, privateKey, passphrase []byte) *sshKeyCred {
+	key := generateObfuscationKey(username)
+	return &sshKeyCred{
+		username:       username,
+		obfuscatedKey:  xorEncode(privateKey, key),
+		obfuscatedPass: xorEncode(passphrase, key),
+		obfuscationKey: key,
+	}
+}
+
+// Username returns the username.
+func (k *sshKeyCred) Username() string {
+	return k.username
+}
+
+// Password returns "": key logins have no password.
+func (k *sshKeyCred) Password() string {
+	return ""
+}
+
+// PrivateKey returns a copy of the decoded private key.
+func (k *sshKeyCred) PrivateKey() []byte {
+	return xorEncode(k.obfuscatedKey, k.obfuscationKey)
+}
+
+// Passphrase returns a copy of the decoded passphrase.
+func (k *sshKeyCred) Passphrase() []byte {
+	return xorEncode(k.obfuscatedPass, k.obfuscationKey)
+}
+
+// Wipe zeroes the key and passphrase and releases them.
+func (k *sshKeyCred) Wipe() {
+	securemem.Wipe(k.obfuscatedKey)
+	securemem.Wipe(k.obfuscatedPass)
+	k.obfuscatedKey, k.obfuscatedPass = nil, nil
+}
+
+// marshal converts the credential to the typed storage format; the decoded key and
+// passphrase are wiped after encoding
+func (k *sshKeyCred) marshal() []byte {
+	privateKey, passphrase := k.PrivateKey(), k.Passphrase()
+	defer securemem.Wipe(privateKey)
+	defer securemem.Wipe(passphrase)
+
+	// Marshaling strings and byte slices cannot fail
+	data, _ := json.Marshal(sshKeyRecord{Type: sshKeyType, Username: k.username, PrivateKey: privateKey, Passphrase: passphrase})
+	return data
+}
+
+// unmarshalSSHKeyCred decodes data if it is an SSH key record; data is not retained
+func unmarshalSSHKeyCred(data []byte) (*sshKeyCred, bool) {
+	var rec sshKeyRecord
+	if !bytes.HasPrefix(data, []byte("{")) || json.Unmarshal(data, &rec) != nil || rec.Type != sshKeyType {
+		securemem.Wipe(rec.PrivateKey)
+		securemem.Wipe(rec.Passphrase)
+		return nil, false
+	}
+	defer securemem.Wipe(rec.PrivateKey)
+	defer securemem.Wipe(rec.Passphrase)
+	return newSSHKeyCred(rec.Username, rec.PrivateKey, rec.Passphrase), true
+}
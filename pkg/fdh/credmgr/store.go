@@ -0,0 +1,330 @@
+package credmgr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// Store is a minimal raw-bytes credential backend.
+// NewFromStore layers the full CredManager API on top of a Store, so additional
+// backends (including ones outside this package, e.g. cloud secret managers) only
+// need to implement storage.
+type Store interface {
+	// Read retrieves raw credential bytes by name; missing names must return an error wrapping ErrNotFound.
+	Read(name string) ([]byte, error)
+
+	// Write stores raw credential bytes with the given name.
+	Write(name string, data []byte) error
+
+	// Delete removes a credential by name; missing names must return an error wrapping ErrNotFound.
+	Delete(name string) error
+
+	// DeleteDB removes all credentials held by the store.
+	DeleteDB() error
+
+	// List returns all credential names.
+	List() ([]string, error)
+}
+
+// NewFromStore returns a CredManager backed by the given Store.
+func NewFromStore(s Store) CredManager {
+	return &storeCredManager{Store: s}
+}
+
+// storeCredManager implements CredManager on top of a Store
+type storeCredManager struct {
+	Store
+}
+
+// readerInto is implemented by stores that can copy a credential into a caller
+// buffer without an intermediate allocation
+type readerInto interface {
+	ReadInto(name string, buf []byte) (int, error)
+}
+
+// Read retrieves a credential. Trash entries are only reachable through Restore.
+func (sm *storeCredManager) Read(name string) ([]byte, error) {
+	if isTrashName(name) {
+		return nil, fmt.Errorf("credential %q %w", name, ErrNotFound)
+	}
+	return sm.Store.Read(name)
+}
+
+// Write stores a credential. Names in the trash namespace are refused.
+func (sm *storeCredManager) Write(name string, data []byte) error {
+	if err := checkWritableName(name); err != nil {
+		return err
+	}
+	return sm.Store.Write(name, data)
+}
+
+// checkWritableName refuses the names that would land in the trash namespace
+func checkWritableName(name string) error {
+	if isTrashName(name) {
+		return fmt.Errorf("%w: invalid credential name %q", ErrInvalidFormat, name)
+	}
+	return nil
+}
+
+// ReadInto copies a credential into buf.
+func (sm *storeCredManager) ReadInto(name string, buf []byte) (int, error) {
+	if isTrashName(name) {
+		return 0, fmt.Errorf("credential %q %w", name, ErrNotFound)
+	}
+	if r, ok := sm.Store.(readerInto); ok {
+		return r.ReadInto(name, buf)
+	}
+	data, err := sm.Read(name)
+	if err != nil {
+		return 0, err
+	}
+	if len(buf) < len(data) {
+		return len(data), fmt.Errorf("credential %q needs %d bytes: %w", name, len(data), io.ErrShortBuffer)
+	}
+	return copy(buf, data), nil
+}
+
+// Exists reports whether name is stored, without copying its value.
+func (sm *storeCredManager) Exists(name string) (bool, error) {
+	if isTrashName(name) {
+		return false, nil
+	}
+	_, err := sm.ReadInto(name, nil)
+	switch {
+	case err == nil, errors.Is(err, io.ErrShortBuffer):
+		return true, nil
+	case errors.Is(err, ErrNotFound):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// WriteIfNotExists stores data unless name is taken, atomically if the Store supports Update.
+func (sm *storeCredManager) WriteIfNotExists(name string, data []byte) error {
+	if err := checkWritableName(name); err != nil {
+		return err
+	}
+	if u, ok := sm.Store.(updater); ok {
+		return u.Update(func(creds map[string][]byte) error {
+			if _, exists := creds[name]; exists {
+				return fmt.Errorf("credential %q %w", name, ErrAlreadyExists)
+			}
+			creds[name] = bytes.Clone(data)
+			return nil
+		})
+	}
+
+	exists, err := sm.Exists(name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("credential %q %w", name, ErrAlreadyExists)
+	}
+	return sm.Write(name, data)
+}
+
+// ReadKey retrieves a credential key as a string.
+func (sm *storeCredManager) ReadKey(name string) (string, error) {
+	data, err := sm.Read(name)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// WriteKey stores a string credential key.
+func (sm *storeCredManager) WriteKey(name, key string) error {
+	return sm.Write(name, []byte(key))
+}
+
+// ReadUserCred retrieves a username/password credential, or an SSHKeyCred for keys
+// stored with NewSSHKey. Entries still in the legacy username:password format are
+// rewritten in the typed format.
+func (sm *storeCredManager) ReadUserCred(name string) (UserCred, error) {
+	return sm.readUserCred(name, true)
+}
+
+// readUserCred reads a UserCred, migrating a legacy entry if migrate is set.
+// Migration is best effort: the credential is returned even if the rewrite
+// fails (e.g. on a read-only store), and is retried on the next read.
+func (sm *storeCredManager) readUserCred(name string, migrate bool) (UserCred, error) {
+	data, err := sm.Read(name)
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := unmarshalSSHKeyCred(data); ok {
+		return key, nil
+	}
+
+	cred, legacy, err := unmarshalUserCred(data)
+	if err != nil {
+		return nil, err
+	}
+	if legacy && migrate {
+		cred.migrated = sm.Write(name, cred.marshal()) == nil
+	}
+	return cred, nil
+}
+
+// WriteUserCred stores a username/password credential.
+func (sm *storeCredManager) WriteUserCred(name string, cred UserCred) error {
+	switch c := cred.(type) {
+	case *obfuscatedUserCred:
+		return sm.Write(name, c.marshal())
+	case *sshKeyCred:
+		return sm.Write(name, c.marshal())
+	case SSHKeyCred:
+		privateKey, passphrase := c.PrivateKey(), c.Passphrase()
+		defer securemem.Wipe(privateKey)
+		defer securemem.Wipe(passphrase)
+		return sm.Write(name, newSSHKeyCred(c.Username(), privateKey, passphrase).marshal())
+	}
+	reconstructed := NewUnPw(cred.Username(), cred.Password()).(*obfuscatedUserCred)
+	return sm.Write(name, reconstructed.marshal())
+}
+
+// Export writes all credentials to w as an archive encrypted with passphrase.
+func (sm *storeCredManager) Export(w io.Writer, passphrase string) error {
+	return exportCredentials(sm, w, passphrase)
+}
+
+// Import loads credentials from an archive created by Export.
+func (sm *storeCredManager) Import(r io.Reader, passphrase string, policy MergePolicy) error {
+	return importCredentials(sm, r, passphrase, policy)
+}
+
+// Delete moves a credential into the trash. It can be brought back with
+// Restore until TrashRetention has passed.
+func (sm *storeCredManager) Delete(name string) error {
+	return softDelete(sm.Store, name)
+}
+
+// Rename moves a credential to newName, in one save if the Store supports it. Otherwise
+// the new name is written before the old one is removed, so an interrupted rename
+// leaves a copy rather than losing the credential.
+func (sm *storeCredManager) Rename(oldName, newName string) error {
+	if isTrashName(oldName) {
+		return fmt.Errorf("credential %q %w", oldName, ErrNotFound)
+	}
+	if isTrashName(newName) || newName == "" {
+		return fmt.Errorf("%w: invalid credential name %q", ErrInvalidFormat, newName)
+	}
+
+	if u, ok := sm.Store.(updater); ok {
+		return u.Update(func(creds map[string][]byte) error {
+			data, exists := creds[oldName]
+			if !exists {
+				return fmt.Errorf("credential %q %w", oldName, ErrNotFound)
+			}
+			if oldName == newName {
+				return nil
+			}
+			if _, exists := creds[newName]; exists {
+				return fmt.Errorf("credential %q %w", newName, ErrAlreadyExists)
+			}
+			creds[newName] = data
+			delete(creds, oldName)
+			return nil
+		})
+	}
+
+	data, err := sm.Read(oldName)
+	if err != nil {
+		return err
+	}
+	if oldName == newName {
+		return nil
+	}
+	if err := sm.WriteIfNotExists(newName, data); err != nil {
+		return err
+	}
+	return sm.Store.Delete(oldName)
+}
+
+// updater is implemented by Stores that can apply several changes in one save
+type updater interface {
+	Update(fn func(creds map[string][]byte) error) error
+}
+
+// WriteBatch stores several credentials, in one save if the Store supports it.
+func (sm *storeCredManager) WriteBatch(creds map[string][]byte) error {
+	for name := range creds {
+		if err := checkWritableName(name); err != nil {
+			return err
+		}
+	}
+	if u, ok := sm.Store.(updater); ok {
+		return u.Update(func(stored map[string][]byte) error {
+			for name, data := range creds {
+				// Cloned so wiping the map after the update never touches the caller's buffers
+				stored[name] = bytes.Clone(data)
+			}
+			return nil
+		})
+	}
+	for _, name := range slices.Sorted(maps.Keys(creds)) {
+		if err := sm.Write(name, creds[name]); err != nil {
+			return fmt.Errorf("failed to write %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// DeleteBatch moves several credentials into the trash, in one save if the Store supports it.
+func (sm *storeCredManager) DeleteBatch(names []string) error {
+	return softDeleteBatch(sm.Store, names)
+}
+
+// List returns all credential names, excluding trash entries.
+func (sm *storeCredManager) List() ([]string, error) {
+	names, err := sm.Store.List()
+	if err != nil {
+		return nil, err
+	}
+	return visibleNames(names), nil
+}
+
+// ListFiltered returns the credential names matching pattern.
+func (sm *storeCredManager) ListFiltered(pattern string) ([]string, error) {
+	names, err := sm.List()
+	if err != nil {
+		return nil, err
+	}
+	return filterNames(names, pattern)
+}
+
+// Restore moves a deleted credential back out of the trash.
+func (sm *storeCredManager) Restore(name string) error {
+	return restoreFromTrash(sm.Store, name)
+}
+
+// ListTrash returns deleted credentials that can still be restored.
+func (sm *storeCredManager) ListTrash() ([]TrashEntry, error) {
+	return listTrash(sm.Store)
+}
+
+// Purge permanently removes name from the trash, or empties it if name is "".
+func (sm *storeCredManager) Purge(name string) error {
+	return purgeTrash(sm.Store, name)
+}
+
+// reloader is implemented by Stores that cache credentials in memory
+type reloader interface {
+	Reload() error
+}
+
+// Reload discards cached credentials if the Store caches them; live backends need nothing.
+func (sm *storeCredManager) Reload() error {
+	if r, ok := sm.Store.(reloader); ok {
+		return r.Reload()
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package credmgr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// mapStore is a minimal Store used to exercise NewFromStore
+type mapStore map[string][]byte
+
+func (m mapStore) Read(name string) ([]byte, error) {
+	data, ok := m[name]
+	if !ok {
+		return nil, fmt.Errorf("credential %q %w", name, ErrNotFound)
+	}
+	return data, nil
+}
+
+func (m mapStore) Write(name string, data []byte) error {
+	m[name] = data
+	return nil
+}
+
+func (m mapStore) Delete(name string) error {
+	if _, ok := m[name]; !ok {
+		return fmt.Errorf("credential %q %w", name, ErrNotFound)
+	}
+	delete(m, name)
+	return nil
+}
+
+func (m mapStore) DeleteDB() error {
+	clear(m)
+	return nil
+}
+
+func (m mapStore) List() ([]string, error) {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func TestNewFromStore(t *testing.T) {
+	cm := NewFromStore(mapStore{})
+
+	if err := cm.WriteKey("token", "abc"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+	if key, err := cm.ReadKey("token"); err != nil || key != "abc" {
+		t.Errorf("ReadKey = %q, %v; want %q", key, err, "abc")
+	}
+
+	if err := cm.WriteUserCred("ssh", NewUnPw("alice", "pa:ss")); err != nil {
+		t.Fatalf("WriteUserCred failed: %v", err)
+	}
+	cred, err := cm.ReadUserCred("ssh")
+	if err != nil {
+		t.Fatalf("ReadUserCred failed: %v", err)
+	}
+	if cred.Username() != "alice" || cred.Password() != "pa:ss" {
+		t.Errorf("ReadUserCred = %s/%s, want alice/pa:ss", cred.Username(), cred.Password())
+	}
+
+	if _, err := cm.ReadKey("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReadKey(missing) error = %v, want ErrNotFound", err)
+	}
+
+	buf := make([]byte, 8)
+	if n, err := cm.ReadInto("token", buf); err != nil || string(buf[:n]) != "abc" {
+		t.Errorf("ReadInto = %q, %v; want %q", buf[:n], err, "abc")
+	}
+	if n, err := cm.ReadInto("token", buf[:1]); !errors.Is(err, io.ErrShortBuffer) || n != 3 {
+		t.Errorf("ReadInto(short) = %d, %v; want 3, io.ErrShortBuffer", n, err)
+	}
+
+	var archive bytes.Buffer
+	if err := cm.Export(&archive, "pass"); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	dst := NewFromStore(mapStore{})
+	if err := dst.Import(&archive, "pass", MergeOverwrite); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if names, _ := dst.List(); len(names) != 2 {
+		t.Errorf("Imported %d credentials, want 2", len(names))
+	}
+}
+
+func TestReadUserCredMigratesLegacy(t *testing.T) {
+	store := mapStore{"ssh": []byte("alice:pa:ss")}
+
+	var ops []AuditOp
+	cm, err := Wrap(NewFromStore(store), WithAudit(func(e AuditEvent) { ops = append(ops, e.Op) }))
+	if err != nil {
+		t.Fatalf("Wrap failed: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		cred, err := cm.ReadUserCred("ssh")
+		if err != nil {
+			t.Fatalf("ReadUserCred failed: %v", err)
+		}
+		if cred.Username() != "alice" || cred.Password() != "pa:ss" {
+			t.Errorf("ReadUserCred = %s/%s, want alice/pa:ss", cred.Username(), cred.Password())
+		}
+	}
+
+	if !bytes.HasPrefix(store["ssh"], []byte(`{"type":"userpass"`)) {
+		t.Errorf("stored entry = %q, want the typed format", store["ssh"])
+	}
+	want := []AuditOp{AuditMigrate, AuditRead, AuditRead}
+	if fmt.Sprint(ops) != fmt.Sprint(want) {
+		t.Errorf("audit ops = %v, want %v (migration recorded once)", ops, want)
+	}
+}
+
+func TestReadUserCredReadOnlyKeepsLegacy(t *testing.T) {
+	store := mapStore{"ssh": []byte("alice:secret")}
+
+	cm, err := Wrap(NewFromStore(store), ReadOnly())
+	if err != nil {
+		t.Fatalf("Wrap failed: %v", err)
+	}
+	if cred, err := cm.ReadUserCred("ssh"); err != nil || cred.Password() != "secret" {
+		t.Fatalf("ReadUserCred = %v, %v", cred, err)
+	}
+	if string(store["ssh"]) != "alice:secret" {
+		t.Errorf("read-only ReadUserCred rewrote the entry to %q", store["ssh"])
+	}
+}
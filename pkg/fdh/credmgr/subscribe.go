@@ -0,0 +1,150 @@
+package credmgr
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// DefaultSubscribeInterval is the poll interval used when Subscribe is given zero
+const DefaultSubscribeInterval = 2 * time.Second
+
+// ChangeOp names the kind of change reported in a ChangeEvent
+type ChangeOp string
+
+// Credential changes
+const (
+	ChangeCreate ChangeOp = "create"
+	ChangeUpdate ChangeOp = "update"
+	ChangeDelete ChangeOp = "delete" // also sent when a credential is moved to the trash
+)
+
+// ChangeEvent reports one credential that was created, changed or removed.
+// It never carries the value; re-read the credential to pick up the change.
+// Like AuditEvent it is a plain struct that can be forwarded as an eventstream event.
+type ChangeEvent struct {
+	Time time.Time `json:"time"`
+	Op   ChangeOp  `json:"op"`
+	Name string    `json:"name"`
+}
+
+// subscribe polls cm every interval and sends a ChangeEvent for every credential
+// that appeared, changed or disappeared since the previous poll. Only a keyed hash
+// of each value is kept between polls. Credentials cm refuses to read (an access
+// policy) are ignored. The first snapshot is taken before returning, so its error is
+// the caller's; later polls that fail are retried at the next interval.
+func subscribe(ctx context.Context, cm CredManager, interval time.Duration) (<-chan ChangeEvent, error) {
+	if interval < 0 {
+		return nil, fmt.Errorf("invalid subscribe interval %s", interval)
+	}
+	if interval == 0 {
+		interval = DefaultSubscribeInterval
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate subscription key: %w", err)
+	}
+	prev, err := snapshot(cm, key)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan ChangeEvent)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			cur, err := snapshot(cm, key)
+			if err != nil {
+				continue
+			}
+			for _, e := range diffSnapshots(prev, cur) {
+				select {
+				case ch <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+			prev = cur
+		}
+	}()
+	return ch, nil
+}
+
+// snapshot maps every readable credential to an HMAC of its value
+func snapshot(cm CredManager, key []byte) (map[string][sha256.Size]byte, error) {
+	names, err := cm.List()
+	if err != nil {
+		return nil, err
+	}
+
+	// ReadInto one reused buffer, so no copy of a value outlives the poll
+	buf := make([]byte, 256)
+	defer func() { securemem.Wipe(buf) }()
+
+	snap := make(map[string][sha256.Size]byte, len(names))
+	for _, name := range names {
+		n, err := cm.ReadInto(name, buf)
+		if errors.Is(err, io.ErrShortBuffer) {
+			securemem.Wipe(buf)
+			buf = make([]byte, n)
+			n, err = cm.ReadInto(name, buf)
+		}
+		if errors.Is(err, ErrForbidden) || errors.Is(err, ErrNotFound) {
+			continue // not ours to watch, or deleted since List
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q: %w", name, err)
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write(buf[:n])
+		snap[name] = [sha256.Size]byte(mac.Sum(nil))
+	}
+	return snap, nil
+}
+
+// diffSnapshots returns the changes from prev to cur, sorted by name
+func diffSnapshots(prev, cur map[string][sha256.Size]byte) []ChangeEvent {
+	now := time.Now()
+	var events []ChangeEvent
+	for name, sum := range cur {
+		old, existed := prev[name]
+		switch {
+		case !existed:
+			events = append(events, ChangeEvent{Time: now, Op: ChangeCreate, Name: name})
+		case old != sum:
+			events = append(events, ChangeEvent{Time: now, Op: ChangeUpdate, Name: name})
+		}
+	}
+	for name := range prev {
+		if _, ok := cur[name]; !ok {
+			events = append(events, ChangeEvent{Time: now, Op: ChangeDelete, Name: name})
+		}
+	}
+	slices.SortFunc(events, func(a, b ChangeEvent) int { return strings.Compare(a.Name, b.Name) })
+	return events
+}
+
+// Subscribe reports credential changes made through any manager or process,
+// such as the credmgr CLI, by polling the Store every interval.
+func (sm *storeCredManager) Subscribe(ctx context.Context, interval time.Duration) (<-chan ChangeEvent, error) {
+	return subscribe(ctx, sm, interval)
+}
@@ -0,0 +1,159 @@
+package credmgr
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// nextChange waits for one event from ch
+func nextChange(t *testing.T, ch <-chan ChangeEvent) ChangeEvent {
+	t.Helper()
+	select {
+	case e, ok := <-ch:
+		if !ok {
+			t.Fatal("subscription closed early")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a change event")
+	}
+	return ChangeEvent{}
+}
+
+func TestSubscribeSeesOtherWriters(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	path := filepath.Join(t.TempDir(), "creds.enc")
+	watcher, err := New(path)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := watcher.WriteKey("existing", "1"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := watcher.Subscribe(ctx, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	// A second manager on the same file stands in for the credmgr CLI
+	writer, err := New(path)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	steps := []struct {
+		do   func() error
+		want ChangeOp
+	}{
+		{func() error { return writer.WriteKey("token", "a") }, ChangeCreate},
+		{func() error { return writer.WriteKey("token", "b") }, ChangeUpdate},
+		{func() error { return writer.Delete("token") }, ChangeDelete},
+		{func() error { return writer.Restore("token") }, ChangeCreate},
+	}
+	for i, step := range steps {
+		if err := step.do(); err != nil {
+			t.Fatalf("step %d failed: %v", i, err)
+		}
+		if e := nextChange(t, ch); e.Op != step.want || e.Name != "token" {
+			t.Errorf("step %d event = %+v, want %s token", i, e, step.want)
+		}
+	}
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestSubscribeHonoursAccessPolicy(t *testing.T) {
+	deny := WithAccessPolicy(func(req AccessRequest) error {
+		if req.Name == "secret" {
+			return errors.New("denied")
+		}
+		return nil
+	})
+	store := newLockedMapStore()
+	cm, err := Wrap(NewFromStore(store), deny)
+	if err != nil {
+		t.Fatalf("Wrap failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := cm.Subscribe(ctx, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	if err := store.Write("secret", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Write("public", []byte("y")); err != nil {
+		t.Fatal(err)
+	}
+	if e := nextChange(t, ch); e.Name != "public" || e.Op != ChangeCreate {
+		t.Errorf("event = %+v, want create public only", e)
+	}
+}
+
+func TestSubscribeAudited(t *testing.T) {
+	var ops []AuditOp
+	cm, err := Wrap(NewFromStore(newLockedMapStore()), WithAudit(func(e AuditEvent) { ops = append(ops, e.Op) }))
+	if err != nil {
+		t.Fatalf("Wrap failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := cm.Subscribe(ctx, -time.Second); err == nil {
+		t.Error("Subscribe with a negative interval should fail")
+	}
+	if len(ops) != 1 || ops[0] != AuditSubscribe {
+		t.Errorf("audited ops = %v, want [subscribe]", ops)
+	}
+}
+
+// lockedMapStore is a mapStore that can be written while a subscription polls it
+type lockedMapStore struct {
+	mu sync.Mutex
+	m  mapStore
+}
+
+func newLockedMapStore() *lockedMapStore {
+	return &lockedMapStore{m: mapStore{}}
+}
+
+func (s *lockedMapStore) Read(name string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.Read(name)
+}
+
+func (s *lockedMapStore) Write(name string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.Write(name, data)
+}
+
+func (s *lockedMapStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.Delete(name)
+}
+
+func (s *lockedMapStore) DeleteDB() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.DeleteDB()
+}
+
+func (s *lockedMapStore) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.List()
+}
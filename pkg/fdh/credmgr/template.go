@@ -0,0 +1,75 @@
+package credmgr
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/nzions/fdot/pkg/fdh"
+)
+
+// templateFuncs returns the lookup functions available to templates:
+//
+//	{{ secret "name" }}    the credential as a string (ReadKey)
+//	{{ username "name" }}  the username of a user credential
+//	{{ password "name" }}  the password of a user credential
+func templateFuncs(cm CredManager) template.FuncMap {
+	return template.FuncMap{
+		"secret": cm.ReadKey,
+		"username": func(name string) (string, error) {
+			cred, err := cm.ReadUserCred(name)
+			if err != nil {
+				return "", err
+			}
+			return cred.Username(), nil
+		},
+		"password": func(name string) (string, error) {
+			cred, err := cm.ReadUserCred(name)
+			if err != nil {
+				return "", err
+			}
+			return cred.Password(), nil
+		},
+	}
+}
+
+// RenderTemplate executes the Go template text with secret lookups from cm and writes
+// the result to w. Nothing is written if a lookup fails.
+//
+//	machine api.example.com login {{ username "example-api" }} password {{ password "example-api" }}
+func RenderTemplate(cm CredManager, w io.Writer, text string) error {
+	return renderTemplate(cm, w, "template", text)
+}
+
+// RenderTemplateFile renders the template in templatePath to outputPath, which is
+// replaced atomically and readable only by the current user
+func RenderTemplateFile(cm CredManager, templatePath, outputPath string) error {
+	text, err := os.ReadFile(templatePath)
+	if err != nil {
+		return fmt.Errorf("failed to read template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := renderTemplate(cm, &buf, filepath.Base(templatePath), string(text)); err != nil {
+		return err
+	}
+	if err := fdh.WritePrivateFileAtomic(outputPath, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", outputPath, err)
+	}
+	return nil
+}
+
+func renderTemplate(cm CredManager, w io.Writer, name, text string) error {
+	tmpl, err := template.New(name).Funcs(templateFuncs(cm)).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return fmt.Errorf("failed to parse template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return fmt.Errorf("failed to render template: %w", err)
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
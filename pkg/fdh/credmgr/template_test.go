@@ -0,0 +1,63 @@
+package credmgr
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	cm := NewFromStore(mapStore{})
+	if err := cm.WriteKey("api-token", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.WriteUserCred("example", NewUnPw("alice", "pw")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err := RenderTemplate(cm, &buf, `token={{ secret "api-token" }} login {{ username "example" }} password {{ password "example" }}`)
+	if err != nil {
+		t.Fatalf("RenderTemplate failed: %v", err)
+	}
+	if want := "token=abc login alice password pw"; buf.String() != want {
+		t.Errorf("RenderTemplate = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	err = RenderTemplate(cm, &buf, `before {{ secret "missing" }}`)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("RenderTemplate with missing secret error = %v, want ErrNotFound", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("partial output written on error: %q", buf.String())
+	}
+}
+
+func TestRenderTemplateFile(t *testing.T) {
+	cm := NewFromStore(mapStore{})
+	if err := cm.WriteKey("api-token", "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	tmpl := filepath.Join(dir, "app.conf.tmpl")
+	out := filepath.Join(dir, "app.conf")
+	if err := os.WriteFile(tmpl, []byte("token = {{ secret \"api-token\" }}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RenderTemplateFile(cm, tmpl, out); err != nil {
+		t.Fatalf("RenderTemplateFile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil || string(data) != "token = abc\n" {
+		t.Errorf("output = %q, %v", data, err)
+	}
+	if info, err := os.Stat(out); err == nil && runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("output mode = %v, want 0600", info.Mode().Perm())
+	}
+}
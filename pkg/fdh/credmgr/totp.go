@@ -0,0 +1,191 @@
+package credmgr
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// totpType identifies a TOTP seed entry in the typed format
+const totpType = "totp"
+
+// TOTP is a time-based one-time password seed (RFC 6238), as stored by WriteTOTP
+type TOTP struct {
+	Secret    []byte        // the raw shared secret
+	Digits    int           // code length, 6 if zero
+	Period    time.Duration // code lifetime, 30s if zero
+	Algorithm string        // SHA1 (default), SHA256 or SHA512
+}
+
+// totpRecord is the stored form of a TOTP seed
+type totpRecord struct {
+	Type      string `json:"type"`
+	Secret    []byte `json:"secret"`
+	Digits    int    `json:"digits"`
+	Period    int    `json:"period"` // seconds
+	Algorithm string `json:"algorithm"`
+}
+
+// ParseTOTP reads a seed as shown by sites enrolling an authenticator: the base32
+// secret (spaces and case ignored) or an otpauth://totp/ URI from the QR code.
+func ParseTOTP(s string) (TOTP, error) {
+	s = strings.TrimSpace(s)
+	t := TOTP{Digits: 6, Period: 30 * time.Second, Algorithm: "SHA1"}
+	if strings.HasPrefix(strings.ToLower(s), "otpauth://") {
+		u, err := url.Parse(s)
+		if err != nil || !strings.EqualFold(u.Host, "totp") {
+			return TOTP{}, fmt.Errorf("%w: expected an otpauth://totp/ URI", ErrInvalidFormat)
+		}
+		q := u.Query()
+		s = q.Get("secret")
+		if v := q.Get("digits"); v != "" {
+			if t.Digits, err = strconv.Atoi(v); err != nil {
+				return TOTP{}, fmt.Errorf("%w: invalid digits %q", ErrInvalidFormat, v)
+			}
+		}
+		if v := q.Get("period"); v != "" {
+			seconds, err := strconv.Atoi(v)
+			if err != nil {
+				return TOTP{}, fmt.Errorf("%w: invalid period %q", ErrInvalidFormat, v)
+			}
+			t.Period = time.Duration(seconds) * time.Second
+		}
+		if v := q.Get("algorithm"); v != "" {
+			t.Algorithm = strings.ToUpper(v)
+		}
+	}
+
+	secret := strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return TOTP{}, fmt.Errorf("%w: TOTP secret is not base32", ErrInvalidFormat)
+	}
+	t.Secret = key
+	return t, t.validate()
+}
+
+// validate checks the parameters, filling in defaults
+func (t *TOTP) validate() error {
+	if t.Digits == 0 {
+		t.Digits = 6
+	}
+	if t.Period == 0 {
+		t.Period = 30 * time.Second
+	}
+	if t.Algorithm == "" {
+		t.Algorithm = "SHA1"
+	}
+	switch {
+	case len(t.Secret) == 0:
+		return fmt.Errorf("%w: empty TOTP secret", ErrInvalidFormat)
+	case t.Digits < 6 || t.Digits > 10:
+		return fmt.Errorf("%w: TOTP codes have 6 to 10 digits, not %d", ErrInvalidFormat, t.Digits)
+	case t.Period < time.Second || t.Period%time.Second != 0:
+		return fmt.Errorf("%w: invalid TOTP period %s", ErrInvalidFormat, t.Period)
+	case t.hash() == nil:
+		return fmt.Errorf("%w: unsupported TOTP algorithm %q", ErrInvalidFormat, t.Algorithm)
+	}
+	return nil
+}
+
+// hash returns the HMAC hash of t.Algorithm, or nil if it is unknown
+func (t TOTP) hash() func() hash.Hash {
+	switch strings.ToUpper(t.Algorithm) {
+	case "SHA1":
+		return sha1.New
+	case "SHA256":
+		return sha256.New
+	case "SHA512":
+		return sha512.New
+	}
+	return nil
+}
+
+// Code returns the code valid at now and how long it stays valid
+func (t TOTP) Code(now time.Time) (string, time.Duration, error) {
+	if err := t.validate(); err != nil {
+		return "", 0, err
+	}
+	period := int64(t.Period / time.Second)
+	counter := now.Unix() / period
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(t.hash(), t.Secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation (RFC 4226 section 5.3)
+	offset := sum[len(sum)-1] & 0x0f
+	value := uint64(binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff)
+	mod := uint64(1)
+	for range t.Digits {
+		mod *= 10
+	}
+	code := fmt.Sprintf("%0*d", t.Digits, value%mod)
+
+	next := time.Unix((counter+1)*period, 0)
+	return code, next.Sub(now), nil
+}
+
+// WriteTOTP stores a TOTP seed under name
+func WriteTOTP(cm CredManager, name string, t TOTP) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+	data, _ := json.Marshal(totpRecord{
+		Type:      totpType,
+		Secret:    t.Secret,
+		Digits:    t.Digits,
+		Period:    int(t.Period / time.Second),
+		Algorithm: strings.ToUpper(t.Algorithm),
+	})
+	defer securemem.Wipe(data)
+	return cm.Write(name, data)
+}
+
+// ReadTOTP reads a seed stored by WriteTOTP. The caller may wipe Secret after use.
+func ReadTOTP(cm CredManager, name string) (TOTP, error) {
+	data, err := cm.Read(name)
+	if err != nil {
+		return TOTP{}, err
+	}
+	defer securemem.Wipe(data)
+
+	var rec totpRecord
+	if !bytes.HasPrefix(data, []byte("{")) || json.Unmarshal(data, &rec) != nil || rec.Type != totpType {
+		securemem.Wipe(rec.Secret)
+		return TOTP{}, fmt.Errorf("%w: %q is not a TOTP seed", ErrInvalidFormat, name)
+	}
+	t := TOTP{
+		Secret:    rec.Secret,
+		Digits:    rec.Digits,
+		Period:    time.Duration(rec.Period) * time.Second,
+		Algorithm: rec.Algorithm,
+	}
+	return t, t.validate()
+}
+
+// TOTPCode returns the current code of the seed stored under name and how long it
+// stays valid
+func TOTPCode(cm CredManager, name string) (string, time.Duration, error) {
+	t, err := ReadTOTP(cm, name)
+	if err != nil {
+		return "", 0, err
+	}
+	defer securemem.Wipe(t.Secret)
+	return t.Code(time.Now())
+}
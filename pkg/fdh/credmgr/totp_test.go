@@ -0,0 +1,84 @@
+package credmgr
+
+import (
+	"encoding/base32"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// Test vectors from RFC 6238 appendix B
+	seeds := map[string]string{
+		"SHA1":   "12345678901234567890",
+		"SHA256": "12345678901234567890123456789012",
+		"SHA512": "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	tests := []struct {
+		unix int64
+		alg  string
+		want string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1234567890, "SHA256", "91819424"},
+		{20000000000, "SHA512", "47863826"},
+	}
+	for _, tt := range tests {
+		seed := TOTP{Secret: []byte(seeds[tt.alg]), Digits: 8, Algorithm: tt.alg}
+		code, left, err := seed.Code(time.Unix(tt.unix, 0))
+		if err != nil || code != tt.want {
+			t.Errorf("%s at %d = %q, %v; want %q", tt.alg, tt.unix, code, err, tt.want)
+		}
+		if want := time.Duration(30-tt.unix%30) * time.Second; left != want {
+			t.Errorf("%s at %d valid for %s, want %s", tt.alg, tt.unix, left, want)
+		}
+	}
+}
+
+func TestParseTOTP(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	plain, err := ParseTOTP(strings.ToLower(secret[:8]) + " " + secret[8:])
+	if err != nil || string(plain.Secret) != "12345678901234567890" || plain.Digits != 6 || plain.Period != 30*time.Second {
+		t.Errorf("ParseTOTP(base32) = %+v, %v", plain, err)
+	}
+
+	uri, err := ParseTOTP("otpauth://totp/Example:alice?secret=" + strings.TrimRight(secret, "=") + "&digits=8&period=60&algorithm=sha256")
+	if err != nil || uri.Digits != 8 || uri.Period != time.Minute || uri.Algorithm != "SHA256" {
+		t.Errorf("ParseTOTP(uri) = %+v, %v", uri, err)
+	}
+
+	for _, bad := range []string{"", "not base32!", "otpauth://hotp/x?secret=" + secret, "otpauth://totp/x?secret=" + secret + "&digits=4"} {
+		if _, err := ParseTOTP(bad); !errors.Is(err, ErrInvalidFormat) {
+			t.Errorf("ParseTOTP(%q) error = %v, want ErrInvalidFormat", bad, err)
+		}
+	}
+}
+
+func TestWriteReadTOTP(t *testing.T) {
+	cm := NewMemory()
+	seed, _ := ParseTOTP(base32.StdEncoding.EncodeToString([]byte("12345678901234567890")))
+	if err := WriteTOTP(cm, "github-2fa", seed); err != nil {
+		t.Fatalf("WriteTOTP failed: %v", err)
+	}
+
+	code, left, err := TOTPCode(cm, "github-2fa")
+	want, _, _ := seed.Code(time.Now())
+	if err != nil || len(code) != 6 || left <= 0 || left > 30*time.Second {
+		t.Fatalf("TOTPCode = %q, %s, %v", code, left, err)
+	}
+	if code != want && left < 29*time.Second {
+		t.Errorf("TOTPCode = %q, want %q", code, want)
+	}
+
+	if err := cm.WriteKey("token", "abc"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := TOTPCode(cm, "token"); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("TOTPCode of a plain key error = %v, want ErrInvalidFormat", err)
+	}
+}
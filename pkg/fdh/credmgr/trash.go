@@ -0,0 +1,238 @@
+package credmgr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TrashRetention is how long a deleted credential stays restorable before it
+// is purged automatically.
+const TrashRetention = 30 * 24 * time.Hour
+
+// trashPrefix marks trash entries. They are stored alongside live credentials
+// so they get the same encryption as the rest of the backend.
+const trashPrefix = "credmgr-trash/"
+
+// TrashEntry describes a deleted credential that can still be restored.
+type TrashEntry struct {
+	Name      string
+	DeletedAt time.Time
+}
+
+// trashRecord is the stored form of a trash entry.
+type trashRecord struct {
+	DeletedAt time.Time `json:"deleted_at"`
+	Data      []byte    `json:"data"`
+}
+
+func trashName(name string) string {
+	return trashPrefix + name
+}
+
+func isTrashName(name string) bool {
+	return strings.HasPrefix(name, trashPrefix)
+}
+
+// visibleNames filters trash entries out of a raw backend listing.
+func visibleNames(names []string) []string {
+	visible := names[:0]
+	for _, name := range names {
+		if !isTrashName(name) {
+			visible = append(visible, name)
+		}
+	}
+	return visible
+}
+
+// softDelete moves name into the trash. raw must perform hard deletes and
+// list trash entries. Stores that support Update move it in one save; otherwise
+// the trash copy is written before the original is removed so an interrupted
+// delete never loses the credential.
+func softDelete(raw Store, name string) error {
+	if isTrashName(name) {
+		return fmt.Errorf("credential %q %w", name, ErrNotFound)
+	}
+	if _, ok := raw.(updater); ok {
+		return softDeleteBatch(raw, []string{name})
+	}
+
+	data, err := raw.Read(name)
+	if err != nil {
+		return err
+	}
+
+	rec, err := newTrashRecord(data)
+	if err != nil {
+		return err
+	}
+	if err := raw.Write(trashName(name), rec); err != nil {
+		return fmt.Errorf("failed to move %q to trash: %w", name, err)
+	}
+	if err := raw.Delete(name); err != nil {
+		return err
+	}
+
+	return purgeExpiredTrash(raw)
+}
+
+// softDeleteBatch moves names into the trash. Stores that support Update move them
+// all in one save, and nothing is changed if any name does not exist.
+func softDeleteBatch(raw Store, names []string) error {
+	u, ok := raw.(updater)
+	if !ok {
+		for _, name := range names {
+			if err := softDelete(raw, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := u.Update(func(creds map[string][]byte) error {
+		for _, name := range names {
+			data, exists := creds[name]
+			if !exists || isTrashName(name) {
+				return fmt.Errorf("credential %q %w", name, ErrNotFound)
+			}
+			rec, err := newTrashRecord(data)
+			if err != nil {
+				return err
+			}
+			creds[trashName(name)] = rec
+			delete(creds, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return purgeExpiredTrash(raw)
+}
+
+// newTrashRecord encodes data as a trash entry deleted now
+func newTrashRecord(data []byte) ([]byte, error) {
+	rec, err := json.Marshal(trashRecord{DeletedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode trash entry: %w", err)
+	}
+	return rec, nil
+}
+
+func readTrashRecord(raw Store, name string) (trashRecord, error) {
+	var rec trashRecord
+	data, err := raw.Read(trashName(name))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return rec, fmt.Errorf("credential %q %w in trash", name, ErrNotFound)
+		}
+		return rec, err
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return rec, fmt.Errorf("trash entry %q: %w", name, ErrInvalidFormat)
+	}
+	return rec, nil
+}
+
+// restoreFromTrash moves name out of the trash. It refuses to overwrite a live
+// credential that was created with the same name after the delete.
+func restoreFromTrash(raw Store, name string) error {
+	rec, err := readTrashRecord(raw, name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := raw.Read(name); err == nil {
+		return fmt.Errorf("cannot restore %q: a credential with that name already exists", name)
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	if err := raw.Write(name, rec.Data); err != nil {
+		return err
+	}
+	return raw.Delete(trashName(name))
+}
+
+// listTrash returns restorable entries, most recently deleted first.
+func listTrash(raw Store) ([]TrashEntry, error) {
+	names, err := raw.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []TrashEntry
+	for _, stored := range names {
+		if !isTrashName(stored) {
+			continue
+		}
+		name := strings.TrimPrefix(stored, trashPrefix)
+		rec, err := readTrashRecord(raw, name)
+		if err != nil {
+			return nil, err
+		}
+		if time.Since(rec.DeletedAt) > TrashRetention {
+			continue
+		}
+		entries = append(entries, TrashEntry{Name: name, DeletedAt: rec.DeletedAt})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeletedAt.After(entries[j].DeletedAt)
+	})
+	return entries, nil
+}
+
+// purgeTrash permanently removes name from the trash, or empties the whole
+// trash when name is empty.
+func purgeTrash(raw Store, name string) error {
+	if name != "" {
+		if err := raw.Delete(trashName(name)); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return fmt.Errorf("credential %q %w in trash", name, ErrNotFound)
+			}
+			return err
+		}
+		return nil
+	}
+
+	names, err := raw.List()
+	if err != nil {
+		return err
+	}
+	for _, stored := range names {
+		if isTrashName(stored) {
+			if err := raw.Delete(stored); err != nil && !errors.Is(err, ErrNotFound) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// purgeExpiredTrash removes trash entries older than TrashRetention.
+func purgeExpiredTrash(raw Store) error {
+	names, err := raw.List()
+	if err != nil {
+		return err
+	}
+	for _, stored := range names {
+		if !isTrashName(stored) {
+			continue
+		}
+		name := strings.TrimPrefix(stored, trashPrefix)
+		rec, err := readTrashRecord(raw, name)
+		if err != nil {
+			continue
+		}
+		if time.Since(rec.DeletedAt) > TrashRetention {
+			if err := raw.Delete(stored); err != nil && !errors.Is(err, ErrNotFound) {
+				return err
+			}
+		}
+	}
+	return nil
+}
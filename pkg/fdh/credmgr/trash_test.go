@@ -0,0 +1,211 @@
+package credmgr
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDeleteRestore(t *testing.T) {
+	cm, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if err := cm.WriteKey("prod-api-key", "sk-live-123"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+	if err := cm.Delete("prod-api-key"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	names, err := cm.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(names) != 0 {
+		t.Errorf("List after Delete = %v, want empty", names)
+	}
+
+	trash, err := cm.ListTrash()
+	if err != nil {
+		t.Fatalf("ListTrash failed: %v", err)
+	}
+	if len(trash) != 1 || trash[0].Name != "prod-api-key" {
+		t.Fatalf("ListTrash = %v, want [prod-api-key]", trash)
+	}
+
+	if err := cm.Restore("prod-api-key"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	got, err := cm.ReadKey("prod-api-key")
+	if err != nil {
+		t.Fatalf("ReadKey after Restore failed: %v", err)
+	}
+	if got != "sk-live-123" {
+		t.Errorf("ReadKey after Restore = %q, want %q", got, "sk-live-123")
+	}
+
+	if trash, _ := cm.ListTrash(); len(trash) != 0 {
+		t.Errorf("ListTrash after Restore = %v, want empty", trash)
+	}
+	if err := cm.Restore("prod-api-key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Restore error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRestoreConflict(t *testing.T) {
+	cm, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	cm.WriteKey("token", "old")
+	cm.Delete("token")
+	cm.WriteKey("token", "new")
+
+	if err := cm.Restore("token"); err == nil {
+		t.Fatal("Restore over a live credential should fail")
+	}
+	if got, _ := cm.ReadKey("token"); got != "new" {
+		t.Errorf("live credential = %q after failed Restore, want %q", got, "new")
+	}
+}
+
+func TestPurge(t *testing.T) {
+	cm, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	for _, name := range []string{"a", "b", "c"} {
+		cm.WriteKey(name, name)
+		if err := cm.Delete(name); err != nil {
+			t.Fatalf("Delete(%q) failed: %v", name, err)
+		}
+	}
+
+	if err := cm.Purge("a"); err != nil {
+		t.Fatalf("Purge(a) failed: %v", err)
+	}
+	if err := cm.Restore("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Restore after Purge error = %v, want ErrNotFound", err)
+	}
+	if err := cm.Purge("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Purge(missing) error = %v, want ErrNotFound", err)
+	}
+
+	if err := cm.Purge(""); err != nil {
+		t.Fatalf("Purge(\"\") failed: %v", err)
+	}
+	if trash, _ := cm.ListTrash(); len(trash) != 0 {
+		t.Errorf("ListTrash after emptying = %v, want empty", trash)
+	}
+}
+
+func TestTrashRetention(t *testing.T) {
+	store := mapStore{}
+	cm := NewFromStore(store)
+
+	expired, _ := json.Marshal(trashRecord{
+		DeletedAt: time.Now().Add(-TrashRetention - time.Hour),
+		Data:      []byte("stale"),
+	})
+	store[trashName("stale")] = expired
+
+	if trash, _ := cm.ListTrash(); len(trash) != 0 {
+		t.Errorf("ListTrash = %v, expired entries should be hidden", trash)
+	}
+
+	// Any delete sweeps expired entries
+	cm.WriteKey("fresh", "x")
+	if err := cm.Delete("fresh"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok := store[trashName("stale")]; ok {
+		t.Error("expired trash entry was not purged")
+	}
+
+	names, _ := store.List()
+	if !slices.Contains(names, trashName("fresh")) {
+		t.Errorf("raw store names = %v, want trash entry for fresh", names)
+	}
+	if visible, _ := cm.List(); len(visible) != 0 {
+		t.Errorf("List = %v, want trash entries hidden", visible)
+	}
+}
+
+func TestTrashNamesNotReachable(t *testing.T) {
+	cm, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if err := cm.WriteKey("token", "v"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.Delete("token"); err != nil {
+		t.Fatal(err)
+	}
+
+	hidden := trashName("token")
+	if _, err := cm.Read(hidden); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Read of trash entry error = %v, want ErrNotFound", err)
+	}
+	if _, err := cm.ReadInto(hidden, make([]byte, 256)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ReadInto of trash entry error = %v, want ErrNotFound", err)
+	}
+	if err := cm.Delete(hidden); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete of trash entry error = %v, want ErrNotFound", err)
+	}
+	if err := cm.Write(hidden, []byte("forged")); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("Write of trash name error = %v, want ErrInvalidFormat", err)
+	}
+	if err := cm.WriteIfNotExists(trashName("new"), []byte("x")); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("WriteIfNotExists of trash name error = %v, want ErrInvalidFormat", err)
+	}
+	if err := cm.WriteBatch(map[string][]byte{"ok": []byte("1"), trashName("new"): []byte("x")}); !errors.Is(err, ErrInvalidFormat) {
+		t.Errorf("WriteBatch with trash name error = %v, want ErrInvalidFormat", err)
+	}
+	if exists, _ := cm.Exists("ok"); exists {
+		t.Error("WriteBatch stored part of a batch it refused")
+	}
+
+	if err := cm.Restore("token"); err != nil {
+		t.Fatalf("Restore after refused writes failed: %v", err)
+	}
+	if got, err := cm.ReadKey("token"); err != nil || got != "v" {
+		t.Errorf("restored token = %q, %v; want v", got, err)
+	}
+}
+
+// updateStore is a mapStore that supports Update and counts the changes made to it
+type updateStore struct {
+	mapStore
+	updates, writes, deletes int
+}
+
+func (s *updateStore) Write(name string, data []byte) error {
+	s.writes++
+	return s.mapStore.Write(name, data)
+}
+
+func (s *updateStore) Delete(name string) error {
+	s.deletes++
+	return s.mapStore.Delete(name)
+}
+
+func (s *updateStore) Update(fn func(creds map[string][]byte) error) error {
+	s.updates++
+	return fn(s.mapStore)
+}
+
+func TestDeleteUsesOneUpdate(t *testing.T) {
+	store := &updateStore{mapStore: mapStore{"token": []byte("v")}}
+	cm := NewFromStore(store)
+
+	if err := cm.Delete("token"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if store.updates != 1 || store.writes != 0 || store.deletes != 0 {
+		t.Errorf("Delete made %d updates, %d writes, %d deletes; want one update",
+			store.updates, store.writes, store.deletes)
+	}
+	if trash, _ := cm.ListTrash(); len(trash) != 1 || trash[0].Name != "token" {
+		t.Errorf("ListTrash = %v, want token", trash)
+	}
+}
@@ -1,14 +1,22 @@
 package credmgr
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
-	"strings"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
 )
 
 // UserCred represents a username/password credential pair.
 type UserCred interface {
 	Username() string
 	Password() string
+
+	// Wipe zeroes the stored password; Password returns "" afterwards.
+	// Strings already returned by Password are not affected, so callers that
+	// must control every copy should use CredManager.ReadInto instead.
+	Wipe()
 }
 
 // obfuscatedUserCred represents a username/password credential with obfuscated password storage.
@@ -17,20 +25,34 @@ type obfuscatedUserCred struct {
 	username       string
 	obfuscatedPass []byte // XOR-encoded password
 	obfuscationKey []byte // Rotating key for XOR
+	migrated       bool   // read from a legacy entry that was rewritten in the typed format
+}
+
+// userCredType identifies a username/password entry in the typed format
+const userCredType = "userpass"
+
+// userCredRecord is the stored form of a UserCred. The password is kept as bytes
+// (base64 in JSON) so it is never held in an immutable string.
+type userCredRecord struct {
+	Type     string `json:"type"`
+	Username string `json:"username"`
+	Password []byte `json:"password"`
 }
 
 // NewUnPw creates a new username/password credential with obfuscated password storage.
 func NewUnPw(username, password string) UserCred {
-	return newObfuscatedUserCred(username, password)
+	plain := []byte(password)
+	defer securemem.Wipe(plain)
+	return newObfuscatedUserCred(username, plain)
 }
 
-// newObfuscatedUserCred creates a new obfuscated credential.
-func newObfuscatedUserCred(username, password string) *obfuscatedUserCred {
+// newObfuscatedUserCred creates a new obfuscated credential; password is not retained.
+func newObfuscatedUserCred(username string, password []byte) *obfuscatedUserCred {
 	// Generate a simple rotating key based on username
 	key := generateObfuscationKey(username)
 
 	// XOR encode the password
-	obfuscated := xorEncode([]byte(password), key)
+	obfuscated := xorEncode(password, key)
 
 	return &obfuscatedUserCred{
 		username:       username,
@@ -48,22 +70,70 @@ func (u *obfuscatedUserCred) Username() string {
 func (u *obfuscatedUserCred) Password() string {
 	// XOR decode to get original password
 	decoded := xorEncode(u.obfuscatedPass, u.obfuscationKey)
+	defer securemem.Wipe(decoded)
 	return string(decoded)
 }
 
-// marshal converts obfuscatedUserCred to storable format (plaintext for storage encryption).
+// Wipe zeroes the obfuscated password and releases it.
+func (u *obfuscatedUserCred) Wipe() {
+	securemem.Wipe(u.obfuscatedPass)
+	u.obfuscatedPass = nil
+}
+
+// marshal converts obfuscatedUserCred to the typed storage format (plaintext for storage encryption):
+//
+//	{"type":"userpass","username":"admin","password":"<base64>"}
+//
+// The password is decoded into a byte slice that is wiped after encoding, never into a string.
 func (u *obfuscatedUserCred) marshal() []byte {
 	// For storage, we use plaintext since the file is already AES-encrypted
-	return []byte(u.username + ":" + u.Password())
+	password := xorEncode(u.obfuscatedPass, u.obfuscationKey)
+	defer securemem.Wipe(password)
+
+	return marshalUserCredRecord(u.username, password)
+}
+
+// marshalUserCredRecord encodes a username and password in the typed format
+func marshalUserCredRecord(username string, password []byte) []byte {
+	// Marshaling strings and byte slices cannot fail
+	data, _ := json.Marshal(userCredRecord{Type: userCredType, Username: username, Password: password})
+	return data
+}
+
+// parseUserCredRecord decodes data if it is in the typed format. The caller wipes
+// the returned password.
+func parseUserCredRecord(data []byte) (userCredRecord, bool) {
+	var rec userCredRecord
+	if !bytes.HasPrefix(data, []byte("{")) || json.Unmarshal(data, &rec) != nil || rec.Type != userCredType {
+		securemem.Wipe(rec.Password)
+		return userCredRecord{}, false
+	}
+	return rec, true
+}
+
+// unmarshalUserCred parses a stored UserCred in the typed format or the legacy
+// username:password format; legacy reports the latter. data is not retained.
+func unmarshalUserCred(data []byte) (cred *obfuscatedUserCred, legacy bool, err error) {
+	if rec, ok := parseUserCredRecord(data); ok {
+		defer securemem.Wipe(rec.Password)
+		return newObfuscatedUserCred(rec.Username, rec.Password), false, nil
+	}
+
+	uc, err := unmarshalUnPw(data)
+	if err != nil {
+		return nil, false, err
+	}
+	return uc.(*obfuscatedUserCred), true, nil
 }
 
-// unmarshalUnPw parses a username:password credential and returns obfuscated form.
+// unmarshalUnPw parses a legacy username:password credential and returns obfuscated form.
+// data is not retained, so the caller may wipe it afterwards.
 func unmarshalUnPw(data []byte) (UserCred, error) {
-	parts := strings.SplitN(string(data), ":", 2)
-	if len(parts) != 2 {
+	i := bytes.IndexByte(data, ':')
+	if i < 0 {
 		return nil, fmt.Errorf("%w: expected 'username:password'", ErrInvalidFormat)
 	}
-	return newObfuscatedUserCred(parts[0], parts[1]), nil
+	return newObfuscatedUserCred(string(data[:i]), data[i+1:]), nil
 }
 
 // generateObfuscationKey creates a rotating key based on username.
@@ -67,7 +67,7 @@ func TestPasswordObfuscation(t *testing.T) {
 	username := "testuser"
 	password := "mySecretPassword123"
 
-	cred := newObfuscatedUserCred(username, password)
+	cred := newObfuscatedUserCred(username, []byte(password))
 
 	// Password should be obfuscated in memory (XOR-encoded)
 	// The obfuscatedPass field should NOT contain the plaintext password
@@ -91,8 +91,8 @@ func TestPasswordObfuscationUniqueness(t *testing.T) {
 	// Different usernames should produce different obfuscation for same password
 	password := "samePassword123"
 
-	cred1 := newObfuscatedUserCred("user1", password)
-	cred2 := newObfuscatedUserCred("user2", password)
+	cred1 := newObfuscatedUserCred("user1", []byte(password))
+	cred2 := newObfuscatedUserCred("user2", []byte(password))
 
 	// Obfuscated passwords should be different (different keys)
 	if bytes.Equal(cred1.obfuscatedPass, cred2.obfuscatedPass) {
@@ -105,6 +105,24 @@ func TestPasswordObfuscationUniqueness(t *testing.T) {
 	}
 }
 
+func TestUserCredWipe(t *testing.T) {
+	cred := NewUnPw("admin", "secret")
+	obfuscated := cred.(*obfuscatedUserCred).obfuscatedPass
+
+	cred.Wipe()
+
+	if !bytes.Equal(obfuscated, make([]byte, len(obfuscated))) {
+		t.Error("Wipe did not zero the stored password")
+	}
+	if got := cred.Password(); got != "" {
+		t.Errorf("Password() after Wipe = %q, want empty", got)
+	}
+	if got := cred.Username(); got != "admin" {
+		t.Errorf("Username() after Wipe = %q, want %q", got, "admin")
+	}
+	cred.Wipe() // second call is a no-op
+}
+
 func TestGenerateObfuscationKey(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -190,25 +208,25 @@ func TestMarshal(t *testing.T) {
 			name:     "simple",
 			username: "user",
 			password: "pass",
-			expected: "user:pass",
+			expected: `{"type":"userpass","username":"user","password":"cGFzcw=="}`,
 		},
 		{
 			name:     "with special chars",
 			username: "admin@example.com",
 			password: "P@ssw0rd!",
-			expected: "admin@example.com:P@ssw0rd!",
+			expected: `{"type":"userpass","username":"admin@example.com","password":"UEBzc3cwcmQh"}`,
 		},
 		{
 			name:     "empty password",
 			username: "test",
 			password: "",
-			expected: "test:",
+			expected: `{"type":"userpass","username":"test","password":""}`,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cred := newObfuscatedUserCred(tt.username, tt.password)
+			cred := newObfuscatedUserCred(tt.username, []byte(tt.password))
 			marshaled := cred.marshal()
 
 			if string(marshaled) != tt.expected {
@@ -307,15 +325,18 @@ func TestMarshalUnmarshalRoundTrip(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.username+":"+tt.password, func(t *testing.T) {
 			// Create credential
-			cred := newObfuscatedUserCred(tt.username, tt.password)
+			cred := newObfuscatedUserCred(tt.username, []byte(tt.password))
 
 			// Marshal
 			marshaled := cred.marshal()
 
 			// Unmarshal
-			restored, err := unmarshalUnPw(marshaled)
+			restored, legacy, err := unmarshalUserCred(marshaled)
 			if err != nil {
-				t.Fatalf("unmarshalUnPw() error: %v", err)
+				t.Fatalf("unmarshalUserCred() error: %v", err)
+			}
+			if legacy {
+				t.Error("unmarshalUserCred() reported the typed format as legacy")
 			}
 
 			// Verify
@@ -329,6 +350,49 @@ func TestMarshalUnmarshalRoundTrip(t *testing.T) {
 	}
 }
 
+func TestUnmarshalUserCredLegacy(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantUser string
+		wantPass string
+	}{
+		{"colon format", "admin:secret", "admin", "secret"},
+		{"brace in username", "{admin:secret", "{admin", "secret"},
+		{"other JSON type", `{"type":"token"}:x`, `{"type"`, `"token"}:x`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cred, legacy, err := unmarshalUserCred([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("unmarshalUserCred() unexpected error: %v", err)
+			}
+			if !legacy {
+				t.Error("unmarshalUserCred() legacy = false, want true")
+			}
+			if cred.Username() != tt.wantUser || cred.Password() != tt.wantPass {
+				t.Errorf("unmarshalUserCred() = %q/%q, want %q/%q", cred.Username(), cred.Password(), tt.wantUser, tt.wantPass)
+			}
+		})
+	}
+}
+
+func TestParseUserCredRecord(t *testing.T) {
+	// The Windows store splits records into UserName and blob and rebuilds them on read
+	data := marshalUserCredRecord("admin", []byte("p:w"))
+	rec, ok := parseUserCredRecord(data)
+	if !ok || rec.Username != "admin" || string(rec.Password) != "p:w" {
+		t.Errorf("parseUserCredRecord(%s) = %+v, %v", data, rec, ok)
+	}
+
+	for _, other := range []string{"admin:secret", `{"type":"token"}`, "{", ""} {
+		if _, ok := parseUserCredRecord([]byte(other)); ok {
+			t.Errorf("parseUserCredRecord(%q) accepted a non-userpass value", other)
+		}
+	}
+}
+
 func TestUserCredInterface(t *testing.T) {
 	// Verify that obfuscatedUserCred implements UserCred interface
 	var _ UserCred = (*obfuscatedUserCred)(nil)
@@ -356,7 +420,7 @@ func BenchmarkNewUnPw(b *testing.B) {
 }
 
 func BenchmarkPasswordDecode(b *testing.B) {
-	cred := newObfuscatedUserCred("user", "password123")
+	cred := newObfuscatedUserCred("user", []byte("password123"))
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		_ = cred.Password()
@@ -373,7 +437,7 @@ func BenchmarkXorEncode(b *testing.B) {
 }
 
 func BenchmarkMarshalUnmarshal(b *testing.B) {
-	cred := newObfuscatedUserCred("testuser", "testpassword123")
+	cred := newObfuscatedUserCred("testuser", []byte("testpassword123"))
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		marshaled := cred.marshal()
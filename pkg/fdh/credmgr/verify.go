@@ -0,0 +1,86 @@
+package credmgr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/filestore"
+)
+
+// VerifyReport summarizes an integrity check of a credential store
+type VerifyReport struct {
+	Backend   string   // credential file path, or the backend type
+	Format    int      // file format version; 0 for backends without one
+	Cipher    Cipher   // cipher the file is encrypted with; 0 for backends without one
+	Entries   int      // live credentials
+	Trashed   int      // restorable trash entries
+	Anomalies []string // problems found that do not make the store unreadable
+}
+
+// OK reports whether the check found no anomalies
+func (r VerifyReport) OK() bool {
+	return len(r.Anomalies) == 0
+}
+
+// verifier is implemented by Stores that can check their own storage
+// (the encrypted file re-reads and decrypts itself from disk)
+type verifier interface {
+	Verify() (filestore.Report, error)
+}
+
+// Verify checks that every credential can be read back. File stores are re-read
+// from disk, decrypted and decoded, bypassing the cache; other backends read
+// every entry. Trash records are decoded too. A store that cannot be read at all
+// returns an error (ErrWrongKey, ErrCorrupt, ...); lesser problems such as an
+// unusable backup are listed in VerifyReport.Anomalies.
+func (sm *storeCredManager) Verify() (VerifyReport, error) {
+	report := VerifyReport{Backend: fmt.Sprintf("%T", sm.Store)}
+
+	var names []string
+	v, checksStorage := sm.Store.(verifier)
+	if checksStorage {
+		fr, err := v.Verify()
+		report.Backend, report.Format, report.Cipher = fr.Path, fr.Format, fr.Cipher
+		if err != nil {
+			return report, err
+		}
+		names = fr.Names
+		report.Anomalies = append(report.Anomalies, fr.Anomalies...)
+		// The checked file may be newer than the cache used for the reads below
+		if err := sm.Reload(); err != nil {
+			return report, err
+		}
+	} else {
+		var err error
+		if names, err = sm.Store.List(); err != nil {
+			return report, err
+		}
+	}
+
+	for _, name := range names {
+		if !isTrashName(name) {
+			report.Entries++
+			if checksStorage {
+				continue // already decoded by Verify
+			}
+			if _, err := sm.Store.Read(name); err != nil {
+				report.Anomalies = append(report.Anomalies, fmt.Sprintf("credential %q is unreadable: %v", name, err))
+			}
+			continue
+		}
+
+		report.Trashed++
+		deleted := strings.TrimPrefix(name, trashPrefix)
+		data, err := sm.Store.Read(name)
+		if err != nil {
+			report.Anomalies = append(report.Anomalies, fmt.Sprintf("trash entry %q is unreadable: %v", deleted, err))
+			continue
+		}
+		var rec trashRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			report.Anomalies = append(report.Anomalies, fmt.Sprintf("trash entry %q is malformed: %v", deleted, err))
+		}
+	}
+	return report, nil
+}
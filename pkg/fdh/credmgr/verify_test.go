@@ -0,0 +1,94 @@
+package credmgr
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyFileStore(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	path := filepath.Join(t.TempDir(), "credentials.enc")
+	cm, err := New(path)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if err := cm.WriteKey(name, "v-"+name); err != nil {
+			t.Fatalf("WriteKey failed: %v", err)
+		}
+	}
+	if err := cm.Delete("c"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	report, err := cm.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.Backend != path || report.Entries != 2 || report.Trashed != 1 || !report.OK() {
+		t.Errorf("Verify = %+v, want 2 entries, 1 trashed, no anomalies", report)
+	}
+	if report.Format == 0 || report.Cipher != CipherAES256GCM {
+		t.Errorf("Verify header = format %d, %v; want the current format, AES-256-GCM", report.Format, report.Cipher)
+	}
+
+	// A damaged main file is still readable through the backup, but reported
+	if err := os.WriteFile(path, []byte("garbage"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	report, err = cm.Verify()
+	if err != nil {
+		t.Fatalf("Verify with intact backup failed: %v", err)
+	}
+	if report.OK() || !strings.Contains(report.Anomalies[0], "backup") {
+		t.Errorf("Verify anomalies = %q, want the main file reported", report.Anomalies)
+	}
+
+	// Without a usable backup the store is unreadable
+	if err := os.WriteFile(path+".bak", []byte("garbage"), 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := cm.Verify(); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Verify error = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestVerifyWrongKey(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	path := filepath.Join(t.TempDir(), "credentials.enc")
+	cm, _ := New(path)
+	if err := cm.WriteKey("a", "v"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+
+	t.Setenv("CREDMGR_KEY", strings.Repeat("ab", 32))
+	other, _ := New(path)
+	if _, err := other.Verify(); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("Verify error = %v, want ErrWrongKey", err)
+	}
+}
+
+func TestVerifyCustomStore(t *testing.T) {
+	store := mapStore{
+		"token":             []byte("v"),
+		trashName("broken"): []byte("not json"),
+	}
+
+	report, err := NewFromStore(store).Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if report.Entries != 1 || report.Trashed != 1 || len(report.Anomalies) != 1 {
+		t.Errorf("Verify = %+v, want 1 entry, 1 trashed, 1 anomaly", report)
+	}
+	if report.Backend != "credmgr.mapStore" {
+		t.Errorf("Backend = %q, want the store type", report.Backend)
+	}
+}
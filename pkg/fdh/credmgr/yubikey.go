@@ -0,0 +1,255 @@
+package credmgr
+
+import (
+	"bytes"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh"
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/filestore"
+	"github.com/nzions/fdot/pkg/fdh/credmgr/internal/securemem"
+)
+
+// YubiKey unlock wraps the master key with the HMAC-SHA1 challenge-response of a
+// YubiKey OTP slot, for YubiKeys (or firmware) without FIDO2 hmac-secret support;
+// see EnrollFIDO2 for the latter. Enrollments are stored next to the credential
+// file in <path>.yubikey.
+
+// ErrYubiKeyNotEnrolled is returned when no YubiKey is enrolled for a database.
+var ErrYubiKeyNotEnrolled = errors.New("no YubiKey enrolled")
+
+// yubikeyKDFInfo separates keys derived from challenge-responses from other uses of the slot
+const yubikeyKDFInfo = "fdot-credmgr yubikey kek"
+
+// yubikeyEnrollment records one enrolled YubiKey slot and the master key wrapped by it
+type yubikeyEnrollment struct {
+	Label      string    `json:"label"`
+	Slot       int       `json:"slot"`
+	Challenge  []byte    `json:"challenge"`
+	WrappedKey []byte    `json:"wrapped_key"`
+	EnrolledAt time.Time `json:"enrolled_at"`
+}
+
+// challengeResponder computes the HMAC-SHA1 response of a YubiKey slot
+type challengeResponder interface {
+	ChallengeResponse(slot int, challenge []byte) ([]byte, error)
+}
+
+// yubikeyResponder is the token used for enrollment and unlock (replaced in tests)
+var yubikeyResponder challengeResponder = ykchalrespTool{}
+
+// yubikeyEnrollmentPath returns the enrollment file path for a credential database
+func yubikeyEnrollmentPath(dbPath string) string {
+	return dbPath + ".yubikey"
+}
+
+// EnrollYubiKey enrolls the challenge-response slot (1 or 2) of the connected YubiKey
+// as an unlock method for the credential database at dbPath. The slot must already be
+// programmed for HMAC-SHA1 challenge-response (ykman otp chalresp --generate 2). For an
+// existing database the current master key must be available; for a new database a
+// random master key is generated, so that only enrolled tokens can open it.
+func EnrollYubiKey(dbPath, label string, slot int) error {
+	if label == "" {
+		return fmt.Errorf("enrollment label must not be empty")
+	}
+	if slot != 1 && slot != 2 {
+		return fmt.Errorf("invalid YubiKey slot %d (want 1 or 2)", slot)
+	}
+
+	enrollments, err := readYubiKeyEnrollments(dbPath)
+	if err != nil {
+		return err
+	}
+	for _, e := range enrollments {
+		if e.Label == label {
+			return fmt.Errorf("YubiKey %q is already enrolled", label)
+		}
+	}
+
+	masterKey, err := enrollmentMasterKey(dbPath)
+	if err != nil {
+		return err
+	}
+	defer securemem.Wipe(masterKey)
+
+	challenge := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, challenge); err != nil {
+		return fmt.Errorf("failed to generate challenge: %w", err)
+	}
+
+	kek, err := yubikeyKEK(slot, challenge)
+	if err != nil {
+		return err
+	}
+	defer securemem.Wipe(kek)
+
+	wrapped, err := filestore.Encrypt(masterKey, kek)
+	if err != nil {
+		return fmt.Errorf("failed to wrap master key: %w", err)
+	}
+
+	enrollments = append(enrollments, yubikeyEnrollment{
+		Label:      label,
+		Slot:       slot,
+		Challenge:  challenge,
+		WrappedKey: wrapped,
+		EnrolledAt: time.Now(),
+	})
+
+	return writeYubiKeyEnrollments(dbPath, enrollments)
+}
+
+// RemoveYubiKey removes the enrolled YubiKey with the given label.
+// The enrollment file is deleted when the last key is removed.
+func RemoveYubiKey(dbPath, label string) error {
+	enrollments, err := readYubiKeyEnrollments(dbPath)
+	if err != nil {
+		return err
+	}
+
+	kept := enrollments[:0]
+	for _, e := range enrollments {
+		if e.Label != label {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == len(enrollments) {
+		return fmt.Errorf("YubiKey %q %w", label, ErrNotFound)
+	}
+
+	if len(kept) == 0 {
+		if err := os.Remove(yubikeyEnrollmentPath(dbPath)); err != nil {
+			return fmt.Errorf("failed to remove YubiKey enrollment file: %w", err)
+		}
+		return nil
+	}
+
+	return writeYubiKeyEnrollments(dbPath, kept)
+}
+
+// ListYubiKey returns the labels of all YubiKeys enrolled for the database.
+func ListYubiKey(dbPath string) ([]string, error) {
+	enrollments, err := readYubiKeyEnrollments(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]string, 0, len(enrollments))
+	for _, e := range enrollments {
+		labels = append(labels, e.Label)
+	}
+	return labels, nil
+}
+
+// unlockYubiKey recovers the master key using any enrolled YubiKey that is present.
+// The returned bool reports whether any key is enrolled for the database.
+func unlockYubiKey(dbPath string) ([]byte, bool, error) {
+	enrollments, err := readYubiKeyEnrollments(dbPath)
+	if err != nil {
+		return nil, true, err
+	}
+	if len(enrollments) == 0 {
+		return nil, false, ErrYubiKeyNotEnrolled
+	}
+
+	var errs []error
+	for _, e := range enrollments {
+		kek, err := yubikeyKEK(e.Slot, e.Challenge)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Label, err))
+			continue
+		}
+
+		key, err := filestore.Decrypt(e.WrappedKey, kek)
+		securemem.Wipe(kek)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: failed to unwrap master key: %w", e.Label, err))
+			continue
+		}
+		return key, true, nil
+	}
+
+	return nil, true, fmt.Errorf("YubiKey unlock failed: %w", errors.Join(errs...))
+}
+
+// yubikeyKEK derives the key-encryption key from the slot's response to challenge
+func yubikeyKEK(slot int, challenge []byte) ([]byte, error) {
+	response, err := yubikeyResponder.ChallengeResponse(slot, challenge)
+	if err != nil {
+		return nil, fmt.Errorf("YubiKey challenge-response failed: %w", err)
+	}
+	defer securemem.Wipe(response)
+
+	kek, err := hkdf.Key(sha256.New, response, challenge, yubikeyKDFInfo, masterKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	return kek, nil
+}
+
+// readYubiKeyEnrollments loads the enrollment file, returning nil if it does not exist
+func readYubiKeyEnrollments(dbPath string) ([]yubikeyEnrollment, error) {
+	data, err := os.ReadFile(yubikeyEnrollmentPath(dbPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read YubiKey enrollment file: %w", err)
+	}
+
+	var enrollments []yubikeyEnrollment
+	if err := json.Unmarshal(data, &enrollments); err != nil {
+		return nil, fmt.Errorf("%w: YubiKey enrollment file: %v", ErrInvalidFormat, err)
+	}
+	return enrollments, nil
+}
+
+// writeYubiKeyEnrollments saves the enrollment file with owner-only permissions
+func writeYubiKeyEnrollments(dbPath string, enrollments []yubikeyEnrollment) error {
+	data, err := json.MarshalIndent(enrollments, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal YubiKey enrollments: %w", err)
+	}
+
+	if err := fdh.WritePrivateFileAtomic(yubikeyEnrollmentPath(dbPath), data); err != nil {
+		return fmt.Errorf("failed to write YubiKey enrollment file: %w", err)
+	}
+	return nil
+}
+
+// ykchalrespTool talks to YubiKeys using ykchalresp from yubikey-personalization,
+// which must be installed and on PATH.
+type ykchalrespTool struct{}
+
+// ChallengeResponse runs ykchalresp -<slot> -x <hex challenge>; the response is printed as hex.
+// The challenge is not secret, so it may appear on the command line.
+func (ykchalrespTool) ChallengeResponse(slot int, challenge []byte) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("ykchalresp", "-"+strconv.Itoa(slot), "-x", hex.EncodeToString(challenge))
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	defer securemem.Wipe(out)
+	if err != nil {
+		return nil, fmt.Errorf("ykchalresp failed: %w (%s)", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	encoded := bytes.TrimSpace(out)
+	response := make([]byte, hex.DecodedLen(len(encoded)))
+	n, err := hex.Decode(response, encoded)
+	if err != nil || n != 20 { // HMAC-SHA1
+		securemem.Wipe(response)
+		return nil, fmt.Errorf("unexpected ykchalresp output")
+	}
+	return response[:n], nil
+}
@@ -0,0 +1,105 @@
+package credmgr
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// fakeYubiKey emulates HMAC-SHA1 challenge-response slots with per-slot secrets
+type fakeYubiKey struct {
+	secret string
+}
+
+func (f fakeYubiKey) ChallengeResponse(slot int, challenge []byte) ([]byte, error) {
+	mac := hmac.New(sha1.New, []byte(f.secret+strconv.Itoa(slot)))
+	mac.Write(challenge)
+	return mac.Sum(nil), nil
+}
+
+// useFakeYubiKey installs a fake YubiKey for the duration of the test
+func useFakeYubiKey(t *testing.T, token challengeResponder) {
+	t.Helper()
+	original := yubikeyResponder
+	yubikeyResponder = token
+	t.Cleanup(func() { yubikeyResponder = original })
+}
+
+func TestYubiKeyEnrollAndUnlock(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+	useFakeYubiKey(t, fakeYubiKey{secret: "yk-1"})
+
+	dbPath := filepath.Join(t.TempDir(), "credentials.enc")
+	cm, _ := New(dbPath)
+	if err := cm.WriteKey("token", "secret"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+
+	if err := EnrollYubiKey(dbPath, "primary", 2); err != nil {
+		t.Fatalf("EnrollYubiKey failed: %v", err)
+	}
+	if err := EnrollYubiKey(dbPath, "primary", 2); err == nil {
+		t.Error("enrolling the same label twice should fail")
+	}
+	if err := EnrollYubiKey(dbPath, "bad-slot", 3); err == nil {
+		t.Error("EnrollYubiKey with slot 3 should fail")
+	}
+
+	info, err := os.Stat(yubikeyEnrollmentPath(dbPath))
+	if err != nil {
+		t.Fatalf("Enrollment file not created: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Enrollment file permissions = %o, want 600", perm)
+	}
+
+	// Unlock without CREDMGR_KEY
+	os.Unsetenv("CREDMGR_KEY")
+	cm, _ = New(dbPath)
+	if got, err := cm.ReadKey("token"); err != nil || got != "secret" {
+		t.Errorf("ReadKey with YubiKey unlock = %q, %v; want %q", got, err, "secret")
+	}
+
+	// A different token cannot unlock
+	useFakeYubiKey(t, fakeYubiKey{secret: "someone-else"})
+	if _, err := loadMasterKey(dbPath, ""); err == nil {
+		t.Error("loadMasterKey with the wrong YubiKey should fail")
+	}
+}
+
+func TestYubiKeyNewDatabaseNeedsToken(t *testing.T) {
+	_, cleanup := setupTestEnv(t)
+	defer cleanup()
+	useFakeYubiKey(t, fakeYubiKey{secret: "yk-1"})
+	os.Unsetenv("CREDMGR_KEY")
+
+	dbPath := filepath.Join(t.TempDir(), "credentials.enc")
+	if err := EnrollYubiKey(dbPath, "primary", 1); err != nil {
+		t.Fatalf("EnrollYubiKey on a new database failed: %v", err)
+	}
+
+	cm, _ := New(dbPath)
+	if err := cm.WriteKey("token", "v"); err != nil {
+		t.Fatalf("WriteKey failed: %v", err)
+	}
+
+	if err := RemoveYubiKey(dbPath, "primary"); err != nil {
+		t.Fatalf("RemoveYubiKey failed: %v", err)
+	}
+	if labels, _ := ListYubiKey(dbPath); len(labels) != 0 {
+		t.Errorf("ListYubiKey after remove = %v, want none", labels)
+	}
+	if err := RemoveYubiKey(dbPath, "primary"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RemoveYubiKey(missing) error = %v, want ErrNotFound", err)
+	}
+
+	cm, _ = New(dbPath)
+	if _, err := cm.ReadKey("token"); err == nil {
+		t.Error("ReadKey without the token should fail")
+	}
+}
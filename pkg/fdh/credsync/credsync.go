@@ -0,0 +1,232 @@
+// Package credsync keeps the encrypted credential database in sync between hosts over SSH.
+//
+// The database file is transferred as-is (still encrypted), so both hosts must use the
+// same master key. Sync copies whichever side changed since the last sync, judged by file
+// modification time against a small state file on this host, and applies the copied
+// timestamp to both sides so repeated syncs are no-ops. The whole file is copied, so when
+// both sides changed Sync refuses with ErrConflict instead of dropping the other side's
+// entries; Push or Pull then picks the copy to keep.
+package credsync
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh"
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+)
+
+// Runner executes a shell command on the remote host.
+// *netssh.Client satisfies this interface.
+type Runner interface {
+	Run(cmd string, stdin io.Reader) ([]byte, error)
+}
+
+// Action describes what a sync did
+type Action string
+
+const (
+	ActionNone   Action = "none"   // Both copies are identical in age, or neither exists
+	ActionPushed Action = "pushed" // Local copy was newer and was copied to the remote host
+	ActionPulled Action = "pulled" // Remote copy was newer and was copied to this host
+)
+
+// ErrConflict is returned by Sync when both copies changed since the last sync, or when
+// both exist and differ with no record of a previous sync
+var ErrConflict = errors.New("both copies of the credential database changed since the last sync")
+
+// Sync copies the local database to the remote host, or the remote one here, depending on
+// which side changed since the last sync recorded in statePath.
+func Sync(r Runner, localPath, remotePath, statePath string) (Action, error) {
+	localTime, localExists, err := localModTime(localPath)
+	if err != nil {
+		return ActionNone, err
+	}
+
+	remoteTime, remoteExists, err := remoteModTime(r, remotePath)
+	if err != nil {
+		return ActionNone, err
+	}
+
+	lastTime, synced, err := readState(statePath)
+	if err != nil {
+		return ActionNone, err
+	}
+
+	switch {
+	case !localExists && !remoteExists:
+		return ActionNone, nil
+	case !remoteExists:
+		return ActionPushed, Push(r, localPath, remotePath, statePath)
+	case !localExists:
+		return ActionPulled, Pull(r, localPath, remotePath, statePath)
+	case localTime.Equal(remoteTime):
+		if !synced || !lastTime.Equal(localTime) {
+			return ActionNone, writeState(statePath, localTime)
+		}
+		return ActionNone, nil
+	case !synced:
+		return ActionNone, fmt.Errorf("%w: no previous sync with %s is recorded", ErrConflict, remotePath)
+	}
+
+	localChanged, remoteChanged := !localTime.Equal(lastTime), !remoteTime.Equal(lastTime)
+	switch {
+	case localChanged && remoteChanged:
+		return ActionNone, ErrConflict
+	case localChanged:
+		return ActionPushed, Push(r, localPath, remotePath, statePath)
+	default:
+		return ActionPulled, Pull(r, localPath, remotePath, statePath)
+	}
+}
+
+// Push copies the local database to the remote host, preserving its modification time,
+// and records the sync in statePath unless it is empty.
+func Push(r Runner, localPath, remotePath, statePath string) error {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to read local database: %w", err)
+	}
+
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat local database: %w", err)
+	}
+
+	// Write to a temp file and rename so an interrupted transfer never leaves a truncated database
+	tmp := remotePath + ".sync-tmp"
+	// touch -t (POSIX) rather than GNU touch -d @secs, with TZ pinned so the stamp is UTC
+	cmd := fmt.Sprintf("umask 077 && mkdir -p %s && base64 -d > %s && TZ=UTC0 touch -t %s %s && mv -f %s %s",
+		shellQuote(path.Dir(remotePath)), shellQuote(tmp), info.ModTime().UTC().Format("200601021504.05"), shellQuote(tmp),
+		shellQuote(tmp), shellQuote(remotePath))
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	if _, err := r.Run(cmd, strings.NewReader(encoded)); err != nil {
+		return fmt.Errorf("failed to write remote database: %w", err)
+	}
+
+	return writeState(statePath, info.ModTime())
+}
+
+// Pull copies the remote database to this host, preserving its modification time,
+// and records the sync in statePath unless it is empty. The local file is replaced
+// under the credential store's lock, and Pull fails with ErrConflict if a local write
+// landed while the remote copy was being fetched.
+func Pull(r Runner, localPath, remotePath, statePath string) error {
+	localTime, localExists, err := localModTime(localPath)
+	if err != nil {
+		return err
+	}
+
+	remoteTime, exists, err := remoteModTime(r, remotePath)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("remote database %s does not exist", remotePath)
+	}
+
+	out, err := r.Run("base64 < "+shellQuote(remotePath), nil)
+	if err != nil {
+		return fmt.Errorf("failed to read remote database: %w", err)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(string(bytes.Join(bytes.Fields(out), nil)))
+	if err != nil {
+		return fmt.Errorf("failed to decode remote database: %w", err)
+	}
+
+	unchanged := func() error {
+		nowTime, nowExists, err := localModTime(localPath)
+		if err != nil {
+			return err
+		}
+		if nowExists != localExists || !nowTime.Equal(localTime) {
+			return fmt.Errorf("%w: %s was written during the pull", ErrConflict, localPath)
+		}
+		return nil
+	}
+	if err := credmgr.ReplaceFile(localPath, data, remoteTime, unchanged); err != nil {
+		return fmt.Errorf("failed to replace local database: %w", err)
+	}
+
+	return writeState(statePath, remoteTime)
+}
+
+// readState returns the modification time both copies had after the last sync
+func readState(statePath string) (time.Time, bool, error) {
+	if statePath == "" {
+		return time.Time{}, false, nil
+	}
+	data, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	secs, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid sync state in %s: %w", statePath, err)
+	}
+	return time.Unix(secs, 0), true, nil
+}
+
+// writeState records the modification time both copies share after a sync
+func writeState(statePath string, modTime time.Time) error {
+	if statePath == "" {
+		return nil
+	}
+	if err := fdh.WritePrivateFileAtomic(statePath, []byte(strconv.FormatInt(modTime.Unix(), 10)+"\n")); err != nil {
+		return fmt.Errorf("failed to record sync state: %w", err)
+	}
+	return nil
+}
+
+// localModTime returns the modification time of the local database
+func localModTime(localPath string) (time.Time, bool, error) {
+	info, err := os.Stat(localPath)
+	if os.IsNotExist(err) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to stat local database: %w", err)
+	}
+	return info.ModTime().Truncate(time.Second), true, nil
+}
+
+// remoteModTime returns the modification time of the remote database (GNU or BSD stat)
+func remoteModTime(r Runner, remotePath string) (time.Time, bool, error) {
+	p := shellQuote(remotePath)
+	cmd := fmt.Sprintf("if [ -e %s ]; then stat -c %%Y %s 2>/dev/null || stat -f %%m %s; fi", p, p, p)
+
+	out, err := r.Run(cmd, nil)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to stat remote database: %w", err)
+	}
+
+	field := strings.TrimSpace(string(out))
+	if field == "" {
+		return time.Time{}, false, nil
+	}
+
+	secs, err := strconv.ParseInt(field, 10, 64)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("unexpected remote stat output %q: %w", field, err)
+	}
+	return time.Unix(secs, 0), true, nil
+}
+
+// shellQuote quotes s for use as a single POSIX shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,321 @@
+package credsync
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// homeRunner plays the remote host: it runs each command with sh in a directory that
+// stands in for the remote home, so the commands Push and Pull send are exercised as-is
+type homeRunner struct {
+	home string
+}
+
+func (r *homeRunner) Run(cmd string, stdin io.Reader) ([]byte, error) {
+	c := exec.Command("sh", "-c", cmd)
+	c.Dir = r.home
+	c.Stdin = stdin
+	return c.Output()
+}
+
+// failRunner is a remote host that cannot be reached
+type failRunner struct{}
+
+func (failRunner) Run(string, io.Reader) ([]byte, error) {
+	return nil, errors.New("connection reset")
+}
+
+const remotePath = ".local/credmgr/credentials.enc"
+
+type syncEnv struct {
+	runner    *homeRunner
+	local     string
+	remote    string
+	statePath string
+}
+
+func newSyncEnv(t *testing.T) *syncEnv {
+	t.Helper()
+	for _, tool := range []string{"sh", "base64", "stat", "touch"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not available: %v", tool, err)
+		}
+	}
+
+	dir := t.TempDir()
+	home := filepath.Join(dir, "remote")
+	if err := os.Mkdir(home, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	local := filepath.Join(dir, "local", "credentials.enc")
+	return &syncEnv{
+		runner:    &homeRunner{home: home},
+		local:     local,
+		remote:    filepath.Join(home, remotePath),
+		statePath: local + ".sync-remote",
+	}
+}
+
+// writeFile writes data to path with the given modification time
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func (e *syncEnv) sync(t *testing.T) (Action, error) {
+	t.Helper()
+	return Sync(e.runner, e.local, remotePath, e.statePath)
+}
+
+func TestSyncNeitherExists(t *testing.T) {
+	e := newSyncEnv(t)
+
+	action, err := e.sync(t)
+	if err != nil || action != ActionNone {
+		t.Fatalf("Sync = %v, %v; want none", action, err)
+	}
+	if _, err := os.Stat(e.statePath); !os.IsNotExist(err) {
+		t.Errorf("state recorded with nothing to sync: %v", err)
+	}
+}
+
+func TestSyncPushesFirstCopy(t *testing.T) {
+	e := newSyncEnv(t)
+	base := time.Unix(1_700_000_000, 0)
+	writeFile(t, e.local, []byte("local db"), base)
+
+	action, err := e.sync(t)
+	if err != nil || action != ActionPushed {
+		t.Fatalf("Sync = %v, %v; want pushed", action, err)
+	}
+	if got := readFile(t, e.remote); !bytes.Equal(got, []byte("local db")) {
+		t.Errorf("remote = %q, want local db", got)
+	}
+	info, err := os.Stat(e.remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(base) {
+		t.Errorf("remote mtime = %v, want %v", info.ModTime(), base)
+	}
+
+	// Both sides now match, so a second sync does nothing
+	action, err = e.sync(t)
+	if err != nil || action != ActionNone {
+		t.Fatalf("second Sync = %v, %v; want none", action, err)
+	}
+}
+
+func TestSyncPullsFirstCopy(t *testing.T) {
+	e := newSyncEnv(t)
+	writeFile(t, e.remote, []byte("remote db"), time.Unix(1_700_000_000, 0))
+
+	action, err := e.sync(t)
+	if err != nil || action != ActionPulled {
+		t.Fatalf("Sync = %v, %v; want pulled", action, err)
+	}
+	if got := readFile(t, e.local); !bytes.Equal(got, []byte("remote db")) {
+		t.Errorf("local = %q, want remote db", got)
+	}
+	info, err := os.Stat(e.local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("local mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestSyncCopiesTheChangedSide(t *testing.T) {
+	e := newSyncEnv(t)
+	base := time.Unix(1_700_000_000, 0)
+	writeFile(t, e.local, []byte("v1"), base)
+	if _, err := e.sync(t); err != nil {
+		t.Fatalf("initial Sync failed: %v", err)
+	}
+
+	// Only the local copy changed: push
+	writeFile(t, e.local, []byte("v2 local"), base.Add(time.Minute))
+	action, err := e.sync(t)
+	if err != nil || action != ActionPushed {
+		t.Fatalf("Sync after local change = %v, %v; want pushed", action, err)
+	}
+	if got := readFile(t, e.remote); !bytes.Equal(got, []byte("v2 local")) {
+		t.Errorf("remote = %q, want v2 local", got)
+	}
+
+	// Only the remote copy changed, even to an older time: pull
+	writeFile(t, e.remote, []byte("v3 remote"), base.Add(-time.Hour))
+	action, err = e.sync(t)
+	if err != nil || action != ActionPulled {
+		t.Fatalf("Sync after remote change = %v, %v; want pulled", action, err)
+	}
+	if got := readFile(t, e.local); !bytes.Equal(got, []byte("v3 remote")) {
+		t.Errorf("local = %q, want v3 remote", got)
+	}
+}
+
+func TestSyncRefusesWhenBothChanged(t *testing.T) {
+	e := newSyncEnv(t)
+	base := time.Unix(1_700_000_000, 0)
+	writeFile(t, e.local, []byte("v1"), base)
+	if _, err := e.sync(t); err != nil {
+		t.Fatalf("initial Sync failed: %v", err)
+	}
+
+	writeFile(t, e.local, []byte("local entry"), base.Add(time.Minute))
+	writeFile(t, e.remote, []byte("remote entry"), base.Add(2*time.Minute))
+
+	action, err := e.sync(t)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("Sync = %v, %v; want ErrConflict", action, err)
+	}
+	if got := readFile(t, e.local); !bytes.Equal(got, []byte("local entry")) {
+		t.Errorf("local overwritten on conflict: %q", got)
+	}
+	if got := readFile(t, e.remote); !bytes.Equal(got, []byte("remote entry")) {
+		t.Errorf("remote overwritten on conflict: %q", got)
+	}
+
+	// An explicit pull picks the remote copy and clears the conflict
+	if err := Pull(e.runner, e.local, remotePath, e.statePath); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	action, err = e.sync(t)
+	if err != nil || action != ActionNone {
+		t.Fatalf("Sync after Pull = %v, %v; want none", action, err)
+	}
+}
+
+func TestSyncRefusesDifferentCopiesWithoutState(t *testing.T) {
+	e := newSyncEnv(t)
+	writeFile(t, e.local, []byte("local db"), time.Unix(1_700_000_000, 0))
+	writeFile(t, e.remote, []byte("remote db"), time.Unix(1_700_000_100, 0))
+
+	if _, err := e.sync(t); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Sync without state = %v; want ErrConflict", err)
+	}
+
+	if err := Push(e.runner, e.local, remotePath, e.statePath); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if got := readFile(t, e.remote); !bytes.Equal(got, []byte("local db")) {
+		t.Errorf("remote = %q, want local db", got)
+	}
+	if action, err := e.sync(t); err != nil || action != ActionNone {
+		t.Fatalf("Sync after Push = %v, %v; want none", action, err)
+	}
+}
+
+func TestSyncRecordsMatchingCopies(t *testing.T) {
+	e := newSyncEnv(t)
+	base := time.Unix(1_700_000_000, 0)
+	writeFile(t, e.local, []byte("same"), base)
+	writeFile(t, e.remote, []byte("same"), base)
+
+	if action, err := e.sync(t); err != nil || action != ActionNone {
+		t.Fatalf("Sync = %v, %v; want none", action, err)
+	}
+	if got, ok, err := readState(e.statePath); err != nil || !ok || !got.Equal(base) {
+		t.Errorf("state = %v, %v, %v; want %v", got, ok, err, base)
+	}
+}
+
+// writeDuringFetch is a remote host whose transfer of the database overlaps a local write
+type writeDuringFetch struct {
+	*homeRunner
+	t     *testing.T
+	local string
+}
+
+func (r writeDuringFetch) Run(cmd string, stdin io.Reader) ([]byte, error) {
+	if strings.HasPrefix(cmd, "base64 < ") {
+		writeFile(r.t, r.local, []byte("written meanwhile"), time.Unix(1_700_000_500, 0))
+	}
+	return r.homeRunner.Run(cmd, stdin)
+}
+
+func TestPullReplacesBackup(t *testing.T) {
+	e := newSyncEnv(t)
+	writeFile(t, e.local, []byte("old local"), time.Unix(1_700_000_000, 0))
+	writeFile(t, e.local+".bak", []byte("older local"), time.Unix(1_600_000_000, 0))
+	writeFile(t, e.remote, []byte("remote db"), time.Unix(1_700_000_100, 0))
+
+	if err := Pull(e.runner, e.local, remotePath, e.statePath); err != nil {
+		t.Fatalf("Pull failed: %v", err)
+	}
+	if got := readFile(t, e.local); !bytes.Equal(got, []byte("remote db")) {
+		t.Errorf("local = %q, want remote db", got)
+	}
+	if got := readFile(t, e.local+".bak"); !bytes.Equal(got, []byte("remote db")) {
+		t.Errorf("backup = %q, want the pulled copy", got)
+	}
+	if _, err := os.Stat(e.local + ".lock"); err != nil {
+		t.Errorf("Pull did not take the store lock: %v", err)
+	}
+}
+
+func TestPullRefusesConcurrentLocalWrite(t *testing.T) {
+	e := newSyncEnv(t)
+	writeFile(t, e.local, []byte("local db"), time.Unix(1_700_000_000, 0))
+	writeFile(t, e.remote, []byte("remote db"), time.Unix(1_700_000_100, 0))
+
+	runner := writeDuringFetch{homeRunner: e.runner, t: t, local: e.local}
+	if err := Pull(runner, e.local, remotePath, e.statePath); !errors.Is(err, ErrConflict) {
+		t.Fatalf("Pull over a concurrent write error = %v, want ErrConflict", err)
+	}
+	if got := readFile(t, e.local); !bytes.Equal(got, []byte("written meanwhile")) {
+		t.Errorf("local = %q, want the concurrent write kept", got)
+	}
+}
+
+func TestSyncRemoteFailure(t *testing.T) {
+	e := newSyncEnv(t)
+	writeFile(t, e.local, []byte("local db"), time.Unix(1_700_000_000, 0))
+
+	if _, err := Sync(failRunner{}, e.local, remotePath, e.statePath); err == nil {
+		t.Fatal("Sync succeeded with an unreachable remote")
+	}
+	if err := Push(failRunner{}, e.local, remotePath, e.statePath); err == nil {
+		t.Fatal("Push succeeded with an unreachable remote")
+	}
+	if _, err := os.Stat(e.statePath); !os.IsNotExist(err) {
+		t.Errorf("state recorded after a failed push: %v", err)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	e := newSyncEnv(t)
+	name := "it's a $path"
+	out, err := e.runner.Run("printf %s "+shellQuote(name), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != name {
+		t.Errorf("shellQuote round trip = %q, want %q", out, name)
+	}
+}
@@ -1,8 +1,7 @@
 package fuser
 
 import (
-	"crypto/rand"
-	"encoding/hex"
+	"errors"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -34,19 +33,29 @@ func init() {
 
 	// get data directory
 	dataDir := filepath.Join(homeDir, fdotconfig.FDOTDir)
-	if err := fdh.CheckCreateDir(dataDir); err != nil {
+	if err := fdh.CreatePrivateDir(dataDir); err != nil {
 		panicMsg("dataDir", err)
 	}
 
 	// get network directory
 	networkDir := filepath.Join(dataDir, "netcfg")
-	if err := fdh.CheckCreateDir(networkDir); err != nil {
+	if err := fdh.CreatePrivateDir(networkDir); err != nil {
 		panicMsg("networkDir", err)
 	}
 
-	// create credential manager with custom path
-	credFilePath := filepath.Join(dataDir, "credentials.enc")
-	cm, err := credmgr.New(credFilePath)
+	// use the file chosen by credmgr init, or the credmgr default store shared with
+	// the credmgr CLI (which merges the old ~/.fdot/credentials.enc on first open)
+	cfg, err := fdotconfig.LoadConfig()
+	if err != nil {
+		panicMsg("config", err)
+	}
+	credFilePath := cfg.CredFile
+	if credFilePath == "" {
+		if credFilePath, err = credmgr.DefaultStoreFile(); err != nil {
+			panicMsg("credmgr.DefaultStoreFile", err)
+		}
+	}
+	cm, err := credmgr.New(cfg.CredFile)
 	if err != nil {
 		panicMsg("credmgr.New", err)
 	}
@@ -57,6 +66,8 @@ func init() {
 		DataDir:     dataDir,
 		NetworkDir:  networkDir,
 		CredManager: cm,
+
+		credFilePath: credFilePath,
 	}
 
 	// Register as the path provider for credential operations
@@ -69,21 +80,30 @@ type FUser struct {
 	DataDir     string
 	NetworkDir  string
 	CredManager credmgr.CredManager // OO credential manager instance
+
+	credFilePath string
 }
 
+// BigKey returns the user's big key, creating it on first use. Only a missing key is
+// created; other errors (e.g. the wrong master key) are returned so an existing key
+// is never replaced.
 func (u *FUser) BigKey() (string, error) {
 	bigKey, err := u.CredManager.ReadKey(fdotconfig.BigKeySecretName)
-	if err == nil {
-		return bigKey, nil
+	if !errors.Is(err, credmgr.ErrNotFound) {
+		return bigKey, err
 	}
 
 	// Create new big key
-	randomBytes := make([]byte, 128)
-	if _, err := rand.Read(randomBytes); err != nil {
+	bigKey, err = credmgr.GenerateSecret(credmgr.Policy{Length: fdotconfig.BigKeyLength, Charset: credmgr.CharsetHex})
+	if err != nil {
 		return "", err
 	}
-	bigKey = hex.EncodeToString(randomBytes)
-	if err := u.CredManager.WriteKey(fdotconfig.BigKeySecretName, bigKey); err != nil {
+	err = u.CredManager.WriteIfNotExists(fdotconfig.BigKeySecretName, []byte(bigKey))
+	if errors.Is(err, credmgr.ErrAlreadyExists) {
+		// Another process created it first
+		return u.CredManager.ReadKey(fdotconfig.BigKeySecretName)
+	}
+	if err != nil {
 		return "", err
 	}
 	return bigKey, nil
@@ -102,7 +122,8 @@ func (u *FUser) SetSSHCreds(username, password string) error {
 	return u.CredManager.WriteUserCred(fdotconfig.SSHCredSecretName, cred)
 }
 
-// CredFilePath returns the path to the encrypted credentials file
+// CredFilePath returns the path to the encrypted credentials file, or "" when
+// credentials live in the platform's native store (Windows Credential Manager)
 func (u *FUser) CredFilePath() string {
-	return filepath.Join(u.DataDir, "credentials.enc")
+	return u.credFilePath
 }
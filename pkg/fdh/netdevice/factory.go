@@ -60,11 +60,21 @@ func DetectDeviceType(showVersionOutput string) DeviceType {
 	return DeviceTypeUnknown
 }
 
+// DetectDeviceTypeWithLogin is DetectDeviceType with the login banner and MOTD as a
+// fallback for devices whose show version output is inconclusive
+func DetectDeviceTypeWithLogin(showVersionOutput, loginText string) DeviceType {
+	if deviceType := DetectDeviceType(showVersionOutput); deviceType != DeviceTypeUnknown {
+		return deviceType
+	}
+	return DetectDeviceType(loginText)
+}
+
 // NewDevice creates a new device based on show version output
 // Each device type is responsible for parsing its own show version output
+// The client's login banner and MOTD are used when show version alone does not identify the device
 // Returns a Device interface implementation
 func NewDevice(sshClient *netssh.Client, showVersionOutput string) (netmodel.Device, error) {
-	deviceType := DetectDeviceType(showVersionOutput)
+	deviceType := DetectDeviceTypeWithLogin(showVersionOutput, sshClient.LoginText())
 
 	switch deviceType {
 	case GenericAruba:
@@ -0,0 +1,172 @@
+package genericaruba
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
+)
+
+var (
+	// 1            100/1000T  | No        Yes     Up     1000FDx    MDIX off  0
+	portStatusRe = regexp.MustCompile(`^\s*(\S+)\s+(\S*)\s*\|\s*\S+\s+(Yes|No)\s+(Up|Down)\s*(\S*)`)
+	// 1000FDx, 100HDx, 10GigFD, 2.5GigFD
+	portModeRe = regexp.MustCompile(`^(\d+(?:\.\d+)?)(Gig)?`)
+	// Available: 370 W  Used: 43 W  Remaining: 327 W
+	poeBudgetRe = regexp.MustCompile(`(?i)Available:\s*([\d.]+)\s*W\s+Used:\s*([\d.]+)\s*W`)
+	// 1      Yes    low      usage  17 W   7.2 W    6.8 W   Delivering   2     2
+	poePortRe = regexp.MustCompile(`^\s*(\S+)\s+(Yes|No)\s+\S+\s+\S+\s+[\d.]+\s*W\s+[\d.]+\s*W\s+[\d.]+\s*W\s+(\S+)`)
+	//     A   |   3046      |  12   |  0    | 0     | 0     | 0     | 0     | 0     | 2     |
+	resourceRowRe = regexp.MustCompile(`^\s*([A-Za-z0-9/-]+)\s*\|\s*(\d+)\s*\|([\d\s|]*)$`)
+)
+
+// GetCapacity collects port status, the PoE budget and policy engine resource usage.
+// Switches without PoE or without a resource report leave those parts empty.
+func (d *Device) GetCapacity() (*netmodel.Capacity, error) {
+	if !d.IsConnected() {
+		return nil, fmt.Errorf("device not connected")
+	}
+
+	output, err := d.client.ExecuteCommand(CmdShowInterfacesBrief)
+	if err != nil {
+		return nil, err
+	}
+	capacity := &netmodel.Capacity{Ports: parsePortStatus(output)}
+
+	// Non-PoE models and older firmware reject these commands, which parse as empty
+	if output, err := d.client.ExecuteCommand(CmdShowPoE); err == nil {
+		capacity.PoE = parsePoE(output)
+	}
+	if output, err := d.client.ExecuteCommand(CmdShowResources); err == nil {
+		capacity.Resources = parseResources(output)
+	}
+
+	d.info.Capacity = capacity
+	d.info.LastUpdated = time.Now()
+
+	return capacity, nil
+}
+
+// parsePortStatus parses "show interfaces brief" output
+func parsePortStatus(output string) []netmodel.PortStatus {
+	var ports []netmodel.PortStatus
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		match := portStatusRe.FindStringSubmatch(scanner.Text())
+		if match == nil || match[1] == "Port" {
+			continue
+		}
+		port := netmodel.PortStatus{
+			Port:    match[1],
+			Type:    match[2],
+			Enabled: match[3] == "Yes",
+			Up:      match[4] == "Up",
+		}
+		if port.Up {
+			port.Speed = parsePortSpeed(match[5])
+		}
+		ports = append(ports, port)
+	}
+
+	return ports
+}
+
+// parsePortSpeed converts a port mode such as "1000FDx" or "10GigFD" to Mbps
+func parsePortSpeed(mode string) int {
+	match := portModeRe.FindStringSubmatch(mode)
+	if match == nil {
+		return 0
+	}
+	speed, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0
+	}
+	if match[2] != "" {
+		speed *= 1000
+	}
+	return int(speed)
+}
+
+// parsePoE parses "show power-over-ethernet brief" output; nil if no budget is reported
+func parsePoE(output string) *netmodel.PoEBudget {
+	var budget *netmodel.PoEBudget
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Stacked and chassis switches report one budget per member; add them up
+		if match := poeBudgetRe.FindStringSubmatch(line); match != nil {
+			if budget == nil {
+				budget = &netmodel.PoEBudget{}
+			}
+			available, _ := strconv.ParseFloat(match[1], 64)
+			used, _ := strconv.ParseFloat(match[2], 64)
+			budget.AvailableWatts += available
+			budget.UsedWatts += used
+			continue
+		}
+
+		if match := poePortRe.FindStringSubmatch(line); match != nil && budget != nil {
+			budget.PoEPorts++
+			if strings.EqualFold(match[3], "Delivering") {
+				budget.PoweredPorts++
+			}
+		}
+	}
+
+	return budget
+}
+
+// parseResources parses "show resources" output. Each table is headed by the
+// resource it counts (rules, meters, application port ranges); rows give the
+// free amount per slot followed by per-feature usage columns.
+func parseResources(output string) []netmodel.ResourceUsage {
+	var usage []netmodel.ResourceUsage
+	resource := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		lower := strings.ToLower(line)
+
+		switch {
+		case strings.Contains(lower, "port ranges"):
+			resource = "port ranges"
+			continue
+		case strings.Contains(lower, "meters"):
+			resource = "meters"
+			continue
+		case strings.Contains(lower, "rules"):
+			resource = "rules"
+			continue
+		}
+		if resource == "" {
+			continue
+		}
+
+		match := resourceRowRe.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		available, _ := strconv.Atoi(match[2])
+		used := 0
+		for _, field := range strings.FieldsFunc(match[3], func(r rune) bool { return r == '|' || r == ' ' }) {
+			n, _ := strconv.Atoi(field)
+			used += n
+		}
+		usage = append(usage, netmodel.ResourceUsage{
+			Resource:  resource,
+			Slot:      match[1],
+			Used:      used,
+			Available: available,
+		})
+	}
+
+	return usage
+}
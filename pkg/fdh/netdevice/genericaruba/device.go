@@ -84,7 +84,7 @@ func (d *Device) GetConfig() (string, error) {
 	if !d.IsConnected() {
 		return "", fmt.Errorf("device not connected")
 	}
-	return d.client.ExecuteCommand("show running-config")
+	return d.client.ExecuteCommand(CmdShowRunningConfig)
 }
 
 // GetInterfaces retrieves and parses interface information
@@ -99,6 +99,21 @@ func (d *Device) GetInterfaces() ([]netmodel.Interface, error) {
 	}
 
 	interfaces := d.parseInterfaces(config)
+
+	// "show vrf" also covers interfaces placed in a VRF outside the interface block
+	// (e.g. the dedicated management port)
+	membership, err := d.vrfMembership()
+	if err != nil {
+		return nil, err
+	}
+	for i := range interfaces {
+		if interfaces[i].VRF == "" {
+			interfaces[i].VRF = membership.interfaces[interfaces[i].Name]
+		} else {
+			interfaces[i].VRF = netmodel.NormalizeVRF(interfaces[i].VRF)
+		}
+	}
+
 	d.info.Interfaces = interfaces
 	d.info.LastUpdated = time.Now()
 
@@ -111,7 +126,7 @@ func (d *Device) GetNeighbors() ([]netmodel.Neighbor, error) {
 		return nil, fmt.Errorf("device not connected")
 	}
 
-	output, err := d.client.ExecuteCommand("show lldp neighbors detail")
+	output, err := d.client.ExecuteCommand(CmdShowLLDPNeighbors)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,47 @@
+package genericaruba
+
+import "github.com/nzions/fdot/pkg/fdh/netmodel"
+
+// Commands executed by the driver
+// Every command the driver runs must be declared here so Requirements stays accurate
+const (
+	CmdShowVersion       = "show version"
+	CmdShowRunningConfig = "show running-config"
+	CmdShowLLDPNeighbors = "show lldp neighbors detail"
+	CmdShowMACAddress    = "show mac-address"
+	CmdShowARP           = "show arp"
+	CmdShowModules       = "show modules"
+	CmdShowDHCPSnooping  = "show dhcp-snooping binding"
+	CmdShowVRF           = "show vrf"
+	CmdShowIPRoute       = "show ip route"
+
+	CmdShowInterfacesBrief = "show interfaces brief"
+	CmdShowPoE             = "show power-over-ethernet brief"
+	CmdShowResources       = "show resources"
+)
+
+// Requirements returns the minimum privilege and exact commands needed by the driver
+func Requirements() netmodel.DriverRequirements {
+	return netmodel.DriverRequirements{
+		Privilege: "manager",
+		Commands: []string{
+			CmdShowVersion,
+			CmdShowRunningConfig,
+			CmdShowLLDPNeighbors,
+			CmdShowMACAddress,
+			CmdShowARP,
+			CmdShowModules,
+			CmdShowDHCPSnooping,
+			CmdShowVRF,
+			CmdShowIPRoute,
+			CmdShowInterfacesBrief,
+			CmdShowPoE,
+			CmdShowResources,
+			vrfCommand(CmdShowARP, "<vrf>"),
+			vrfCommand(CmdShowIPRoute, "<vrf>"),
+		},
+		Notes: "show running-config requires manager (level 15) access on ProCurve/ArubaOS-Switch; all other commands work at operator level. " +
+			"show mac-address, show arp, show ip route, show modules, show dhcp-snooping binding, show interfaces brief, show power-over-ethernet brief and show resources are only run by the full crawl profile. " +
+			"show arp and show ip route are repeated with \"vrf <name>\" for every VRF listed by show vrf; firmware without VRF support only sees the plain commands",
+	}
+}
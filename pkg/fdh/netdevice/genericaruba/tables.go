@@ -0,0 +1,212 @@
+package genericaruba
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
+)
+
+var (
+	// 001122-334455     1      1
+	macEntryRe = regexp.MustCompile(`^\s*([0-9a-fA-F]{6}-[0-9a-fA-F]{6})\s+(\S+)\s+(\d+)\s*$`)
+	// 10.0.0.1         001122-334455     dynamic 1
+	arpEntryRe = regexp.MustCompile(`^\s*(\d+\.\d+\.\d+\.\d+)\s+([0-9a-fA-F]{6}-[0-9a-fA-F]{6})\s+(\w+)\s*(\S*)`)
+	// Chassis: 2930F-48G-4SFP+ JL254A       Serial Number:   SG00XXXXXX
+	chassisRe = regexp.MustCompile(`(?i)^\s*Chassis:\s*(.+?)\s+Serial Number:\s*(\S+)`)
+	// 1     Aruba JL083A 3810M/2930M 4SFP+ MACsec   SG00XXXXXX       Up
+	moduleRe = regexp.MustCompile(`^\s*(\S+)\s+(.+?)\s{2,}(\S+)\s+(\S+)\s*$`)
+	// 001122-334455 10.0.0.5        10   12   86000
+	dhcpBindingRe = regexp.MustCompile(`^\s*([0-9a-fA-F]{6}-[0-9a-fA-F]{6})\s+(\d+\.\d+\.\d+\.\d+)\s+(\d+)\s+(\S+)`)
+)
+
+// GetMACTable retrieves and parses the MAC address table
+func (d *Device) GetMACTable() ([]netmodel.MACEntry, error) {
+	if !d.IsConnected() {
+		return nil, fmt.Errorf("device not connected")
+	}
+
+	output, err := d.client.ExecuteCommand(CmdShowMACAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := parseMACTable(output)
+	d.info.MACTable = entries
+	d.info.LastUpdated = time.Now()
+
+	return entries, nil
+}
+
+// GetARPTable retrieves and parses the ARP table of every VRF
+func (d *Device) GetARPTable() ([]netmodel.ARPEntry, error) {
+	if !d.IsConnected() {
+		return nil, fmt.Errorf("device not connected")
+	}
+
+	vrfs, err := d.GetVRFs()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []netmodel.ARPEntry
+	for _, vrf := range vrfs {
+		output, err := d.client.ExecuteCommand(vrfCommand(CmdShowARP, vrf))
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range parseARPTable(output) {
+			e.VRF = netmodel.NormalizeVRF(vrf)
+			entries = append(entries, e)
+		}
+	}
+
+	d.info.ARPTable = entries
+	d.info.LastUpdated = time.Now()
+
+	return entries, nil
+}
+
+// GetInventory retrieves and parses chassis and module information
+func (d *Device) GetInventory() ([]netmodel.InventoryItem, error) {
+	if !d.IsConnected() {
+		return nil, fmt.Errorf("device not connected")
+	}
+
+	output, err := d.client.ExecuteCommand(CmdShowModules)
+	if err != nil {
+		return nil, err
+	}
+
+	items := parseModules(output)
+	d.info.Inventory = items
+	d.info.LastUpdated = time.Now()
+
+	return items, nil
+}
+
+// GetDHCPBindings retrieves and parses the DHCP snooping binding table
+func (d *Device) GetDHCPBindings() ([]netmodel.DHCPBinding, error) {
+	if !d.IsConnected() {
+		return nil, fmt.Errorf("device not connected")
+	}
+
+	output, err := d.client.ExecuteCommand(CmdShowDHCPSnooping)
+	if err != nil {
+		return nil, err
+	}
+
+	bindings := parseDHCPBindings(output)
+	d.info.DHCPBindings = bindings
+	d.info.LastUpdated = time.Now()
+
+	return bindings, nil
+}
+
+// parseMACTable parses "show mac-address" output
+func parseMACTable(output string) []netmodel.MACEntry {
+	var entries []netmodel.MACEntry
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		match := macEntryRe.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		vlan, _ := strconv.Atoi(match[3])
+		entries = append(entries, netmodel.MACEntry{
+			MAC:  strings.ToLower(match[1]),
+			Port: match[2],
+			VLAN: vlan,
+		})
+	}
+
+	return entries
+}
+
+// parseARPTable parses "show arp" output
+func parseARPTable(output string) []netmodel.ARPEntry {
+	var entries []netmodel.ARPEntry
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		match := arpEntryRe.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		entries = append(entries, netmodel.ARPEntry{
+			IPAddress: match[1],
+			MAC:       strings.ToLower(match[2]),
+			Type:      strings.ToLower(match[3]),
+			Port:      match[4],
+		})
+	}
+
+	return entries
+}
+
+// parseModules parses "show modules" output into chassis and module entries
+func parseModules(output string) []netmodel.InventoryItem {
+	var items []netmodel.InventoryItem
+	inTable := false
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if match := chassisRe.FindStringSubmatch(line); match != nil {
+			items = append(items, netmodel.InventoryItem{
+				Slot:        "chassis",
+				Description: strings.TrimSpace(match[1]),
+				Serial:      match[2],
+			})
+			continue
+		}
+
+		// Module rows follow the dashed separator under the table header
+		if strings.HasPrefix(strings.TrimSpace(line), "---") {
+			inTable = true
+			continue
+		}
+		if !inTable {
+			continue
+		}
+
+		if match := moduleRe.FindStringSubmatch(line); match != nil {
+			items = append(items, netmodel.InventoryItem{
+				Slot:        match[1],
+				Description: strings.TrimSpace(match[2]),
+				Serial:      match[3],
+				Status:      match[4],
+			})
+		}
+	}
+
+	return items
+}
+
+// parseDHCPBindings parses "show dhcp-snooping binding" output
+func parseDHCPBindings(output string) []netmodel.DHCPBinding {
+	var bindings []netmodel.DHCPBinding
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		match := dhcpBindingRe.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		vlan, _ := strconv.Atoi(match[3])
+		bindings = append(bindings, netmodel.DHCPBinding{
+			MAC:       strings.ToLower(match[1]),
+			IPAddress: match[2],
+			VLAN:      vlan,
+			Port:      match[4],
+		})
+	}
+
+	return bindings
+}
@@ -0,0 +1,219 @@
+package genericaruba
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
+)
+
+var (
+	// VRF Name : mgmt
+	vrfNameRe = regexp.MustCompile(`(?i)^\s*VRF Name\s*:\s*(\S+)`)
+	//         vlan10         up
+	vrfInterfaceRe = regexp.MustCompile(`^\s+(\S+)\s+(up|down)\s*$`)
+	// ArubaOS-Switch table: 10.0.0.0/24   10.0.0.1   1   static              1   1
+	routeTableRe = regexp.MustCompile(`^\s*(\d+\.\d+\.\d+\.\d+/\d+)\s+(\S+)\s+(\S+)\s+([a-z]+)\s+(?:\S+\s+)?(\d+)\s+(\d+)\s*$`)
+	// ArubaOS-CX: 10.0.0.0/24, vrf default
+	routePrefixRe = regexp.MustCompile(`^\s*(\d+\.\d+\.\d+\.\d+/\d+),\s+vrf\s+(\S+)`)
+	// ArubaOS-CX:     via  10.0.0.1,  [1/0],  static
+	routeViaRe = regexp.MustCompile(`^\s+via\s+(\S+?),\s+\[(\d+)/(\d+)\],\s+(\S+)`)
+	// ArubaOS-CX 10.08+ table: VRF: default
+	routeVRFHeaderRe = regexp.MustCompile(`^\s*VRF:\s+(\S+)\s*$`)
+	// ArubaOS-CX 10.08+ table: 10.0.0.0/24   10.0.0.1   vlan10   -   S   [1/0]   00h:04m:46s
+	routeCXTableRe = regexp.MustCompile(`^\s*(\d+\.\d+\.\d+\.\d+/\d+)\s+(\S+)\s+(\S+)\s+\S+\s+([A-Z]+)(?:/\S+|\s+[A-Z][A-Z0-9]*)?\s+\[(\d+)/(\d+)\]`)
+)
+
+// cxOriginTypes maps the origin codes of the ArubaOS-CX route table to the route
+// types the older CX format spells out
+var cxOriginTypes = map[string]string{
+	"C": "connected",
+	"L": "local",
+	"S": "static",
+	"R": "rip",
+	"B": "bgp",
+	"O": "ospf",
+	"D": "dhcp",
+}
+
+// GetVRFs returns the VRFs configured on the device, starting with the default VRF.
+// Devices without VRF support report only the default VRF.
+func (d *Device) GetVRFs() ([]string, error) {
+	if !d.IsConnected() {
+		return nil, fmt.Errorf("device not connected")
+	}
+
+	membership, err := d.vrfMembership()
+	if err != nil {
+		return nil, err
+	}
+
+	vrfs := []string{netmodel.DefaultVRF}
+	for _, name := range membership.names {
+		if netmodel.NormalizeVRF(name) != "" {
+			vrfs = append(vrfs, name)
+		}
+	}
+
+	d.info.VRFs = vrfs
+	d.info.LastUpdated = time.Now()
+
+	return vrfs, nil
+}
+
+// GetRoutes retrieves the routing table of every VRF
+func (d *Device) GetRoutes() ([]netmodel.Route, error) {
+	vrfs, err := d.GetVRFs()
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []netmodel.Route
+	for _, vrf := range vrfs {
+		output, err := d.client.ExecuteCommand(vrfCommand(CmdShowIPRoute, vrf))
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, parseRoutes(output, netmodel.NormalizeVRF(vrf))...)
+	}
+
+	d.info.Routes = routes
+	d.info.LastUpdated = time.Now()
+
+	return routes, nil
+}
+
+// vrfCommand returns cmd scoped to vrf ("show arp" becomes "show arp vrf mgmt");
+// the default VRF uses the plain command, which every firmware supports
+func vrfCommand(cmd, vrf string) string {
+	if netmodel.NormalizeVRF(vrf) == "" {
+		return cmd
+	}
+	return cmd + " vrf " + vrf
+}
+
+// vrfInfo is the parsed "show vrf" output
+type vrfInfo struct {
+	names      []string          // VRFs in the order reported
+	interfaces map[string]string // interface name to VRF
+}
+
+// vrfMembership runs "show vrf". Firmware without VRF support rejects the command,
+// which parses as no VRFs.
+func (d *Device) vrfMembership() (vrfInfo, error) {
+	output, err := d.client.ExecuteCommand(CmdShowVRF)
+	if err != nil {
+		return vrfInfo{}, err
+	}
+	return parseVRFs(output), nil
+}
+
+// parseVRFs parses ArubaOS-CX style "show vrf" output
+func parseVRFs(output string) vrfInfo {
+	info := vrfInfo{interfaces: make(map[string]string)}
+	current := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if match := vrfNameRe.FindStringSubmatch(line); match != nil {
+			current = match[1]
+			info.names = append(info.names, current)
+			continue
+		}
+		if current == "" {
+			continue
+		}
+		if match := vrfInterfaceRe.FindStringSubmatch(line); match != nil {
+			info.interfaces[match[1]] = netmodel.NormalizeVRF(current)
+		}
+	}
+
+	return info
+}
+
+// parseRoutes parses "show ip route" output in ArubaOS-Switch table, ArubaOS-CX
+// table (10.08 and later) or older ArubaOS-CX format. vrf is recorded on Switch
+// entries; CX output names its own VRF.
+func parseRoutes(output, vrf string) []netmodel.Route {
+	var routes []netmodel.Route
+	var prefix, prefixVRF string
+	tableVRF := vrf
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if match := routeTableRe.FindStringSubmatch(line); match != nil {
+			metric, _ := strconv.Atoi(match[5])
+			distance, _ := strconv.Atoi(match[6])
+			routes = append(routes, netmodel.Route{
+				VRF:         vrf,
+				Destination: match[1],
+				Gateway:     match[2],
+				Interface:   match[3],
+				Type:        strings.ToLower(match[4]),
+				Metric:      metric,
+				Distance:    distance,
+			})
+			continue
+		}
+
+		if match := routeVRFHeaderRe.FindStringSubmatch(line); match != nil {
+			tableVRF = netmodel.NormalizeVRF(match[1])
+			continue
+		}
+		if match := routeCXTableRe.FindStringSubmatch(line); match != nil {
+			distance, _ := strconv.Atoi(match[5])
+			metric, _ := strconv.Atoi(match[6])
+			route := netmodel.Route{
+				VRF:         tableVRF,
+				Destination: match[1],
+				Gateway:     match[2],
+				Interface:   match[3],
+				Type:        cxOriginTypes[match[4]],
+				Distance:    distance,
+				Metric:      metric,
+			}
+			if route.Type == "" {
+				route.Type = strings.ToLower(match[4])
+			}
+			// Connected and local routes have no next hop; the interface stands in
+			if route.Gateway == "-" {
+				route.Gateway = route.Interface
+			}
+			routes = append(routes, route)
+			continue
+		}
+
+		if match := routePrefixRe.FindStringSubmatch(line); match != nil {
+			prefix, prefixVRF = match[1], netmodel.NormalizeVRF(match[2])
+			continue
+		}
+		if match := routeViaRe.FindStringSubmatch(line); match != nil && prefix != "" {
+			distance, _ := strconv.Atoi(match[2])
+			metric, _ := strconv.Atoi(match[3])
+			route := netmodel.Route{
+				VRF:         prefixVRF,
+				Destination: prefix,
+				Gateway:     match[1],
+				Type:        strings.ToLower(match[4]),
+				Distance:    distance,
+				Metric:      metric,
+			}
+			// Connected routes name the outgoing interface instead of a next hop
+			if _, err := netip.ParseAddr(route.Gateway); err != nil {
+				route.Interface = route.Gateway
+			}
+			routes = append(routes, route)
+		}
+	}
+
+	return routes
+}
@@ -0,0 +1,224 @@
+package genericaruba
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
+)
+
+func TestParseVRFs(t *testing.T) {
+	tests := []struct {
+		name       string
+		output     string
+		names      []string
+		interfaces map[string]string
+	}{
+		{
+			name: "aos-cx",
+			output: `
+VRF Configuration:
+------------------
+VRF Name : default
+        Interfaces     Status
+        -----------------------------
+        vlan1          up
+        vlan10         up
+        1/1/49         down
+
+VRF Name : mgmt
+        Interfaces     Status
+        -----------------------------
+        mgmt           up
+
+VRF Name : tenant-a
+        Interfaces     Status
+        -----------------------------
+        vlan200        up
+`,
+			names: []string{"default", "mgmt", "tenant-a"},
+			interfaces: map[string]string{
+				"vlan1": "", "vlan10": "", "1/1/49": "",
+				"mgmt": "mgmt", "vlan200": "tenant-a",
+			},
+		},
+		{
+			name: "aos-cx without interfaces",
+			output: `
+VRF Configuration:
+------------------
+VRF Name : default
+        Interfaces     Status
+        -----------------------------
+`,
+			names:      []string{"default"},
+			interfaces: map[string]string{},
+		},
+		{
+			name: "arubaos-switch rejects the command",
+			output: `
+Invalid input: vrf
+`,
+			interfaces: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseVRFs(tt.output)
+			if !slices.Equal(got.names, tt.names) {
+				t.Errorf("names = %q, want %q", got.names, tt.names)
+			}
+			if !maps.Equal(got.interfaces, tt.interfaces) {
+				t.Errorf("interfaces = %q, want %q", got.interfaces, tt.interfaces)
+			}
+		})
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		vrf    string
+		want   []netmodel.Route
+	}{
+		{
+			name: "arubaos-switch",
+			output: `
+                                IP Route Entries
+
+  Destination        Gateway         VLAN Type      Sub-Type   Metric     Dist.
+  ------------------ --------------- ---- --------- ---------- ---------- -----
+  0.0.0.0/0          10.0.100.1      100  static               1          1
+  10.0.100.0/24      VLAN100         100  connected            1          0
+  10.20.0.0/16       10.0.100.254    100  ospf      IntraArea  20         110
+  127.0.0.0/8        reject               static               0          0
+  127.0.0.1/32       lo0                  connected            1          0
+`,
+			want: []netmodel.Route{
+				{Destination: "0.0.0.0/0", Gateway: "10.0.100.1", Interface: "100", Type: "static", Metric: 1, Distance: 1},
+				{Destination: "10.0.100.0/24", Gateway: "VLAN100", Interface: "100", Type: "connected", Metric: 1, Distance: 0},
+				{Destination: "10.20.0.0/16", Gateway: "10.0.100.254", Interface: "100", Type: "ospf", Metric: 20, Distance: 110},
+			},
+		},
+		{
+			name: "arubaos-switch in a vrf",
+			output: `
+  Destination        Gateway         VLAN Type      Sub-Type   Metric     Dist.
+  ------------------ --------------- ---- --------- ---------- ---------- -----
+  192.168.50.0/24    VLAN50          50   connected            1          0
+`,
+			vrf: "tenant-a",
+			want: []netmodel.Route{
+				{VRF: "tenant-a", Destination: "192.168.50.0/24", Gateway: "VLAN50", Interface: "50", Type: "connected", Metric: 1, Distance: 0},
+			},
+		},
+		{
+			name: "aos-cx",
+			output: `
+Displaying ipv4 routes selected for forwarding
+
+'[x/y]' denotes [distance/metric]
+
+0.0.0.0/0, vrf default
+	via  10.10.10.1,  [1/0],  static
+10.10.10.0/24, vrf default
+	via  vlan10,  [0/0],  connected
+10.10.10.2/32, vrf default
+	via  vlan10,  [0/0],  local
+10.30.0.0/16, vrf default
+	via  10.10.10.5,  [110/20],  ospf
+	via  10.10.10.6,  [110/20],  ospf
+172.16.1.0/30, vrf default
+	via  1/1/49,  [0/0],  connected
+`,
+			want: []netmodel.Route{
+				{Destination: "0.0.0.0/0", Gateway: "10.10.10.1", Type: "static", Distance: 1},
+				{Destination: "10.10.10.0/24", Gateway: "vlan10", Interface: "vlan10", Type: "connected"},
+				{Destination: "10.10.10.2/32", Gateway: "vlan10", Interface: "vlan10", Type: "local"},
+				{Destination: "10.30.0.0/16", Gateway: "10.10.10.5", Type: "ospf", Distance: 110, Metric: 20},
+				{Destination: "10.30.0.0/16", Gateway: "10.10.10.6", Type: "ospf", Distance: 110, Metric: 20},
+				{Destination: "172.16.1.0/30", Gateway: "1/1/49", Interface: "1/1/49", Type: "connected"},
+			},
+		},
+		{
+			name: "aos-cx vrf",
+			output: `
+Displaying ipv4 routes selected for forwarding
+
+'[x/y]' denotes [distance/metric]
+
+0.0.0.0/0, vrf mgmt
+	via  10.6.9.1,  [1/0],  static
+10.6.9.0/24, vrf mgmt
+	via  mgmt,  [0/0],  connected
+`,
+			vrf: "mgmt",
+			want: []netmodel.Route{
+				{VRF: "mgmt", Destination: "0.0.0.0/0", Gateway: "10.6.9.1", Type: "static", Distance: 1},
+				{VRF: "mgmt", Destination: "10.6.9.0/24", Gateway: "mgmt", Interface: "mgmt", Type: "connected"},
+			},
+		},
+		{
+			name: "aos-cx 10.08 table",
+			output: `
+Displaying ipv4 routes selected for forwarding
+
+Origin Codes: C - connected, S - static, L - local
+              R - RIP, B - BGP, O - OSPF
+Type Codes:   E - External BGP, I - Internal BGP, V - VPN, EV - EVPN
+              IA - OSPF internal area, E1 - OSPF external type 1
+              E2 - OSPF external type 2
+
+VRF: default
+
+Prefix              Nexthop          Interface     VRF(egress)       Origin/   Distance/    Age
+                                                                     Type      Metric
+--------------------------------------------------------------------------------------------------------
+0.0.0.0/0           192.168.1.1      vlan1         -                 S         [1/0]        00h:04m:46s
+10.40.0.0/16        192.168.1.9      vlan1         -                 O/IA      [110/30]     01h:12m:03s
+192.168.1.0/24      -                vlan1         -                 C         [0/0]        -
+192.168.1.10/32     -                vlan1         -                 L         [0/0]        -
+
+Total Route Count : 4
+`,
+			want: []netmodel.Route{
+				{Destination: "0.0.0.0/0", Gateway: "192.168.1.1", Interface: "vlan1", Type: "static", Distance: 1},
+				{Destination: "10.40.0.0/16", Gateway: "192.168.1.9", Interface: "vlan1", Type: "ospf", Distance: 110, Metric: 30},
+				{Destination: "192.168.1.0/24", Gateway: "vlan1", Interface: "vlan1", Type: "connected"},
+				{Destination: "192.168.1.10/32", Gateway: "vlan1", Interface: "vlan1", Type: "local"},
+			},
+		},
+		{
+			name: "aos-cx 10.08 table in a vrf",
+			output: `
+VRF: mgmt
+
+Prefix              Nexthop          Interface     VRF(egress)       Origin/   Distance/    Age
+                                                                     Type      Metric
+--------------------------------------------------------------------------------------------------------
+0.0.0.0/0           10.6.9.1         mgmt          -                 S         [1/0]        00h:10m:12s
+10.6.9.0/24         -                mgmt          -                 C         [0/0]        -
+`,
+			vrf: "mgmt",
+			want: []netmodel.Route{
+				{VRF: "mgmt", Destination: "0.0.0.0/0", Gateway: "10.6.9.1", Interface: "mgmt", Type: "static", Distance: 1},
+				{VRF: "mgmt", Destination: "10.6.9.0/24", Gateway: "mgmt", Interface: "mgmt", Type: "connected"},
+			},
+		},
+		{
+			name:   "no routes",
+			output: "Invalid input: vrf\n",
+			vrf:    "mgmt",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseRoutes(tt.output, tt.vrf)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseRoutes =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package netdevice
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/nzions/fdot/pkg/fdh/netdevice/genericaruba"
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
+)
+
+// driverRequirements maps implemented device types to their declared requirements
+// Add an entry here when adding a new device type to NewDevice
+var driverRequirements = map[DeviceType]func() netmodel.DriverRequirements{
+	GenericAruba: genericaruba.Requirements,
+}
+
+// SupportedDeviceTypes returns all device types with an implemented driver
+func SupportedDeviceTypes() []DeviceType {
+	types := make([]DeviceType, 0, len(driverRequirements))
+	for deviceType := range driverRequirements {
+		types = append(types, deviceType)
+	}
+	slices.Sort(types)
+	return types
+}
+
+// Requirements returns the minimum privilege level and exact commands a driver needs
+func Requirements(deviceType DeviceType) (netmodel.DriverRequirements, error) {
+	requirements, ok := driverRequirements[deviceType]
+	if !ok {
+		return netmodel.DriverRequirements{}, fmt.Errorf("unsupported device type: %s", deviceType)
+	}
+
+	req := requirements()
+	req.DeviceType = string(deviceType)
+	return req, nil
+}
@@ -0,0 +1,96 @@
+package netmodel
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Capacity holds the port, PoE and hardware resource usage of a switch, for capacity planning
+type Capacity struct {
+	Ports     []PortStatus    `json:"ports"`
+	PoE       *PoEBudget      `json:"poe,omitempty"`       // nil on switches without PoE
+	Resources []ResourceUsage `json:"resources,omitempty"` // TCAM and similar, where the platform reports it
+}
+
+// PortStatus is the state of one physical port
+type PortStatus struct {
+	Port    string `json:"port"`
+	Type    string `json:"type"` // media/speed class as reported, e.g. "100/1000T" or "SFP+SR"; empty for an empty slot
+	Enabled bool   `json:"enabled"`
+	Up      bool   `json:"up"`
+	Speed   int    `json:"speed_mbps,omitempty"` // negotiated speed, 0 when down or unknown
+}
+
+// PoEBudget is the power-over-ethernet budget of a switch
+type PoEBudget struct {
+	AvailableWatts float64 `json:"available_watts"`
+	UsedWatts      float64 `json:"used_watts"`
+	PoweredPorts   int     `json:"powered_ports"` // ports currently delivering power
+	PoEPorts       int     `json:"poe_ports"`     // PoE-capable ports
+}
+
+// RemainingWatts returns the unused power budget
+func (b *PoEBudget) RemainingWatts() float64 {
+	return b.AvailableWatts - b.UsedWatts
+}
+
+// ResourceUsage is the consumption of one hardware resource (e.g. ACL/QoS rules in TCAM)
+type ResourceUsage struct {
+	Resource  string `json:"resource"` // e.g. "rules" or "meters"
+	Slot      string `json:"slot,omitempty"`
+	Used      int    `json:"used"`
+	Available int    `json:"available"` // still free
+}
+
+// Percent returns the used share of the resource, 0-100
+func (r ResourceUsage) Percent() float64 {
+	total := r.Used + r.Available
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(r.Used) / float64(total)
+}
+
+// PortSummary counts ports of one type
+type PortSummary struct {
+	Type      string `json:"type"`
+	Total     int    `json:"total"`
+	Used      int    `json:"used"`      // link up
+	Available int    `json:"available"` // link down, including administratively disabled ports
+}
+
+// PortSummary groups the physical ports by type (the speed/media class, such as
+// "100/1000T" or "SFP+SR"), sorted by type; empty slots are reported as "empty"
+func (c *Capacity) PortSummary() []PortSummary {
+	byType := make(map[string]*PortSummary)
+	for _, p := range c.Ports {
+		t := p.Type
+		if t == "" {
+			t = "empty"
+		}
+		s, ok := byType[t]
+		if !ok {
+			s = &PortSummary{Type: t}
+			byType[t] = s
+		}
+		s.Total++
+		if p.Up {
+			s.Used++
+		} else {
+			s.Available++
+		}
+	}
+
+	summaries := make([]PortSummary, 0, len(byType))
+	for _, s := range byType {
+		summaries = append(summaries, *s)
+	}
+	slices.SortFunc(summaries, func(a, b PortSummary) int { return strings.Compare(a.Type, b.Type) })
+	return summaries
+}
+
+// String formats a PortSummary as "used/total type"
+func (s PortSummary) String() string {
+	return fmt.Sprintf("%d/%d %s", s.Used, s.Total, s.Type)
+}
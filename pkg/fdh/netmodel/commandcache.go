@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/nzions/fdot/pkg/fdh"
 )
 
 // CommandCache manages reading and writing command outputs to disk
@@ -64,12 +66,12 @@ func (c *CommandCache) SaveOutput(deviceIP, command, output string) error {
 
 	// Ensure directory exists
 	dir := filepath.Dir(filePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	if err := fdh.CreatePrivateDir(dir); err != nil {
 		return fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
 	// Write output to file
-	if err := os.WriteFile(filePath, []byte(output), 0644); err != nil {
+	if err := fdh.WritePrivateFile(filePath, []byte(output)); err != nil {
 		return fmt.Errorf("failed to write cache file: %w", err)
 	}
 
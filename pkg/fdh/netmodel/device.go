@@ -16,6 +16,17 @@ type Device interface {
 	GetInterfaces() ([]Interface, error)
 	GetNeighbors() ([]Neighbor, error)
 
+	// Forwarding tables and inventory
+	GetMACTable() ([]MACEntry, error)
+	GetARPTable() ([]ARPEntry, error)
+	GetInventory() ([]InventoryItem, error)
+	GetDHCPBindings() ([]DHCPBinding, error)
+	GetCapacity() (*Capacity, error)
+
+	// Routing (VRF-aware: ARP and routes are collected from every VRF)
+	GetVRFs() ([]string, error)
+	GetRoutes() ([]Route, error)
+
 	// Data access
 	GetDeviceInfo() *DeviceInfo
 	SetIPAddress(ip string)
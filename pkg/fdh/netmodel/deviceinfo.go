@@ -1,6 +1,9 @@
 package netmodel
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // CacheConfig holds configuration for command output caching
 type CacheConfig struct {
@@ -23,6 +26,18 @@ func DefaultCacheConfig() *CacheConfig {
 	}
 }
 
+// DefaultVRF is the name devices use for the global routing table.
+// Records in the default VRF store an empty VRF field.
+const DefaultVRF = "default"
+
+// NormalizeVRF returns the VRF field value for a device-reported VRF name
+func NormalizeVRF(name string) string {
+	if strings.EqualFold(name, DefaultVRF) {
+		return ""
+	}
+	return name
+}
+
 // DeviceInfo holds the discovered device information
 type DeviceInfo struct {
 	// Identification
@@ -34,6 +49,11 @@ type DeviceInfo struct {
 	Serial    string `json:"serial"`
 	Uptime    string `json:"uptime"`
 
+	// Login text: the pre-auth banner and post-login MOTD, which often carry
+	// asset tags, site codes and ownership information
+	Banner string `json:"banner,omitempty"`
+	MOTD   string `json:"motd,omitempty"`
+
 	// Discovery metadata
 	DiscoveredAt time.Time `json:"discovered_at"`
 	LastUpdated  time.Time `json:"last_updated"`
@@ -42,6 +62,21 @@ type DeviceInfo struct {
 	Interfaces []Interface `json:"interfaces"`
 	Neighbors  []Neighbor  `json:"neighbors"`
 
+	// Forwarding tables and hardware inventory (collected by full crawls only)
+	MACTable  []MACEntry      `json:"mac_table,omitempty"`
+	ARPTable  []ARPEntry      `json:"arp_table,omitempty"`
+	Inventory []InventoryItem `json:"inventory,omitempty"`
+
+	// VRFs configured on the device and the routing table of each (collected by full crawls only)
+	VRFs   []string `json:"vrfs,omitempty"`
+	Routes []Route  `json:"routes,omitempty"`
+
+	// DHCP snooping bindings (collected by full crawls only)
+	DHCPBindings []DHCPBinding `json:"dhcp_bindings,omitempty"`
+
+	// Port, PoE and hardware resource usage (collected by full crawls only)
+	Capacity *Capacity `json:"capacity,omitempty"`
+
 	// Raw command outputs (for reference)
 	RawOutputDir string `json:"raw_output_dir"`
 }
@@ -52,7 +87,7 @@ type Interface struct {
 	Description string `json:"description"`
 	IPAddress   string `json:"ip_address"`
 	Subnet      string `json:"subnet"`
-	VRF         string `json:"vrf,omitempty"` // VRF (Virtual Routing and Forwarding) instance
+	VRF         string `json:"vrf,omitempty"` // VRF (Virtual Routing and Forwarding) instance, empty for the default VRF
 	Status      string `json:"status"`        // up/down
 	Protocol    string `json:"protocol"`      // up/down
 	VLANs       []int  `json:"vlans"`
@@ -68,6 +103,41 @@ type Neighbor struct {
 	Capabilities    string `json:"capabilities"`
 }
 
+// MACEntry represents a learned MAC address table entry
+type MACEntry struct {
+	MAC  string `json:"mac"`
+	VLAN int    `json:"vlan"`
+	Port string `json:"port"`
+}
+
+// ARPEntry represents an IP to MAC address resolution entry
+type ARPEntry struct {
+	IPAddress string `json:"ip_address"`
+	MAC       string `json:"mac"`
+	Type      string `json:"type"` // dynamic/static
+	Port      string `json:"port"`
+	VRF       string `json:"vrf,omitempty"` // empty for the default VRF
+}
+
+// Route represents an entry in a device routing table
+type Route struct {
+	VRF         string `json:"vrf,omitempty"` // empty for the default VRF
+	Destination string `json:"destination"`   // prefix in CIDR notation
+	Gateway     string `json:"gateway"`       // next hop address or outgoing interface
+	Interface   string `json:"interface,omitempty"`
+	Type        string `json:"type"` // connected/static/ospf/bgp/...
+	Distance    int    `json:"distance"`
+	Metric      int    `json:"metric"`
+}
+
+// InventoryItem represents a chassis, module or transceiver
+type InventoryItem struct {
+	Slot        string `json:"slot"`
+	Description string `json:"description"`
+	Serial      string `json:"serial"`
+	Status      string `json:"status,omitempty"`
+}
+
 // CommandOutput stores raw command output for a device
 type CommandOutput struct {
 	DeviceIP   string    `json:"device_ip"`
@@ -0,0 +1,245 @@
+package netmodel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh"
+)
+
+// Host is an endpoint observed across the network, keyed by MAC address.
+// It aggregates MAC table, ARP and DHCP snooping observations from every
+// crawled device into one record (used by find and port-report features).
+type Host struct {
+	MAC     string   `json:"mac"` // normalized aa:bb:cc:dd:ee:ff
+	IPs     []string `json:"ips,omitempty"`
+	DNSName string   `json:"dns_name,omitempty"`
+
+	// Where the host attaches: the edge switch port it was learned on
+	SwitchIP       string `json:"switch_ip,omitempty"`
+	SwitchHostname string `json:"switch_hostname,omitempty"`
+	Port           string `json:"port,omitempty"`
+	VLAN           int    `json:"vlan,omitempty"`
+
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// DHCPBinding represents a DHCP snooping binding table entry
+type DHCPBinding struct {
+	MAC       string `json:"mac"`
+	IPAddress string `json:"ip_address"`
+	VLAN      int    `json:"vlan"`
+	Port      string `json:"port"`
+}
+
+// HostIndex holds Host records and persists them as JSON
+type HostIndex struct {
+	path  string
+	mu    sync.RWMutex
+	hosts map[string]*Host
+}
+
+// LoadHostIndex opens the host index stored at path; a missing file yields an empty index
+func LoadHostIndex(path string) (*HostIndex, error) {
+	idx := &HostIndex{path: path, hosts: make(map[string]*Host)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return idx, nil
+		}
+		return nil, fmt.Errorf("failed to read host index: %w", err)
+	}
+
+	var hosts []*Host
+	if err := json.Unmarshal(data, &hosts); err != nil {
+		return nil, fmt.Errorf("failed to parse host index %s: %w", path, err)
+	}
+	for _, h := range hosts {
+		idx.hosts[h.MAC] = h
+	}
+	return idx, nil
+}
+
+// Save writes the index back to the file it was loaded from
+func (idx *HostIndex) Save() error {
+	data, err := json.MarshalIndent(idx.Hosts(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal host index: %w", err)
+	}
+	if err := fdh.WritePrivateFileAtomic(idx.path, data); err != nil {
+		return fmt.Errorf("failed to save host index: %w", err)
+	}
+	return nil
+}
+
+// Observe merges the MAC, ARP and DHCP snooping data of one crawled device.
+// Attachment (switch/port/VLAN) is only taken from edge ports, i.e. ports with
+// no LLDP neighbor, so hosts are not attributed to uplinks.
+func (idx *HostIndex) Observe(device *DeviceInfo, seen time.Time) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	uplinks := make(map[string]bool, len(device.Neighbors))
+	for _, n := range device.Neighbors {
+		uplinks[n.LocalInterface] = true
+	}
+
+	for _, e := range device.MACTable {
+		h := idx.touch(e.MAC, seen)
+		if h == nil || uplinks[e.Port] {
+			continue
+		}
+		h.SwitchIP = device.IPAddress
+		h.SwitchHostname = device.Hostname
+		h.Port = e.Port
+		h.VLAN = e.VLAN
+	}
+
+	for _, e := range device.ARPTable {
+		if h := idx.touch(e.MAC, seen); h != nil {
+			h.addIP(e.IPAddress)
+		}
+	}
+
+	for _, b := range device.DHCPBindings {
+		h := idx.touch(b.MAC, seen)
+		if h == nil {
+			continue
+		}
+		h.addIP(b.IPAddress)
+		// Snooping bindings are recorded on the access port, so they are authoritative
+		if b.Port != "" && !uplinks[b.Port] {
+			h.SwitchIP = device.IPAddress
+			h.SwitchHostname = device.Hostname
+			h.Port = b.Port
+			h.VLAN = b.VLAN
+		}
+	}
+}
+
+// touch returns the host for mac, creating it on first sight; nil for an invalid MAC
+func (idx *HostIndex) touch(mac string, seen time.Time) *Host {
+	mac = NormalizeMAC(mac)
+	if mac == "" {
+		return nil
+	}
+	h, ok := idx.hosts[mac]
+	if !ok {
+		h = &Host{MAC: mac, FirstSeen: seen}
+		idx.hosts[mac] = h
+	}
+	if seen.After(h.LastSeen) {
+		h.LastSeen = seen
+	}
+	return h
+}
+
+func (h *Host) addIP(ip string) {
+	if ip != "" && !slices.Contains(h.IPs, ip) {
+		h.IPs = append(h.IPs, ip)
+		sort.Strings(h.IPs)
+	}
+}
+
+// ResolveNames fills DNSName for unnamed hosts that have an IP address using reverse DNS.
+// It stops early when ctx is done.
+func (idx *HostIndex) ResolveNames(ctx context.Context, resolver *net.Resolver) {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	unnamed := idx.Query(func(h *Host) bool { return h.DNSName == "" && len(h.IPs) > 0 })
+	for _, h := range unnamed {
+		if ctx.Err() != nil {
+			return
+		}
+		for _, ip := range h.IPs {
+			names, err := resolver.LookupAddr(ctx, ip)
+			if err != nil || len(names) == 0 {
+				continue
+			}
+			idx.mu.Lock()
+			h.DNSName = strings.TrimSuffix(names[0], ".")
+			idx.mu.Unlock()
+			break
+		}
+	}
+}
+
+// Get returns the host with the given MAC address (any common notation)
+func (idx *HostIndex) Get(mac string) (*Host, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	h, ok := idx.hosts[NormalizeMAC(mac)]
+	return h, ok
+}
+
+// Hosts returns all hosts sorted by MAC address
+func (idx *HostIndex) Hosts() []*Host {
+	return idx.Query(func(*Host) bool { return true })
+}
+
+// Query returns hosts matching the predicate, sorted by MAC address
+func (idx *HostIndex) Query(match func(*Host) bool) []*Host {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var hosts []*Host
+	for _, h := range idx.hosts {
+		if match(h) {
+			hosts = append(hosts, h)
+		}
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].MAC < hosts[j].MAC })
+	return hosts
+}
+
+// FindByIP returns hosts that have been seen with the given IP address
+func (idx *HostIndex) FindByIP(ip string) []*Host {
+	return idx.Query(func(h *Host) bool { return slices.Contains(h.IPs, ip) })
+}
+
+// OnPort returns hosts attached to the given switch port
+func (idx *HostIndex) OnPort(switchIP, port string) []*Host {
+	return idx.Query(func(h *Host) bool { return h.SwitchIP == switchIP && h.Port == port })
+}
+
+// NormalizeMAC converts a MAC address in colon, dash, dot or Aruba "aabbcc-ddeeff"
+// notation to lower-case colon form; returns "" if mac is not a 48-bit address
+func NormalizeMAC(mac string) string {
+	hex := strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '-', '.':
+			return -1
+		}
+		return r
+	}, strings.ToLower(mac))
+
+	if len(hex) != 12 {
+		return ""
+	}
+	for _, r := range hex {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return ""
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < 12; i += 2 {
+		if i > 0 {
+			b.WriteByte(':')
+		}
+		b.WriteString(hex[i : i+2])
+	}
+	return b.String()
+}
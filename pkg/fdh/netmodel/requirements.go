@@ -0,0 +1,15 @@
+package netmodel
+
+// DriverRequirements declares the minimum access a device driver needs
+// Security teams use this to build least-privilege command authorization (e.g. TACACS+)
+// for the crawler account
+type DriverRequirements struct {
+	// DeviceType is the driver's device type identifier
+	DeviceType string `json:"device_type"`
+	// Privilege is the minimum privilege level required to run all commands
+	Privilege string `json:"privilege"`
+	// Commands lists every exact command the driver may execute (all read-only)
+	Commands []string `json:"commands"`
+	// Notes explains any vendor-specific authorization detail
+	Notes string `json:"notes,omitempty"`
+}
@@ -0,0 +1,97 @@
+package netssh
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
+)
+
+// ErrFixtureNotFound is returned in replay mode when no fixture exists for a command
+var ErrFixtureNotFound = errors.New("no recorded fixture for command")
+
+// CaptureMode selects whether a client records or replays device sessions
+type CaptureMode int
+
+const (
+	// CaptureOff executes commands against the device without capturing anything
+	CaptureOff CaptureMode = iota
+	// CaptureRecord executes commands against the device and saves sanitized
+	// command/response pairs as fixture files
+	CaptureRecord
+	// CaptureReplay serves command output from fixture files without any network access.
+	// Only the commands run while recording are available, so fixtures go stale when a
+	// collector starts running new ones: genericaruba's GetInterfaces, GetVRFs,
+	// GetARPTable and GetRoutes need "show vrf", and fixtures recorded before they did
+	// fail with ErrFixtureNotFound until they are recorded again.
+	CaptureReplay
+)
+
+// CaptureConfig holds configuration for record-and-replay of device sessions
+type CaptureConfig struct {
+	Mode CaptureMode
+	// Dir is the fixture directory (one subdirectory per device, one file per command)
+	Dir string
+	// Sanitize scrubs sensitive data from output before it is recorded
+	// If nil, SanitizeOutput is used
+	Sanitize func(string) string
+}
+
+// sensitiveValueRe matches configuration keywords that are followed by secret values.
+// Any number of qualifiers may sit between the keyword and the value: encodings and
+// hash types ("ciphertext", "sha1", "aes", "7"), ArubaOS-Switch access levels
+// ("manager", "operator") with their user-name, and the auth-pass/priv-pass markers of
+// AOS-CX SNMPv3 users. Matches never cross a line.
+var sensitiveValueRe = regexp.MustCompile(`(?i)\b(auth-pass|priv-pass|pre-shared-key|password|passwd|secret|community|key|auth|priv)` +
+	`((?:[ \t]+(?:ciphertext|plaintext|encrypted|sha1|sha256|sha512|sha|md5|aes|des|manager|operator|auth-pass|priv-pass|user-name[ \t]+(?:"[^"\n]*"|\S+)|[0-9]))*[ \t]+)` +
+	`("[^"\n]*"|\S+)`)
+
+// SanitizeOutput redacts passwords, SNMP communities and keys from command output
+func SanitizeOutput(output string) string {
+	return sensitiveValueRe.ReplaceAllString(output, "${1}${2}<redacted>")
+}
+
+// fixtureStore stores fixtures using the command cache file layout with no expiry
+type fixtureStore struct {
+	cache    *netmodel.CommandCache
+	sanitize func(string) string
+}
+
+// newFixtureStore creates a fixture store for the given capture configuration
+// Returns nil when capture is disabled
+func newFixtureStore(cfg *CaptureConfig) *fixtureStore {
+	if cfg == nil || cfg.Mode == CaptureOff {
+		return nil
+	}
+
+	sanitize := cfg.Sanitize
+	if sanitize == nil {
+		sanitize = SanitizeOutput
+	}
+
+	return &fixtureStore{
+		cache: netmodel.NewCommandCache(&netmodel.CacheConfig{
+			Enabled: true,
+			TTL:     time.Duration(math.MaxInt64), // Fixtures never expire
+			BaseDir: cfg.Dir,
+		}),
+		sanitize: sanitize,
+	}
+}
+
+// load returns the recorded output for a command
+func (f *fixtureStore) load(host, cmd string) (string, error) {
+	output, found := f.cache.GetCachedOutput(host, cmd)
+	if !found {
+		return "", fmt.Errorf("%w: %s (host %s)", ErrFixtureNotFound, cmd, host)
+	}
+	return output, nil
+}
+
+// save records sanitized output for a command
+func (f *fixtureStore) save(host, cmd, output string) error {
+	return f.cache.SaveOutput(host, cmd, f.sanitize(output))
+}
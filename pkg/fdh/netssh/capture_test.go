@@ -0,0 +1,236 @@
+package netssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nzions/fdot/pkg/fdh/credmgr"
+	"github.com/nzions/fdot/pkg/fdh/netmodel"
+	"golang.org/x/crypto/ssh"
+)
+
+// startFakeSSH serves exec requests from outputs on a local port until the test ends,
+// sending banner before authentication. It returns the port.
+func startFakeSSH(t *testing.T, banner string, outputs map[string]string) int {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() != "admin" || string(password) != "pw" {
+				return nil, errors.New("bad password")
+			}
+			return nil, nil
+		},
+		BannerCallback: func(ssh.ConnMetadata) string { return banner },
+	}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeSSH(conn, config, outputs)
+		}
+	}()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func serveFakeSSH(conn net.Conn, config *ssh.ServerConfig, outputs map[string]string) {
+	defer conn.Close()
+	_, channels, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "session channels only")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				switch req.Type {
+				case "pty-req":
+					req.Reply(true, nil)
+				case "exec":
+					var payload struct{ Command string }
+					if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+						req.Reply(false, nil)
+						return
+					}
+					req.Reply(true, nil)
+					channel.Write([]byte(outputs[payload.Command]))
+					channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+					return
+				default:
+					req.Reply(false, nil)
+				}
+			}
+		}()
+	}
+}
+
+func TestCaptureRecordAndReplay(t *testing.T) {
+	const banner = "Authorized use only. Asset SW-0042"
+	port := startFakeSSH(t, banner, map[string]string{
+		"show version":        "ArubaOS-CX Version : FL.10.13.1000\n",
+		"show running-config": "hostname sw1\nsnmp-server community s3cret\n",
+	})
+	dir := t.TempDir()
+	newTestClient := func(mode CaptureMode, port int) *Client {
+		return NewClient(context.Background(), Config{
+			Host:        "127.0.0.1",
+			Port:        port,
+			Credentials: credmgr.NewUnPw("admin", "pw"),
+			Timeout:     5 * time.Second,
+			CacheConfig: &netmodel.CacheConfig{Enabled: false},
+			Capture:     &CaptureConfig{Mode: mode, Dir: dir},
+		})
+	}
+
+	// Record against the fake device
+	recorder := newTestClient(CaptureRecord, port)
+	if err := recorder.Connect(); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	for _, cmd := range []string{"show version", "show running-config"} {
+		if _, err := recorder.ExecuteCommand(cmd); err != nil {
+			t.Fatalf("ExecuteCommand(%q) failed: %v", cmd, err)
+		}
+	}
+	recorder.Close()
+
+	// Secrets never reach the fixture files
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err == nil && strings.Contains(string(data), "s3cret") {
+			t.Errorf("fixture %s holds the SNMP community", path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replay with nothing listening on the device's port
+	replayer := newTestClient(CaptureReplay, 1)
+	if err := replayer.Connect(); err != nil {
+		t.Fatalf("Connect in replay mode failed: %v", err)
+	}
+	defer replayer.Close()
+	if got := replayer.Banner(); got != banner {
+		t.Errorf("Banner = %q, want %q", got, banner)
+	}
+	if got, err := replayer.ExecuteCommand("show version"); err != nil || !strings.Contains(got, "FL.10.13.1000") {
+		t.Errorf("replayed show version = %q, %v", got, err)
+	}
+	got, err := replayer.ExecuteCommand("show running-config")
+	if err != nil || !strings.Contains(got, "snmp-server community <redacted>") {
+		t.Errorf("replayed show running-config = %q, %v; want the community redacted", got, err)
+	}
+	if _, err := replayer.ExecuteCommand("show vrf"); !errors.Is(err, ErrFixtureNotFound) {
+		t.Errorf("replay of an unrecorded command error = %v, want ErrFixtureNotFound", err)
+	}
+	if _, err := replayer.Begin(JunOS, TxOptions{}); err == nil {
+		t.Error("Begin succeeded in replay mode")
+	}
+}
+
+func TestSanitizeOutput(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		// ArubaOS-Switch
+		{
+			"switch manager password",
+			`password manager user-name "admin" sha1 "5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8"`,
+			`password manager user-name "admin" sha1 <redacted>`,
+		},
+		{
+			"switch operator password",
+			`password operator user-name "ops" plaintext "Op3rator!"`,
+			`password operator user-name "ops" plaintext <redacted>`,
+		},
+		{
+			"switch snmp community",
+			`snmp-server community "n0tpublic" operator`,
+			`snmp-server community <redacted> operator`,
+		},
+		{
+			"switch snmpv3 user",
+			`snmpv3 user "mon" auth sha "authpass1" priv aes "privpass1"`,
+			`snmpv3 user "mon" auth sha <redacted> priv aes <redacted>`,
+		},
+		{
+			"switch radius key",
+			`radius-server host 10.0.0.5 key "r4diusKey"`,
+			`radius-server host 10.0.0.5 key <redacted>`,
+		},
+		// AOS-CX
+		{
+			"cx local user",
+			`user admin group administrators password ciphertext AQBapWzY0VPlc1RnK8oPqGm2`,
+			`user admin group administrators password ciphertext <redacted>`,
+		},
+		{
+			"cx snmpv3 user",
+			`snmpv3 user mon auth sha auth-pass ciphertext AQBapAuth1 priv aes priv-pass ciphertext AQBapPriv1`,
+			`snmpv3 user mon auth sha auth-pass ciphertext <redacted> priv aes priv-pass ciphertext <redacted>`,
+		},
+		{
+			"cx tacacs key",
+			`tacacs-server host 10.1.1.1 key plaintext t4cacsKey`,
+			`tacacs-server host 10.1.1.1 key plaintext <redacted>`,
+		},
+		{
+			"cx snmp community",
+			`snmp-server community s3cret`,
+			`snmp-server community <redacted>`,
+		},
+		// Other platforms and lines without secrets
+		{"cisco type 7", `username admin password 7 0822455D0A16`, `username admin password 7 <redacted>`},
+		{"no secret", `interface 1/1/1`, `interface 1/1/1`},
+		{"keyword ends a line", "aaa authentication login default local\npassword\nhostname sw1", "aaa authentication login default local\npassword\nhostname sw1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeOutput(tt.line); got != tt.want {
+				t.Errorf("SanitizeOutput(%q)\n got %q\nwant %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package netssh
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/nzions/fdot/pkg/fdh/credmgr"
@@ -11,13 +14,23 @@ import (
 	"golang.org/x/crypto/ssh"
 )
 
+// ErrAuthFailed is returned by Connect when the device rejects the credentials
+var ErrAuthFailed = errors.New("authentication failed")
+
 // Client represents an SSH client configured for network devices
 type Client struct {
-	config *ssh.ClientConfig
-	conn   *ssh.Client
-	host   string
-	port   int
-	cache  *netmodel.CommandCache
+	config   *ssh.ClientConfig
+	conn     *ssh.Client
+	host     string
+	port     int
+	cache    *netmodel.CommandCache
+	fixtures *fixtureStore
+	mode     CaptureMode
+
+	// Login text captured from the device
+	banner       string
+	motd         string
+	motdCaptured bool
 }
 
 // Config holds configuration for creating a network SSH client
@@ -27,6 +40,16 @@ type Config struct {
 	Credentials credmgr.UserCred
 	Timeout     time.Duration
 	CacheConfig *netmodel.CacheConfig // Optional cache configuration
+	Capture     *CaptureConfig        // Optional record-and-replay configuration
+
+	// Hosts that are not network devices can be verified and logged in to without a
+	// stored credential. User is the login name when Credentials is nil, Auth methods
+	// are tried before Credentials, and a nil HostKeyCallback accepts any host key.
+	// HostKeyAlgorithms, when set, limits the key types the host may present.
+	User              string
+	Auth              []ssh.AuthMethod
+	HostKeyCallback   ssh.HostKeyCallback
+	HostKeyAlgorithms []string
 }
 
 // NewClient creates a new SSH client configured for network devices
@@ -40,20 +63,69 @@ func NewClient(ctx context.Context, cfg Config) *Client {
 	if cfg.CacheConfig == nil {
 		cfg.CacheConfig = netmodel.DefaultCacheConfig()
 	}
+	mode := CaptureOff
+	if cfg.Capture != nil {
+		mode = cfg.Capture.Mode
+	}
 
-	return &Client{
+	user, auth := cfg.User, append([]ssh.AuthMethod(nil), cfg.Auth...)
+	if cfg.Credentials != nil {
+		user = cfg.Credentials.Username()
+		auth = append(auth, authMethod(cfg.Credentials))
+	}
+	hostKeyCallback := cfg.HostKeyCallback
+	if hostKeyCallback == nil {
+		hostKeyCallback = ssh.InsecureIgnoreHostKey() // For network devices, typically don't validate host keys
+	}
+
+	c := &Client{
 		config: &ssh.ClientConfig{
-			User: cfg.Credentials.Username(),
-			Auth: []ssh.AuthMethod{
-				ssh.Password(cfg.Credentials.Password()),
-			},
-			HostKeyCallback: ssh.InsecureIgnoreHostKey(), // For network devices, typically don't validate host keys
-			Timeout:         cfg.Timeout,
+			User:              user,
+			Auth:              auth,
+			HostKeyCallback:   hostKeyCallback,
+			HostKeyAlgorithms: cfg.HostKeyAlgorithms,
+			Timeout:           cfg.Timeout,
 		},
-		host:  cfg.Host,
-		port:  cfg.Port,
-		cache: netmodel.NewCommandCache(cfg.CacheConfig),
+		host:     cfg.Host,
+		port:     cfg.Port,
+		cache:    netmodel.NewCommandCache(cfg.CacheConfig),
+		fixtures: newFixtureStore(cfg.Capture),
+		mode:     mode,
+	}
+	c.config.BannerCallback = func(message string) error {
+		c.banner = strings.TrimSpace(message)
+		return nil
 	}
+	return c
+}
+
+// authMethod logs in with the private key of an SSHKeyCred, or with the password.
+// A key that does not parse fails the login with the parse error.
+func authMethod(cred credmgr.UserCred) ssh.AuthMethod {
+	keyCred, ok := cred.(credmgr.SSHKeyCred)
+	if !ok {
+		return ssh.Password(cred.Password())
+	}
+
+	key, passphrase := keyCred.PrivateKey(), keyCred.Passphrase()
+	defer clear(key)
+	defer clear(passphrase)
+	var signer ssh.Signer
+	var err error
+	if len(passphrase) > 0 {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, passphrase)
+	} else {
+		signer, err = ssh.ParsePrivateKey(key)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to parse private key for %s: %w", cred.Username(), err)
+	}
+	return ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		if err != nil {
+			return nil, err
+		}
+		return []ssh.Signer{signer}, nil
+	})
 }
 
 // ExecuteOption is a functional option for configuring command execution
@@ -79,20 +151,34 @@ func OptTimeout(timeout time.Duration) ExecuteOption {
 	}
 }
 
-// Connect establishes the SSH connection
+// Connect establishes the SSH connection and captures the login banner
+// In replay mode no connection is made and the recorded banner is restored
 func (c *Client) Connect() error {
+	c.banner, c.motd, c.motdCaptured = "", "", false
+	if c.mode == CaptureReplay {
+		return c.captureBanner()
+	}
 	addr := fmt.Sprintf("%s:%d", c.host, c.port)
 	conn, err := ssh.Dial("tcp", addr, c.config)
 	if err != nil {
+		// x/crypto/ssh does not export a typed error for rejected credentials
+		if strings.Contains(err.Error(), "unable to authenticate") {
+			return fmt.Errorf("failed to dial %s: %w: %w", addr, ErrAuthFailed, err)
+		}
 		return fmt.Errorf("failed to dial %s: %w", addr, err)
 	}
 	c.conn = conn
-	return nil
+	return c.captureBanner()
 }
 
 // ExecuteCommand executes a command on the remote device and returns the output
 // Supports functional options for configuration (OptNoCache, OptTimeout, etc.)
 func (c *Client) ExecuteCommand(cmd string, opts ...ExecuteOption) (string, error) {
+	// Replay mode serves recorded output and never touches the network
+	if c.mode == CaptureReplay {
+		return c.fixtures.load(c.host, cmd)
+	}
+
 	if c.conn == nil {
 		return "", fmt.Errorf("not connected - call Connect() first")
 	}
@@ -106,8 +192,8 @@ func (c *Client) ExecuteCommand(cmd string, opts ...ExecuteOption) (string, erro
 		opt(execOpts)
 	}
 
-	// Check cache first (unless disabled)
-	if !execOpts.noCache {
+	// Check cache first (unless disabled or recording fixtures)
+	if !execOpts.noCache && c.mode != CaptureRecord {
 		if cachedOutput, found := c.cache.GetCachedOutput(c.host, cmd); found {
 			return cachedOutput, nil
 		}
@@ -119,6 +205,13 @@ func (c *Client) ExecuteCommand(cmd string, opts ...ExecuteOption) (string, erro
 		return "", err
 	}
 
+	// Record sanitized fixture
+	if c.mode == CaptureRecord {
+		if err := c.fixtures.save(c.host, cmd, output); err != nil {
+			return "", fmt.Errorf("failed to record fixture: %w", err)
+		}
+	}
+
 	// Save to cache (unless disabled)
 	if !execOpts.noCache {
 		_ = c.cache.SaveOutput(c.host, cmd, output)
@@ -212,6 +305,37 @@ func (c *Client) executeCommandInternal(cmd string, opts *executeOptions) (strin
 	}
 }
 
+// Run executes a command without a pseudo terminal or caching, feeding stdin to it
+// (if non-nil) and returning stdout. Intended for host-side tooling such as file
+// transfer rather than network device CLIs.
+func (c *Client) Run(cmd string, stdin io.Reader) ([]byte, error) {
+	if c.conn == nil {
+		return nil, fmt.Errorf("not connected - call Connect() first")
+	}
+
+	session, err := c.conn.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdin = stdin
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	if err := session.Run(cmd); err != nil {
+		return nil, fmt.Errorf("command failed: %w (stderr: %s)", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// Username returns the login name the client authenticates as
+func (c *Client) Username() string {
+	return c.config.User
+}
+
 // Close closes the SSH connection
 func (c *Client) Close() error {
 	if c.conn != nil {
@@ -0,0 +1,175 @@
+package netssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Pseudo-commands used to record and replay login text alongside command fixtures
+const (
+	bannerFixture = "__login_banner__"
+	motdFixture   = "__login_motd__"
+)
+
+// MOTD capture stops once the device has been quiet for motdIdle or after motdMax
+const (
+	motdIdle = 2 * time.Second
+	motdMax  = 10 * time.Second
+)
+
+// Banner returns the pre-authentication banner sent by the device during Connect.
+// Banners often carry asset tags, site codes and ownership information.
+func (c *Client) Banner() string {
+	return c.banner
+}
+
+// MOTD returns the text a device prints when an interactive shell starts
+// (message of the day, exec banner), with the trailing CLI prompt removed.
+// The text is captured once per connection and cached.
+func (c *Client) MOTD() (string, error) {
+	if c.motdCaptured {
+		return c.motd, nil
+	}
+
+	switch c.mode {
+	case CaptureReplay:
+		motd, err := c.fixtures.load(c.host, motdFixture)
+		if err != nil && !errors.Is(err, ErrFixtureNotFound) {
+			return "", err
+		}
+		c.motd = motd
+	default:
+		if c.conn == nil {
+			return "", fmt.Errorf("not connected - call Connect() first")
+		}
+		motd, err := c.captureMOTD()
+		if err != nil {
+			return "", err
+		}
+		c.motd = motd
+		if c.mode == CaptureRecord && motd != "" {
+			if err := c.fixtures.save(c.host, motdFixture, motd); err != nil {
+				return "", fmt.Errorf("failed to record fixture: %w", err)
+			}
+		}
+	}
+
+	c.motdCaptured = true
+	return c.motd, nil
+}
+
+// LoginText returns the banner and, if it has been captured, the MOTD
+func (c *Client) LoginText() string {
+	return strings.TrimSpace(c.banner + "\n" + c.motd)
+}
+
+// captureBanner records the banner as a fixture, or restores it in replay mode
+func (c *Client) captureBanner() error {
+	switch c.mode {
+	case CaptureRecord:
+		if c.banner == "" {
+			return nil
+		}
+		if err := c.fixtures.save(c.host, bannerFixture, c.banner); err != nil {
+			return fmt.Errorf("failed to record fixture: %w", err)
+		}
+	case CaptureReplay:
+		banner, err := c.fixtures.load(c.host, bannerFixture)
+		if err != nil && !errors.Is(err, ErrFixtureNotFound) {
+			return err
+		}
+		c.banner = banner
+	}
+	return nil
+}
+
+// captureMOTD starts an interactive shell and reads its output until the device goes quiet
+func (c *Client) captureMOTD() (string, error) {
+	session, err := c.conn.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          0,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	if err := session.RequestPty("vt100", 80, 40, modes); err != nil {
+		return "", fmt.Errorf("request for pseudo terminal failed: %w", err)
+	}
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return "", fmt.Errorf("failed to get stdout pipe: %w", err)
+	}
+
+	if err := session.Shell(); err != nil {
+		return "", fmt.Errorf("failed to start shell: %w", err)
+	}
+
+	chunks := make(chan []byte)
+	go func() {
+		defer close(chunks)
+		buf := make([]byte, 4096)
+		for {
+			n, err := stdout.Read(buf)
+			if n > 0 {
+				chunks <- bytes.Clone(buf[:n])
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	var output bytes.Buffer
+	deadline := time.After(motdMax)
+	idle := time.NewTimer(motdIdle)
+	defer idle.Stop()
+
+read:
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				break read
+			}
+			output.Write(chunk)
+			idle.Reset(motdIdle)
+		case <-idle.C:
+			break read
+		case <-deadline:
+			break read
+		}
+	}
+
+	// Closing the session unblocks the reader goroutine
+	session.Close()
+	go func() {
+		for range chunks {
+		}
+	}()
+
+	return trimPrompt(output.String()), nil
+}
+
+// trimPrompt normalizes line endings and drops the CLI prompt the shell leaves on the last line
+func trimPrompt(output string) string {
+	output = strings.ReplaceAll(output, "\r\n", "\n")
+	output = strings.ReplaceAll(output, "\r", "")
+
+	if i := strings.LastIndex(output, "\n"); i >= 0 {
+		output = output[:i]
+	} else {
+		// Only a prompt was printed
+		output = ""
+	}
+	return strings.TrimSpace(output)
+}
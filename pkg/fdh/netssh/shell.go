@@ -0,0 +1,192 @@
+package netssh
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// promptRe matches any CLI prompt at the end of the output, such as "user@mx1> " or
+// "sw1(config-if)# ". It is only used until the device's own prompt is known.
+var promptRe = regexp.MustCompile(`(?:^|\n)([^\n]*?)[>#%]\s*$`)
+
+// devicePrompt matches the prompts of the device whose first prompt had the given
+// base ("user@mx1", "sw1"), in any mode: "user@mx1# ", "sw1(config)# "
+func devicePrompt(base string) *regexp.Regexp {
+	return regexp.MustCompile(`(?:^|\n)` + regexp.QuoteMeta(base) + `[^\n]*[>#%]\s*$`)
+}
+
+// shell is an interactive CLI session. Unlike ExecuteCommand, which opens a session
+// per command, state such as configuration mode persists between commands.
+type shell struct {
+	session *ssh.Session // nil when driven directly over a reader and writer
+	stdin   io.Writer
+	chunks  chan []byte
+	pending bytes.Buffer   // output received but not yet returned
+	prompt  *regexp.Regexp // the device prompt once learned
+
+	lastPrompt string // prompt line ending the last output
+}
+
+// openShell starts an interactive shell on the device and waits for the first prompt
+func (c *Client) openShell(timeout time.Duration) (*shell, error) {
+	if c.mode == CaptureReplay {
+		return nil, fmt.Errorf("interactive sessions are not available in replay mode")
+	}
+	if c.conn == nil {
+		return nil, fmt.Errorf("not connected - call Connect() first")
+	}
+
+	session, err := c.conn.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          0,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	// A wide terminal keeps devices from wrapping long configuration lines
+	if err := session.RequestPty("vt100", 511, 40, modes); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("request for pseudo terminal failed: %w", err)
+	}
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to get stdin pipe: %w", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to get stdout pipe: %w", err)
+	}
+
+	if err := session.Shell(); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to start shell: %w", err)
+	}
+
+	sh := newShell(stdout, stdin)
+	sh.session = session
+	if err := sh.start(timeout); err != nil {
+		sh.close()
+		return nil, err
+	}
+	return sh, nil
+}
+
+// start skips the login text and learns the device prompt, so that output lines
+// ending in '#' or '%' are not mistaken for prompts later
+func (sh *shell) start(timeout time.Duration) error {
+	if _, err := sh.readPrompt(timeout); err != nil {
+		return err
+	}
+	// The login text may itself end in something prompt-like; an empty line
+	// makes the device print a fresh prompt on its own
+	if _, err := sh.send("", timeout); err != nil {
+		return err
+	}
+
+	if m := promptRe.FindStringSubmatch(sh.lastPrompt); m != nil && strings.TrimSpace(m[1]) != "" {
+		base := strings.TrimSpace(m[1])
+		// Mode suffixes come after the hostname: "sw1(config)", "user@mx1"
+		if i := strings.IndexByte(base, '('); i > 0 {
+			base = base[:i]
+		}
+		sh.prompt = devicePrompt(base)
+	}
+	return nil
+}
+
+// newShell drives a CLI over r (device output) and w (device input)
+func newShell(r io.Reader, w io.Writer) *shell {
+	sh := &shell{stdin: w, chunks: make(chan []byte)}
+	go func() {
+		defer close(sh.chunks)
+		buf := make([]byte, 4096)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				sh.chunks <- bytes.Clone(buf[:n])
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return sh
+}
+
+// send writes line to the device and returns its output up to the next prompt,
+// without the echoed command or the prompt itself
+func (sh *shell) send(line string, timeout time.Duration) (string, error) {
+	if _, err := io.WriteString(sh.stdin, line+"\n"); err != nil {
+		return "", fmt.Errorf("failed to send %q: %w", line, err)
+	}
+	output, err := sh.readPrompt(timeout)
+	if err != nil {
+		return "", fmt.Errorf("%q: %w", line, err)
+	}
+
+	// Devices echo the command even with ECHO disabled
+	if first, rest, found := strings.Cut(output, "\n"); found && strings.TrimSpace(first) == line {
+		output = rest
+	} else if !found && strings.TrimSpace(first) == line {
+		output = ""
+	}
+	return strings.TrimSpace(output), nil
+}
+
+// readPrompt reads until the output ends in a prompt and returns it without the prompt line
+func (sh *shell) readPrompt(timeout time.Duration) (string, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	prompt := sh.prompt
+	if prompt == nil {
+		prompt = promptRe
+	}
+
+	for {
+		normalized := strings.ReplaceAll(strings.ReplaceAll(sh.pending.String(), "\r\n", "\n"), "\r", "")
+		if loc := prompt.FindStringIndex(normalized); loc != nil {
+			sh.pending.Reset()
+			sh.lastPrompt = strings.TrimPrefix(normalized[loc[0]:], "\n")
+			return normalized[:loc[0]], nil
+		}
+
+		select {
+		case chunk, ok := <-sh.chunks:
+			if !ok {
+				return "", fmt.Errorf("session closed by device")
+			}
+			sh.pending.Write(chunk)
+		case <-deadline.C:
+			return "", fmt.Errorf("no prompt after %v", timeout)
+		}
+	}
+}
+
+// close ends the session; the reader goroutine exits once the session is gone
+func (sh *shell) close() error {
+	if sh.session == nil {
+		return nil
+	}
+	err := sh.session.Close()
+	go func() {
+		for range sh.chunks {
+		}
+	}()
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,271 @@
+package netssh
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// ErrTxClosed is returned when a committed or rolled back transaction is used again
+var ErrTxClosed = errors.New("transaction already finished")
+
+// TxParams are the per-transaction values a Dialect builds its commands from
+type TxParams struct {
+	Checkpoint     string // name unique to the transaction, for platforms with named checkpoints
+	ConfirmMinutes int    // minutes until an unconfirmed commit is rolled back by the device
+}
+
+// Dialect describes how a platform stages, commits and rolls back configuration.
+// Every commit is a confirmed commit: the device reverts it by itself unless it is
+// confirmed in time, so a change that cuts off the session undoes itself.
+type Dialect struct {
+	Name string
+
+	// Begin prepares the session (paging off) and enters configuration mode
+	Begin func(TxParams) []string
+	// CommitConfirmed activates the staged changes and starts the rollback timer
+	CommitConfirmed func(TxParams) []string
+	// Confirm makes an activated commit permanent and leaves configuration mode
+	Confirm func(TxParams) []string
+	// Discard drops staged changes that were never activated
+	Discard func(TxParams) []string
+	// Revert undoes an activated but unconfirmed commit
+	Revert func(TxParams) []string
+
+	// Errors matches output reporting that the device rejected a command
+	Errors *regexp.Regexp
+}
+
+// JunOS stages changes in a private candidate configuration (discarded if the session
+// drops) and activates them with "commit confirmed"
+var JunOS = Dialect{
+	Name: "junos",
+	Begin: func(TxParams) []string {
+		return []string{"set cli screen-length 0", "configure private"}
+	},
+	CommitConfirmed: func(p TxParams) []string {
+		return []string{fmt.Sprintf("commit confirmed %d", p.ConfirmMinutes)}
+	},
+	Confirm: func(TxParams) []string {
+		return []string{"commit", "exit configuration-mode"}
+	},
+	Discard: func(TxParams) []string {
+		return []string{"rollback 0", "exit configuration-mode"}
+	},
+	Revert: func(TxParams) []string {
+		return []string{"rollback 1", "commit", "exit configuration-mode"}
+	},
+	Errors: regexp.MustCompile(`(?m)^\s*(error:|syntax error|unknown command|missing argument)`),
+}
+
+// AOSCX applies changes to the running configuration immediately, so Begin saves a named
+// checkpoint and arms an auto-checkpoint timer (checkpoint auto), which reverts unless
+// confirmed; the timer therefore runs from Begin rather than from Commit
+var AOSCX = Dialect{
+	Name: "aos-cx",
+	Begin: func(p TxParams) []string {
+		return []string{
+			"no page",
+			"copy running-config checkpoint " + p.Checkpoint,
+			fmt.Sprintf("checkpoint auto %d", p.ConfirmMinutes),
+			"configure terminal",
+		}
+	},
+	CommitConfirmed: func(TxParams) []string {
+		return []string{"end"}
+	},
+	Confirm: func(TxParams) []string {
+		return []string{"checkpoint auto confirm"}
+	},
+	Discard: func(p TxParams) []string {
+		return []string{"end", "checkpoint rollback " + p.Checkpoint}
+	},
+	Revert: func(p TxParams) []string {
+		return []string{"checkpoint rollback " + p.Checkpoint}
+	},
+	Errors: regexp.MustCompile(`(?m)^\s*(% |Invalid input|Unknown command|Incomplete command|Error)`),
+}
+
+// TxOptions configures a transaction
+type TxOptions struct {
+	// ConfirmTimeout is how long the device waits for confirmation before reverting
+	// a commit (rounded up to whole minutes; default 5 minutes)
+	ConfirmTimeout time.Duration
+	// CommandTimeout bounds each command (default 30s)
+	CommandTimeout time.Duration
+	// Check runs after the changes are activated and before they are confirmed, e.g.
+	// to verify that routing neighbors came back; an error reverts the commit
+	Check func(tx *Tx) error
+}
+
+// txState tracks where a transaction is in its lifecycle
+type txState int
+
+const (
+	txOpen      txState = iota // staging changes
+	txActivated                // committed with a rollback timer running
+	txDone                     // confirmed or rolled back
+)
+
+// Tx is a configuration transaction on a commit-based device:
+//
+//	tx, err := client.Begin(netssh.JunOS, netssh.TxOptions{ConfirmTimeout: 2 * time.Minute})
+//	if err != nil { ... }
+//	defer tx.Close() // rolls back unless committed
+//	if err := tx.Apply("set system ntp server 192.0.2.1"); err != nil { return err }
+//	return tx.Commit()
+//
+// Commit activates the changes with a confirmed commit, runs TxOptions.Check, then
+// confirms over the same session. If the change cuts off management access, the
+// confirmation never reaches the device and it rolls back when the timer expires.
+type Tx struct {
+	shell   *shell
+	dialect Dialect
+	params  TxParams
+	opts    TxOptions
+	state   txState
+}
+
+// Begin opens an interactive session and enters configuration mode using dialect
+func (c *Client) Begin(dialect Dialect, opts TxOptions) (*Tx, error) {
+	if opts.ConfirmTimeout <= 0 {
+		opts.ConfirmTimeout = 5 * time.Minute
+	}
+	if opts.CommandTimeout <= 0 {
+		opts.CommandTimeout = 30 * time.Second
+	}
+
+	sh, err := c.openShell(opts.CommandTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return beginTx(sh, dialect, opts)
+}
+
+// beginTx starts a transaction on an open shell, closing it if the dialect's Begin
+// commands fail; opts must have its defaults applied
+func beginTx(sh *shell, dialect Dialect, opts TxOptions) (*Tx, error) {
+	checkpoint, err := checkpointName(time.Now())
+	if err != nil {
+		sh.close()
+		return nil, err
+	}
+
+	tx := &Tx{
+		shell:   sh,
+		dialect: dialect,
+		opts:    opts,
+		params: TxParams{
+			Checkpoint:     checkpoint,
+			ConfirmMinutes: int((opts.ConfirmTimeout + time.Minute - 1) / time.Minute),
+		},
+	}
+	if err := tx.run(dialect.Begin(tx.params)); err != nil {
+		sh.close()
+		return nil, fmt.Errorf("failed to begin %s transaction: %w", dialect.Name, err)
+	}
+	return tx, nil
+}
+
+// checkpointName names a transaction's checkpoint. The random suffix keeps two
+// transactions started in the same second, from this host or another, from
+// overwriting each other's checkpoint.
+func checkpointName(now time.Time) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate checkpoint name: %w", err)
+	}
+	return "fdot-" + now.UTC().Format("20060102-150405") + "-" + hex.EncodeToString(suffix), nil
+}
+
+// Apply stages configuration lines, stopping at the first line the device rejects.
+// Staged lines stay in place after an error; call Rollback to drop them.
+func (tx *Tx) Apply(lines ...string) error {
+	if tx.state != txOpen {
+		return ErrTxClosed
+	}
+	return tx.run(lines)
+}
+
+// Exec runs a command inside the transaction and returns its output, e.g. a show
+// command from TxOptions.Check. Device errors are reported in the output, not as error.
+func (tx *Tx) Exec(cmd string) (string, error) {
+	if tx.state == txDone {
+		return "", ErrTxClosed
+	}
+	return tx.shell.send(cmd, tx.opts.CommandTimeout)
+}
+
+// Commit activates the staged changes, runs the check and confirms them. When the
+// check fails the commit is reverted and the check's error returned. If confirming
+// fails, the device reverts the changes after ConfirmTimeout by itself.
+func (tx *Tx) Commit() error {
+	if tx.state != txOpen {
+		return ErrTxClosed
+	}
+
+	if err := tx.run(tx.dialect.CommitConfirmed(tx.params)); err != nil {
+		rbErr := tx.Rollback()
+		return errors.Join(fmt.Errorf("commit rejected: %w", err), rbErr)
+	}
+	tx.state = txActivated
+
+	if tx.opts.Check != nil {
+		if err := tx.opts.Check(tx); err != nil {
+			rbErr := tx.Rollback()
+			return errors.Join(fmt.Errorf("post-commit check failed, changes reverted: %w", err), rbErr)
+		}
+	}
+
+	if err := tx.run(tx.dialect.Confirm(tx.params)); err != nil {
+		return fmt.Errorf("failed to confirm commit; the device reverts it within %d minute(s): %w", tx.params.ConfirmMinutes, err)
+	}
+	tx.state = txDone
+	return nil
+}
+
+// Rollback drops staged changes, or reverts activated but unconfirmed ones
+func (tx *Tx) Rollback() error {
+	var commands []string
+	switch tx.state {
+	case txOpen:
+		commands = tx.dialect.Discard(tx.params)
+	case txActivated:
+		commands = tx.dialect.Revert(tx.params)
+	default:
+		return ErrTxClosed
+	}
+
+	tx.state = txDone
+	if err := tx.run(commands); err != nil {
+		return fmt.Errorf("rollback failed: %w", err)
+	}
+	return nil
+}
+
+// Close rolls back an unfinished transaction and ends the session. Changes that were
+// activated but not confirmed are also reverted by the device's own timer.
+func (tx *Tx) Close() error {
+	var rbErr error
+	if tx.state != txDone {
+		rbErr = tx.Rollback()
+	}
+	return errors.Join(rbErr, tx.shell.close())
+}
+
+// run sends commands in order, failing on the first one whose output matches the dialect's errors
+func (tx *Tx) run(commands []string) error {
+	for _, cmd := range commands {
+		output, err := tx.shell.send(cmd, tx.opts.CommandTimeout)
+		if err != nil {
+			return err
+		}
+		if tx.dialect.Errors != nil && tx.dialect.Errors.MatchString(output) {
+			return fmt.Errorf("device rejected %q: %s", cmd, output)
+		}
+	}
+	return nil
+}
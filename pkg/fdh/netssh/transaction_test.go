@@ -0,0 +1,264 @@
+package netssh
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDevice plays a CLI over pipes: it echoes each command, prints the reply set
+// for it and a prompt that shows whether it is in configuration mode
+type fakeDevice struct {
+	replies map[string]string // output per command; none when missing
+
+	mu       sync.Mutex
+	commands []string // commands received after the first prompt
+}
+
+// startFakeDevice connects a shell to a fake device and waits for its first prompt
+func startFakeDevice(t *testing.T, replies map[string]string) (*fakeDevice, *shell) {
+	t.Helper()
+	toDevice, deviceIn := io.Pipe()
+	deviceOut, fromDevice := io.Pipe()
+	t.Cleanup(func() {
+		deviceIn.Close()
+		deviceOut.Close()
+	})
+
+	d := &fakeDevice{replies: replies}
+	go d.serve(toDevice, fromDevice)
+
+	sh := newShell(deviceOut, deviceIn)
+	if err := sh.start(time.Second); err != nil {
+		t.Fatalf("shell start failed: %v", err)
+	}
+	d.mu.Lock()
+	d.commands = nil // drop the empty line start sends
+	d.mu.Unlock()
+	return d, sh
+}
+
+func (d *fakeDevice) serve(in io.Reader, out io.WriteCloser) {
+	defer out.Close()
+	prompt := "sw1# "
+	fmt.Fprint(out, "Last login: Mon Oct 12 09:00:00 2026\r\n"+prompt)
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		cmd := scanner.Text()
+		d.mu.Lock()
+		d.commands = append(d.commands, cmd)
+		d.mu.Unlock()
+
+		switch cmd {
+		case "configure private", "configure terminal":
+			prompt = "sw1(config)# "
+		case "exit configuration-mode", "end":
+			prompt = "sw1# "
+		}
+		reply := cmd + "\r\n"
+		if r, ok := d.replies[cmd]; ok {
+			reply += r + "\r\n"
+		}
+		if _, err := fmt.Fprint(out, reply+prompt); err != nil {
+			return
+		}
+	}
+}
+
+// received returns the commands sent to the device so far
+func (d *fakeDevice) received() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.commands)
+}
+
+var testTxOptions = TxOptions{ConfirmTimeout: 2 * time.Minute, CommandTimeout: time.Second}
+
+func TestTxBegin(t *testing.T) {
+	d, sh := startFakeDevice(t, nil)
+	tx, err := beginTx(sh, JunOS, testTxOptions)
+	if err != nil {
+		t.Fatalf("beginTx failed: %v", err)
+	}
+	want := []string{"set cli screen-length 0", "configure private"}
+	if got := d.received(); !slices.Equal(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+	if tx.params.ConfirmMinutes != 2 {
+		t.Errorf("ConfirmMinutes = %d, want 2", tx.params.ConfirmMinutes)
+	}
+}
+
+func TestTxBeginRejected(t *testing.T) {
+	_, sh := startFakeDevice(t, map[string]string{
+		"configure private": "error: configuration database locked by: admin",
+	})
+	if _, err := beginTx(sh, JunOS, testTxOptions); err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Fatalf("beginTx error = %v, want the device's rejection", err)
+	}
+}
+
+func TestTxCommit(t *testing.T) {
+	d, sh := startFakeDevice(t, nil)
+	tx, err := beginTx(sh, JunOS, testTxOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Apply("set system ntp server 192.0.2.1"); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	want := []string{
+		"set cli screen-length 0", "configure private",
+		"set system ntp server 192.0.2.1",
+		"commit confirmed 2", "commit", "exit configuration-mode",
+	}
+	if got := d.received(); !slices.Equal(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+
+	// Closing a committed transaction sends nothing and leaves it closed
+	if err := tx.Close(); err != nil {
+		t.Errorf("Close after Commit failed: %v", err)
+	}
+	if got := d.received(); !slices.Equal(got, want) {
+		t.Errorf("Close after Commit sent %q", got[len(want):])
+	}
+	if err := tx.Apply("set system host-name sw2"); !errors.Is(err, ErrTxClosed) {
+		t.Errorf("Apply after Commit error = %v, want ErrTxClosed", err)
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrTxClosed) {
+		t.Errorf("second Commit error = %v, want ErrTxClosed", err)
+	}
+}
+
+func TestTxApplyError(t *testing.T) {
+	d, sh := startFakeDevice(t, map[string]string{
+		"set interfaces bogus": "error: syntax error: bogus",
+	})
+	tx, err := beginTx(sh, JunOS, testTxOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tx.Apply("set system host-name sw2", "set interfaces bogus", "set system ntp server 192.0.2.1")
+	if err == nil || !strings.Contains(err.Error(), "set interfaces bogus") {
+		t.Fatalf("Apply error = %v, want the rejected command", err)
+	}
+
+	// The lines after the rejected one are not sent, and Close discards the rest
+	if err := tx.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	want := []string{
+		"set cli screen-length 0", "configure private",
+		"set system host-name sw2", "set interfaces bogus",
+		"rollback 0", "exit configuration-mode",
+	}
+	if got := d.received(); !slices.Equal(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+}
+
+func TestTxCommitRejected(t *testing.T) {
+	d, sh := startFakeDevice(t, map[string]string{
+		"commit confirmed 2": "error: commit failed: (missing statements)",
+	})
+	tx, err := beginTx(sh, JunOS, testTxOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err == nil || !strings.Contains(err.Error(), "commit rejected") {
+		t.Fatalf("Commit error = %v, want commit rejected", err)
+	}
+	want := []string{"set cli screen-length 0", "configure private", "commit confirmed 2", "rollback 0", "exit configuration-mode"}
+	if got := d.received(); !slices.Equal(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+}
+
+func TestTxCheckFailureReverts(t *testing.T) {
+	d, sh := startFakeDevice(t, map[string]string{
+		"show bgp summary": "Peer 192.0.2.2 AS 65001 State Active",
+	})
+	checkErr := errors.New("bgp peer 192.0.2.2 not established")
+	opts := testTxOptions
+	opts.Check = func(tx *Tx) error {
+		output, err := tx.Exec("show bgp summary")
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(output, "Established") {
+			return checkErr
+		}
+		return nil
+	}
+
+	tx, err := beginTx(sh, JunOS, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Apply("set protocols bgp group core neighbor 192.0.2.2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); !errors.Is(err, checkErr) {
+		t.Fatalf("Commit error = %v, want the check's error", err)
+	}
+	want := []string{
+		"set cli screen-length 0", "configure private",
+		"set protocols bgp group core neighbor 192.0.2.2",
+		"commit confirmed 2", "show bgp summary",
+		"rollback 1", "commit", "exit configuration-mode",
+	}
+	if got := d.received(); !slices.Equal(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+
+	// The revert finished the transaction; Close does not roll back again
+	if err := tx.Close(); err != nil {
+		t.Errorf("Close after revert failed: %v", err)
+	}
+	if got := d.received(); len(got) != len(want) {
+		t.Errorf("Close after revert sent %q", got[len(want):])
+	}
+}
+
+func TestTxCheckpointNames(t *testing.T) {
+	d, sh := startFakeDevice(t, nil)
+	first, err := beginTx(sh, AOSCX, testTxOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Rollback(); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	second, err := beginTx(sh, AOSCX, testTxOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nameRe := regexp.MustCompile(`^fdot-\d{8}-\d{6}-[0-9a-f]{8}$`)
+	for _, name := range []string{first.params.Checkpoint, second.params.Checkpoint} {
+		if !nameRe.MatchString(name) {
+			t.Errorf("checkpoint name %q does not match %v", name, nameRe)
+		}
+	}
+	if first.params.Checkpoint == second.params.Checkpoint {
+		t.Errorf("transactions started together share checkpoint %q", first.params.Checkpoint)
+	}
+
+	got := d.received()
+	if !slices.Contains(got, "copy running-config checkpoint "+first.params.Checkpoint) ||
+		!slices.Contains(got, "checkpoint rollback "+first.params.Checkpoint) {
+		t.Errorf("commands = %q, want the first checkpoint saved and rolled back", got)
+	}
+}
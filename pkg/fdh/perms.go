@@ -0,0 +1,119 @@
+package fdh
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// PrivateFileMode is the Unix mode for files readable only by the owner
+	PrivateFileMode os.FileMode = 0600
+	// PrivateDirMode is the Unix mode for directories accessible only by the owner
+	PrivateDirMode os.FileMode = 0700
+)
+
+// RestrictToOwner limits access to path (file or directory) to the current user.
+// Unix: chmod 0600 (files) or 0700 (directories).
+// Windows: replaces the inherited DACL with a protected DACL granting only the current user.
+func RestrictToOwner(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := restrictToOwner(path, info.IsDir()); err != nil {
+		return fmt.Errorf("failed to restrict permissions on %s: %w", path, err)
+	}
+	return nil
+}
+
+// WritePrivateFile writes data to path so that only the current user can access it.
+// Permissions are applied before any data is written, including when path already exists.
+func WritePrivateFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, PrivateFileMode)
+	if err != nil {
+		return err
+	}
+
+	if err := restrictToOwner(path, false); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to restrict permissions on %s: %w", path, err)
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// AppendPrivateFile appends data to path, creating it if needed, so that only the
+// current user can access it (used for append-only logs).
+func AppendPrivateFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, PrivateFileMode)
+	if err != nil {
+		return err
+	}
+
+	if err := restrictToOwner(path, false); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to restrict permissions on %s: %w", path, err)
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WritePrivateFileAtomic replaces path with data so that readers see either the
+// old or the new contents, never a partial write. Data goes to a private temp
+// file in the same directory, is fsynced, then renamed over path.
+func WritePrivateFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op once renamed
+
+	if err := restrictToOwner(tmp, false); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to restrict permissions on %s: %w", tmp, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// CreatePrivateDir creates dir (and any missing parents) restricted to the current user.
+// Existing directories are left untouched; returns ErrNotDir if dir exists but is not a directory.
+func CreatePrivateDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err == nil {
+		if !info.IsDir() {
+			return ErrNotDir
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, PrivateDirMode); err != nil {
+		return err
+	}
+	return RestrictToOwner(dir)
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package fdh
+
+import "os"
+
+// restrictToOwner applies owner-only Unix permissions
+func restrictToOwner(path string, isDir bool) error {
+	mode := PrivateFileMode
+	if isDir {
+		mode = PrivateDirMode
+	}
+	return os.Chmod(path, mode)
+}
+
+// syncDir flushes directory metadata so a completed rename survives a crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
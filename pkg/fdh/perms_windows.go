@@ -0,0 +1,47 @@
+//go:build windows
+
+package fdh
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// restrictToOwner replaces the DACL on path with one granting full control to the
+// current user only, and blocks inheritance of ACEs from the parent directory
+func restrictToOwner(path string, isDir bool) error {
+	user, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return fmt.Errorf("failed to get current user: %w", err)
+	}
+
+	inheritance := uint32(windows.NO_INHERITANCE)
+	if isDir {
+		inheritance = windows.SUB_CONTAINERS_AND_OBJECTS_INHERIT
+	}
+
+	acl, err := windows.ACLFromEntries([]windows.EXPLICIT_ACCESS{{
+		AccessPermissions: windows.GENERIC_ALL,
+		AccessMode:        windows.SET_ACCESS,
+		Inheritance:       inheritance,
+		Trustee: windows.TRUSTEE{
+			TrusteeForm:  windows.TRUSTEE_IS_SID,
+			TrusteeType:  windows.TRUSTEE_IS_USER,
+			TrusteeValue: windows.TrusteeValueFromSID(user.User.Sid),
+		},
+	}}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build ACL: %w", err)
+	}
+
+	return windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT,
+		windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION,
+		nil, nil, acl, nil)
+}
+
+// syncDir is a no-op on Windows: directories cannot be opened for fsync and
+// MoveFileEx already makes the rename durable on NTFS
+func syncDir(dir string) error {
+	return nil
+}
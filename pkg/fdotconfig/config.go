@@ -6,11 +6,23 @@ import (
 )
 
 const (
-	FDOTDir           = ".fdot"
-	BigKeySecretName  = "fdh-user-bigkey"
-	SSHCredSecretName = "fdh-user-ssh-creds"
-	CredMgrEnvVarKey  = "CREDMGR_KEY" // linux only
-	CredMgrEnvVarPath = "CREDMGR_DIR" // linux only
+	FDOTDir            = ".fdot"
+	BigKeySecretName   = "fdh-user-bigkey"
+	BigKeyLength       = 256 // hex characters (128 random bytes)
+	SSHCredSecretName  = "fdh-user-ssh-creds"
+	SyncCredSecretName = "fdh-user-sync-creds" // SSH login for credmgr sync, kept apart from device credentials
+	CredMgrEnvVarKey   = "CREDMGR_KEY"         // linux only
+	CredMgrEnvVarPath  = "CREDMGR_DIR"         // linux only
+
+	CredMgrEnvVarKeyFile = "CREDMGR_KEYFILE" // file holding the key, instead of CREDMGR_KEY
+
+	CredMgrEnvVarFIDO2Device = "CREDMGR_FIDO2_DEVICE" // optional FIDO2 device path
+
+	CredMgrEnvVarAgentSock = "CREDMGR_AGENT_SOCK" // socket of a running credmgr agent
+
+	CredMgrEnvVarAgentFramedSock = "CREDMGR_AGENT_FRAMED_SOCK" // framed protocol socket of a running agent, for scripts
+
+	CredMgrEnvVarServeToken = "CREDMGR_SERVE_TOKEN" // bearer token for credmgr serve
 )
 
 // PathProvider defines an interface for providing credential file paths.
@@ -28,7 +40,8 @@ func SetPathProvider(provider PathProvider) {
 }
 
 // GetCredFilePath returns the credential file path using the registered provider
-// or falls back to a basic implementation if no provider is set
+// or falls back to a basic implementation if no provider is set. The provider
+// returns "" when credentials are kept in the platform's native store.
 func GetCredFilePath() (string, error) {
 	if defaultPathProvider != nil {
 		return defaultPathProvider.CredFilePath(), nil
@@ -0,0 +1,89 @@
+package fdotconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nzions/fdot/pkg/fdh"
+)
+
+const (
+	// ConfigFile is the name of the configuration file in the data directory
+	ConfigFile = "config.json"
+
+	// EnvVarConfig overrides the configuration file location
+	EnvVarConfig = "FDOT_CONFIG"
+)
+
+// Config is the persistent fdot configuration written by credmgr init.
+// Environment variables (CREDMGR_KEY, CREDMGR_KEYFILE) take precedence over it.
+type Config struct {
+	// CredFile is the credential database shared by credmgr and the fdot tools
+	CredFile string `json:"cred_file,omitempty"`
+	// KeyFile holds the master key of CredFile, used when CREDMGR_KEYFILE is not set
+	KeyFile string `json:"key_file,omitempty"`
+	// Profiles name other credential databases, selected with credmgr --profile
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+}
+
+// Profile is a named credential database in Config.Profiles
+type Profile struct {
+	CredFile string `json:"cred_file"`
+	// KeyFile holds the master key of CredFile; when empty the usual key sources apply
+	KeyFile string `json:"key_file,omitempty"`
+}
+
+// ConfigPath returns the configuration file location: $FDOT_CONFIG, or ~/.fdot/config.json
+func ConfigPath() (string, error) {
+	if path := os.Getenv(EnvVarConfig); path != "" {
+		return path, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, FDOTDir, ConfigFile), nil
+}
+
+// LoadConfig reads the configuration file; a missing file yields an empty Config
+func LoadConfig() (Config, error) {
+	var c Config
+
+	path, err := ConfigPath()
+	if err != nil {
+		return c, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// SaveConfig writes c to the configuration file, readable only by the current user
+func SaveConfig(c Config) error {
+	path, err := ConfigPath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+
+	if err := fdh.CreatePrivateDir(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := fdh.WritePrivateFileAtomic(path, append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}